@@ -7,11 +7,13 @@ import (
 	"os"
 	"os/signal"
 	"path/filepath"
+	"strings"
 	"syscall"
 	"time"
 
 	"github.com/probe-system/agent/internal/collector"
 	"github.com/probe-system/agent/internal/executor"
+	"github.com/probe-system/agent/internal/plugin"
 	"github.com/probe-system/agent/internal/ws"
 	"github.com/probe-system/agent/pkg/protocol"
 )
@@ -19,9 +21,35 @@ import (
 const Version = "1.0.0"
 
 type Config struct {
-	ServerURL      string `json:"server_url"`
-	Token          string `json:"token"`
-	MetricInterval int    `json:"metric_interval"`
+	ServerURL      string   `json:"server_url"`
+	Token          string   `json:"token"`
+	MetricInterval int      `json:"metric_interval"`
+	NetInclude     []string `json:"net_include"`
+	NetExclude     []string `json:"net_exclude"`
+
+	DiskExcludeFSTypes []string `json:"disk_exclude_fstypes"`
+	DiskExcludePaths   []string `json:"disk_exclude_paths"`
+	DiskIOExclude      []string `json:"disk_io_exclude"`
+
+	// TopProcesses is how many processes by CPU and by memory are sent with
+	// each sample; 0 disables it.
+	TopProcesses int `json:"top_processes"`
+
+	Containers   bool   `json:"containers"`
+	DockerSocket string `json:"docker_socket"`
+
+	// Collectors enables or disables collectors by name, e.g. "sockets".
+	Collectors map[string]bool `json:"collectors"`
+
+	Plugins []plugin.Config `json:"plugins"`
+
+	// RemoteCommands lets admins run shell commands on this agent from
+	// core. Predefined scripts can always be run.
+	RemoteCommands bool `json:"remote_commands"`
+
+	// Terminal lets admins open an interactive shell on this agent from
+	// core. Like RemoteCommands, it is off unless enabled.
+	Terminal bool `json:"terminal"`
 }
 
 func main() {
@@ -34,6 +62,9 @@ func main() {
 	// Load config
 	config := &Config{
 		MetricInterval: 10,
+		TopProcesses:   5,
+		Containers:     true,
+		DockerSocket:   collector.DefaultDockerSocket,
 	}
 
 	if data, err := os.ReadFile(*configPath); err == nil {
@@ -61,12 +92,50 @@ func main() {
 
 	// Create components
 	client := ws.NewClient(config.ServerURL, config.Token, Version)
-	coll := collector.NewCollector(time.Duration(config.MetricInterval) * time.Second)
+	coll := collector.NewRegistry(time.Duration(config.MetricInterval) * time.Second)
+	// A missing net_exclude keeps the defaults; an empty list disables them.
+	netFilter := collector.InterfaceFilter{Include: config.NetInclude, Exclude: config.NetExclude}
+	if netFilter.Exclude == nil {
+		netFilter.Exclude = collector.DefaultInterfaceExclude
+	}
+	coll.SetInterfaceFilter(netFilter)
+
+	diskFilter := collector.DiskFilter{
+		ExcludeFSTypes: collector.DefaultDiskExcludeFSTypes,
+		ExcludePaths:   collector.DefaultDiskExcludePaths,
+		ExcludeDevices: collector.DefaultDiskIOExclude,
+	}
+	if config.DiskExcludeFSTypes != nil {
+		diskFilter.ExcludeFSTypes = config.DiskExcludeFSTypes
+	}
+	if config.DiskExcludePaths != nil {
+		diskFilter.ExcludePaths = config.DiskExcludePaths
+	}
+	if config.DiskIOExclude != nil {
+		diskFilter.ExcludeDevices = config.DiskIOExclude
+	}
+	coll.SetDiskFilter(diskFilter)
+	coll.SetTopProcesses(config.TopProcesses)
+	coll.SetContainers(config.Containers, config.DockerSocket)
+	for name, enabled := range config.Collectors {
+		if err := coll.SetEnabled(name, enabled); err != nil {
+			log.Printf("Ignoring collector setting: %v (known: %s)", err, strings.Join(coll.Names(), ", "))
+		}
+	}
 
 	// Get script directory
 	execPath, _ := os.Executable()
 	scriptDir := filepath.Join(filepath.Dir(execPath), "scripts")
 	taskMgr := executor.NewTaskManager(config.ServerURL, scriptDir)
+	commands := executor.NewCommandRunner(
+		executor.NewScriptExecutor(config.ServerURL, scriptDir, 0),
+		config.RemoteCommands, client.SendCommandOutput, client.SendCommandExit,
+	)
+	terminals := executor.NewTerminalManager(config.Terminal, client.SendTerminalOutput, client.SendTerminalExit)
+	// Core ends the sessions of agents that disconnect.
+	client.SetDisconnectHandler(func() {
+		terminals.CloseAll("connection to core lost")
+	})
 
 	// Handle incoming messages
 	client.SetMessageHandler(func(msg *protocol.Message) {
@@ -78,7 +147,104 @@ func main() {
 				return
 			}
 			log.Printf("Received task: %s (%s)", payload.TaskID, payload.Type)
-			taskMgr.HandleTask(&payload)
+			ack := protocol.TaskAckPayload{TaskID: payload.TaskID, Success: true}
+			if err := taskMgr.HandleTask(&payload); err != nil {
+				log.Printf("Rejected task %s: %v", payload.TaskID, err)
+				ack.Success, ack.Error = false, err.Error()
+			}
+			if err := client.SendTaskAck(&ack); err != nil {
+				log.Printf("Failed to acknowledge task %s: %v", payload.TaskID, err)
+			}
+
+		case protocol.MsgTypeTaskCancel:
+			var payload protocol.TaskCancelPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				log.Printf("Invalid task cancel payload: %v", err)
+				return
+			}
+			if taskMgr.CancelTask(payload.TaskID) {
+				log.Printf("Canceled task: %s", payload.TaskID)
+			}
+
+		case protocol.MsgTypeCommandRun:
+			var payload protocol.CommandRunPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				log.Printf("Invalid command payload: %v", err)
+				return
+			}
+			log.Printf("Running command run %s", payload.RunID)
+			commands.Run(&payload)
+
+		case protocol.MsgTypeCommandCancel:
+			var payload protocol.CommandCancelPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				log.Printf("Invalid command cancel payload: %v", err)
+				return
+			}
+			if commands.Cancel(payload.RunID) {
+				log.Printf("Canceled command run %s", payload.RunID)
+			}
+
+		case protocol.MsgTypeTerminalOpen:
+			var payload protocol.TerminalOpenPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				log.Printf("Invalid terminal payload: %v", err)
+				return
+			}
+			log.Printf("Opening terminal %s", payload.SessionID)
+			terminals.Open(&payload)
+
+		case protocol.MsgTypeTerminalInput:
+			var payload protocol.TerminalDataPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				log.Printf("Invalid terminal input: %v", err)
+				return
+			}
+			terminals.Input(&payload)
+
+		case protocol.MsgTypeTerminalResize:
+			var payload protocol.TerminalResizePayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				log.Printf("Invalid terminal resize: %v", err)
+				return
+			}
+			terminals.Resize(&payload)
+
+		case protocol.MsgTypeTerminalClose:
+			var payload protocol.TerminalClosePayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				log.Printf("Invalid terminal close payload: %v", err)
+				return
+			}
+			if terminals.Close(payload.SessionID, "closed by core") {
+				log.Printf("Closed terminal %s", payload.SessionID)
+			}
+
+		case protocol.MsgTypeProcessesRequest:
+			var payload protocol.ProcessesRequestPayload
+			json.Unmarshal(msg.Payload, &payload)
+			if payload.Limit <= 0 {
+				payload.Limit = 5
+			}
+			go func() {
+				top, err := coll.TopProcesses(payload.Limit)
+				if err != nil {
+					log.Printf("Failed to collect processes: %v", err)
+					return
+				}
+				if err := client.SendProcesses(top); err != nil {
+					log.Printf("Failed to send processes: %v", err)
+				}
+			}()
+
+		case protocol.MsgTypeWatchConfig:
+			var payload protocol.WatchConfigPayload
+			if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+				log.Printf("Invalid watch config: %v", err)
+				return
+			}
+			log.Printf("Received %d watches", len(payload.Watches))
+			coll.SetWatches(payload.Watches)
 
 		case protocol.MsgTypeConfig:
 			// Handle config updates
@@ -99,10 +265,11 @@ func main() {
 		defer ticker.Stop()
 
 		for range ticker.C {
-			metrics, err := coll.Collect()
-			if err != nil {
-				log.Printf("Failed to collect metrics: %v", err)
-				continue
+			metrics := coll.Collect()
+			for _, status := range metrics.Collectors {
+				if status.Error != "" {
+					log.Printf("Collector %s failed: %s", status.Name, status.Error)
+				}
 			}
 
 			if err := client.SendMetrics(metrics); err != nil {
@@ -111,6 +278,10 @@ func main() {
 		}
 	}()
 
+	// Run metric plugins
+	plugins := plugin.NewRunner(config.Plugins, client.SendCustomMetrics)
+	plugins.Start()
+
 	// Forward task results
 	go func() {
 		for result := range taskMgr.GetResultChan() {
@@ -126,6 +297,7 @@ func main() {
 	<-quit
 
 	log.Println("Shutting down...")
+	plugins.Stop()
 	taskMgr.Stop()
 	client.Stop()
 }
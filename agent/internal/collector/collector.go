@@ -1,97 +1,189 @@
 package collector
 
 import (
+	"fmt"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/probe-system/agent/pkg/protocol"
-	"github.com/shirou/gopsutil/v3/cpu"
-	"github.com/shirou/gopsutil/v3/disk"
-	"github.com/shirou/gopsutil/v3/mem"
-	"github.com/shirou/gopsutil/v3/net"
 )
 
-type Collector struct {
-	interval         time.Duration
-	lastNetStats     *net.IOCountersStat
-	lastNetStatsTime time.Time
+// Collector gathers one family of metrics into the payload. Collectors run
+// in registration order, so one may read what an earlier one filled in.
+type Collector interface {
+	Name() string
+	Collect(metrics *protocol.MetricsPayload) error
 }
 
-func NewCollector(interval time.Duration) *Collector {
-	return &Collector{
-		interval: interval,
+// Registry runs the registered collectors on every cycle and reports how
+// each of them did.
+type Registry struct {
+	interval   time.Duration
+	collectors []Collector
+
+	mu       sync.RWMutex
+	disabled map[string]bool
+
+	// Built-in collectors that take settings after construction.
+	disk       *diskCollector
+	diskIO     *diskIOCollector
+	network    *networkCollector
+	containers *containerCollector
+	watches    *watchCollector
+	processes  *processCollector
+}
+
+// NewRegistry returns a registry with the built-in collectors. Containers
+// are disabled until SetContainers enables them.
+func NewRegistry(interval time.Duration) *Registry {
+	diskFilter := DiskFilter{
+		ExcludeFSTypes: DefaultDiskExcludeFSTypes,
+		ExcludePaths:   DefaultDiskExcludePaths,
+		ExcludeDevices: DefaultDiskIOExclude,
 	}
+	r := &Registry{
+		interval:   interval,
+		disabled:   map[string]bool{},
+		disk:       &diskCollector{filter: diskFilter},
+		diskIO:     newDiskIOCollector(diskFilter),
+		network:    newNetworkCollector(InterfaceFilter{Exclude: DefaultInterfaceExclude}),
+		containers: newContainerCollector(""),
+		watches:    &watchCollector{},
+		processes:  &processCollector{},
+	}
+
+	r.Register(newCPUCollector())
+	r.Register(loadCollector{})
+	r.Register(memoryCollector{})
+	r.Register(r.disk)
+	r.Register(r.diskIO)
+	r.Register(r.network)
+	r.Register(hostCollector{})
+	r.Register(socketCollector{})
+	// Containers read the host memory filled in by the memory collector.
+	r.Register(r.containers)
+	r.Register(r.watches)
+	r.Register(r.processes)
+
+	r.disabled[r.containers.Name()] = true
+	return r
 }
 
-func (c *Collector) Collect() (*protocol.MetricsPayload, error) {
-	metrics := &protocol.MetricsPayload{}
+// Register adds a collector to run after those already registered.
+func (r *Registry) Register(c Collector) {
+	r.collectors = append(r.collectors, c)
+}
 
-	// CPU
-	cpuPercent, err := cpu.Percent(time.Second, false)
-	if err == nil && len(cpuPercent) > 0 {
-		metrics.CPU = cpuPercent[0]
+// Names returns the names of the registered collectors.
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.collectors))
+	for i, c := range r.collectors {
+		names[i] = c.Name()
 	}
+	sort.Strings(names)
+	return names
+}
 
-	// Memory
-	memInfo, err := mem.VirtualMemory()
-	if err == nil {
-		metrics.Memory = protocol.MemoryStats{
-			Total:     memInfo.Total,
-			Used:      memInfo.Used,
-			Available: memInfo.Available,
-			Percent:   memInfo.UsedPercent,
+// SetEnabled enables or disables a collector by name.
+func (r *Registry) SetEnabled(name string, enabled bool) error {
+	for _, c := range r.collectors {
+		if c.Name() == name {
+			r.mu.Lock()
+			r.disabled[name] = !enabled
+			r.mu.Unlock()
+			return nil
 		}
 	}
+	return fmt.Errorf("unknown collector %q", name)
+}
 
-	// Disk
-	partitions, err := disk.Partitions(false)
-	if err == nil {
-		for _, p := range partitions {
-			usage, err := disk.Usage(p.Mountpoint)
-			if err != nil {
-				continue
-			}
-
-			metrics.Disks = append(metrics.Disks, protocol.DiskStats{
-				Path:      p.Mountpoint,
-				Total:     usage.Total,
-				Used:      usage.Used,
-				Available: usage.Free,
-				Percent:   usage.UsedPercent,
-			})
-		}
-	}
+func (r *Registry) enabled(name string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return !r.disabled[name]
+}
 
-	// Network
-	netStats, err := net.IOCounters(false)
-	if err == nil && len(netStats) > 0 {
-		current := &netStats[0]
-		now := time.Now()
+// Collect runs every enabled collector. A collector that fails leaves its
+// fields empty and its error in the payload's Collectors list; it does not
+// stop the others.
+func (r *Registry) Collect() *protocol.MetricsPayload {
+	metrics := &protocol.MetricsPayload{}
+	for _, c := range r.collectors {
+		if !r.enabled(c.Name()) {
+			continue
+		}
 
-		metrics.Network = protocol.NetworkStats{
-			BytesSent: current.BytesSent,
-			BytesRecv: current.BytesRecv,
+		start := time.Now()
+		err := runCollector(c, metrics)
+		status := protocol.CollectorStatus{
+			Name:       c.Name(),
+			DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+		}
+		if err != nil {
+			status.Error = err.Error()
 		}
+		metrics.Collectors = append(metrics.Collectors, status)
+	}
+	return metrics
+}
 
-		// Calculate rate
-		if c.lastNetStats != nil {
-			duration := now.Sub(c.lastNetStatsTime).Seconds()
-			if duration > 0 {
-				metrics.Network.BytesSentRate = uint64(float64(current.BytesSent-c.lastNetStats.BytesSent) / duration)
-				metrics.Network.BytesRecvRate = uint64(float64(current.BytesRecv-c.lastNetStats.BytesRecv) / duration)
-			}
+// runCollector turns a panic in a collector into an error, so one broken
+// collector cannot take the agent down.
+func runCollector(c Collector, metrics *protocol.MetricsPayload) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
 		}
+	}()
+	return c.Collect(metrics)
+}
 
-		c.lastNetStats = current
-		c.lastNetStatsTime = now
+// counterRate returns the per-second rate between two counter readings, or 0
+// if the counter went backwards because the interface was reset.
+func counterRate(current, last uint64, seconds float64) uint64 {
+	if current < last {
+		return 0
 	}
+	return uint64(float64(current-last) / seconds)
+}
+
+// TopProcesses samples the busiest processes on demand.
+func (r *Registry) TopProcesses(n int) (*protocol.ProcessesPayload, error) {
+	return r.processes.tracker.Top(n)
+}
+
+// SetTopProcesses sets how many processes by CPU and by memory are attached
+// to each sample; 0 disables it.
+func (r *Registry) SetTopProcesses(n int) {
+	r.processes.limit = n
+}
+
+// SetContainers enables container collection. dockerSocket may be empty to
+// rely on cgroups alone.
+func (r *Registry) SetContainers(enabled bool, dockerSocket string) {
+	r.containers.setDockerSocket(dockerSocket)
+	r.SetEnabled(r.containers.Name(), enabled)
+}
+
+// SetWatches replaces the watch list received from core.
+func (r *Registry) SetWatches(specs []protocol.WatchSpec) {
+	r.watches.set(specs)
+}
+
+func (r *Registry) SetInterfaceFilter(filter InterfaceFilter) {
+	r.network.filter = filter
+}
 
-	return metrics, nil
+func (r *Registry) SetDiskFilter(filter DiskFilter) {
+	r.disk.filter = filter
+	r.diskIO.filter = filter
 }
 
-func (c *Collector) GetInterval() time.Duration {
-	return c.interval
+func (r *Registry) GetInterval() time.Duration {
+	return r.interval
 }
 
-func (c *Collector) SetInterval(interval time.Duration) {
-	c.interval = interval
+func (r *Registry) SetInterval(interval time.Duration) {
+	r.interval = interval
 }
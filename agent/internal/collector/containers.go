@@ -0,0 +1,343 @@
+package collector
+
+import (
+	"bufio"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
+)
+
+const defaultCgroupRoot = "/sys/fs/cgroup"
+
+// maxCgroupDepth bounds the cgroup walk. Container scopes sit a few levels
+// below the root at most, e.g. kubepods.slice/<qos>/<pod>/<container>.
+const maxCgroupDepth = 6
+
+// containerForgetAfter is how long the state of a vanished container is kept,
+// so a restart slower than one interval is still noticed.
+const containerForgetAfter = time.Hour
+
+// containerScope matches the cgroup directory names that runtimes create for
+// containers: "docker-<id>.scope" with the systemd driver or a bare "<id>"
+// with the cgroupfs driver.
+var containerScope = regexp.MustCompile(`^(?:(docker|libpod|cri-containerd|crio)-)?([0-9a-f]{64})(?:\.scope)?$`)
+
+var scopeRuntimes = map[string]string{
+	"docker":         "docker",
+	"libpod":         "podman",
+	"cri-containerd": "containerd",
+	"crio":           "crio",
+}
+
+type cgroupContainer struct {
+	path    string
+	runtime string
+}
+
+type containerState struct {
+	cpuUsage  uint64
+	netRx     uint64
+	netTx     uint64
+	sampledAt time.Time
+	missing   bool
+	restarts  int
+}
+
+// containerCollector discovers containers from the cgroup v2 hierarchy and,
+// when the Docker API socket exists, adds names, images, stopped containers
+// and restart counts from it.
+type containerCollector struct {
+	cgroupRoot string
+	docker     *dockerClient
+	states     map[string]*containerState
+}
+
+func newContainerCollector(dockerSocket string) *containerCollector {
+	c := &containerCollector{
+		cgroupRoot: defaultCgroupRoot,
+		states:     map[string]*containerState{},
+	}
+	c.setDockerSocket(dockerSocket)
+	return c
+}
+
+func (c *containerCollector) setDockerSocket(dockerSocket string) {
+	c.docker = nil
+	if dockerSocket != "" {
+		c.docker = newDockerClient(dockerSocket)
+	}
+}
+
+func (c *containerCollector) Name() string { return "containers" }
+
+// Collect reports every known container. The list is never nil so core can
+// tell an empty host from one where collection is disabled. A Docker API
+// that fails is reported, one that is not installed is not.
+func (c *containerCollector) Collect(metrics *protocol.MetricsPayload) error {
+	now := time.Now()
+	hostMemory := metrics.Memory.Total
+	cgroups := c.discover()
+
+	var listed []dockerContainer
+	var listErr error
+	if c.docker != nil {
+		listed, listErr = c.docker.List()
+		if errors.Is(listErr, fs.ErrNotExist) {
+			listErr = nil
+		}
+	}
+
+	stats := []protocol.ContainerStats{}
+	for _, d := range listed {
+		s := protocol.ContainerStats{
+			ID:           d.ID,
+			Name:         d.Name,
+			Image:        d.Image,
+			Runtime:      "docker",
+			State:        d.State,
+			RestartCount: d.RestartCount,
+			StartedAt:    d.StartedAt,
+		}
+		if cg, ok := cgroups[d.ID]; ok {
+			// Podman serves the Docker API too; the cgroup knows better.
+			if cg.runtime != "cgroup" {
+				s.Runtime = cg.runtime
+			}
+			c.sample(&s, cg.path, now, hostMemory)
+			delete(cgroups, d.ID)
+		}
+		stats = append(stats, s)
+	}
+
+	for id, cg := range cgroups {
+		s := protocol.ContainerStats{
+			ID:      id,
+			Name:    shortID(id),
+			Runtime: cg.runtime,
+			State:   cgroupState(cg.path),
+		}
+		c.sample(&s, cg.path, now, hostMemory)
+		s.RestartCount = c.states[id].restarts
+		stats = append(stats, s)
+	}
+
+	for id, st := range c.states {
+		if st.sampledAt.Before(now) {
+			st.missing = true
+		}
+		if now.Sub(st.sampledAt) > containerForgetAfter {
+			delete(c.states, id)
+		}
+	}
+
+	sort.Slice(stats, func(i, j int) bool { return stats[i].Name < stats[j].Name })
+	metrics.Containers = stats
+	return listErr
+}
+
+// discover walks the cgroup v2 tree for container scopes. It returns nothing
+// on cgroup v1 hosts.
+func (c *containerCollector) discover() map[string]cgroupContainer {
+	found := map[string]cgroupContainer{}
+	if _, err := os.Stat(filepath.Join(c.cgroupRoot, "cgroup.controllers")); err != nil {
+		return found
+	}
+
+	var walk func(dir string, depth int)
+	walk = func(dir string, depth int) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return
+		}
+		for _, e := range entries {
+			if !e.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			if m := containerScope.FindStringSubmatch(e.Name()); m != nil {
+				runtime := scopeRuntimes[m[1]]
+				if runtime == "" {
+					runtime = runtimeFromParent(dir)
+				}
+				// Containers running systemd create nested cgroups; the
+				// scope itself already accounts for them.
+				found[m[2]] = cgroupContainer{path: path, runtime: runtime}
+				continue
+			}
+			if depth < maxCgroupDepth {
+				walk(path, depth+1)
+			}
+		}
+	}
+	walk(c.cgroupRoot, 1)
+
+	return found
+}
+
+func shortID(id string) string {
+	if len(id) > 12 {
+		return id[:12]
+	}
+	return id
+}
+
+// runtimeFromParent names the runtime of a bare "<id>" cgroup from the
+// directory it sits in.
+func runtimeFromParent(dir string) string {
+	switch {
+	case filepath.Base(dir) == "docker":
+		return "docker"
+	case strings.Contains(dir, "libpod"):
+		return "podman"
+	case strings.Contains(dir, "containerd"):
+		return "containerd"
+	default:
+		return "cgroup"
+	}
+}
+
+// cgroupState derives a container state from cgroup.events when no runtime
+// API is available.
+func cgroupState(path string) string {
+	events := readCgroupStat(filepath.Join(path, "cgroup.events"))
+	switch {
+	case events["frozen"] == 1:
+		return "paused"
+	case events["populated"] == 0:
+		return "exited"
+	default:
+		return "running"
+	}
+}
+
+// sample fills the resource usage of one container from its cgroup. CPU and
+// network rates are computed against the previous sample; a CPU counter that
+// went backwards or a container that was missing in between means the
+// container was restarted.
+func (c *containerCollector) sample(s *protocol.ContainerStats, path string, now time.Time, hostMemory uint64) {
+	st, ok := c.states[s.ID]
+	if !ok {
+		st = &containerState{}
+		c.states[s.ID] = st
+	}
+
+	usage := readCgroupStat(filepath.Join(path, "cpu.stat"))["usage_usec"]
+	elapsed := now.Sub(st.sampledAt).Seconds()
+	if ok && (st.missing || usage < st.cpuUsage) {
+		st.restarts++
+	} else if ok && elapsed > 0 {
+		s.CPU = float64(usage-st.cpuUsage) / (elapsed * 1e6) * 100
+	}
+
+	// Like docker stats, page cache that can be reclaimed is not counted.
+	if current, err := readCgroupValue(filepath.Join(path, "memory.current")); err == nil {
+		inactive := readCgroupStat(filepath.Join(path, "memory.stat"))["inactive_file"]
+		if inactive < current {
+			current -= inactive
+		}
+		s.MemoryUsage = current
+	}
+	if limit, err := readCgroupValue(filepath.Join(path, "memory.max")); err == nil && limit < hostMemory {
+		s.MemoryLimit = limit
+	}
+	if limit := s.MemoryLimit; limit > 0 {
+		s.MemoryPercent = float64(s.MemoryUsage) / float64(limit) * 100
+	} else if hostMemory > 0 {
+		s.MemoryPercent = float64(s.MemoryUsage) / float64(hostMemory) * 100
+	}
+
+	if rx, tx, hasNet := containerNetwork(path); hasNet {
+		s.NetRx, s.NetTx = rx, tx
+		if ok && !st.missing && elapsed > 0 {
+			s.NetRxRate = counterRate(rx, st.netRx, elapsed)
+			s.NetTxRate = counterRate(tx, st.netTx, elapsed)
+		}
+		st.netRx, st.netTx = rx, tx
+	}
+
+	st.cpuUsage = usage
+	st.sampledAt = now
+	st.missing = false
+}
+
+// containerNetwork sums the interface counters in the network namespace of
+// the container's first process. It reports false for containers without a
+// process or sharing the host network, whose traffic is the host's.
+func containerNetwork(path string) (rx, tx uint64, ok bool) {
+	data, err := os.ReadFile(filepath.Join(path, "cgroup.procs"))
+	if err != nil {
+		return 0, 0, false
+	}
+	pid := strings.SplitN(strings.TrimSpace(string(data)), "\n", 2)[0]
+	if pid == "" {
+		return 0, 0, false
+	}
+
+	ns, err := os.Readlink(filepath.Join("/proc", pid, "ns/net"))
+	if err != nil {
+		return 0, 0, false
+	}
+	if own, err := os.Readlink("/proc/self/ns/net"); err == nil && own == ns {
+		return 0, 0, false
+	}
+
+	f, err := os.Open(filepath.Join("/proc", pid, "net/dev"))
+	if err != nil {
+		return 0, 0, false
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		name, counters, found := strings.Cut(scanner.Text(), ":")
+		if !found || strings.TrimSpace(name) == "lo" {
+			continue
+		}
+		fields := strings.Fields(counters)
+		if len(fields) < 9 {
+			continue
+		}
+		r, _ := strconv.ParseUint(fields[0], 10, 64)
+		t, _ := strconv.ParseUint(fields[8], 10, 64)
+		rx += r
+		tx += t
+	}
+
+	return rx, tx, true
+}
+
+// readCgroupStat parses a flat-keyed cgroup file such as cpu.stat.
+func readCgroupStat(path string) map[string]uint64 {
+	values := map[string]uint64{}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return values
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		key, value, ok := strings.Cut(line, " ")
+		if !ok {
+			continue
+		}
+		if v, err := strconv.ParseUint(strings.TrimSpace(value), 10, 64); err == nil {
+			values[key] = v
+		}
+	}
+	return values
+}
+
+// readCgroupValue reads a single-value cgroup file. "max" reads as an error.
+func readCgroupValue(path string) (uint64, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+}
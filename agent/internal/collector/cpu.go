@@ -0,0 +1,67 @@
+package collector
+
+import (
+	"math"
+
+	"github.com/probe-system/agent/pkg/protocol"
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// cpuCollector computes CPU usage from the change in per-core CPU times since
+// the previous cycle, instead of sleeping to take two samples.
+type cpuCollector struct {
+	last []cpu.TimesStat
+}
+
+// newCPUCollector records the baseline, so the first cycle already has an
+// interval to measure.
+func newCPUCollector() *cpuCollector {
+	c := &cpuCollector{}
+	c.last, _ = cpu.Times(true)
+	return c
+}
+
+func (c *cpuCollector) Name() string { return "cpu" }
+
+// Collect reports the usage of each core and their mean. Nothing is reported
+// when there is no baseline or the number of CPUs changed.
+func (c *cpuCollector) Collect(metrics *protocol.MetricsPayload) error {
+	times, err := cpu.Times(true)
+	if err != nil {
+		return err
+	}
+	last := c.last
+	c.last = times
+	if len(times) == 0 || len(last) != len(times) {
+		return nil
+	}
+
+	cores := make([]float64, len(times))
+	var total float64
+	for i := range times {
+		cores[i] = busyPercent(last[i], times[i])
+		total += cores[i]
+	}
+	metrics.CPU = total / float64(len(cores))
+	metrics.CPUCores = cores
+	return nil
+}
+
+// busyPercent returns the share of time a core was not idle between two
+// readings.
+func busyPercent(prev, cur cpu.TimesStat) float64 {
+	prevBusy, prevTotal := cpuBusy(prev)
+	busy, total := cpuBusy(cur)
+	if total <= prevTotal {
+		return 0
+	}
+	percent := (busy - prevBusy) / (total - prevTotal) * 100
+	return math.Max(0, math.Min(percent, 100))
+}
+
+// cpuBusy returns the busy and total time of a core. Guest time is already
+// included in user time.
+func cpuBusy(t cpu.TimesStat) (busy, total float64) {
+	total = t.User + t.System + t.Idle + t.Nice + t.Iowait + t.Irq + t.Softirq + t.Steal
+	return total - t.Idle - t.Iowait, total
+}
@@ -0,0 +1,137 @@
+package collector
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// diskCollector reports space usage, one entry per device.
+type diskCollector struct {
+	filter DiskFilter
+}
+
+func (c *diskCollector) Name() string { return "disk" }
+
+func (c *diskCollector) Collect(metrics *protocol.MetricsPayload) error {
+	partitions, err := disk.Partitions(false)
+	if err != nil {
+		return err
+	}
+
+	byDevice := map[string]int{}
+	for _, p := range partitions {
+		if !c.filter.Mount(p.Fstype, p.Mountpoint) {
+			continue
+		}
+		// Bind mounts and subvolumes repeat the device; keep the shortest
+		// mountpoint.
+		if i, ok := byDevice[p.Device]; ok {
+			if len(p.Mountpoint) < len(metrics.Disks[i].Path) {
+				metrics.Disks[i].Path = p.Mountpoint
+			}
+			continue
+		}
+
+		usage, err := disk.Usage(p.Mountpoint)
+		if err != nil || usage.Total == 0 {
+			continue
+		}
+
+		byDevice[p.Device] = len(metrics.Disks)
+		metrics.Disks = append(metrics.Disks, protocol.DiskStats{
+			Path:      p.Mountpoint,
+			Device:    p.Device,
+			FSType:    p.Fstype,
+			Total:     usage.Total,
+			Used:      usage.Used,
+			Available: usage.Free,
+			Percent:   usage.UsedPercent,
+		})
+	}
+
+	return nil
+}
+
+// diskIOCollector reports rates for each whole block device since the
+// previous cycle.
+type diskIOCollector struct {
+	filter   DiskFilter
+	last     map[string]disk.IOCountersStat
+	lastTime time.Time
+}
+
+func newDiskIOCollector(filter DiskFilter) *diskIOCollector {
+	c := &diskIOCollector{filter: filter}
+	c.last, _ = disk.IOCounters()
+	c.lastTime = time.Now()
+	return c
+}
+
+func (c *diskIOCollector) Name() string { return "disk_io" }
+
+func (c *diskIOCollector) Collect(metrics *protocol.MetricsPayload) error {
+	counters, err := disk.IOCounters()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	elapsed := now.Sub(c.lastTime).Seconds()
+	last := c.last
+	c.last = counters
+	c.lastTime = now
+	if last == nil || elapsed <= 0 {
+		return nil
+	}
+
+	names := make([]string, 0, len(counters))
+	for name := range counters {
+		if c.filter.Device(name) && isWholeDisk(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	stats := []protocol.DiskIOStats{}
+	for _, name := range names {
+		cur, prev := counters[name], last[name]
+		if _, ok := last[name]; !ok || cur.ReadCount < prev.ReadCount || cur.WriteCount < prev.WriteCount {
+			continue
+		}
+
+		ops := (cur.ReadCount - prev.ReadCount) + (cur.WriteCount - prev.WriteCount)
+		stat := protocol.DiskIOStats{
+			Device:         name,
+			ReadBytesRate:  counterRate(cur.ReadBytes, prev.ReadBytes, elapsed),
+			WriteBytesRate: counterRate(cur.WriteBytes, prev.WriteBytes, elapsed),
+			ReadIOPS:       float64(cur.ReadCount-prev.ReadCount) / elapsed,
+			WriteIOPS:      float64(cur.WriteCount-prev.WriteCount) / elapsed,
+		}
+		if cur.IoTime >= prev.IoTime {
+			stat.Util = math.Min(float64(cur.IoTime-prev.IoTime)/(elapsed*1000)*100, 100)
+		}
+		if ops > 0 && cur.ReadTime+cur.WriteTime >= prev.ReadTime+prev.WriteTime {
+			stat.AwaitMs = float64((cur.ReadTime+cur.WriteTime)-(prev.ReadTime+prev.WriteTime)) / float64(ops)
+		}
+		stats = append(stats, stat)
+	}
+
+	metrics.DiskIO = stats
+	return nil
+}
+
+// isWholeDisk reports whether a device is a disk rather than a partition of
+// one. Only Linux exposes the distinction, via /sys/block.
+func isWholeDisk(name string) bool {
+	if _, err := os.Stat("/sys/block"); err != nil {
+		return true
+	}
+	_, err := os.Stat(filepath.Join("/sys/block", name))
+	return err == nil
+}
@@ -0,0 +1,52 @@
+package collector
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// DefaultDiskExcludeFSTypes skips pseudo, in-memory and container filesystems.
+var DefaultDiskExcludeFSTypes = []string{
+	"tmpfs", "devtmpfs", "devfs", "ramfs", "overlay", "aufs", "squashfs", "iso9660",
+	"proc", "sysfs", "cgroup", "cgroup2", "nsfs", "autofs", "tracefs", "debugfs",
+	"securityfs", "pstore", "bpf", "configfs", "fusectl", "mqueue", "hugetlbfs",
+	"fuse.lxcfs",
+}
+
+// DefaultDiskExcludePaths skips kernel mounts and container runtime storage.
+var DefaultDiskExcludePaths = []string{
+	"/proc", "/sys", "/dev", "/run", "/snap",
+	"/var/lib/docker", "/var/lib/containers", "/var/lib/kubelet",
+}
+
+// DefaultDiskIOExclude skips virtual block devices that have no disk behind
+// them.
+var DefaultDiskIOExclude = []string{"loop*", "ram*", "zram*", "sr*", "fd*"}
+
+// DiskFilter selects the mounts reported for space usage and the block
+// devices reported for I/O. A path pattern excludes the mountpoint and
+// everything below it; all patterns use filepath.Match syntax.
+type DiskFilter struct {
+	ExcludeFSTypes []string
+	ExcludePaths   []string
+	ExcludeDevices []string
+}
+
+func (f *DiskFilter) Mount(fstype, path string) bool {
+	if matchAny(f.ExcludeFSTypes, fstype) {
+		return false
+	}
+	for _, p := range f.ExcludePaths {
+		if ok, _ := filepath.Match(p, path); ok {
+			return false
+		}
+		if path == p || strings.HasPrefix(path, strings.TrimSuffix(p, "/")+"/") {
+			return false
+		}
+	}
+	return true
+}
+
+func (f *DiskFilter) Device(name string) bool {
+	return !matchAny(f.ExcludeDevices, name)
+}
@@ -0,0 +1,156 @@
+package collector
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// DefaultDockerSocket is where the Docker engine listens. Podman serves the
+// same API at /run/podman/podman.sock.
+const DefaultDockerSocket = "/var/run/docker.sock"
+
+// dockerInspectRefresh is how long a container's restart count and start
+// time are reused while its state does not change. A restart quick enough
+// to happen between two collections shows up after at most this long.
+const dockerInspectRefresh = 5 * time.Minute
+
+// dockerClient talks to the Docker engine API over its unix socket. Only the
+// two read-only endpoints the collector needs are implemented.
+type dockerClient struct {
+	socket string
+	http   *http.Client
+	// inspected caches what inspecting each listed container returned.
+	inspected map[string]*dockerInspect
+}
+
+type dockerInspect struct {
+	state        string
+	restartCount int
+	startedAt    int64
+	checkedAt    time.Time
+}
+
+type dockerContainer struct {
+	ID           string
+	Name         string
+	Image        string
+	State        string
+	RestartCount int
+	StartedAt    int64
+}
+
+func newDockerClient(socket string) *dockerClient {
+	return &dockerClient{
+		socket:    socket,
+		inspected: make(map[string]*dockerInspect),
+		http: &http.Client{
+			Timeout: 5 * time.Second,
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var d net.Dialer
+					return d.DialContext(ctx, "unix", socket)
+				},
+			},
+		},
+	}
+}
+
+// List returns all containers, including stopped ones, with their restart
+// counts. Containers are only inspected for those when they are new, their
+// state changed or dockerInspectRefresh passed. It fails quietly when the
+// socket does not exist.
+func (d *dockerClient) List() ([]dockerContainer, error) {
+	if _, err := os.Stat(d.socket); err != nil {
+		return nil, err
+	}
+
+	var summaries []struct {
+		ID    string   `json:"Id"`
+		Names []string `json:"Names"`
+		Image string   `json:"Image"`
+		State string   `json:"State"`
+	}
+	if err := d.get("/containers/json?all=1", &summaries); err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	listed := make(map[string]bool, len(summaries))
+	containers := make([]dockerContainer, 0, len(summaries))
+	for _, s := range summaries {
+		listed[s.ID] = true
+		c := dockerContainer{
+			ID:    s.ID,
+			Name:  shortID(s.ID),
+			Image: s.Image,
+			State: s.State,
+		}
+		if len(s.Names) > 0 {
+			c.Name = strings.TrimPrefix(s.Names[0], "/")
+		}
+
+		// The list endpoint has no restart count.
+		info := d.inspected[s.ID]
+		if info == nil || info.state != s.State || now.Sub(info.checkedAt) >= dockerInspectRefresh {
+			info = d.inspect(s.ID, s.State, now, info)
+			d.inspected[s.ID] = info
+		}
+		c.RestartCount = info.restartCount
+		c.StartedAt = info.startedAt
+
+		containers = append(containers, c)
+	}
+
+	for id := range d.inspected {
+		if !listed[id] {
+			delete(d.inspected, id)
+		}
+	}
+
+	return containers, nil
+}
+
+// inspect gets the container's restart count and start time. If that fails
+// the previous ones are kept until the next refresh.
+func (d *dockerClient) inspect(id, state string, now time.Time, previous *dockerInspect) *dockerInspect {
+	result := &dockerInspect{state: state, checkedAt: now}
+	if previous != nil {
+		result.restartCount, result.startedAt = previous.restartCount, previous.startedAt
+	}
+
+	var info struct {
+		RestartCount int `json:"RestartCount"`
+		State        struct {
+			StartedAt time.Time `json:"StartedAt"`
+		} `json:"State"`
+	}
+	if err := d.get("/containers/"+id+"/json", &info); err != nil {
+		return result
+	}
+	result.restartCount = info.RestartCount
+	result.startedAt = 0
+	if info.State.StartedAt.Year() > 1 {
+		result.startedAt = info.State.StartedAt.Unix()
+	}
+	return result
+}
+
+func (d *dockerClient) get(path string, v interface{}) error {
+	resp, err := d.http.Get("http://docker" + path)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("docker API %s returned status %d", path, resp.StatusCode)
+	}
+
+	return json.NewDecoder(resp.Body).Decode(v)
+}
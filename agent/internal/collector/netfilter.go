@@ -0,0 +1,36 @@
+package collector
+
+import "path/filepath"
+
+// DefaultInterfaceExclude skips loopback, container bridges, tunnels and
+// traffic-shaping devices, whose traffic is already seen on the physical
+// interface.
+var DefaultInterfaceExclude = []string{
+	"lo", "lo0", "Loopback*",
+	"docker*", "veth*", "br-*", "virbr*", "cni*", "flannel*", "cali*",
+	"tun*", "tap*", "wg*", "ifb*",
+}
+
+// InterfaceFilter selects the network interfaces that count toward totals
+// and traffic billing. Patterns use filepath.Match syntax. An empty Include
+// matches every interface; Exclude is applied afterwards.
+type InterfaceFilter struct {
+	Include []string
+	Exclude []string
+}
+
+func (f *InterfaceFilter) Counts(name string) bool {
+	if len(f.Include) > 0 && !matchAny(f.Include, name) {
+		return false
+	}
+	return !matchAny(f.Exclude, name)
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, p := range patterns {
+		if ok, _ := filepath.Match(p, name); ok {
+			return true
+		}
+	}
+	return false
+}
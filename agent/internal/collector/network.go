@@ -0,0 +1,68 @@
+package collector
+
+import (
+	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// networkCollector reports every interface and totals those the filter
+// counts.
+type networkCollector struct {
+	filter   InterfaceFilter
+	last     map[string]net.IOCountersStat
+	lastTime time.Time
+}
+
+func newNetworkCollector(filter InterfaceFilter) *networkCollector {
+	c := &networkCollector{filter: filter}
+	if stats, err := net.IOCounters(true); err == nil {
+		c.last = make(map[string]net.IOCountersStat, len(stats))
+		for _, n := range stats {
+			c.last[n.Name] = n
+		}
+		c.lastTime = time.Now()
+	}
+	return c
+}
+
+func (c *networkCollector) Name() string { return "network" }
+
+func (c *networkCollector) Collect(metrics *protocol.MetricsPayload) error {
+	netStats, err := net.IOCounters(true)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	duration := now.Sub(c.lastTime).Seconds()
+	current := make(map[string]net.IOCountersStat, len(netStats))
+
+	for _, n := range netStats {
+		current[n.Name] = n
+		iface := protocol.InterfaceStats{
+			Name:      n.Name,
+			BytesSent: n.BytesSent,
+			BytesRecv: n.BytesRecv,
+			Counted:   c.filter.Counts(n.Name),
+		}
+
+		if last, ok := c.last[n.Name]; ok && duration > 0 {
+			iface.BytesSentRate = counterRate(n.BytesSent, last.BytesSent, duration)
+			iface.BytesRecvRate = counterRate(n.BytesRecv, last.BytesRecv, duration)
+		}
+
+		if iface.Counted {
+			metrics.Network.BytesSent += iface.BytesSent
+			metrics.Network.BytesRecv += iface.BytesRecv
+			metrics.Network.BytesSentRate += iface.BytesSentRate
+			metrics.Network.BytesRecvRate += iface.BytesRecvRate
+		}
+		metrics.Network.Interfaces = append(metrics.Network.Interfaces, iface)
+	}
+
+	c.last = current
+	c.lastTime = now
+	return nil
+}
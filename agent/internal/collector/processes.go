@@ -0,0 +1,136 @@
+package collector
+
+import (
+	"sort"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/probe-system/agent/pkg/protocol"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const maxCmdlineLen = 256
+
+// processCollector attaches the top processes to each sample when a limit
+// is set.
+type processCollector struct {
+	limit   int
+	tracker processTracker
+}
+
+func (c *processCollector) Name() string { return "processes" }
+
+func (c *processCollector) Collect(metrics *protocol.MetricsPayload) error {
+	if c.limit <= 0 {
+		return nil
+	}
+	top, err := c.tracker.Top(c.limit)
+	if err != nil {
+		return err
+	}
+	metrics.Processes = top
+	return nil
+}
+
+// processTracker keeps process handles between samples so CPU usage is
+// measured over the interval since the previous sample rather than the whole
+// process lifetime.
+type processTracker struct {
+	mu    sync.Mutex
+	procs map[int32]*process.Process
+}
+
+type processSample struct {
+	proc *process.Process
+	cpu  float64
+	rss  uint64
+}
+
+// Top returns the n processes using the most CPU and the n using the most
+// memory. A call with no earlier baseline samples twice, 500ms apart.
+func (t *processTracker) Top(n int) (*protocol.ProcessesPayload, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.procs == nil {
+		if _, err := t.scan(); err != nil {
+			return nil, err
+		}
+		time.Sleep(500 * time.Millisecond)
+	}
+
+	samples, err := t.scan()
+	if err != nil {
+		return nil, err
+	}
+
+	payload := &protocol.ProcessesPayload{}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].cpu > samples[j].cpu })
+	for i := 0; i < n && i < len(samples); i++ {
+		payload.TopCPU = append(payload.TopCPU, describeProcess(samples[i]))
+	}
+
+	sort.Slice(samples, func(i, j int) bool { return samples[i].rss > samples[j].rss })
+	for i := 0; i < n && i < len(samples); i++ {
+		payload.TopMemory = append(payload.TopMemory, describeProcess(samples[i]))
+	}
+
+	return payload, nil
+}
+
+// scan refreshes the handle cache and reads CPU and RSS for every process.
+func (t *processTracker) scan() ([]processSample, error) {
+	pids, err := process.Pids()
+	if err != nil {
+		return nil, err
+	}
+
+	procs := make(map[int32]*process.Process, len(pids))
+	samples := make([]processSample, 0, len(pids))
+	for _, pid := range pids {
+		p, ok := t.procs[pid]
+		if !ok {
+			if p, err = process.NewProcess(pid); err != nil {
+				continue
+			}
+		}
+		procs[pid] = p
+
+		cpu, err := p.Percent(0)
+		if err != nil {
+			continue
+		}
+		var rss uint64
+		if mem, err := p.MemoryInfo(); err == nil {
+			rss = mem.RSS
+		}
+		samples = append(samples, processSample{proc: p, cpu: cpu, rss: rss})
+	}
+
+	t.procs = procs
+	return samples, nil
+}
+
+// describeProcess reads the fields that are only needed for the processes
+// actually reported.
+func describeProcess(s processSample) protocol.ProcessInfo {
+	info := protocol.ProcessInfo{
+		PID: s.proc.Pid,
+		CPU: s.cpu,
+		RSS: s.rss,
+	}
+	info.Name, _ = s.proc.Name()
+	info.User, _ = s.proc.Username()
+	info.Cmdline, _ = s.proc.Cmdline()
+	if len(info.Cmdline) > maxCmdlineLen {
+		// Cut at a rune boundary so the JSON stays valid UTF-8.
+		cut := maxCmdlineLen
+		for cut > 0 && !utf8.RuneStart(info.Cmdline[cut]) {
+			cut--
+		}
+		info.Cmdline = info.Cmdline[:cut] + "..."
+	}
+	return info
+}
@@ -0,0 +1,167 @@
+package collector
+
+import (
+	"bufio"
+	"os"
+	"runtime"
+	"strconv"
+	"strings"
+
+	"github.com/probe-system/agent/pkg/protocol"
+	"github.com/shirou/gopsutil/v3/net"
+)
+
+// tcpStateListen is the LISTEN state as written in /proc/net/tcp.
+const tcpStateListen = "0A"
+
+// tcpStates names the hex states in /proc/net/tcp, matching the names
+// gopsutil reports on other platforms.
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+	"0C": "NEW_SYN_RECV",
+}
+
+type socketCollector struct{}
+
+func (socketCollector) Name() string { return "sockets" }
+
+func (socketCollector) Collect(metrics *protocol.MetricsPayload) error {
+	sockets, err := collectSockets()
+	if err != nil {
+		return err
+	}
+	metrics.Sockets = sockets
+	return nil
+}
+
+// collectSockets counts TCP connections per state, UDP sockets and listening
+// TCP sockets.
+func collectSockets() (protocol.SocketStats, error) {
+	stats := protocol.SocketStats{TCP: map[string]uint64{}}
+	addTCP := func(state string) {
+		if state == "LISTEN" {
+			stats.Listen++
+			return
+		}
+		stats.TCP[state]++
+		stats.TCPTotal++
+	}
+
+	if runtime.GOOS != "linux" {
+		conns, err := net.Connections("tcp")
+		if err != nil {
+			return stats, err
+		}
+		for _, c := range conns {
+			if c.Status != "" && c.Status != "NONE" {
+				addTCP(c.Status)
+			}
+		}
+		if udp, err := net.Connections("udp"); err == nil {
+			stats.UDP = uint64(len(udp))
+		}
+		return stats, nil
+	}
+
+	read := 0
+	var lastErr error
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		err := scanProcNetTCP(path, func(_ uint32, state string) {
+			if name, ok := tcpStates[state]; ok {
+				addTCP(name)
+			}
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		read++
+	}
+	if read == 0 {
+		return stats, lastErr
+	}
+
+	// The UDP tables share the layout; every row is a socket.
+	for _, path := range []string{"/proc/net/udp", "/proc/net/udp6"} {
+		scanProcNetTCP(path, func(uint32, string) { stats.UDP++ })
+	}
+	return stats, nil
+}
+
+// listeningTCPPorts returns the number of listening TCP sockets per local
+// port. On Linux it reads /proc/net/tcp{,6} directly, which is much cheaper
+// than gopsutil mapping every socket to its process.
+func listeningTCPPorts() (map[uint32]int, error) {
+	if runtime.GOOS != "linux" {
+		conns, err := net.Connections("tcp")
+		if err != nil {
+			return nil, err
+		}
+		ports := map[uint32]int{}
+		for _, c := range conns {
+			if c.Status == "LISTEN" {
+				ports[c.Laddr.Port]++
+			}
+		}
+		return ports, nil
+	}
+
+	ports := map[uint32]int{}
+	var lastErr error
+	read := 0
+	for _, path := range []string{"/proc/net/tcp", "/proc/net/tcp6"} {
+		err := scanProcNetTCP(path, func(localPort uint32, state string) {
+			if state == tcpStateListen {
+				ports[localPort]++
+			}
+		})
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		read++
+	}
+	if read == 0 {
+		return nil, lastErr
+	}
+	return ports, nil
+}
+
+// scanProcNetTCP calls fn with the local port and hex state of every socket
+// in a /proc/net/tcp style file, which includes /proc/net/udp.
+func scanProcNetTCP(path string, fn func(localPort uint32, state string)) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		i := strings.LastIndexByte(fields[1], ':')
+		if i < 0 {
+			continue
+		}
+		port, err := strconv.ParseUint(fields[1][i+1:], 16, 16)
+		if err != nil {
+			continue
+		}
+		fn(uint32(port), fields[3])
+	}
+	return scanner.Err()
+}
@@ -0,0 +1,94 @@
+package collector
+
+import (
+	"errors"
+	"runtime"
+
+	"github.com/probe-system/agent/pkg/protocol"
+	"github.com/shirou/gopsutil/v3/host"
+	"github.com/shirou/gopsutil/v3/load"
+	"github.com/shirou/gopsutil/v3/mem"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+type loadCollector struct{}
+
+func (loadCollector) Name() string { return "load" }
+
+func (loadCollector) Collect(metrics *protocol.MetricsPayload) error {
+	avg, err := load.Avg()
+	if err != nil {
+		return err
+	}
+	metrics.Load = protocol.LoadStats{
+		Load1:  avg.Load1,
+		Load5:  avg.Load5,
+		Load15: avg.Load15,
+	}
+	return nil
+}
+
+// memoryCollector reports memory and swap.
+type memoryCollector struct{}
+
+func (memoryCollector) Name() string { return "memory" }
+
+func (memoryCollector) Collect(metrics *protocol.MetricsPayload) error {
+	memInfo, memErr := mem.VirtualMemory()
+	if memErr == nil {
+		metrics.Memory = protocol.MemoryStats{
+			Total:     memInfo.Total,
+			Used:      memInfo.Used,
+			Available: memInfo.Available,
+			Percent:   memInfo.UsedPercent,
+		}
+	}
+
+	swapInfo, swapErr := mem.SwapMemory()
+	if swapErr == nil {
+		metrics.Swap = protocol.SwapStats{
+			Total:   swapInfo.Total,
+			Used:    swapInfo.Used,
+			Free:    swapInfo.Free,
+			Percent: swapInfo.UsedPercent,
+		}
+	}
+
+	return errors.Join(memErr, swapErr)
+}
+
+// hostCollector reports uptime, boot time and process and thread counts.
+type hostCollector struct{}
+
+func (hostCollector) Name() string { return "host" }
+
+func (hostCollector) Collect(metrics *protocol.MetricsPayload) error {
+	var errs []error
+
+	if uptime, err := host.Uptime(); err == nil {
+		metrics.Host.Uptime = uptime
+	} else {
+		errs = append(errs, err)
+	}
+	if bootTime, err := host.BootTime(); err == nil {
+		metrics.Host.BootTime = bootTime
+	} else {
+		errs = append(errs, err)
+	}
+	if pids, err := process.Pids(); err == nil {
+		metrics.Host.Processes = uint64(len(pids))
+	} else {
+		errs = append(errs, err)
+	}
+	// On Linux the total in /proc/loadavg counts threads; elsewhere it is
+	// the process count, so it is only reported there.
+	if runtime.GOOS == "linux" {
+		if misc, err := load.Misc(); err == nil {
+			metrics.Host.Threads = uint64(misc.ProcsTotal)
+		} else {
+			errs = append(errs, err)
+		}
+	}
+
+	return errors.Join(errs...)
+}
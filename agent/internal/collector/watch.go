@@ -0,0 +1,140 @@
+package collector
+
+import (
+	"errors"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/probe-system/agent/pkg/protocol"
+	"github.com/shirou/gopsutil/v3/process"
+)
+
+const (
+	watchTypeProcess = "process"
+	watchTypePort    = "port"
+)
+
+// watchCollector checks the processes and ports core asked the agent to
+// watch.
+type watchCollector struct {
+	mu         sync.Mutex
+	specs      []protocol.WatchSpec
+	patterns   map[string]*regexp.Regexp
+	configured bool
+}
+
+// globPattern compiles a process pattern where * matches any run of
+// characters, including the slashes in command-line paths, and ? matches one.
+func globPattern(glob string) *regexp.Regexp {
+	quoted := regexp.QuoteMeta(glob)
+	quoted = strings.ReplaceAll(quoted, `\*`, ".*")
+	quoted = strings.ReplaceAll(quoted, `\?`, ".")
+	return regexp.MustCompile("^" + quoted + "$")
+}
+
+// watchedProcess caches the command line of a process for one check, so it
+// is only read when a pattern does not match the name.
+type watchedProcess struct {
+	proc    *process.Process
+	name    string
+	cmdline *string
+}
+
+func (p *watchedProcess) matches(pattern *regexp.Regexp) bool {
+	if pattern.MatchString(p.name) {
+		return true
+	}
+	if p.cmdline == nil {
+		cmdline, _ := p.proc.Cmdline()
+		p.cmdline = &cmdline
+	}
+	return pattern.MatchString(*p.cmdline)
+}
+
+func (c *watchCollector) set(specs []protocol.WatchSpec) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.specs = specs
+	c.patterns = make(map[string]*regexp.Regexp)
+	for _, w := range specs {
+		if w.Type == watchTypeProcess {
+			c.patterns[w.Target] = globPattern(w.Target)
+		}
+	}
+	c.configured = true
+}
+
+func (c *watchCollector) Name() string { return "watches" }
+
+// Collect evaluates every watch. Watches stay nil until core has sent a
+// watch list. Watches that cannot be checked are left out rather than
+// reported down.
+func (c *watchCollector) Collect(metrics *protocol.MetricsPayload) error {
+	c.mu.Lock()
+	specs, patterns, configured := c.specs, c.patterns, c.configured
+	c.mu.Unlock()
+	if !configured {
+		return nil
+	}
+
+	var procs []*watchedProcess
+	var procsErr error
+	var ports map[uint32]int
+	var portsErr error
+
+	statuses := make([]protocol.WatchStatus, 0, len(specs))
+	for _, w := range specs {
+		status := protocol.WatchStatus{ID: w.ID}
+
+		switch w.Type {
+		case watchTypeProcess:
+			if procs == nil && procsErr == nil {
+				procs, procsErr = listWatchedProcesses()
+			}
+			if procsErr != nil {
+				continue
+			}
+			for _, p := range procs {
+				if p.matches(patterns[w.Target]) {
+					status.Count++
+				}
+			}
+		case watchTypePort:
+			if ports == nil && portsErr == nil {
+				ports, portsErr = listeningTCPPorts()
+			}
+			port, err := strconv.ParseUint(w.Target, 10, 16)
+			if portsErr != nil || err != nil {
+				continue
+			}
+			status.Count = ports[uint32(port)]
+		default:
+			continue
+		}
+
+		status.Up = status.Count > 0
+		statuses = append(statuses, status)
+	}
+
+	metrics.Watches = statuses
+	return errors.Join(procsErr, portsErr)
+}
+
+func listWatchedProcesses() ([]*watchedProcess, error) {
+	procs, err := process.Processes()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]*watchedProcess, 0, len(procs))
+	for _, p := range procs {
+		name, err := p.Name()
+		if err != nil {
+			continue
+		}
+		result = append(result, &watchedProcess{proc: p, name: name})
+	}
+	return result, nil
+}
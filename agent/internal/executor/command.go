@@ -0,0 +1,227 @@
+package executor
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
+)
+
+var errCommandsDisabled = errors.New("remote commands are disabled on this agent")
+
+const (
+	// Output is sent every commandFlushInterval, or sooner once
+	// commandFlushLines lines are waiting.
+	commandFlushInterval = 200 * time.Millisecond
+	commandFlushLines    = 100
+	// Longer lines are split.
+	maxCommandLine = 64 * 1024
+
+	defaultCommandTimeout = 5 * time.Minute
+)
+
+// CommandRunner runs commands and scripts for core once and streams their
+// output back line by line. Shell commands only run if allowed in the
+// agent's config; scripts are the predefined ones tasks run.
+type CommandRunner struct {
+	scripts       *ScriptExecutor
+	allowCommands bool
+	output        func(*protocol.CommandOutputPayload) error
+	exit          func(*protocol.CommandExitPayload) error
+	runs          sync.Map // runID -> context.CancelFunc
+}
+
+func NewCommandRunner(scripts *ScriptExecutor, allowCommands bool,
+	output func(*protocol.CommandOutputPayload) error,
+	exit func(*protocol.CommandExitPayload) error) *CommandRunner {
+	return &CommandRunner{
+		scripts:       scripts,
+		allowCommands: allowCommands,
+		output:        output,
+		exit:          exit,
+	}
+}
+
+// Run starts the command in the background. A run that is already going is
+// left alone.
+func (r *CommandRunner) Run(p *protocol.CommandRunPayload) {
+	timeout := defaultCommandTimeout
+	if p.Timeout > 0 {
+		timeout = time.Duration(p.Timeout) * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	if _, running := r.runs.LoadOrStore(p.RunID, cancel); running {
+		cancel()
+		return
+	}
+
+	go func() {
+		defer r.runs.Delete(p.RunID)
+		defer cancel()
+
+		start := time.Now()
+		code, err := r.run(ctx, p)
+		switch ctx.Err() {
+		case context.DeadlineExceeded:
+			code, err = -1, fmt.Errorf("timed out after %s", timeout)
+		case context.Canceled:
+			code, err = -1, errors.New("canceled")
+		}
+
+		result := &protocol.CommandExitPayload{
+			RunID:    p.RunID,
+			ExitCode: code,
+			Duration: time.Since(start).Milliseconds(),
+		}
+		if err != nil {
+			result.Error = err.Error()
+		}
+		if err := retrySend(func() error { return r.exit(result) }); err != nil {
+			log.Printf("Failed to send exit of command run %s: %v", p.RunID, err)
+		}
+	}()
+}
+
+// Cancel stops the run, killing the command, and reports whether it was
+// running.
+func (r *CommandRunner) Cancel(runID string) bool {
+	cancel, ok := r.runs.Load(runID)
+	if ok {
+		cancel.(context.CancelFunc)()
+	}
+	return ok
+}
+
+func (r *CommandRunner) run(ctx context.Context, p *protocol.CommandRunPayload) (int, error) {
+	var cmd *exec.Cmd
+	switch {
+	case p.ScriptID != "":
+		path, err := r.scripts.Fetch(p.ScriptID, p.Checksum)
+		if err != nil {
+			return -1, err
+		}
+		defer os.Remove(path)
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/c", path)
+		} else {
+			cmd = exec.CommandContext(ctx, "/bin/sh", path)
+		}
+	case !r.allowCommands:
+		return -1, errCommandsDisabled
+	case p.Command == "":
+		return -1, errors.New("no command given")
+	default:
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/c", p.Command)
+		} else {
+			cmd = exec.CommandContext(ctx, "/bin/sh", "-c", p.Command)
+		}
+	}
+
+	cmd.Env = os.Environ()
+	for k, v := range p.Params {
+		cmd.Env = append(cmd.Env, fmt.Sprintf("%s=%s", k, v))
+	}
+	killProcessGroup(cmd)
+
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return -1, err
+	}
+	stderr, err := cmd.StderrPipe()
+	if err != nil {
+		return -1, err
+	}
+	if err := cmd.Start(); err != nil {
+		return -1, err
+	}
+	r.sendOutput(&protocol.CommandOutputPayload{RunID: p.RunID})
+
+	lines := make(chan protocol.CommandLine, commandFlushLines)
+	var wg sync.WaitGroup
+	wg.Add(2)
+	go readLines(stdout, "stdout", lines, &wg)
+	go readLines(stderr, "stderr", lines, &wg)
+	go func() {
+		wg.Wait()
+		close(lines)
+	}()
+	r.stream(p.RunID, lines)
+
+	if err := cmd.Wait(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode(), nil
+		}
+		return -1, err
+	}
+	return 0, nil
+}
+
+// stream sends the lines in batches until the channel is closed.
+func (r *CommandRunner) stream(runID string, lines <-chan protocol.CommandLine) {
+	ticker := time.NewTicker(commandFlushInterval)
+	defer ticker.Stop()
+
+	var batch []protocol.CommandLine
+	flush := func() {
+		if len(batch) > 0 {
+			r.sendOutput(&protocol.CommandOutputPayload{RunID: runID, Lines: batch})
+			batch = nil
+		}
+	}
+	for {
+		select {
+		case line, ok := <-lines:
+			if !ok {
+				flush()
+				return
+			}
+			batch = append(batch, line)
+			if len(batch) >= commandFlushLines {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+func (r *CommandRunner) sendOutput(p *protocol.CommandOutputPayload) {
+	if err := retrySend(func() error { return r.output(p) }); err != nil {
+		log.Printf("Dropping %d output lines of command run %s: %v", len(p.Lines), p.RunID, err)
+	}
+}
+
+// retrySend gives a full send buffer a couple of seconds to drain.
+func retrySend(send func() error) error {
+	var err error
+	for i := 0; i < 20; i++ {
+		if err = send(); err == nil {
+			return nil
+		}
+		time.Sleep(100 * time.Millisecond)
+	}
+	return err
+}
+
+func readLines(rd io.Reader, stream string, lines chan<- protocol.CommandLine, wg *sync.WaitGroup) {
+	defer wg.Done()
+	reader := bufio.NewReaderSize(rd, maxCommandLine)
+	for {
+		line, _, err := reader.ReadLine()
+		if err != nil {
+			return
+		}
+		lines <- protocol.CommandLine{Stream: stream, Text: string(line)}
+	}
+}
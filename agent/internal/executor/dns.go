@@ -0,0 +1,235 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
+)
+
+// Ways a dns task's answers are checked against its "expected" values.
+const (
+	DNSMatchAll   = "all"   // every expected value is answered
+	DNSMatchAny   = "any"   // at least one expected value is answered
+	DNSMatchExact = "exact" // the answers are exactly the expected values
+)
+
+var ErrInvalidDNSCheck = errors.New("invalid dns check")
+
+// DNSExecutor runs dns tasks: it looks up a name for one record type and
+// checks the answers.
+type DNSExecutor struct {
+	timeout time.Duration
+}
+
+func NewDNSExecutor(timeout time.Duration) *DNSExecutor {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &DNSExecutor{timeout: timeout}
+}
+
+// Execute looks up name. The parameters are record_type (A, AAAA, CNAME, MX
+// or TXT, default A), resolver (host or host:port, default the system
+// resolver), expected (values separated by commas or newlines) and match.
+// The hosts file is still consulted when a resolver is given. The result is
+// returned whenever a lookup was made; the error then says why the check
+// failed.
+func (e *DNSExecutor) Execute(ctx context.Context, name string, params map[string]string, timeout time.Duration) (*protocol.DNSResult, error) {
+	recordType := strings.ToUpper(params["record_type"])
+	if recordType == "" {
+		recordType = "A"
+	}
+	match := strings.ToLower(params["match"])
+	if match == "" {
+		match = DNSMatchAll
+	}
+	if match != DNSMatchAll && match != DNSMatchAny && match != DNSMatchExact {
+		return nil, fmt.Errorf("%w: unknown match %q", ErrInvalidDNSCheck, params["match"])
+	}
+
+	resolver := net.DefaultResolver
+	server := strings.TrimSpace(params["resolver"])
+	if server != "" {
+		if _, _, err := net.SplitHostPort(server); err != nil {
+			server = net.JoinHostPort(strings.Trim(server, "[]"), "53")
+		}
+		resolver = &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+				var d net.Dialer
+				return d.DialContext(ctx, network, server)
+			},
+		}
+	}
+
+	if timeout <= 0 {
+		timeout = e.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	name = strings.TrimSuffix(strings.TrimSpace(name), ".")
+	result := &protocol.DNSResult{Name: name, RecordType: recordType, Resolver: server, Answers: []string{}}
+
+	start := time.Now()
+	answers, err := lookup(ctx, resolver, recordType, name)
+	result.ResolveMs = msSince(start)
+	if errors.Is(err, ErrInvalidDNSCheck) {
+		return nil, err
+	}
+	if err != nil {
+		// The error names the servers of resolv.conf, which were not asked.
+		var dnsErr *net.DNSError
+		if server != "" && errors.As(err, &dnsErr) {
+			dnsErr.Server = server
+		}
+		return result, err
+	}
+	sort.Strings(answers)
+	result.Answers = answers
+
+	if expected := splitExpected(params["expected"]); len(expected) > 0 {
+		if err := checkAnswers(recordType, answers, expected, match); err != nil {
+			return result, err
+		}
+	}
+	return result, nil
+}
+
+func lookup(ctx context.Context, resolver *net.Resolver, recordType, name string) ([]string, error) {
+	var answers []string
+	switch recordType {
+	case "A", "AAAA":
+		network := "ip4"
+		if recordType == "AAAA" {
+			network = "ip6"
+		}
+		ips, err := resolver.LookupIP(ctx, network, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, ip := range ips {
+			answers = append(answers, ip.String())
+		}
+	case "CNAME":
+		cname, err := resolver.LookupCNAME(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, strings.TrimSuffix(cname, "."))
+	case "MX":
+		mxs, err := resolver.LookupMX(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		for _, mx := range mxs {
+			answers = append(answers, fmt.Sprintf("%d %s", mx.Pref, strings.TrimSuffix(mx.Host, ".")))
+		}
+	case "TXT":
+		txts, err := resolver.LookupTXT(ctx, name)
+		if err != nil {
+			return nil, err
+		}
+		answers = append(answers, txts...)
+	default:
+		return nil, fmt.Errorf("%w: unsupported record type %q", ErrInvalidDNSCheck, recordType)
+	}
+	return answers, nil
+}
+
+func splitExpected(spec string) []string {
+	var values []string
+	for _, v := range strings.FieldsFunc(spec, func(r rune) bool { return r == ',' || r == '\n' }) {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
+
+func checkAnswers(recordType string, answers, expected []string, match string) error {
+	var missing []string
+	for _, want := range expected {
+		found := false
+		for _, got := range answers {
+			if answerMatches(recordType, got, want) {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, want)
+		}
+	}
+
+	switch match {
+	case DNSMatchAny:
+		if len(missing) == len(expected) {
+			return fmt.Errorf("none of %s answered", strings.Join(expected, ", "))
+		}
+	case DNSMatchExact:
+		var unexpected []string
+		for _, got := range answers {
+			found := false
+			for _, want := range expected {
+				if answerMatches(recordType, got, want) {
+					found = true
+					break
+				}
+			}
+			if !found {
+				unexpected = append(unexpected, got)
+			}
+		}
+		if len(unexpected) > 0 {
+			return fmt.Errorf("unexpected answers %s", strings.Join(unexpected, ", "))
+		}
+		fallthrough
+	default:
+		if len(missing) > 0 {
+			return fmt.Errorf("missing %s", strings.Join(missing, ", "))
+		}
+	}
+	return nil
+}
+
+// answerMatches compares an answer with an expected value. TXT values are
+// compared exactly, addresses in their canonical form and names without
+// case or the trailing dot. An MX value may leave out the preference.
+func answerMatches(recordType, answer, expected string) bool {
+	switch recordType {
+	case "TXT":
+		return answer == expected
+	case "A", "AAAA":
+		if ip := net.ParseIP(expected); ip != nil {
+			return ip.String() == answer
+		}
+		return false
+	}
+
+	expected = strings.ToLower(strings.TrimSuffix(expected, "."))
+	answer = strings.ToLower(answer)
+	if recordType == "MX" && !strings.Contains(expected, " ") {
+		_, host, _ := strings.Cut(answer, " ")
+		return host == expected
+	}
+	return answer == expected
+}
+
+func (e *DNSExecutor) FormatOutput(result *protocol.DNSResult) string {
+	resolver := result.Resolver
+	if resolver == "" {
+		resolver = "system resolver"
+	}
+	if len(result.Answers) == 0 {
+		return fmt.Sprintf("%s %s via %s: no answer after %.1f ms", result.Name, result.RecordType, resolver, result.ResolveMs)
+	}
+	return fmt.Sprintf("%s %s via %s: %s (%.1f ms)", result.Name, result.RecordType, resolver,
+		strings.Join(result.Answers, ", "), result.ResolveMs)
+}
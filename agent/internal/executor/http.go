@@ -0,0 +1,310 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptrace"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
+)
+
+// maxHTTPBody is how much of the response body is read for content checks.
+const maxHTTPBody = 1 << 20
+
+const defaultExpectedStatus = "200-399"
+
+// HTTPExecutor runs http tasks. Every run uses a fresh connection so the
+// timing breakdown always includes DNS, connect and TLS.
+type HTTPExecutor struct {
+	timeout time.Duration
+}
+
+func NewHTTPExecutor(timeout time.Duration) *HTTPExecutor {
+	if timeout <= 0 {
+		timeout = 30 * time.Second
+	}
+	return &HTTPExecutor{timeout: timeout}
+}
+
+// httpCheck is an http task's parameters, parsed.
+type httpCheck struct {
+	method       string
+	headers      http.Header
+	host         string
+	body         string
+	expected     []statusRange
+	expectedSpec string
+	keyword      string
+	pattern      *regexp.Regexp
+	follow       bool
+	maxRedirects int
+}
+
+func parseHTTPCheck(params map[string]string) (*httpCheck, error) {
+	check := &httpCheck{
+		method:       strings.ToUpper(params["method"]),
+		headers:      http.Header{},
+		body:         params["body"],
+		keyword:      params["keyword"],
+		follow:       true,
+		maxRedirects: 10,
+	}
+	if check.method == "" {
+		check.method = http.MethodGet
+	}
+
+	for _, line := range strings.Split(params["headers"], "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		name, value, ok := strings.Cut(line, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid header %q", line)
+		}
+		name, value = strings.TrimSpace(name), strings.TrimSpace(value)
+		if strings.EqualFold(name, "Host") {
+			check.host = value
+			continue
+		}
+		check.headers.Add(name, value)
+	}
+
+	spec := params["expected_status"]
+	if spec == "" {
+		spec = defaultExpectedStatus
+	}
+	expected, err := parseStatusRanges(spec)
+	if err != nil {
+		return nil, err
+	}
+	check.expected, check.expectedSpec = expected, spec
+
+	if params["regex"] != "" {
+		if check.pattern, err = regexp.Compile(params["regex"]); err != nil {
+			return nil, fmt.Errorf("invalid regex: %w", err)
+		}
+	}
+	if params["follow_redirects"] != "" {
+		if check.follow, err = strconv.ParseBool(params["follow_redirects"]); err != nil {
+			return nil, fmt.Errorf("invalid follow_redirects: %w", err)
+		}
+	}
+	if params["max_redirects"] != "" {
+		if check.maxRedirects, err = strconv.Atoi(params["max_redirects"]); err != nil || check.maxRedirects < 0 {
+			return nil, fmt.Errorf("invalid max_redirects %q", params["max_redirects"])
+		}
+	}
+	return check, nil
+}
+
+// statusRange is an inclusive range of status codes.
+type statusRange struct{ min, max int }
+
+// parseStatusRanges parses a comma-separated list of codes ("200"), ranges
+// ("200-299") and classes ("2xx").
+func parseStatusRanges(spec string) ([]statusRange, error) {
+	var ranges []statusRange
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		var r statusRange
+		var err1, err2 error
+		switch {
+		case len(part) == 3 && strings.HasSuffix(part, "xx"):
+			r.min, err1 = strconv.Atoi(part[:1])
+			r.min *= 100
+			r.max = r.min + 99
+		case strings.Contains(part, "-"):
+			lo, hi, _ := strings.Cut(part, "-")
+			r.min, err1 = strconv.Atoi(strings.TrimSpace(lo))
+			r.max, err2 = strconv.Atoi(strings.TrimSpace(hi))
+		default:
+			r.min, err1 = strconv.Atoi(part)
+			r.max = r.min
+		}
+		if err1 != nil || err2 != nil || r.min < 100 || r.max > 599 || r.min > r.max {
+			return nil, fmt.Errorf("invalid expected status %q", part)
+		}
+		ranges = append(ranges, r)
+	}
+	return ranges, nil
+}
+
+func statusExpected(ranges []statusRange, code int) bool {
+	for _, r := range ranges {
+		if code >= r.min && code <= r.max {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute requests target and checks the response. The result is returned
+// whenever a request was attempted; the error says why the check failed.
+func (e *HTTPExecutor) Execute(ctx context.Context, target string, params map[string]string, timeout time.Duration) (*protocol.HTTPResult, error) {
+	check, err := parseHTTPCheck(params)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = e.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	result := &protocol.HTTPResult{URL: target, Method: check.method}
+	timer := &httpTimer{}
+
+	var body io.Reader
+	if check.body != "" {
+		body = strings.NewReader(check.body)
+	}
+	req, err := http.NewRequestWithContext(httptrace.WithClientTrace(ctx, timer.trace()), check.method, target, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header = check.headers
+	if req.Header.Get("User-Agent") == "" {
+		req.Header.Set("User-Agent", "probe-agent")
+	}
+	if check.host != "" {
+		req.Host = check.host
+	}
+
+	client := &http.Client{
+		// No proxy: the probe measures the path from this agent.
+		Transport: &http.Transport{DisableKeepAlives: true},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if !check.follow {
+				return http.ErrUseLastResponse
+			}
+			if len(via) > check.maxRedirects {
+				return fmt.Errorf("stopped after %d redirects", check.maxRedirects)
+			}
+			result.Redirects = len(via)
+			return nil
+		},
+	}
+
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		result.TotalMs = msSince(start)
+		timer.fill(result)
+		return result, err
+	}
+	defer resp.Body.Close()
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxHTTPBody))
+	result.TotalMs = msSince(start)
+	timer.fill(result)
+	result.StatusCode = resp.StatusCode
+	result.BodyBytes = len(content)
+	if resp.Request.URL.String() != target {
+		result.FinalURL = resp.Request.URL.String()
+	}
+	if err != nil {
+		return result, fmt.Errorf("reading body: %w", err)
+	}
+
+	var failures []string
+	if !statusExpected(check.expected, resp.StatusCode) {
+		failures = append(failures, fmt.Sprintf("status %d not in %s", resp.StatusCode, check.expectedSpec))
+	}
+	if check.keyword != "" && !bytes.Contains(content, []byte(check.keyword)) {
+		failures = append(failures, fmt.Sprintf("keyword %q not found", check.keyword))
+	}
+	if check.pattern != nil && !check.pattern.Match(content) {
+		failures = append(failures, fmt.Sprintf("regex %q did not match", check.pattern))
+	}
+	if len(failures) > 0 {
+		return result, errors.New(strings.Join(failures, "; "))
+	}
+	return result, nil
+}
+
+func (e *HTTPExecutor) FormatOutput(result *protocol.HTTPResult) string {
+	if result.StatusCode == 0 {
+		return fmt.Sprintf("%s %s: no response after %.1f ms", result.Method, result.URL, result.TotalMs)
+	}
+	return fmt.Sprintf("%s %s: %d, %d bytes, dns %.1f ms, connect %.1f ms, tls %.1f ms, ttfb %.1f ms, total %.1f ms",
+		result.Method, result.URL, result.StatusCode, result.BodyBytes,
+		result.DNSMs, result.ConnectMs, result.TLSMs, result.TTFBMs, result.TotalMs)
+}
+
+// httpTimer records the phases of the latest request. A redirect starts a
+// new request, so the breakdown is that of the final one.
+type httpTimer struct {
+	mu           sync.Mutex
+	requestStart time.Time
+	dnsStart     time.Time
+	connectStart time.Time
+	tlsStart     time.Time
+	dns          float64
+	connect      float64
+	tls          float64
+	ttfb         float64
+}
+
+func (t *httpTimer) trace() *httptrace.ClientTrace {
+	// Connects may run in parallel when a name has several addresses, and a
+	// losing one can finish after the request.
+	record := func(f func()) {
+		t.mu.Lock()
+		defer t.mu.Unlock()
+		f()
+	}
+
+	return &httptrace.ClientTrace{
+		GetConn: func(string) {
+			record(func() {
+				t.requestStart = time.Now()
+				t.dns, t.connect, t.tls, t.ttfb = 0, 0, 0, 0
+			})
+		},
+		DNSStart: func(httptrace.DNSStartInfo) {
+			record(func() { t.dnsStart = time.Now() })
+		},
+		DNSDone: func(httptrace.DNSDoneInfo) {
+			record(func() { t.dns = msSince(t.dnsStart) })
+		},
+		ConnectStart: func(string, string) {
+			record(func() { t.connectStart = time.Now() })
+		},
+		ConnectDone: func(_, _ string, err error) {
+			if err == nil {
+				record(func() { t.connect = msSince(t.connectStart) })
+			}
+		},
+		TLSHandshakeStart: func() {
+			record(func() { t.tlsStart = time.Now() })
+		},
+		TLSHandshakeDone: func(tls.ConnectionState, error) {
+			record(func() { t.tls = msSince(t.tlsStart) })
+		},
+		GotFirstResponseByte: func() {
+			record(func() { t.ttfb = msSince(t.requestStart) })
+		},
+	}
+}
+
+// fill copies the recorded phases into result.
+func (t *httpTimer) fill(result *protocol.HTTPResult) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	result.DNSMs, result.ConnectMs, result.TLSMs, result.TTFBMs = t.dns, t.connect, t.tls, t.ttfb
+}
+
+func msSince(t time.Time) float64 {
+	return time.Since(t).Seconds() * 1000
+}
@@ -0,0 +1,163 @@
+package executor
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"strings"
+	"time"
+)
+
+const (
+	icmpEchoRequest   = 8
+	icmpEchoReply     = 0
+	icmpv6EchoRequest = 128
+	icmpv6EchoReply   = 129
+)
+
+var errICMPUnsupported = errors.New("unprivileged ICMP sockets are not supported on this platform")
+
+// icmpPinger sends ICMP echo requests over an unprivileged datagram socket.
+// The kernel picks the echo identifier, so replies are matched on the
+// sequence number and a random token in the payload instead.
+type icmpPinger struct {
+	conn    net.PacketConn
+	ip      net.IP
+	dst     net.Addr
+	v6      bool
+	token   []byte
+	timeout time.Duration
+}
+
+func newICMPPinger(ctx context.Context, target string, timeout time.Duration) (*icmpPinger, error) {
+	host := target
+	if h, _, err := net.SplitHostPort(target); err == nil {
+		host = h
+	}
+	host = strings.Trim(host, "[]")
+
+	addr, err := resolvePingTarget(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	v6 := addr.IP.To4() == nil
+	conn, err := listenICMP(v6)
+	if err != nil {
+		return nil, fmt.Errorf("icmp socket: %w", err)
+	}
+
+	token := make([]byte, 8)
+	if _, err := rand.Read(token); err != nil {
+		conn.Close()
+		return nil, err
+	}
+
+	return &icmpPinger{
+		conn:    conn,
+		ip:      addr.IP,
+		dst:     &net.UDPAddr{IP: addr.IP, Zone: addr.Zone},
+		v6:      v6,
+		token:   token,
+		timeout: timeout,
+	}, nil
+}
+
+// resolvePingTarget prefers an IPv4 address, as most hosts that answer ICMP
+// at all answer it over IPv4.
+func resolvePingTarget(ctx context.Context, host string) (*net.IPAddr, error) {
+	addrs, err := net.DefaultResolver.LookupIPAddr(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+	if len(addrs) == 0 {
+		return nil, fmt.Errorf("no addresses for %s", host)
+	}
+	for _, a := range addrs {
+		if a.IP.To4() != nil {
+			return &a, nil
+		}
+	}
+	return &addrs[0], nil
+}
+
+func (p *icmpPinger) Close() error {
+	return p.conn.Close()
+}
+
+// ping sends one echo request and returns the round trip in milliseconds.
+func (p *icmpPinger) ping(seq int) (float64, error) {
+	reply := byte(icmpEchoReply)
+	if p.v6 {
+		reply = icmpv6EchoReply
+	}
+	packet := icmpEcho(p.v6, seq, p.token)
+
+	start := time.Now()
+	if err := p.conn.SetDeadline(start.Add(p.timeout)); err != nil {
+		return 0, err
+	}
+	if _, err := p.conn.WriteTo(packet, p.dst); err != nil {
+		return 0, err
+	}
+
+	buf := make([]byte, 1500)
+	for {
+		n, _, err := p.conn.ReadFrom(buf)
+		if errors.Is(err, os.ErrDeadlineExceeded) {
+			return 0, fmt.Errorf("no reply within %s", p.timeout)
+		}
+		if err != nil {
+			return 0, err
+		}
+		msg := buf[:n]
+		// macOS hands IPv4 replies over with their IP header.
+		if !p.v6 && len(msg) >= 20 && msg[0]>>4 == 4 {
+			msg = msg[int(msg[0]&0x0f)*4:]
+		}
+
+		if len(msg) < len(packet) || msg[0] != reply ||
+			binary.BigEndian.Uint16(msg[6:]) != uint16(seq) ||
+			!bytes.Equal(msg[8:len(packet)], p.token) {
+			continue
+		}
+		return time.Since(start).Seconds() * 1000, nil
+	}
+}
+
+// icmpEcho builds an echo request carrying payload. The identifier is left
+// for the kernel to fill in.
+func icmpEcho(v6 bool, seq int, payload []byte) []byte {
+	packet := make([]byte, 8+len(payload))
+	packet[0] = icmpEchoRequest
+	if v6 {
+		packet[0] = icmpv6EchoRequest
+	}
+	binary.BigEndian.PutUint16(packet[6:], uint16(seq))
+	copy(packet[8:], payload)
+	// The kernel fills in the ICMPv6 checksum itself.
+	if !v6 {
+		binary.BigEndian.PutUint16(packet[2:], icmpChecksum(packet))
+	}
+	return packet
+}
+
+// icmpChecksum is the Internet checksum of RFC 1071.
+func icmpChecksum(b []byte) uint16 {
+	var sum uint32
+	for i := 0; i+1 < len(b); i += 2 {
+		sum += uint32(b[i])<<8 | uint32(b[i+1])
+	}
+	if len(b)%2 == 1 {
+		sum += uint32(b[len(b)-1]) << 8
+	}
+	for sum>>16 != 0 {
+		sum = sum&0xffff + sum>>16
+	}
+	return ^uint16(sum)
+}
@@ -0,0 +1,9 @@
+//go:build !linux && !darwin
+
+package executor
+
+import "net"
+
+func listenICMP(v6 bool) (net.PacketConn, error) {
+	return nil, errICMPUnsupported
+}
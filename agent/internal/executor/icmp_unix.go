@@ -0,0 +1,27 @@
+//go:build linux || darwin
+
+package executor
+
+import (
+	"net"
+	"os"
+	"syscall"
+)
+
+// listenICMP opens an ICMP datagram socket, which Linux allows for groups in
+// net.ipv4.ping_group_range and macOS allows for everyone.
+func listenICMP(v6 bool) (net.PacketConn, error) {
+	family, proto := syscall.AF_INET, syscall.IPPROTO_ICMP
+	if v6 {
+		family, proto = syscall.AF_INET6, syscall.IPPROTO_ICMPV6
+	}
+
+	fd, err := syscall.Socket(family, syscall.SOCK_DGRAM, proto)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	f := os.NewFile(uintptr(fd), "icmp")
+	defer f.Close()
+	return net.FilePacketConn(f)
+}
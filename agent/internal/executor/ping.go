@@ -2,18 +2,25 @@ package executor
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
 	"net"
+	"strings"
 	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
 )
 
-type PingResult struct {
-	Target     string  `json:"target"`
-	Latency    float64 `json:"latency"`
-	PacketLoss float64 `json:"packet_loss"`
-	Success    bool    `json:"success"`
-	Error      string  `json:"error,omitempty"`
-}
+// Ping modes, chosen per task with the "mode" parameter. Auto uses ICMP when
+// the kernel allows unprivileged ICMP sockets and TCP otherwise.
+const (
+	PingModeAuto = "auto"
+	PingModeICMP = "icmp"
+	PingModeTCP  = "tcp"
+)
+
+var ErrInvalidPingMode = errors.New("invalid ping mode")
 
 type PingExecutor struct {
 	timeout time.Duration
@@ -33,74 +40,130 @@ func NewPingExecutor(timeout time.Duration, count int) *PingExecutor {
 	}
 }
 
-func (e *PingExecutor) Execute(ctx context.Context, target string) (*PingResult, error) {
-	result := &PingResult{
-		Target: target,
+// Execute pings target count times. The result is returned even when an
+// error is, as long as probes were sent; the error is then why none were
+// answered.
+func (e *PingExecutor) Execute(ctx context.Context, target, mode string) (*protocol.PingResult, error) {
+	result := &protocol.PingResult{Target: target}
+
+	var probe func(seq int) (float64, error)
+	switch mode {
+	case "", PingModeAuto, PingModeICMP:
+		pinger, err := newICMPPinger(ctx, target, e.timeout)
+		if err != nil {
+			if mode == PingModeICMP {
+				return nil, err
+			}
+			break
+		}
+		defer pinger.Close()
+		// Unblock a probe waiting for its reply when the task is cancelled.
+		stop := context.AfterFunc(ctx, func() { pinger.Close() })
+		defer stop()
+
+		result.Mode = PingModeICMP
+		result.Address = pinger.ip.String()
+		probe = pinger.ping
+	case PingModeTCP:
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrInvalidPingMode, mode)
 	}
 
-	// Use TCP connection as a simple ping alternative (works without root)
-	var successCount int
-	var totalLatency float64
+	if probe == nil {
+		result.Mode = PingModeTCP
+		probe = func(int) (float64, error) { return e.tcpPing(ctx, target) }
+	}
 
+	var rtts []float64
+	var lastErr error
 	for i := 0; i < e.count; i++ {
-		select {
-		case <-ctx.Done():
-			result.Error = "context cancelled"
-			return result, ctx.Err()
-		default:
+		if err := ctx.Err(); err != nil {
+			return result, err
 		}
 
-		latency, err := e.tcpPing(target)
-		if err == nil {
-			successCount++
-			totalLatency += latency
+		result.Sent++
+		rtt, err := probe(i)
+		if err != nil {
+			lastErr = err
+		} else {
+			rtts = append(rtts, rtt)
 		}
 
 		if i < e.count-1 {
-			time.Sleep(200 * time.Millisecond)
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			case <-time.After(200 * time.Millisecond):
+			}
 		}
 	}
 
-	if successCount > 0 {
-		result.Success = true
-		result.Latency = totalLatency / float64(successCount)
-	} else {
-		result.Success = false
-		result.Latency = -1
-		result.Error = "all pings failed"
+	summarizePing(result, rtts)
+	if result.Received == 0 {
+		return result, fmt.Errorf("all pings failed: %v", lastErr)
 	}
+	return result, nil
+}
 
-	result.PacketLoss = float64(e.count-successCount) / float64(e.count) * 100
+// summarizePing fills in the loss and latency statistics from the round trips
+// of the answered probes, in the order they were sent.
+func summarizePing(result *protocol.PingResult, rtts []float64) {
+	result.Received = len(rtts)
+	if result.Sent > 0 {
+		result.PacketLoss = float64(result.Sent-result.Received) / float64(result.Sent) * 100
+	}
+	if len(rtts) == 0 {
+		return
+	}
 
-	return result, nil
+	result.Min, result.Max = rtts[0], rtts[0]
+	var sum, jitter float64
+	for i, rtt := range rtts {
+		sum += rtt
+		result.Min = math.Min(result.Min, rtt)
+		result.Max = math.Max(result.Max, rtt)
+		if i > 0 {
+			jitter += math.Abs(rtt - rtts[i-1])
+		}
+	}
+	result.Avg = sum / float64(len(rtts))
+
+	var variance float64
+	for _, rtt := range rtts {
+		variance += (rtt - result.Avg) * (rtt - result.Avg)
+	}
+	result.StdDev = math.Sqrt(variance / float64(len(rtts)))
+	if len(rtts) > 1 {
+		result.Jitter = jitter / float64(len(rtts)-1)
+	}
 }
 
-func (e *PingExecutor) tcpPing(target string) (float64, error) {
-	// Add default port if not specified
+// tcpPing measures how long a TCP connect takes. Port 80 is used unless the
+// target names one.
+func (e *PingExecutor) tcpPing(ctx context.Context, target string) (float64, error) {
 	host, port, err := net.SplitHostPort(target)
 	if err != nil {
-		// No port specified, try common ports
-		host = target
+		host = strings.Trim(target, "[]")
 		port = "80"
 	}
 
-	addr := net.JoinHostPort(host, port)
-
+	dialer := net.Dialer{Timeout: e.timeout}
 	start := time.Now()
-	conn, err := net.DialTimeout("tcp", addr, e.timeout)
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
 	if err != nil {
 		return 0, err
 	}
 	defer conn.Close()
 
-	latency := time.Since(start).Seconds() * 1000 // ms
-	return latency, nil
+	return time.Since(start).Seconds() * 1000, nil
 }
 
-func (e *PingExecutor) FormatOutput(result *PingResult) string {
-	if result.Success {
-		return fmt.Sprintf("PING %s: latency=%.2fms, packet_loss=%.1f%%",
-			result.Target, result.Latency, result.PacketLoss)
+func (e *PingExecutor) FormatOutput(result *protocol.PingResult) string {
+	if result.Received == 0 {
+		return fmt.Sprintf("PING %s (%s): %d sent, 0 received, 100%% loss",
+			result.Target, result.Mode, result.Sent)
 	}
-	return fmt.Sprintf("PING %s: FAILED - %s", result.Target, result.Error)
+	return fmt.Sprintf("PING %s (%s): %d sent, %d received, %.1f%% loss, min/avg/max/stddev = %.2f/%.2f/%.2f/%.2f ms, jitter %.2f ms",
+		result.Target, result.Mode, result.Sent, result.Received, result.PacketLoss,
+		result.Min, result.Avg, result.Max, result.StdDev, result.Jitter)
 }
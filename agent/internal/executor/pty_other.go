@@ -0,0 +1,20 @@
+//go:build !linux
+
+package executor
+
+import (
+	"os"
+	"os/exec"
+)
+
+func startPTY(cmd *exec.Cmd, cols, rows int) (*os.File, error) {
+	return nil, errTerminalUnsupported
+}
+
+func setTerminalSize(pty *os.File, cols, rows int) error {
+	return nil
+}
+
+func hangUp(cmd *exec.Cmd) {}
+
+func killTerminal(cmd *exec.Cmd) {}
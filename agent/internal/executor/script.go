@@ -130,6 +130,30 @@ func (e *ScriptExecutor) Execute(ctx context.Context, scriptID string, params ma
 	return result, nil
 }
 
+// Fetch downloads the script and checks it against the expected checksum,
+// if one is given. The caller removes the returned file.
+func (e *ScriptExecutor) Fetch(scriptID, expectedChecksum string) (string, error) {
+	scriptPath, err := e.downloadScript(scriptID)
+	if err != nil {
+		return "", fmt.Errorf("download failed: %w", err)
+	}
+
+	if expectedChecksum != "" {
+		actualChecksum, err := e.computeChecksum(scriptPath)
+		if err != nil {
+			os.Remove(scriptPath)
+			return "", fmt.Errorf("checksum computation failed: %w", err)
+		}
+		if actualChecksum != expectedChecksum {
+			os.Remove(scriptPath)
+			return "", fmt.Errorf("checksum mismatch: expected %s, got %s", expectedChecksum, actualChecksum)
+		}
+	}
+
+	os.Chmod(scriptPath, 0755)
+	return scriptPath, nil
+}
+
 func (e *ScriptExecutor) downloadScript(scriptID string) (string, error) {
 	url := fmt.Sprintf("%s/api/scripts/%s/content", e.coreURL, scriptID)
 
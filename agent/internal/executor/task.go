@@ -2,16 +2,22 @@ package executor
 
 import (
 	"context"
-	"encoding/json"
+	"fmt"
 	"log"
+	"math/rand"
 	"sync"
 	"time"
 
+	"github.com/probe-system/agent/pkg/cron"
 	"github.com/probe-system/agent/pkg/protocol"
 )
 
 type TaskManager struct {
 	pingExecutor   *PingExecutor
+	httpExecutor   *HTTPExecutor
+	tlsExecutor    *TLSExecutor
+	dnsExecutor    *DNSExecutor
+	traceExecutor  *TracerouteExecutor
 	scriptExecutor *ScriptExecutor
 	tasks          sync.Map // taskID -> *RunningTask
 	resultChan     chan *protocol.TaskResultPayload
@@ -28,6 +34,10 @@ type RunningTask struct {
 func NewTaskManager(coreURL, scriptDir string) *TaskManager {
 	return &TaskManager{
 		pingExecutor:   NewPingExecutor(5*time.Second, 4),
+		httpExecutor:   NewHTTPExecutor(30 * time.Second),
+		tlsExecutor:    NewTLSExecutor(10 * time.Second),
+		dnsExecutor:    NewDNSExecutor(10 * time.Second),
+		traceExecutor:  NewTracerouteExecutor(2 * time.Second),
 		scriptExecutor: NewScriptExecutor(coreURL, scriptDir, 60*time.Second),
 		resultChan:     make(chan *protocol.TaskResultPayload, 100),
 		stopChan:       make(chan struct{}),
@@ -38,7 +48,30 @@ func (m *TaskManager) GetResultChan() <-chan *protocol.TaskResultPayload {
 	return m.resultChan
 }
 
-func (m *TaskManager) HandleTask(task *protocol.TaskAssignPayload) {
+// taskTypes are the task types runTask executes.
+var taskTypes = map[string]bool{
+	"ping":       true,
+	"http":       true,
+	"tls":        true,
+	"dns":        true,
+	"traceroute": true,
+	"script":     true,
+}
+
+// HandleTask starts the task, replacing a running task with the same ID. It
+// returns an error without starting anything if the task cannot run here.
+func (m *TaskManager) HandleTask(task *protocol.TaskAssignPayload) error {
+	if !taskTypes[task.Type] {
+		return fmt.Errorf("unknown task type %q", task.Type)
+	}
+	var schedule *cron.Schedule
+	if task.Schedule != "" {
+		var err error
+		if schedule, err = cron.Parse(task.Schedule, task.Timezone); err != nil {
+			return err
+		}
+	}
+
 	// Cancel existing task with same ID
 	if existing, ok := m.tasks.Load(task.TaskID); ok {
 		rt := existing.(*RunningTask)
@@ -55,16 +88,22 @@ func (m *TaskManager) HandleTask(task *protocol.TaskAssignPayload) {
 	}
 	m.tasks.Store(task.TaskID, rt)
 
-	go m.runTask(ctx, task)
+	go m.runTask(ctx, task, schedule)
+	return nil
 }
 
-func (m *TaskManager) runTask(ctx context.Context, task *protocol.TaskAssignPayload) {
+func (m *TaskManager) runTask(ctx context.Context, task *protocol.TaskAssignPayload, schedule *cron.Schedule) {
+	recurring := task.Interval > 0 || schedule != nil
+
 	defer func() {
-		if task.Interval <= 0 {
+		if !recurring {
 			m.tasks.Delete(task.TaskID)
 		}
 	}()
 
+	// Scheduled runs are counted from the previous slot rather than the end
+	// of the previous run, so jitter does not push later runs back.
+	slot := time.Now()
 	for {
 		select {
 		case <-ctx.Done():
@@ -74,6 +113,16 @@ func (m *TaskManager) runTask(ctx context.Context, task *protocol.TaskAssignPayl
 		default:
 		}
 
+		if schedule != nil {
+			if slot = nextSlot(schedule, slot); slot.IsZero() {
+				log.Printf("Schedule %q of task %s matches no future time", task.Schedule, task.TaskID)
+				return
+			}
+			if !m.sleepUntil(ctx, slot.Add(jitter(task.Jitter))) {
+				return
+			}
+		}
+
 		start := time.Now()
 		result := &protocol.TaskResultPayload{
 			TaskID: task.TaskID,
@@ -81,17 +130,58 @@ func (m *TaskManager) runTask(ctx context.Context, task *protocol.TaskAssignPayl
 
 		switch task.Type {
 		case "ping":
-			pingResult, err := m.pingExecutor.Execute(ctx, task.Target)
+			pingResult, err := m.pingExecutor.Execute(ctx, task.Target, task.Params["mode"])
+			if pingResult != nil {
+				result.Ping = pingResult
+				result.Output = m.pingExecutor.FormatOutput(pingResult)
+			}
+			result.Success = err == nil
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+		case "http":
+			httpResult, err := m.httpExecutor.Execute(ctx, task.Target, task.Params, time.Duration(task.Timeout)*time.Second)
+			if httpResult != nil {
+				result.HTTP = httpResult
+				result.Output = m.httpExecutor.FormatOutput(httpResult)
+			}
+			result.Success = err == nil
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+		case "tls":
+			tlsResult, err := m.tlsExecutor.Execute(ctx, task.Target, task.Params, time.Duration(task.Timeout)*time.Second)
+			if tlsResult != nil {
+				result.TLS = tlsResult
+				result.Output = m.tlsExecutor.FormatOutput(tlsResult)
+			}
+			result.Success = err == nil
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+		case "dns":
+			dnsResult, err := m.dnsExecutor.Execute(ctx, task.Target, task.Params, time.Duration(task.Timeout)*time.Second)
+			if dnsResult != nil {
+				result.DNS = dnsResult
+				result.Output = m.dnsExecutor.FormatOutput(dnsResult)
+			}
+			result.Success = err == nil
+			if err != nil {
+				result.Error = err.Error()
+			}
+
+		case "traceroute":
+			traceResult, err := m.traceExecutor.Execute(ctx, task.Target, task.Params, time.Duration(task.Timeout)*time.Second)
+			if traceResult != nil {
+				result.Traceroute = traceResult
+				result.Output = m.traceExecutor.FormatOutput(traceResult)
+			}
+			result.Success = err == nil
 			if err != nil {
-				result.Success = false
 				result.Error = err.Error()
-			} else {
-				result.Success = pingResult.Success
-				output, _ := json.Marshal(pingResult)
-				result.Output = string(output)
-				if !pingResult.Success {
-					result.Error = pingResult.Error
-				}
 			}
 
 		case "script":
@@ -117,17 +207,15 @@ func (m *TaskManager) runTask(ctx context.Context, task *protocol.TaskAssignPayl
 		}
 
 		result.Duration = time.Since(start).Milliseconds()
-
-		select {
-		case m.resultChan <- result:
-		default:
-			log.Printf("Result channel full, dropping result for task %s", task.TaskID)
-		}
+		m.sendResult(result)
 
 		// If not recurring, exit
-		if task.Interval <= 0 {
+		if !recurring {
 			return
 		}
+		if schedule != nil {
+			continue
+		}
 
 		// Wait for next interval
 		select {
@@ -140,12 +228,63 @@ func (m *TaskManager) runTask(ctx context.Context, task *protocol.TaskAssignPayl
 	}
 }
 
-func (m *TaskManager) CancelTask(taskID string) {
-	if existing, ok := m.tasks.Load(taskID); ok {
-		rt := existing.(*RunningTask)
-		rt.Cancel()
-		m.tasks.Delete(taskID)
+func (m *TaskManager) sendResult(result *protocol.TaskResultPayload) {
+	select {
+	case m.resultChan <- result:
+	default:
+		log.Printf("Result channel full, dropping result for task %s", result.TaskID)
+	}
+}
+
+// nextSlot returns the schedule's first time after the previous slot. Slots
+// missed while a run took longer are skipped.
+func nextSlot(schedule *cron.Schedule, prev time.Time) time.Time {
+	next := schedule.Next(prev)
+	if !next.IsZero() && next.Before(time.Now()) {
+		next = schedule.Next(time.Now())
+	}
+	return next
+}
+
+func jitter(seconds int) time.Duration {
+	if seconds <= 0 {
+		return 0
+	}
+	return time.Duration(rand.Int63n(int64(seconds) * int64(time.Second)))
+}
+
+// sleepUntil waits for the wall clock to reach t, checking it every minute
+// so a clock adjustment or a suspended host does not shift the run. It
+// returns false if the task was stopped first.
+func (m *TaskManager) sleepUntil(ctx context.Context, t time.Time) bool {
+	for {
+		wait := time.Until(t)
+		if wait <= 0 {
+			return true
+		}
+		if wait > time.Minute {
+			wait = time.Minute
+		}
+		timer := time.NewTimer(wait)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return false
+		case <-m.stopChan:
+			timer.Stop()
+			return false
+		case <-timer.C:
+		}
+	}
+}
+
+// CancelTask stops the task and reports whether it was running.
+func (m *TaskManager) CancelTask(taskID string) bool {
+	existing, ok := m.tasks.LoadAndDelete(taskID)
+	if ok {
+		existing.(*RunningTask).Cancel()
 	}
+	return ok
 }
 
 func (m *TaskManager) Stop() {
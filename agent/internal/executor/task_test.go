@@ -0,0 +1,70 @@
+package executor
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
+)
+
+func TestTaskManagerDispatch(t *testing.T) {
+	var downloads atomic.Int32
+	core := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		downloads.Add(1)
+		if r.URL.Path != "/api/scripts/s1/content" {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprintln(w, "echo from-script")
+	}))
+	defer core.Close()
+
+	m := NewTaskManager(core.URL, t.TempDir())
+	defer m.Stop()
+
+	run := func(task *protocol.TaskAssignPayload) *protocol.TaskResultPayload {
+		t.Helper()
+		if err := m.HandleTask(task); err != nil {
+			t.Fatalf("HandleTask(%s): %v", task.Type, err)
+		}
+		select {
+		case result := <-m.GetResultChan():
+			return result
+		case <-time.After(10 * time.Second):
+			t.Fatalf("no result for %s task", task.Type)
+			return nil
+		}
+	}
+
+	// The protocol is rejected before any probe is sent, so the result is
+	// the traceroute executor's own error.
+	trace := run(&protocol.TaskAssignPayload{
+		TaskID: "trace",
+		Type:   "traceroute",
+		Target: "127.0.0.1",
+		Params: map[string]string{"protocol": "bogus"},
+	})
+	if trace.Success || !strings.Contains(trace.Error, "invalid traceroute") {
+		t.Errorf("traceroute result = success %v, error %q; want the traceroute error", trace.Success, trace.Error)
+	}
+	if n := downloads.Load(); n != 0 {
+		t.Errorf("traceroute task downloaded %d scripts", n)
+	}
+
+	script := run(&protocol.TaskAssignPayload{
+		TaskID:   "script",
+		Type:     "script",
+		ScriptID: "s1",
+	})
+	if !script.Success || script.Output != "from-script\n" || script.Traceroute != nil {
+		t.Errorf("script result = success %v, output %q, error %q; want the script's output", script.Success, script.Output, script.Error)
+	}
+	if n := downloads.Load(); n != 1 {
+		t.Errorf("script task downloaded %d scripts, want 1", n)
+	}
+}
@@ -0,0 +1,294 @@
+package executor
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
+)
+
+var (
+	errTerminalDisabled    = errors.New("the terminal is disabled on this agent")
+	errTerminalUnsupported = errors.New("terminals are not supported on this platform")
+)
+
+const (
+	terminalReadBuffer = 16 * 1024
+	// terminalInputBuffer is how many input messages may wait for the shell
+	// before more are dropped.
+	terminalInputBuffer = 256
+	// terminalKillGrace is how long a hung up shell has to exit before it
+	// is killed.
+	terminalKillGrace = 5 * time.Second
+	// terminalDrain is how long output is still read after the shell exited;
+	// background jobs may keep the terminal open.
+	terminalDrain = time.Second
+)
+
+// TerminalManager runs interactive shells for core, each on its own
+// pseudo-terminal, and relays their input and output.
+type TerminalManager struct {
+	enabled bool
+	output  func(*protocol.TerminalDataPayload) error
+	exit    func(*protocol.TerminalExitPayload) error
+
+	mu       sync.Mutex
+	sessions map[string]*terminalSession
+}
+
+type terminalSession struct {
+	id    string
+	pty   *os.File
+	cmd   *exec.Cmd
+	input chan []byte
+	stop  chan struct{}
+	done  chan struct{}
+
+	mu     sync.Mutex
+	reason string
+}
+
+func NewTerminalManager(enabled bool,
+	output func(*protocol.TerminalDataPayload) error,
+	exit func(*protocol.TerminalExitPayload) error) *TerminalManager {
+	return &TerminalManager{
+		enabled:  enabled,
+		output:   output,
+		exit:     exit,
+		sessions: make(map[string]*terminalSession),
+	}
+}
+
+// Open starts a login shell for the session. A session that is already
+// open is left alone.
+func (m *TerminalManager) Open(p *protocol.TerminalOpenPayload) {
+	if !m.enabled {
+		m.sendExit(&protocol.TerminalExitPayload{SessionID: p.SessionID, ExitCode: -1, Error: errTerminalDisabled.Error()})
+		return
+	}
+
+	m.mu.Lock()
+	if _, ok := m.sessions[p.SessionID]; ok {
+		m.mu.Unlock()
+		return
+	}
+	cmd := shellCommand()
+	pty, err := startPTY(cmd, terminalSize(p.Cols, 80), terminalSize(p.Rows, 24))
+	if err != nil {
+		m.mu.Unlock()
+		m.sendExit(&protocol.TerminalExitPayload{SessionID: p.SessionID, ExitCode: -1, Error: err.Error()})
+		return
+	}
+	s := &terminalSession{
+		id:    p.SessionID,
+		pty:   pty,
+		cmd:   cmd,
+		input: make(chan []byte, terminalInputBuffer),
+		stop:  make(chan struct{}),
+		done:  make(chan struct{}),
+	}
+	m.sessions[s.id] = s
+	m.mu.Unlock()
+
+	go m.run(s, time.Duration(p.IdleTimeout)*time.Second)
+}
+
+func (m *TerminalManager) Input(p *protocol.TerminalDataPayload) {
+	s := m.get(p.SessionID)
+	if s == nil {
+		return
+	}
+	select {
+	case s.input <- p.Data:
+	default:
+		log.Printf("Dropping input for terminal %s: the shell is not reading it", s.id)
+	}
+}
+
+func (m *TerminalManager) Resize(p *protocol.TerminalResizePayload) {
+	s := m.get(p.SessionID)
+	if s == nil {
+		return
+	}
+	if err := setTerminalSize(s.pty, terminalSize(p.Cols, 80), terminalSize(p.Rows, 24)); err != nil {
+		log.Printf("Failed to resize terminal %s: %v", s.id, err)
+	}
+}
+
+// Close hangs up the session's shell and reports whether it was open.
+func (m *TerminalManager) Close(sessionID, reason string) bool {
+	s := m.get(sessionID)
+	if s != nil {
+		s.close(reason)
+	}
+	return s != nil
+}
+
+// CloseAll hangs up every session, e.g. once core can no longer see them.
+func (m *TerminalManager) CloseAll(reason string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for _, s := range m.sessions {
+		s.close(reason)
+	}
+}
+
+func (m *TerminalManager) get(sessionID string) *terminalSession {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.sessions[sessionID]
+}
+
+func (m *TerminalManager) run(s *terminalSession, idle time.Duration) {
+	defer func() {
+		m.mu.Lock()
+		delete(m.sessions, s.id)
+		m.mu.Unlock()
+	}()
+	defer close(s.done)
+
+	m.sendOutput(&protocol.TerminalDataPayload{SessionID: s.id})
+	go s.writeInput(idle)
+	go s.hangUpOnStop()
+
+	read := make(chan struct{})
+	go func() {
+		defer close(read)
+		buf := make([]byte, terminalReadBuffer)
+		for {
+			n, err := s.pty.Read(buf)
+			if n > 0 {
+				m.sendOutput(&protocol.TerminalDataPayload{SessionID: s.id, Data: append([]byte(nil), buf[:n]...)})
+			}
+			if err != nil {
+				return
+			}
+		}
+	}()
+
+	waitErr := s.cmd.Wait()
+	select {
+	case <-read:
+	case <-time.After(terminalDrain):
+	}
+	s.pty.Close()
+	<-read
+
+	result := &protocol.TerminalExitPayload{SessionID: s.id}
+	var exitErr *exec.ExitError
+	switch {
+	case s.closeReason() != "":
+		result.ExitCode, result.Error = -1, s.closeReason()
+	case errors.As(waitErr, &exitErr):
+		result.ExitCode = exitErr.ExitCode()
+	case waitErr != nil:
+		result.ExitCode, result.Error = -1, waitErr.Error()
+	}
+	m.sendExit(result)
+}
+
+func (m *TerminalManager) sendOutput(p *protocol.TerminalDataPayload) {
+	if err := retrySend(func() error { return m.output(p) }); err != nil {
+		log.Printf("Dropping %d bytes of output of terminal %s: %v", len(p.Data), p.SessionID, err)
+	}
+}
+
+func (m *TerminalManager) sendExit(p *protocol.TerminalExitPayload) {
+	if err := retrySend(func() error { return m.exit(p) }); err != nil {
+		log.Printf("Failed to send exit of terminal %s: %v", p.SessionID, err)
+	}
+}
+
+// writeInput passes input to the shell and closes the session after idle
+// without any.
+func (s *terminalSession) writeInput(idle time.Duration) {
+	var timeout <-chan time.Time
+	var timer *time.Timer
+	if idle > 0 {
+		timer = time.NewTimer(idle)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	for {
+		select {
+		case data := <-s.input:
+			if _, err := s.pty.Write(data); err != nil {
+				return
+			}
+			if timer != nil {
+				if !timer.Stop() {
+					<-timer.C
+				}
+				timer.Reset(idle)
+			}
+		case <-timeout:
+			s.close(fmt.Sprintf("closed after %s without input", idle))
+			return
+		case <-s.done:
+			return
+		}
+	}
+}
+
+// hangUpOnStop hangs up the shell once the session is closed, and kills it
+// and everything it started if it does not exit.
+func (s *terminalSession) hangUpOnStop() {
+	select {
+	case <-s.stop:
+	case <-s.done:
+		return
+	}
+	hangUp(s.cmd)
+	select {
+	case <-s.done:
+	case <-time.After(terminalKillGrace):
+		killTerminal(s.cmd)
+	}
+}
+
+func (s *terminalSession) close(reason string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.reason == "" {
+		s.reason = reason
+		close(s.stop)
+	}
+}
+
+func (s *terminalSession) closeReason() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.reason
+}
+
+// shellCommand is the user's login shell, started in their home directory.
+func shellCommand() *exec.Cmd {
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+		if _, err := os.Stat("/bin/bash"); err == nil {
+			shell = "/bin/bash"
+		}
+	}
+	cmd := exec.Command(shell, "-l")
+	cmd.Env = append(os.Environ(), "TERM=xterm-256color")
+	if home, err := os.UserHomeDir(); err == nil {
+		if info, err := os.Stat(home); err == nil && info.IsDir() {
+			cmd.Dir = home
+		}
+	}
+	return cmd
+}
+
+func terminalSize(n, fallback int) int {
+	if n <= 0 || n > 1000 {
+		return fallback
+	}
+	return n
+}
@@ -0,0 +1,112 @@
+package executor
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
+)
+
+// TLSExecutor runs tls tasks: it completes a handshake with host:port and
+// reports the certificate chain the server presented.
+type TLSExecutor struct {
+	timeout time.Duration
+}
+
+func NewTLSExecutor(timeout time.Duration) *TLSExecutor {
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return &TLSExecutor{timeout: timeout}
+}
+
+// Execute connects to target, port 443 unless it names one. The "sni"
+// parameter overrides the server name, which defaults to the target host.
+// The chain is reported even when it does not verify; the error then says
+// why.
+func (e *TLSExecutor) Execute(ctx context.Context, target string, params map[string]string, timeout time.Duration) (*protocol.TLSResult, error) {
+	host, port, err := net.SplitHostPort(target)
+	if err != nil {
+		host, port = strings.Trim(target, "[]"), "443"
+	}
+	serverName := params["sni"]
+	if serverName == "" && net.ParseIP(host) == nil {
+		serverName = host
+	}
+
+	if timeout <= 0 {
+		timeout = e.timeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	// Verification is done below, so a chain that fails it is still
+	// reported.
+	dialer := &tls.Dialer{Config: &tls.Config{ServerName: serverName, InsecureSkipVerify: true}}
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, port))
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	state := conn.(*tls.Conn).ConnectionState()
+	if len(state.PeerCertificates) == 0 {
+		return nil, fmt.Errorf("server presented no certificate")
+	}
+
+	leaf := state.PeerCertificates[0]
+	result := &protocol.TLSResult{
+		Target:        target,
+		ServerName:    serverName,
+		Version:       tls.VersionName(state.Version),
+		NotAfter:      leaf.NotAfter,
+		DaysRemaining: time.Until(leaf.NotAfter).Hours() / 24,
+	}
+	for _, cert := range state.PeerCertificates {
+		result.Chain = append(result.Chain, describeCertificate(cert))
+	}
+
+	intermediates := x509.NewCertPool()
+	for _, cert := range state.PeerCertificates[1:] {
+		intermediates.AddCert(cert)
+	}
+	name := serverName
+	if name == "" {
+		name = host
+	}
+	if _, err := leaf.Verify(x509.VerifyOptions{DNSName: name, Intermediates: intermediates}); err != nil {
+		result.VerifyError = err.Error()
+		return result, err
+	}
+	result.Verified = true
+	return result, nil
+}
+
+func describeCertificate(cert *x509.Certificate) protocol.TLSCertificate {
+	sans := append([]string{}, cert.DNSNames...)
+	for _, ip := range cert.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return protocol.TLSCertificate{
+		Subject:   cert.Subject.String(),
+		Issuer:    cert.Issuer.String(),
+		SANs:      sans,
+		NotBefore: cert.NotBefore,
+		NotAfter:  cert.NotAfter,
+	}
+}
+
+func (e *TLSExecutor) FormatOutput(result *protocol.TLSResult) string {
+	status := "verified"
+	if !result.Verified {
+		status = "not verified"
+	}
+	return fmt.Sprintf("TLS %s (%s): %s, %s, expires %s (%.0f days)",
+		result.Target, result.Version, result.Chain[0].Subject, status,
+		result.NotAfter.UTC().Format("2006-01-02"), result.DaysRemaining)
+}
@@ -0,0 +1,257 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
+)
+
+// Traceroute protocols, chosen per task with the "protocol" parameter. UDP
+// probes go to consecutive ports from 33434 unless the target names a port;
+// TCP probes are connection attempts to the target port, 80 by default.
+const (
+	TracerouteUDP  = "udp"
+	TracerouteICMP = "icmp"
+	TracerouteTCP  = "tcp"
+)
+
+var ErrInvalidTraceroute = errors.New("invalid traceroute")
+
+var errTracerouteUnsupported = errors.New("traceroute is only supported on Linux")
+
+// traceReply is the answer to one probe. A timed out probe has no address.
+type traceReply struct {
+	addr net.IP
+	rtt  float64
+	// final is set when the probe got past the path: the target answered, or
+	// a router reported it unreachable.
+	final bool
+}
+
+// TracerouteExecutor runs traceroute tasks MTR-style: every round probes each
+// hop once, and the hops are summarized over the rounds.
+type TracerouteExecutor struct {
+	probeTimeout time.Duration
+}
+
+func NewTracerouteExecutor(probeTimeout time.Duration) *TracerouteExecutor {
+	if probeTimeout <= 0 {
+		probeTimeout = 2 * time.Second
+	}
+	return &TracerouteExecutor{probeTimeout: probeTimeout}
+}
+
+// traceCheck is a traceroute task's parameters, parsed.
+type traceCheck struct {
+	protocol string
+	maxHops  int
+	rounds   int
+	port     int
+	// portGiven is set when the target names the port, which UDP probes
+	// then keep.
+	portGiven bool
+}
+
+func parseTraceCheck(target string, params map[string]string) (string, *traceCheck, error) {
+	check := &traceCheck{protocol: strings.ToLower(params["protocol"]), maxHops: 30, rounds: 3}
+	if check.protocol == "" {
+		check.protocol = TracerouteUDP
+	}
+	switch check.protocol {
+	case TracerouteUDP:
+		check.port = 33434
+	case TracerouteTCP:
+		check.port = 80
+	case TracerouteICMP:
+	default:
+		return "", nil, fmt.Errorf("%w: unknown protocol %q", ErrInvalidTraceroute, params["protocol"])
+	}
+
+	host := strings.Trim(target, "[]")
+	if h, p, err := net.SplitHostPort(target); err == nil {
+		host, check.portGiven = h, true
+		if check.port, err = strconv.Atoi(p); err != nil || check.port < 1 || check.port > 65535 {
+			return "", nil, fmt.Errorf("%w: invalid port %q", ErrInvalidTraceroute, p)
+		}
+	}
+
+	var err error
+	if v := params["max_hops"]; v != "" {
+		if check.maxHops, err = strconv.Atoi(v); err != nil || check.maxHops < 1 || check.maxHops > 64 {
+			return "", nil, fmt.Errorf("%w: max_hops must be between 1 and 64", ErrInvalidTraceroute)
+		}
+	}
+	if v := params["rounds"]; v != "" {
+		if check.rounds, err = strconv.Atoi(v); err != nil || check.rounds < 1 || check.rounds > 100 {
+			return "", nil, fmt.Errorf("%w: rounds must be between 1 and 100", ErrInvalidTraceroute)
+		}
+	}
+	return host, check, nil
+}
+
+// Execute traces the path to target. The parameters are protocol, max_hops
+// (default 30) and rounds (default 3). The first round probes every hop at
+// once; later rounds stop at the hop that ended the path. The result is
+// returned whenever probes were sent, even if the target was not reached.
+// A timeout, if given, bounds all the rounds together.
+func (e *TracerouteExecutor) Execute(ctx context.Context, target string, params map[string]string, timeout time.Duration) (*protocol.TracerouteResult, error) {
+	host, check, err := parseTraceCheck(target, params)
+	if err != nil {
+		return nil, err
+	}
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+	dst, err := resolvePingTarget(ctx, host)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &protocol.TracerouteResult{
+		Target:   target,
+		Address:  dst.IP.String(),
+		Protocol: check.protocol,
+	}
+
+	replies := make([][]traceReply, check.maxHops)
+	last := check.maxHops
+	var lastErr error
+	for round := 0; round < check.rounds; round++ {
+		if round > 0 {
+			select {
+			case <-ctx.Done():
+				return nil, ctx.Err()
+			case <-time.After(200 * time.Millisecond):
+			}
+		}
+
+		var wg sync.WaitGroup
+		var mu sync.Mutex
+		for ttl := 1; ttl <= last; ttl++ {
+			wg.Add(1)
+			go func(ttl int) {
+				defer wg.Done()
+				port := check.port
+				if check.protocol == TracerouteUDP && !check.portGiven {
+					port += ttl - 1
+				}
+				reply, err := traceProbe(ctx, check.protocol, dst, port, ttl, round, e.probeTimeout)
+
+				mu.Lock()
+				defer mu.Unlock()
+				if err != nil {
+					lastErr = err
+				}
+				replies[ttl-1] = append(replies[ttl-1], reply)
+			}(ttl)
+		}
+		wg.Wait()
+
+		if errors.Is(lastErr, errTracerouteUnsupported) {
+			return nil, lastErr
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, err
+		}
+		result.Rounds++
+		for ttl := 1; ttl <= last; ttl++ {
+			if reply := replies[ttl-1][len(replies[ttl-1])-1]; reply.final {
+				last = ttl
+				break
+			}
+		}
+	}
+
+	summarizeTrace(result, replies[:last], dst.IP)
+	if !result.Reached {
+		if lastErr != nil && len(result.Hops) == 0 {
+			return result, lastErr
+		}
+		if last < check.maxHops {
+			return result, fmt.Errorf("%s unreachable at hop %d", result.Address, last)
+		}
+		return result, fmt.Errorf("%s not reached within %d hops", result.Address, check.maxHops)
+	}
+	return result, nil
+}
+
+// summarizeTrace turns the replies of each hop into its statistics. Silent
+// hops after the last one that answered are left out.
+func summarizeTrace(result *protocol.TracerouteResult, replies [][]traceReply, dst net.IP) {
+	for i, hopReplies := range replies {
+		hop := protocol.TracerouteHop{TTL: i + 1, Sent: len(hopReplies)}
+		counts := map[string]int{}
+		var rtts []float64
+		for _, r := range hopReplies {
+			if r.addr == nil {
+				continue
+			}
+			addr := r.addr.String()
+			if counts[addr] == 0 {
+				hop.Addresses = append(hop.Addresses, addr)
+			}
+			counts[addr]++
+			rtts = append(rtts, r.rtt)
+			if r.final && r.addr.Equal(dst) {
+				result.Reached = true
+			}
+		}
+		for _, addr := range hop.Addresses {
+			if counts[addr] > counts[hop.Address] {
+				hop.Address = addr
+			}
+		}
+		if len(hop.Addresses) < 2 {
+			hop.Addresses = nil
+		}
+
+		hop.Received = len(rtts)
+		if hop.Sent > 0 {
+			hop.Loss = float64(hop.Sent-hop.Received) / float64(hop.Sent) * 100
+		}
+		if len(rtts) > 0 {
+			hop.Min, hop.Max, hop.Last = rtts[0], rtts[0], rtts[len(rtts)-1]
+			var sum float64
+			for _, rtt := range rtts {
+				sum += rtt
+				hop.Min = math.Min(hop.Min, rtt)
+				hop.Max = math.Max(hop.Max, rtt)
+			}
+			hop.Avg = sum / float64(len(rtts))
+			var variance float64
+			for _, rtt := range rtts {
+				variance += (rtt - hop.Avg) * (rtt - hop.Avg)
+			}
+			hop.StdDev = math.Sqrt(variance / float64(len(rtts)))
+		}
+		result.Hops = append(result.Hops, hop)
+	}
+
+	for len(result.Hops) > 0 && result.Hops[len(result.Hops)-1].Received == 0 {
+		result.Hops = result.Hops[:len(result.Hops)-1]
+	}
+}
+
+func (e *TracerouteExecutor) FormatOutput(result *protocol.TracerouteResult) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "TRACEROUTE %s (%s) over %s, %d rounds\n", result.Target, result.Address, result.Protocol, result.Rounds)
+	for _, hop := range result.Hops {
+		if hop.Received == 0 {
+			fmt.Fprintf(&b, "%2d. ???\n", hop.TTL)
+			continue
+		}
+		fmt.Fprintf(&b, "%2d. %-39s %5.1f%% loss  %.2f/%.2f/%.2f ms\n",
+			hop.TTL, hop.Address, hop.Loss, hop.Min, hop.Avg, hop.Max)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
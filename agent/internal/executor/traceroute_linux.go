@@ -0,0 +1,209 @@
+package executor
+
+import (
+	"context"
+	"encoding/binary"
+	"net"
+	"os"
+	"syscall"
+	"time"
+	"unsafe"
+)
+
+// Origins and types of the ICMP errors Linux queues with IP_RECVERR.
+const (
+	soEEOriginICMP  = 2
+	soEEOriginICMP6 = 3
+
+	icmpDestUnreach   = 3
+	icmpv6DestUnreach = 1
+
+	pollIn  = 0x1
+	pollOut = 0x4
+)
+
+// traceProbe sends one probe with the given TTL and waits for its answer.
+// Every probe has a socket of its own with IP_RECVERR set, so the ICMP errors
+// routers send back are queued on it and need no raw socket to read.
+func traceProbe(ctx context.Context, proto string, dst *net.IPAddr, port, ttl, seq int, timeout time.Duration) (traceReply, error) {
+	v6 := dst.IP.To4() == nil
+	family, level, ttlOpt, recvErrOpt := syscall.AF_INET, syscall.IPPROTO_IP, syscall.IP_TTL, syscall.IP_RECVERR
+	if v6 {
+		family, level, ttlOpt, recvErrOpt = syscall.AF_INET6, syscall.IPPROTO_IPV6, syscall.IPV6_UNICAST_HOPS, syscall.IPV6_RECVERR
+	}
+
+	sotype, sockProto := syscall.SOCK_DGRAM, syscall.IPPROTO_UDP
+	switch proto {
+	case TracerouteICMP:
+		sockProto = syscall.IPPROTO_ICMP
+		if v6 {
+			sockProto = syscall.IPPROTO_ICMPV6
+		}
+	case TracerouteTCP:
+		sotype, sockProto = syscall.SOCK_STREAM, syscall.IPPROTO_TCP
+	}
+
+	fd, err := syscall.Socket(family, sotype|syscall.SOCK_NONBLOCK|syscall.SOCK_CLOEXEC, sockProto)
+	if err != nil {
+		return traceReply{}, os.NewSyscallError("socket", err)
+	}
+	defer syscall.Close(fd)
+	if err := syscall.SetsockoptInt(fd, level, ttlOpt, ttl); err != nil {
+		return traceReply{}, os.NewSyscallError("setsockopt", err)
+	}
+	if err := syscall.SetsockoptInt(fd, level, recvErrOpt, 1); err != nil {
+		return traceReply{}, os.NewSyscallError("setsockopt", err)
+	}
+
+	var sa syscall.Sockaddr
+	if v6 {
+		addr := &syscall.SockaddrInet6{Port: port}
+		copy(addr.Addr[:], dst.IP.To16())
+		if dst.Zone != "" {
+			if ifi, err := net.InterfaceByName(dst.Zone); err == nil {
+				addr.ZoneId = uint32(ifi.Index)
+			}
+		}
+		sa = addr
+	} else {
+		addr := &syscall.SockaddrInet4{Port: port}
+		copy(addr.Addr[:], dst.IP.To4())
+		sa = addr
+	}
+
+	start := time.Now()
+	switch proto {
+	case TracerouteUDP:
+		err = syscall.Sendto(fd, make([]byte, 32), 0, sa)
+	case TracerouteICMP:
+		err = syscall.Sendto(fd, icmpEcho(v6, seq, []byte("probe-agent")), 0, sa)
+	case TracerouteTCP:
+		if err = syscall.Connect(fd, sa); err == syscall.EINPROGRESS {
+			err = nil
+		}
+	}
+	if err != nil {
+		return traceReply{}, os.NewSyscallError("send", err)
+	}
+
+	deadline := start.Add(timeout)
+	buf := make([]byte, 512)
+	oob := make([]byte, 512)
+	for {
+		if err := ctx.Err(); err != nil {
+			return traceReply{}, err
+		}
+		wait := time.Until(deadline)
+		if wait <= 0 {
+			return traceReply{}, nil
+		}
+		// Wake up now and then to notice a cancelled task.
+		if wait > 100*time.Millisecond {
+			wait = 100 * time.Millisecond
+		}
+		events, err := pollFd(fd, wait)
+		if err != nil {
+			return traceReply{}, err
+		}
+		if events == 0 {
+			continue
+		}
+		rtt := msSince(start)
+
+		// An ICMP error from a router or the target.
+		if _, oobn, _, _, err := syscall.Recvmsg(fd, buf, oob, syscall.MSG_ERRQUEUE); err == nil {
+			if reply, ok := parseICMPError(oob[:oobn]); ok {
+				reply.rtt = rtt
+				return reply, nil
+			}
+			continue
+		}
+
+		switch proto {
+		case TracerouteTCP:
+			// The connection was accepted or refused by the target.
+			if events&pollOut != 0 {
+				soErr, err := syscall.GetsockoptInt(fd, syscall.SOL_SOCKET, syscall.SO_ERROR)
+				if err == nil && (soErr == 0 || syscall.Errno(soErr) == syscall.ECONNREFUSED) {
+					return traceReply{addr: dst.IP, rtt: rtt, final: true}, nil
+				}
+				return traceReply{}, nil
+			}
+		default:
+			// An echo reply, or an answer from a UDP service.
+			n, _, err := syscall.Recvfrom(fd, buf, 0)
+			if err == syscall.EAGAIN {
+				continue
+			}
+			if err != nil {
+				return traceReply{}, nil
+			}
+			if proto == TracerouteICMP && (n < 1 || (buf[0] != icmpEchoReply && buf[0] != icmpv6EchoReply)) {
+				continue
+			}
+			return traceReply{addr: dst.IP, rtt: rtt, final: true}, nil
+		}
+	}
+}
+
+// pollFd waits until fd is readable, writable or has an error queued.
+func pollFd(fd int, timeout time.Duration) (int16, error) {
+	pfd := struct {
+		fd      int32
+		events  int16
+		revents int16
+	}{fd: int32(fd), events: pollIn | pollOut}
+	ts := syscall.NsecToTimespec(timeout.Nanoseconds())
+	_, _, errno := syscall.Syscall6(syscall.SYS_PPOLL, uintptr(unsafe.Pointer(&pfd)), 1,
+		uintptr(unsafe.Pointer(&ts)), 0, 0, 0)
+	if errno == syscall.EINTR {
+		return 0, nil
+	}
+	if errno != 0 {
+		return 0, os.NewSyscallError("ppoll", errno)
+	}
+	return pfd.revents, nil
+}
+
+// parseICMPError reads the sock_extended_err of an IP_RECVERR control
+// message and the address of the node that sent the error after it.
+func parseICMPError(oob []byte) (traceReply, bool) {
+	msgs, err := syscall.ParseSocketControlMessage(oob)
+	if err != nil {
+		return traceReply{}, false
+	}
+	for _, msg := range msgs {
+		isV4 := msg.Header.Level == syscall.IPPROTO_IP && msg.Header.Type == syscall.IP_RECVERR
+		isV6 := msg.Header.Level == syscall.IPPROTO_IPV6 && msg.Header.Type == syscall.IPV6_RECVERR
+		if (!isV4 && !isV6) || len(msg.Data) < 16+4 {
+			continue
+		}
+		origin, icmpType := msg.Data[4], msg.Data[5]
+		if origin != soEEOriginICMP && origin != soEEOriginICMP6 {
+			continue
+		}
+
+		offender := msg.Data[16:]
+		var addr net.IP
+		switch binary.NativeEndian.Uint16(offender) {
+		case syscall.AF_INET:
+			if len(offender) >= 8 {
+				addr = net.IP(append([]byte{}, offender[4:8]...))
+			}
+		case syscall.AF_INET6:
+			if len(offender) >= 24 {
+				addr = net.IP(append([]byte{}, offender[8:24]...))
+			}
+		}
+		if addr == nil {
+			return traceReply{}, false
+		}
+
+		unreach := icmpType == icmpDestUnreach
+		if origin == soEEOriginICMP6 {
+			unreach = icmpType == icmpv6DestUnreach
+		}
+		return traceReply{addr: addr, final: unreach}, true
+	}
+	return traceReply{}, false
+}
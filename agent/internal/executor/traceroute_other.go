@@ -0,0 +1,13 @@
+//go:build !linux
+
+package executor
+
+import (
+	"context"
+	"net"
+	"time"
+)
+
+func traceProbe(ctx context.Context, proto string, dst *net.IPAddr, port, ttl, seq int, timeout time.Duration) (traceReply, error) {
+	return traceReply{}, errTracerouteUnsupported
+}
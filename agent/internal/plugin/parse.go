@@ -0,0 +1,165 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"math"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/probe-system/agent/pkg/protocol"
+)
+
+const (
+	FormatAuto       = "auto"
+	FormatKeyValue   = "keyvalue"
+	FormatPrometheus = "prometheus"
+)
+
+var metricName = regexp.MustCompile(`^[a-zA-Z_:][a-zA-Z0-9_:]*$`)
+
+// Parse reads plugin output as "key=value" lines or Prometheus text. With
+// FormatAuto each line is read as whichever it looks like. Comments, blank
+// lines and non-finite values are skipped; the error names the first line
+// that could not be parsed, while the other lines are still returned.
+func Parse(output []byte, format string) ([]protocol.CustomMetric, error) {
+	var metrics []protocol.CustomMetric
+	var firstErr error
+
+	scanner := bufio.NewScanner(bytes.NewReader(output))
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || line[0] == '#' {
+			continue
+		}
+
+		var m protocol.CustomMetric
+		var err error
+		switch format {
+		case FormatKeyValue:
+			m, err = parseKeyValue(line)
+		case FormatPrometheus:
+			m, err = parsePrometheus(line)
+		default:
+			if isKeyValue(line) {
+				m, err = parseKeyValue(line)
+			} else {
+				m, err = parsePrometheus(line)
+			}
+		}
+		if err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("line %d: %v", n, err)
+			}
+			continue
+		}
+		if math.IsNaN(m.Value) || math.IsInf(m.Value, 0) {
+			continue
+		}
+		metrics = append(metrics, m)
+	}
+
+	return metrics, firstErr
+}
+
+// isKeyValue reports whether a line has an "=" outside a Prometheus label set.
+func isKeyValue(line string) bool {
+	eq := strings.IndexByte(line, '=')
+	brace := strings.IndexByte(line, '{')
+	return eq >= 0 && (brace < 0 || eq < brace)
+}
+
+func parseKeyValue(line string) (protocol.CustomMetric, error) {
+	key, value, ok := strings.Cut(line, "=")
+	if !ok {
+		return protocol.CustomMetric{}, fmt.Errorf("expected key=value")
+	}
+	return newMetric(strings.TrimSpace(key), nil, strings.TrimSpace(value))
+}
+
+// parsePrometheus parses one sample line of the Prometheus text format,
+// `name{label="value",...} value [timestamp]`. The timestamp is ignored.
+func parsePrometheus(line string) (protocol.CustomMetric, error) {
+	end := strings.IndexAny(line, "{ \t")
+	if end < 0 {
+		return protocol.CustomMetric{}, fmt.Errorf("missing value")
+	}
+	name, rest := line[:end], line[end:]
+
+	var labels map[string]string
+	if rest[0] == '{' {
+		var err error
+		labels, rest, err = parseLabels(rest[1:])
+		if err != nil {
+			return protocol.CustomMetric{}, err
+		}
+	}
+
+	fields := strings.Fields(rest)
+	if len(fields) == 0 {
+		return protocol.CustomMetric{}, fmt.Errorf("missing value")
+	}
+	return newMetric(name, labels, fields[0])
+}
+
+// parseLabels reads a label set up to its closing brace and returns the rest
+// of the line.
+func parseLabels(s string) (map[string]string, string, error) {
+	labels := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return nil, "", fmt.Errorf("unterminated label set")
+		}
+		if s[0] == '}' {
+			return labels, s[1:], nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return nil, "", fmt.Errorf("expected label=\"value\"")
+		}
+		key := strings.TrimSpace(s[:eq])
+		if !metricName.MatchString(key) {
+			return nil, "", fmt.Errorf("invalid label name %q", key)
+		}
+		s = strings.TrimLeft(s[eq+1:], " \t")
+		if s == "" || s[0] != '"' {
+			return nil, "", fmt.Errorf("label %s: value must be quoted", key)
+		}
+
+		var value strings.Builder
+		i := 1
+		for ; i < len(s) && s[i] != '"'; i++ {
+			if s[i] == '\\' && i+1 < len(s) {
+				i++
+				if s[i] == 'n' {
+					value.WriteByte('\n')
+					continue
+				}
+			}
+			value.WriteByte(s[i])
+		}
+		if i == len(s) {
+			return nil, "", fmt.Errorf("label %s: unterminated value", key)
+		}
+		labels[key] = value.String()
+		s = s[i+1:]
+	}
+}
+
+func newMetric(name string, labels map[string]string, value string) (protocol.CustomMetric, error) {
+	if !metricName.MatchString(name) {
+		return protocol.CustomMetric{}, fmt.Errorf("invalid metric name %q", name)
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return protocol.CustomMetric{}, fmt.Errorf("%s: invalid value %q", name, value)
+	}
+	if len(labels) == 0 {
+		labels = nil
+	}
+	return protocol.CustomMetric{Name: name, Labels: labels, Value: v}, nil
+}
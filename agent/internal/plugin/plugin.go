@@ -0,0 +1,182 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/probe-system/agent/pkg/protocol"
+)
+
+const (
+	defaultInterval = 60 * time.Second
+	defaultTimeout  = 10 * time.Second
+
+	// maxOutput and maxMetrics bound what a misbehaving plugin can send.
+	maxOutput  = 1 << 20
+	maxMetrics = 1000
+	// Only the first line of stderr is reported.
+	maxStderr = 4 << 10
+)
+
+// Config describes a plugin executable. Interval and Timeout are in seconds.
+type Config struct {
+	Name     string   `json:"name"`
+	Command  string   `json:"command"`
+	Args     []string `json:"args"`
+	Format   string   `json:"format"`
+	Interval int      `json:"interval"`
+	Timeout  int      `json:"timeout"`
+}
+
+func (c *Config) interval() time.Duration {
+	if c.Interval <= 0 {
+		return defaultInterval
+	}
+	return time.Duration(c.Interval) * time.Second
+}
+
+func (c *Config) timeout() time.Duration {
+	if c.Timeout <= 0 {
+		return defaultTimeout
+	}
+	return time.Duration(c.Timeout) * time.Second
+}
+
+// Runner runs each plugin on its own interval and hands the results to send.
+type Runner struct {
+	plugins []Config
+	send    func(*protocol.CustomMetricsPayload) error
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+}
+
+func NewRunner(plugins []Config, send func(*protocol.CustomMetricsPayload) error) *Runner {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &Runner{
+		plugins: plugins,
+		send:    send,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
+
+func (r *Runner) Start() {
+	for _, p := range r.plugins {
+		if p.Command == "" {
+			log.Printf("Plugin %q has no command, skipping", p.Name)
+			continue
+		}
+		if p.Name == "" {
+			p.Name = p.Command
+		}
+		r.wg.Add(1)
+		go r.loop(p)
+	}
+}
+
+func (r *Runner) Stop() {
+	r.cancel()
+	r.wg.Wait()
+}
+
+func (r *Runner) loop(p Config) {
+	defer r.wg.Done()
+
+	ticker := time.NewTicker(p.interval())
+	defer ticker.Stop()
+
+	for {
+		payload := Run(r.ctx, &p)
+		if r.ctx.Err() != nil {
+			return
+		}
+		if payload.Error != "" {
+			log.Printf("Plugin %s: %s", p.Name, payload.Error)
+		}
+		if err := r.send(payload); err != nil {
+			log.Printf("Failed to send plugin %s metrics: %v", p.Name, err)
+		}
+
+		select {
+		case <-r.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Run executes a plugin once and parses its standard output.
+func Run(ctx context.Context, p *Config) *protocol.CustomMetricsPayload {
+	payload := &protocol.CustomMetricsPayload{
+		Plugin:    p.Name,
+		Metrics:   []protocol.CustomMetric{},
+		Timestamp: time.Now().Unix(),
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, p.timeout())
+	defer cancel()
+
+	stdout := &cappedBuffer{max: maxOutput}
+	stderr := &cappedBuffer{max: maxStderr}
+	cmd := exec.CommandContext(ctx, p.Command, p.Args...)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	// Children left behind by a killed shell would otherwise keep the
+	// output pipes open and Wait blocked.
+	cmd.WaitDelay = time.Second
+	err := cmd.Run()
+
+	metrics, parseErr := Parse(stdout.Bytes(), p.Format)
+	if len(metrics) > maxMetrics {
+		metrics = metrics[:maxMetrics]
+	}
+	if metrics != nil {
+		payload.Metrics = metrics
+	}
+
+	switch {
+	case errors.Is(ctx.Err(), context.DeadlineExceeded):
+		payload.Error = fmt.Sprintf("timed out after %v", p.timeout())
+	case err != nil:
+		payload.Error = err.Error()
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			payload.Error += ": " + firstLine(msg)
+		}
+	case parseErr != nil:
+		payload.Error = parseErr.Error()
+	}
+
+	return payload
+}
+
+// cappedBuffer keeps the first max bytes written to it and discards the rest
+// without failing the writer.
+type cappedBuffer struct {
+	bytes.Buffer
+	max int
+}
+
+func (b *cappedBuffer) Write(p []byte) (int, error) {
+	if room := b.max - b.Len(); room > 0 {
+		if len(p) > room {
+			b.Buffer.Write(p[:room])
+		} else {
+			b.Buffer.Write(p)
+		}
+	}
+	return len(p), nil
+}
+
+func firstLine(s string) string {
+	line, _, _ := strings.Cut(s, "\n")
+	return line
+}
@@ -33,6 +33,8 @@ type Client struct {
 	mu        sync.Mutex
 	connected bool
 	onMessage func(*protocol.Message)
+	// onDisconnect is called when the connection drops, before reconnecting.
+	onDisconnect func()
 }
 
 func NewClient(serverURL, token, version string) *Client {
@@ -49,6 +51,10 @@ func (c *Client) SetMessageHandler(handler func(*protocol.Message)) {
 	c.onMessage = handler
 }
 
+func (c *Client) SetDisconnectHandler(handler func()) {
+	c.onDisconnect = handler
+}
+
 func (c *Client) Connect() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
@@ -131,6 +137,9 @@ func (c *Client) readPump() {
 			c.conn.Close()
 		}
 		c.mu.Unlock()
+		if c.onDisconnect != nil {
+			c.onDisconnect()
+		}
 		c.reconnect()
 	}()
 
@@ -284,6 +293,62 @@ func (c *Client) SendMetrics(metrics *protocol.MetricsPayload) error {
 	return c.Send(msg)
 }
 
+func (c *Client) SendProcesses(processes *protocol.ProcessesPayload) error {
+	msg, err := protocol.NewMessage(protocol.MsgTypeProcesses, uuid.New().String(), processes)
+	if err != nil {
+		return err
+	}
+	return c.Send(msg)
+}
+
+func (c *Client) SendCustomMetrics(metrics *protocol.CustomMetricsPayload) error {
+	msg, err := protocol.NewMessage(protocol.MsgTypeCustomMetrics, uuid.New().String(), metrics)
+	if err != nil {
+		return err
+	}
+	return c.Send(msg)
+}
+
+func (c *Client) SendCommandOutput(output *protocol.CommandOutputPayload) error {
+	msg, err := protocol.NewMessage(protocol.MsgTypeCommandOutput, uuid.New().String(), output)
+	if err != nil {
+		return err
+	}
+	return c.Send(msg)
+}
+
+func (c *Client) SendCommandExit(exit *protocol.CommandExitPayload) error {
+	msg, err := protocol.NewMessage(protocol.MsgTypeCommandExit, uuid.New().String(), exit)
+	if err != nil {
+		return err
+	}
+	return c.Send(msg)
+}
+
+func (c *Client) SendTerminalOutput(output *protocol.TerminalDataPayload) error {
+	msg, err := protocol.NewMessage(protocol.MsgTypeTerminalOutput, uuid.New().String(), output)
+	if err != nil {
+		return err
+	}
+	return c.Send(msg)
+}
+
+func (c *Client) SendTerminalExit(exit *protocol.TerminalExitPayload) error {
+	msg, err := protocol.NewMessage(protocol.MsgTypeTerminalExit, uuid.New().String(), exit)
+	if err != nil {
+		return err
+	}
+	return c.Send(msg)
+}
+
+func (c *Client) SendTaskAck(ack *protocol.TaskAckPayload) error {
+	msg, err := protocol.NewMessage(protocol.MsgTypeTaskAck, uuid.New().String(), ack)
+	if err != nil {
+		return err
+	}
+	return c.Send(msg)
+}
+
 func (c *Client) SendTaskResult(result *protocol.TaskResultPayload) error {
 	msg, err := protocol.NewMessage(protocol.MsgTypeTaskResult, uuid.New().String(), result)
 	if err != nil {
@@ -0,0 +1,231 @@
+// Package cron parses five-field cron expressions and finds the times they
+// match. Core and agent carry the same copy, so an expression the core
+// accepts is evaluated the same way by every agent.
+package cron
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	// Agents evaluate schedules in the task's time zone whether or not the
+	// host has a zone database.
+	_ "time/tzdata"
+)
+
+var ErrInvalidSchedule = errors.New("invalid schedule")
+
+// Schedule is a parsed expression: minute, hour, day of month, month and day
+// of week, in a time zone.
+type Schedule struct {
+	minute, hour, dom, month, dow uint64
+	// When both day fields are restricted a day matching either one
+	// matches, as in Vixie cron.
+	domAny, dowAny bool
+	loc            *time.Location
+}
+
+type field struct {
+	name     string
+	min, max int
+	names    map[string]int
+}
+
+var (
+	minuteField = field{name: "minute", min: 0, max: 59}
+	hourField   = field{name: "hour", min: 0, max: 23}
+	domField    = field{name: "day of month", min: 1, max: 31}
+	monthField  = field{name: "month", min: 1, max: 12, names: map[string]int{
+		"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+		"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+	}}
+	// 7 is Sunday as well as 0.
+	dowField = field{name: "day of week", min: 0, max: 7, names: map[string]int{
+		"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+	}}
+)
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse parses an expression such as "15 3 * * *" or "*/10 8-18 * * mon-fri",
+// or one of @yearly, @monthly, @weekly, @daily and @hourly. Fields take
+// lists, ranges and steps; months and days of week also take their English
+// abbreviations. timezone is an IANA name; empty means UTC.
+func Parse(spec, timezone string) (*Schedule, error) {
+	loc := time.UTC
+	if timezone != "" {
+		var err error
+		if loc, err = time.LoadLocation(timezone); err != nil {
+			return nil, fmt.Errorf("%w: unknown time zone %q", ErrInvalidSchedule, timezone)
+		}
+	}
+
+	spec = strings.TrimSpace(spec)
+	if m, ok := macros[strings.ToLower(spec)]; ok {
+		spec = m
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("%w: expected 5 fields, got %d", ErrInvalidSchedule, len(fields))
+	}
+
+	s := &Schedule{loc: loc}
+	var err error
+	if s.minute, err = minuteField.parse(fields[0]); err != nil {
+		return nil, err
+	}
+	if s.hour, err = hourField.parse(fields[1]); err != nil {
+		return nil, err
+	}
+	if s.dom, err = domField.parse(fields[2]); err != nil {
+		return nil, err
+	}
+	if s.month, err = monthField.parse(fields[3]); err != nil {
+		return nil, err
+	}
+	if s.dow, err = dowField.parse(fields[4]); err != nil {
+		return nil, err
+	}
+	if s.dow&(1<<7) != 0 {
+		s.dow |= 1
+	}
+	s.domAny = strings.HasPrefix(fields[2], "*")
+	s.dowAny = strings.HasPrefix(fields[4], "*")
+	return s, nil
+}
+
+// parse returns the values the field matches as a bit set.
+func (f field) parse(spec string) (uint64, error) {
+	var bits uint64
+	for _, item := range strings.Split(spec, ",") {
+		rangeSpec, stepSpec, hasStep := strings.Cut(item, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepSpec); err != nil || step < 1 {
+				return 0, fmt.Errorf("%w: invalid step %q in %s", ErrInvalidSchedule, stepSpec, f.name)
+			}
+		}
+
+		var lo, hi int
+		switch {
+		case rangeSpec == "*":
+			lo, hi = f.min, f.max
+		case strings.Contains(rangeSpec, "-"):
+			from, to, _ := strings.Cut(rangeSpec, "-")
+			var err error
+			if lo, err = f.value(from); err != nil {
+				return 0, err
+			}
+			if hi, err = f.value(to); err != nil {
+				return 0, err
+			}
+			if lo > hi {
+				return 0, fmt.Errorf("%w: range %q in %s is backwards", ErrInvalidSchedule, rangeSpec, f.name)
+			}
+		default:
+			v, err := f.value(rangeSpec)
+			if err != nil {
+				return 0, err
+			}
+			// "5/15" means from 5 to the end in steps of 15.
+			lo, hi = v, v
+			if hasStep {
+				hi = f.max
+			}
+		}
+		for v := lo; v <= hi; v += step {
+			bits |= 1 << uint(v)
+		}
+	}
+	return bits, nil
+}
+
+func (f field) value(s string) (int, error) {
+	if v, ok := f.names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil || v < f.min || v > f.max {
+		return 0, fmt.Errorf("%w: %s must be between %d and %d, got %q", ErrInvalidSchedule, f.name, f.min, f.max, s)
+	}
+	return v, nil
+}
+
+// Location is the time zone the schedule is evaluated in.
+func (s *Schedule) Location() *time.Location {
+	return s.loc
+}
+
+// Next returns the first time after t that the schedule matches, or the zero
+// time if it matches none in the next five years, as for "0 0 30 2 *".
+// Wall-clock times skipped by a daylight saving change do not match; those
+// repeated by one match twice.
+func (s *Schedule) Next(t time.Time) time.Time {
+	t = t.Truncate(time.Minute).Add(time.Minute).In(s.loc)
+	limit := t.Year() + 5
+
+wrap:
+	for t.Year() <= limit {
+		for s.month&(1<<uint(t.Month())) == 0 {
+			t = startOf(time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.loc), t)
+			if t.Year() > limit {
+				return time.Time{}
+			}
+		}
+		for !s.dayMatches(t) {
+			month := t.Month()
+			t = startOf(time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.loc), t)
+			if t.Month() != month {
+				continue wrap
+			}
+		}
+		// Hours and minutes advance in elapsed time so a daylight saving
+		// change cannot send them back.
+		for s.hour&(1<<uint(t.Hour())) == 0 {
+			day := t.Day()
+			t = t.Add(time.Duration(60-t.Minute()) * time.Minute)
+			if t.Day() != day {
+				continue wrap
+			}
+		}
+		for s.minute&(1<<uint(t.Minute())) == 0 {
+			hour := t.Hour()
+			t = t.Add(time.Minute)
+			if t.Hour() != hour {
+				continue wrap
+			}
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// startOf returns midnight of a later day than from. Where a daylight saving
+// change skips midnight, time.Date may give the hour before it, still on
+// from's day; the day then starts an hour later.
+func startOf(midnight, from time.Time) time.Time {
+	if midnight.Day() == from.Day() && midnight.Month() == from.Month() {
+		return midnight.Add(time.Hour)
+	}
+	return midnight
+}
+
+func (s *Schedule) dayMatches(t time.Time) bool {
+	dom := s.dom&(1<<uint(t.Day())) != 0
+	dow := s.dow&(1<<uint(t.Weekday())) != 0
+	if s.domAny || s.dowAny {
+		return dom && dow
+	}
+	return dom || dow
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/probe-system/core/internal/config"
+	"github.com/probe-system/core/internal/repository"
+	"github.com/probe-system/core/internal/service"
+)
+
+// runBackupCommand implements `core backup`. It is safe to run while core is
+// serving requests.
+func runBackupCommand(args []string) {
+	fs := flag.NewFlagSet("backup", flag.ExitOnError)
+	configPath := fs.String("config", "config.json", "Path to config file")
+	out := fs.String("out", "", "Write the snapshot to this file instead of the backup directory")
+	fs.Parse(args)
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	if _, err := os.Stat(cfg.Database.Path); err != nil {
+		log.Fatalf("Database not found: %v", err)
+	}
+
+	db, err := repository.NewDB(cfg.Database.Path)
+	if err != nil {
+		log.Fatalf("Failed to connect to database: %v", err)
+	}
+	defer db.Close()
+
+	if *out != "" {
+		if err := db.Backup(context.Background(), *out); err != nil {
+			log.Fatalf("Backup failed: %v", err)
+		}
+		fmt.Printf("Backup written to %s\n", *out)
+		return
+	}
+
+	backup, err := service.NewBackupService(db, cfg.Backup.Dir, cfg.Backup.Keep).Create(context.Background())
+	if err != nil {
+		log.Fatalf("Backup failed: %v", err)
+	}
+	fmt.Printf("Backup written to %s/%s\n", cfg.Backup.Dir, backup.Name)
+}
+
+// runRestoreCommand implements `core restore`. Core must be stopped first.
+func runRestoreCommand(args []string) {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	configPath := fs.String("config", "config.json", "Path to config file")
+	from := fs.String("from", "", "Backup file to restore")
+	fs.Parse(args)
+
+	if *from == "" {
+		log.Fatal("Usage: core restore -from <backup file> [-config config.json]")
+	}
+
+	cfg, err := config.Load(*configPath)
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	version, err := repository.InspectBackup(*from)
+	if err != nil {
+		log.Fatalf("Cannot restore %s: %v", *from, err)
+	}
+
+	previous, err := repository.Restore(cfg.Database.Path, *from)
+	if err != nil {
+		log.Fatalf("Restore failed: %v", err)
+	}
+
+	fmt.Printf("Restored %s (schema version %d) to %s\n", *from, version, cfg.Database.Path)
+	if previous != "" {
+		fmt.Printf("Previous database kept at %s\n", previous)
+	}
+}
@@ -21,6 +21,17 @@ import (
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		switch os.Args[1] {
+		case "backup":
+			runBackupCommand(os.Args[2:])
+			return
+		case "restore":
+			runRestoreCommand(os.Args[2:])
+			return
+		}
+	}
+
 	configPath := flag.String("config", "config.json", "Path to config file")
 	flag.Parse()
 
@@ -33,6 +44,12 @@ func main() {
 	log.Println("Probe System Core starting...")
 
 	// Initialize database
+	unlock, err := repository.LockDatabase(cfg.Database.Path)
+	if err != nil {
+		log.Fatalf("Failed to lock database: %v", err)
+	}
+	defer unlock()
+
 	db, err := repository.NewDB(cfg.Database.Path)
 	if err != nil {
 		log.Fatalf("Failed to connect to database: %v", err)
@@ -47,12 +64,17 @@ func main() {
 	agentRepo := repository.NewAgentRepository(db)
 	groupRepo := repository.NewGroupRepository(db)
 	metricsRepo := repository.NewMetricsRepository(db)
+	containerRepo := repository.NewContainerRepository(db)
 	trafficRepo := repository.NewTrafficRepository(db)
 	taskRepo := repository.NewTaskRepository(db)
 	scriptRepo := repository.NewScriptRepository(db)
 	alertRepo := repository.NewAlertRepository(db)
+	watchRepo := repository.NewWatchRepository(db)
+	customMetricRepo := repository.NewCustomMetricRepository(db)
 	settingsRepo := repository.NewSettingsRepository(db)
 	userRepo := repository.NewUserRepository(db)
+	commandRepo := repository.NewCommandRepository(db)
+	terminalRepo := repository.NewTerminalRepository(db)
 
 	// Initialize services
 	geoSvc := service.NewGeoService()
@@ -62,9 +84,25 @@ func main() {
 	trafficSvc := service.NewTrafficService(trafficRepo)
 	taskSvc := service.NewTaskService(taskRepo)
 	scriptSvc := service.NewScriptService(scriptRepo)
-	alertSvc := service.NewAlertService(alertRepo)
+	processSvc := service.NewProcessService()
+	containerSvc := service.NewContainerService(containerRepo)
+	watchSvc := service.NewWatchService(watchRepo)
+	customMetricSvc := service.NewCustomMetricService(customMetricRepo)
+	alertSvc := service.NewAlertService(alertRepo, processSvc)
 	settingsSvc := service.NewSettingsService(settingsRepo)
 	authSvc := service.NewAuthService(userRepo, cfg.Auth.JWTSecret)
+	commandSvc := service.NewCommandService(commandRepo, scriptRepo)
+	terminalSvc := service.NewTerminalService(terminalRepo, cfg.Terminal.RecordInput)
+	transferSvc := service.NewTransferService(agentRepo, metricsRepo, trafficRepo, taskRepo)
+	retentionSvc := service.NewRetentionService(db, settingsRepo, metricsRepo, containerRepo, customMetricRepo, trafficRepo, taskRepo, alertRepo, commandRepo, terminalRepo)
+	backupSvc := service.NewBackupService(db, cfg.Backup.Dir, cfg.Backup.Keep)
+
+	if err := commandSvc.AbortUnfinished(context.Background()); err != nil {
+		log.Printf("Failed to end unfinished command runs: %v", err)
+	}
+	if err := terminalSvc.AbortUnfinished(context.Background()); err != nil {
+		log.Printf("Failed to close unfinished terminal sessions: %v", err)
+	}
 
 	// Setup notifiers
 	settings, _ := settingsSvc.Get(context.Background())
@@ -86,16 +124,22 @@ func main() {
 	go hub.Run()
 
 	wsHandler := ws.NewHandler(hub, cfg.Agent.Token)
-	wsHandler.SetServices(agentSvc, metricSvc, trafficSvc, taskSvc, alertSvc)
+	wsHandler.SetServices(agentSvc, metricSvc, trafficSvc, taskSvc, alertSvc, processSvc, containerSvc, watchSvc, customMetricSvc, commandSvc, terminalSvc)
 
 	// Initialize HTTP handlers
 	adminHandler := handler.NewAdminHandler(
 		agentSvc, groupSvc, metricSvc, trafficSvc,
-		taskSvc, scriptSvc, alertSvc, settingsSvc, authSvc, wsHandler,
+		taskSvc, scriptSvc, alertSvc, settingsSvc, authSvc, processSvc, containerSvc, watchSvc,
+		customMetricSvc, wsHandler,
 	)
 	publicHandler := handler.NewPublicHandler(agentSvc, metricSvc, trafficSvc)
 	dashboardWSHandler := handler.NewDashboardWSHandler(agentSvc, metricSvc, trafficSvc)
 	scriptHandler := handler.NewScriptHandler(scriptSvc)
+	transferHandler := handler.NewTransferHandler(transferSvc)
+	maintenanceHandler := handler.NewMaintenanceHandler(retentionSvc, settingsSvc, backupSvc)
+	commandHandler := handler.NewCommandHandler(commandSvc, authSvc, wsHandler)
+	terminalHandler := handler.NewTerminalHandler(terminalSvc, authSvc, wsHandler,
+		time.Duration(cfg.Terminal.IdleTimeoutMinutes)*time.Minute)
 
 	// Setup Gin router
 	gin.SetMode(gin.ReleaseMode)
@@ -108,6 +152,8 @@ func main() {
 		wsHandler.ServeWS(c.Writer, c.Request)
 	})
 	r.GET("/ws/dashboard", dashboardWSHandler.ServeWS)
+	r.GET("/ws/commands/:id", commandHandler.Watch)
+	r.GET("/ws/terminal/:agentId", terminalHandler.Connect)
 
 	// Public API
 	public := r.Group("/api/public")
@@ -135,9 +181,22 @@ func main() {
 		admin.DELETE("/agents/:id", adminHandler.DeleteAgent)
 		admin.GET("/agents/:id/metrics", adminHandler.GetAgentMetrics)
 		admin.GET("/agents/:id/metrics/history", adminHandler.GetAgentMetricsHistory)
+		admin.GET("/agents/:id/network/interfaces", adminHandler.GetAgentInterfaces)
+		admin.GET("/agents/:id/containers", adminHandler.GetAgentContainers)
+		admin.GET("/agents/:id/containers/:cid/metrics", adminHandler.GetContainerMetrics)
+		admin.GET("/agents/:id/custom-metrics", adminHandler.GetAgentCustomMetrics)
+		admin.GET("/agents/:id/custom-metrics/:name", adminHandler.GetCustomMetricHistory)
+		admin.GET("/agents/:id/watches", adminHandler.GetAgentWatches)
+		admin.GET("/agents/:id/processes", adminHandler.GetAgentProcesses)
+		admin.GET("/agents/:id/collectors", adminHandler.GetAgentCollectors)
+		admin.POST("/agents/:id/processes/refresh", adminHandler.RefreshAgentProcesses)
 		admin.GET("/agents/:id/traffic", adminHandler.GetAgentTraffic)
+		admin.GET("/agents/:id/traffic/archives", adminHandler.GetAgentTrafficArchives)
 		admin.POST("/agents/:id/traffic/cycle", adminHandler.ConfigureTrafficCycle)
 
+		// Metrics
+		admin.GET("/metrics/query", adminHandler.QueryMetrics)
+
 		// Groups
 		admin.GET("/groups", adminHandler.ListGroups)
 		admin.POST("/groups", adminHandler.CreateGroup)
@@ -148,6 +207,12 @@ func main() {
 		admin.GET("/tasks", adminHandler.ListTasks)
 		admin.POST("/tasks", adminHandler.CreateTask)
 		admin.GET("/tasks/:id/results", adminHandler.GetTaskResults)
+		admin.GET("/tasks/:id/deliveries", adminHandler.GetTaskDeliveries)
+		admin.GET("/tasks/:id/path-diff", adminHandler.GetPathDiff)
+		admin.GET("/tasks/:id/series", adminHandler.GetTaskSeries)
+		admin.GET("/tasks/:id/sla", adminHandler.GetTaskSLA)
+		admin.GET("/sla", adminHandler.ListSLA)
+		admin.GET("/certificates", adminHandler.ListCertificates)
 		admin.POST("/tasks/:id/cancel", adminHandler.CancelTask)
 
 		// Scripts
@@ -156,6 +221,24 @@ func main() {
 		admin.GET("/scripts/:id", adminHandler.GetScript)
 		admin.DELETE("/scripts/:id", adminHandler.DeleteScript)
 
+		// Commands
+		admin.GET("/commands", commandHandler.List)
+		admin.POST("/commands", commandHandler.Run)
+		admin.GET("/commands/:id", commandHandler.Get)
+		admin.POST("/commands/:id/cancel", commandHandler.Cancel)
+
+		// Terminal sessions
+		admin.GET("/terminals", terminalHandler.List)
+		admin.GET("/terminals/:id", terminalHandler.Get)
+		admin.GET("/terminals/:id/recording", terminalHandler.Recording)
+		admin.POST("/terminals/:id/close", terminalHandler.Close)
+
+		// Watches
+		admin.GET("/watches", adminHandler.ListWatches)
+		admin.POST("/watches", adminHandler.CreateWatch)
+		admin.PUT("/watches/:id", adminHandler.UpdateWatch)
+		admin.DELETE("/watches/:id", adminHandler.DeleteWatch)
+
 		// Alerts
 		admin.GET("/alerts/rules", adminHandler.ListAlertRules)
 		admin.POST("/alerts/rules", adminHandler.CreateAlertRule)
@@ -164,10 +247,22 @@ func main() {
 		admin.GET("/alerts/active", adminHandler.GetActiveAlerts)
 		admin.GET("/alerts/history", adminHandler.GetAlertHistory)
 
+		// Data export/import
+		admin.GET("/export/:dataset", transferHandler.Export)
+		admin.POST("/import/:dataset", transferHandler.Import)
+
 		// Settings
 		admin.GET("/settings", adminHandler.GetSettings)
 		admin.PUT("/settings", adminHandler.UpdateSettings)
 		admin.POST("/settings/password", adminHandler.ChangePassword)
+
+		// Maintenance
+		admin.GET("/maintenance/retention", maintenanceHandler.GetRetention)
+		admin.POST("/maintenance/retention/run", maintenanceHandler.RunRetention)
+		admin.POST("/maintenance/vacuum/incremental", maintenanceHandler.EnableIncrementalVacuum)
+		admin.GET("/maintenance/backups", maintenanceHandler.ListBackups)
+		admin.POST("/maintenance/backups", maintenanceHandler.CreateBackup)
+		admin.GET("/maintenance/backups/:name", maintenanceHandler.DownloadBackup)
 	}
 
 	// Serve static files (frontend)
@@ -177,8 +272,12 @@ func main() {
 	r.Static("/assets", "./web/dist/assets")
 
 	// Start background tasks
-	go runCleanupTask(metricSvc, settingsSvc)
+	go runCleanupTask(retentionSvc)
 	go runTrafficCycleCheck(trafficSvc)
+	go runDeliveryRetry(wsHandler)
+	if cfg.Backup.IntervalHours > 0 {
+		go runBackupTask(backupSvc, time.Duration(cfg.Backup.IntervalHours)*time.Hour)
+	}
 
 	// Start server
 	addr := fmt.Sprintf("%s:%d", cfg.Server.Host, cfg.Server.Port)
@@ -209,22 +308,13 @@ func main() {
 	}
 }
 
-func runCleanupTask(metricSvc service.MetricService, settingsSvc service.SettingsService) {
-	ticker := time.NewTicker(24 * time.Hour)
+func runCleanupTask(retentionSvc service.RetentionService) {
+	ticker := time.NewTicker(1 * time.Hour)
 	defer ticker.Stop()
 
 	for range ticker.C {
-		ctx := context.Background()
-		settings, err := settingsSvc.Get(ctx)
-		if err != nil {
-			continue
-		}
-
-		deleted, err := metricSvc.Cleanup(ctx, settings.DataRetentionDays)
-		if err != nil {
+		if _, err := retentionSvc.Enforce(context.Background()); err != nil {
 			log.Printf("Cleanup error: %v", err)
-		} else if deleted > 0 {
-			log.Printf("Cleaned up %d old metric records", deleted)
 		}
 	}
 }
@@ -240,3 +330,28 @@ func runTrafficCycleCheck(trafficSvc service.TrafficService) {
 		}
 	}
 }
+
+func runDeliveryRetry(wsHandler *ws.Handler) {
+	ticker := time.NewTicker(15 * time.Second)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		if err := wsHandler.RetryDeliveries(context.Background()); err != nil {
+			log.Printf("Task delivery retry error: %v", err)
+		}
+	}
+}
+
+func runBackupTask(backupSvc service.BackupService, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		backup, err := backupSvc.Create(context.Background())
+		if err != nil {
+			log.Printf("Backup error: %v", err)
+			continue
+		}
+		log.Printf("Backup written: %s", backup.Name)
+	}
+}
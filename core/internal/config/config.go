@@ -10,6 +10,8 @@ type Config struct {
 	Database  DatabaseConfig  `json:"database"`
 	Auth      AuthConfig      `json:"auth"`
 	Agent     AgentConfig     `json:"agent"`
+	Backup    BackupConfig    `json:"backup"`
+	Terminal  TerminalConfig  `json:"terminal"`
 }
 
 type ServerConfig struct {
@@ -21,6 +23,22 @@ type DatabaseConfig struct {
 	Path string `json:"path"`
 }
 
+// BackupConfig controls scheduled database snapshots. IntervalHours of 0
+// disables the schedule; manual backups still go to Dir.
+type BackupConfig struct {
+	Dir           string `json:"dir"`
+	IntervalHours int    `json:"interval_hours"`
+	Keep          int    `json:"keep"`
+}
+
+// TerminalConfig controls admin shells on agents. A session is closed after
+// IdleTimeoutMinutes without input; 0 keeps idle sessions open. RecordInput
+// adds what admins type to the recordings, passwords included.
+type TerminalConfig struct {
+	IdleTimeoutMinutes int  `json:"idle_timeout_minutes"`
+	RecordInput        bool `json:"record_input"`
+}
+
 type AuthConfig struct {
 	JWTSecret string `json:"jwt_secret"`
 }
@@ -44,6 +62,15 @@ func Load(path string) (*Config, error) {
 		Agent: AgentConfig{
 			Token: "",
 		},
+		Backup: BackupConfig{
+			Dir:           "backups",
+			IntervalHours: 24,
+			Keep:          7,
+		},
+		Terminal: TerminalConfig{
+			IdleTimeoutMinutes: 15,
+			RecordInput:        true,
+		},
 	}
 
 	data, err := os.ReadFile(path)
@@ -71,6 +98,9 @@ func Load(path string) (*Config, error) {
 	if v := os.Getenv("PROBE_DB_PATH"); v != "" {
 		config.Database.Path = v
 	}
+	if v := os.Getenv("PROBE_BACKUP_DIR"); v != "" {
+		config.Backup.Dir = v
+	}
 	if v := os.Getenv("PROBE_JWT_SECRET"); v != "" {
 		config.Auth.JWTSecret = v
 	}
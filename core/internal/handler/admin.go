@@ -1,8 +1,11 @@
 package handler
 
 import (
+	"context"
+	"errors"
 	"net/http"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -12,16 +15,20 @@ import (
 )
 
 type AdminHandler struct {
-	agentSvc    service.AgentService
-	groupSvc    service.GroupService
-	metricSvc   service.MetricService
-	trafficSvc  service.TrafficService
-	taskSvc     service.TaskService
-	scriptSvc   service.ScriptService
-	alertSvc    service.AlertService
-	settingsSvc service.SettingsService
-	authSvc     service.AuthService
-	wsHandler   *ws.Handler
+	agentSvc     service.AgentService
+	groupSvc     service.GroupService
+	metricSvc    service.MetricService
+	trafficSvc   service.TrafficService
+	taskSvc      service.TaskService
+	scriptSvc    service.ScriptService
+	alertSvc     service.AlertService
+	settingsSvc  service.SettingsService
+	authSvc      service.AuthService
+	processSvc   service.ProcessService
+	containerSvc service.ContainerService
+	watchSvc     service.WatchService
+	customSvc    service.CustomMetricService
+	wsHandler    *ws.Handler
 }
 
 func NewAdminHandler(
@@ -34,19 +41,27 @@ func NewAdminHandler(
 	alertSvc service.AlertService,
 	settingsSvc service.SettingsService,
 	authSvc service.AuthService,
+	processSvc service.ProcessService,
+	containerSvc service.ContainerService,
+	watchSvc service.WatchService,
+	customSvc service.CustomMetricService,
 	wsHandler *ws.Handler,
 ) *AdminHandler {
 	return &AdminHandler{
-		agentSvc:    agentSvc,
-		groupSvc:    groupSvc,
-		metricSvc:   metricSvc,
-		trafficSvc:  trafficSvc,
-		taskSvc:     taskSvc,
-		scriptSvc:   scriptSvc,
-		alertSvc:    alertSvc,
-		settingsSvc: settingsSvc,
-		authSvc:     authSvc,
-		wsHandler:   wsHandler,
+		agentSvc:     agentSvc,
+		groupSvc:     groupSvc,
+		metricSvc:    metricSvc,
+		trafficSvc:   trafficSvc,
+		taskSvc:      taskSvc,
+		scriptSvc:    scriptSvc,
+		alertSvc:     alertSvc,
+		settingsSvc:  settingsSvc,
+		authSvc:      authSvc,
+		processSvc:   processSvc,
+		containerSvc: containerSvc,
+		watchSvc:     watchSvc,
+		customSvc:    customSvc,
+		wsHandler:    wsHandler,
 	}
 }
 
@@ -116,10 +131,12 @@ func (h *AdminHandler) UpdateAgentRemark(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.agentSvc.GetByID(c.Request.Context(), c.Param("id"))
 	if err := h.agentSvc.UpdateRemark(c.Request.Context(), c.Param("id"), &req); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.retargetAgent(c.Request.Context(), before)
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -133,14 +150,29 @@ func (h *AdminHandler) AssignAgentGroup(c *gin.Context) {
 		return
 	}
 
+	before, _ := h.agentSvc.GetByID(c.Request.Context(), c.Param("id"))
 	if err := h.agentSvc.AssignGroup(c.Request.Context(), c.Param("id"), req.GroupID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.retargetAgent(c.Request.Context(), before)
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
 
+// retargetAgent assigns and cancels the tasks whose targeting changed for the
+// agent, given as it was before its group or tags were updated.
+func (h *AdminHandler) retargetAgent(ctx context.Context, before *models.Agent) {
+	if before == nil {
+		return
+	}
+	after, err := h.agentSvc.GetByID(ctx, before.ID)
+	if err != nil || after == nil {
+		return
+	}
+	h.wsHandler.RetargetTasks(ctx, before, after)
+}
+
 func (h *AdminHandler) SetAgentVisibility(c *gin.Context) {
 	var req struct {
 		Visible bool `json:"visible"`
@@ -210,10 +242,15 @@ func (h *AdminHandler) UpdateGroup(c *gin.Context) {
 }
 
 func (h *AdminHandler) DeleteGroup(c *gin.Context) {
-	if err := h.groupSvc.Delete(c.Request.Context(), c.Param("id")); err != nil {
+	groupID := c.Param("id")
+	members, _ := h.agentSvc.List(c.Request.Context(), &models.AgentFilter{GroupID: &groupID})
+	if err := h.groupSvc.Delete(c.Request.Context(), groupID); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	for _, agent := range members {
+		h.retargetAgent(c.Request.Context(), agent)
+	}
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -247,6 +284,229 @@ func (h *AdminHandler) GetAgentMetricsHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, history)
 }
 
+// GetAgentInterfaces returns per-interface network series for the agent.
+func (h *AdminHandler) GetAgentInterfaces(c *gin.Context) {
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	to := time.Now()
+	from := to.Add(-time.Duration(hours) * time.Hour)
+
+	series, err := h.metricSvc.GetInterfaceSeries(c.Request.Context(), c.Param("id"), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// GetAgentProcesses returns the most recent top-processes snapshot reported
+// by the agent.
+func (h *AdminHandler) GetAgentProcesses(c *gin.Context) {
+	snapshot := h.processSvc.Latest(c.Param("id"))
+	if snapshot == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no process snapshot"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// GetAgentCollectors returns how each collector did in the agent's latest
+// sample, including the errors it reported.
+func (h *AdminHandler) GetAgentCollectors(c *gin.Context) {
+	report := h.metricSvc.CollectorReport(c.Param("id"))
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no collector report"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// RefreshAgentProcesses asks the agent for a new snapshot. The result is
+// available from GetAgentProcesses once the agent replies.
+func (h *AdminHandler) RefreshAgentProcesses(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if limit <= 0 || limit > 50 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 50"})
+		return
+	}
+
+	if err := h.wsHandler.RequestProcesses(c.Param("id"), limit); err != nil {
+		if errors.Is(err, ws.ErrAgentNotConnected) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "refresh requested"})
+}
+
+// GetAgentContainers lists the containers on an agent with their latest
+// usage, including ones that have since been removed.
+func (h *AdminHandler) GetAgentContainers(c *gin.Context) {
+	containers, err := h.containerSvc.List(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, containers)
+}
+
+// GetAgentWatches returns the latest result of each watch on an agent.
+func (h *AdminHandler) GetAgentWatches(c *gin.Context) {
+	c.JSON(http.StatusOK, h.watchSvc.Status(c.Param("id")))
+}
+
+// GetAgentCustomMetrics lists the latest value of each plugin series the
+// agent reported in the last day.
+func (h *AdminHandler) GetAgentCustomMetrics(c *gin.Context) {
+	metrics, err := h.customSvc.List(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, metrics)
+}
+
+func (h *AdminHandler) GetCustomMetricHistory(c *gin.Context) {
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	to := time.Now()
+	from := to.Add(-time.Duration(hours) * time.Hour)
+
+	history, err := h.customSvc.GetHistory(c.Request.Context(), c.Param("id"), c.Param("name"), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+func (h *AdminHandler) GetContainerMetrics(c *gin.Context) {
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	to := time.Now()
+	from := to.Add(-time.Duration(hours) * time.Hour)
+
+	history, err := h.containerSvc.GetHistory(c.Request.Context(), c.Param("id"), c.Param("cid"), from, to)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, history)
+}
+
+// QueryMetrics aggregates a metric over a time window for an agent, a group or
+// a tag selection. Series are split per agent, per group or merged into one
+// depending on group_by.
+func (h *AdminHandler) QueryMetrics(c *gin.Context) {
+	ctx := c.Request.Context()
+
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	if v := c.Query("hours"); v != "" {
+		hours, err := strconv.Atoi(v)
+		if err != nil || hours <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid hours"})
+			return
+		}
+		from = to.Add(-time.Duration(hours) * time.Hour)
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from, expected RFC3339"})
+			return
+		}
+		from = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to, expected RFC3339"})
+			return
+		}
+		to = t
+	}
+
+	step, err := strconv.Atoi(c.DefaultQuery("step", "300"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid step"})
+		return
+	}
+
+	var agents []*models.Agent
+	if agentID := c.Query("agent_id"); agentID != "" {
+		agent, err := h.agentSvc.GetByID(ctx, agentID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if agent == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "agent not found"})
+			return
+		}
+		agents = []*models.Agent{agent}
+	} else {
+		filter := &models.AgentFilter{}
+		if groupID := c.Query("group_id"); groupID != "" {
+			filter.GroupID = &groupID
+		}
+		if tags := c.Query("tags"); tags != "" {
+			filter.Tags = strings.Split(tags, ",")
+		}
+		agents, err = h.agentSvc.List(ctx, filter)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	series := make(map[string][]string)
+	groupBy := c.DefaultQuery("group_by", "agent")
+	for _, agent := range agents {
+		key := "all"
+		switch groupBy {
+		case "agent":
+			key = agent.ID
+		case "group":
+			key = "ungrouped"
+			if agent.GroupID != nil {
+				key = *agent.GroupID
+			}
+		case "none":
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "group_by must be agent, group or none"})
+			return
+		}
+		series[key] = append(series[key], agent.ID)
+	}
+
+	result, err := h.metricSvc.Query(ctx, &models.MetricQuery{
+		Metric:      models.MetricType(c.Query("metric")),
+		Aggregation: models.Aggregation(c.DefaultQuery("agg", "avg")),
+		From:        from,
+		To:          to,
+		Step:        time.Duration(step) * time.Second,
+		Series:      series,
+	})
+	if errors.Is(err, service.ErrInvalidMetricQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
 func (h *AdminHandler) GetAgentTraffic(c *gin.Context) {
 	stats, err := h.trafficSvc.GetStats(c.Request.Context(), c.Param("id"))
 	if err != nil {
@@ -257,6 +517,16 @@ func (h *AdminHandler) GetAgentTraffic(c *gin.Context) {
 	c.JSON(http.StatusOK, stats)
 }
 
+func (h *AdminHandler) GetAgentTrafficArchives(c *gin.Context) {
+	archives, err := h.trafficSvc.ListArchives(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, archives)
+}
+
 func (h *AdminHandler) ConfigureTrafficCycle(c *gin.Context) {
 	var req struct {
 		StartDate string `json:"start_date"`
@@ -301,14 +571,15 @@ func (h *AdminHandler) CreateTask(c *gin.Context) {
 	}
 
 	if err := h.taskSvc.Create(c.Request.Context(), &task); err != nil {
+		if errors.Is(err, service.ErrInvalidTask) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
 
-	// Assign to agents
-	for _, agentID := range task.AgentIDs {
-		h.wsHandler.AssignTask(agentID, &task)
-	}
+	h.wsHandler.PushTask(c.Request.Context(), &task)
 
 	c.JSON(http.StatusCreated, task)
 }
@@ -324,11 +595,201 @@ func (h *AdminHandler) GetTaskResults(c *gin.Context) {
 	c.JSON(http.StatusOK, results)
 }
 
+// GetTaskDeliveries returns the task's state on each agent it was assigned
+// to.
+func (h *AdminHandler) GetTaskDeliveries(c *gin.Context) {
+	deliveries, err := h.taskSvc.ListDeliveries(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// GetPathDiff compares a traceroute task's latest path on an agent with its
+// path the given number of hours earlier, a day by default.
+func (h *AdminHandler) GetPathDiff(c *gin.Context) {
+	agentID := c.Query("agent_id")
+	if agentID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "agent_id is required"})
+		return
+	}
+	hours, _ := strconv.Atoi(c.DefaultQuery("hours", "24"))
+	if hours <= 0 {
+		hours = 24
+	}
+
+	diff, err := h.taskSvc.DiffPath(c.Request.Context(), c.Param("id"), agentID, time.Duration(hours)*time.Hour)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if diff == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no path recorded"})
+		return
+	}
+
+	c.JSON(http.StatusOK, diff)
+}
+
+// GetTaskSeries aggregates a field of a task's results over a time window,
+// one series per agent. Without a step the window is split into about 300
+// buckets of at least a minute.
+func (h *AdminHandler) GetTaskSeries(c *gin.Context) {
+	to := time.Now()
+	from := to.Add(-24 * time.Hour)
+	if v := c.Query("hours"); v != "" {
+		hours, err := strconv.Atoi(v)
+		if err != nil || hours <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid hours"})
+			return
+		}
+		from = to.Add(-time.Duration(hours) * time.Hour)
+	}
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from, expected RFC3339"})
+			return
+		}
+		from = t
+	}
+	if v := c.Query("to"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to, expected RFC3339"})
+			return
+		}
+		to = t
+	}
+
+	step := (to.Sub(from) / 300).Round(time.Minute)
+	if step < time.Minute {
+		step = time.Minute
+	}
+	if v := c.Query("step"); v != "" {
+		seconds, err := strconv.Atoi(v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid step"})
+			return
+		}
+		step = time.Duration(seconds) * time.Second
+	}
+
+	var agentIDs []string
+	if v := c.Query("agent_id"); v != "" {
+		agentIDs = strings.Split(v, ",")
+	}
+
+	result, err := h.taskSvc.QuerySeries(c.Request.Context(), &models.TaskSeriesQuery{
+		TaskID:      c.Param("id"),
+		Field:       c.Query("field"),
+		AgentIDs:    agentIDs,
+		Aggregation: models.Aggregation(c.DefaultQuery("agg", "avg")),
+		From:        from,
+		To:          to,
+		Step:        step,
+	})
+	if errors.Is(err, service.ErrInvalidSeriesQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if result == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// GetTaskSLA reports the availability of a task's target over 24h, 7d, 30d
+// and 90d, or over from to to (RFC3339, to defaults to now) for a report
+// period. quorum is how many agents must fail for the target to count as
+// down, a majority of the task's agents by default.
+func (h *AdminHandler) GetTaskSLA(c *gin.Context) {
+	quorum := 0
+	if v := c.Query("quorum"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid quorum"})
+			return
+		}
+		quorum = n
+	}
+
+	var from, to time.Time
+	if v := c.Query("from"); v != "" {
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid from, expected RFC3339"})
+			return
+		}
+		from = t
+	}
+	if v := c.Query("to"); v != "" {
+		if from.IsZero() {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to needs from"})
+			return
+		}
+		t, err := time.Parse(time.RFC3339, v)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid to, expected RFC3339"})
+			return
+		}
+		to = t
+	}
+
+	report, err := h.taskSvc.SLA(c.Request.Context(), c.Param("id"), quorum, from, to)
+	if errors.Is(err, service.ErrInvalidSLAQuery) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if report == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "task not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// ListSLA summarizes the availability of every recurring task's target.
+func (h *AdminHandler) ListSLA(c *gin.Context) {
+	reports, err := h.taskSvc.ListSLA(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, reports)
+}
+
+// ListCertificates returns the latest certificate of every tls task on each
+// agent, soonest to expire first.
+func (h *AdminHandler) ListCertificates(c *gin.Context) {
+	certs, err := h.taskSvc.ListCertificates(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, certs)
+}
+
 func (h *AdminHandler) CancelTask(c *gin.Context) {
 	if err := h.taskSvc.Cancel(c.Request.Context(), c.Param("id")); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
+	h.wsHandler.StopTask(c.Param("id"))
 
 	c.JSON(http.StatusOK, gin.H{"success": true})
 }
@@ -401,6 +862,10 @@ func (h *AdminHandler) CreateAlertRule(c *gin.Context) {
 	}
 
 	if err := h.alertSvc.CreateRule(c.Request.Context(), &rule); err != nil {
+		if errors.Is(err, service.ErrInvalidAlertRule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -417,6 +882,10 @@ func (h *AdminHandler) UpdateAlertRule(c *gin.Context) {
 	rule.ID = c.Param("id")
 
 	if err := h.alertSvc.UpdateRule(c.Request.Context(), &rule); err != nil {
+		if errors.Is(err, service.ErrInvalidAlertRule) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
 		return
 	}
@@ -454,6 +923,68 @@ func (h *AdminHandler) GetAlertHistory(c *gin.Context) {
 	c.JSON(http.StatusOK, alerts)
 }
 
+// Watches
+func (h *AdminHandler) ListWatches(c *gin.Context) {
+	watches, err := h.watchSvc.List(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, watches)
+}
+
+func (h *AdminHandler) CreateWatch(c *gin.Context) {
+	var watch models.Watch
+	if err := c.ShouldBindJSON(&watch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.watchSvc.Create(c.Request.Context(), &watch); err != nil {
+		if errors.Is(err, service.ErrInvalidWatch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.wsHandler.PushWatches(c.Request.Context())
+
+	c.JSON(http.StatusCreated, watch)
+}
+
+func (h *AdminHandler) UpdateWatch(c *gin.Context) {
+	var watch models.Watch
+	if err := c.ShouldBindJSON(&watch); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	watch.ID = c.Param("id")
+
+	if err := h.watchSvc.Update(c.Request.Context(), &watch); err != nil {
+		if errors.Is(err, service.ErrInvalidWatch) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.wsHandler.PushWatches(c.Request.Context())
+
+	c.JSON(http.StatusOK, watch)
+}
+
+func (h *AdminHandler) DeleteWatch(c *gin.Context) {
+	if err := h.watchSvc.Delete(c.Request.Context(), c.Param("id")); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.wsHandler.PushWatches(c.Request.Context())
+
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
 // Settings
 func (h *AdminHandler) GetSettings(c *gin.Context) {
 	settings, err := h.settingsSvc.Get(c.Request.Context())
@@ -471,6 +1002,10 @@ func (h *AdminHandler) UpdateSettings(c *gin.Context) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 		return
 	}
+	if !settings.VacuumMode.Valid() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "unknown vacuum_mode"})
+		return
+	}
 
 	if err := h.settingsSvc.Update(c.Request.Context(), &settings); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
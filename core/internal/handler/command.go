@@ -0,0 +1,164 @@
+package handler
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/service"
+	"github.com/probe-system/core/internal/ws"
+)
+
+type CommandHandler struct {
+	commandSvc service.CommandService
+	authSvc    service.AuthService
+	wsHandler  *ws.Handler
+	upgrader   websocket.Upgrader
+}
+
+func NewCommandHandler(commandSvc service.CommandService, authSvc service.AuthService, wsHandler *ws.Handler) *CommandHandler {
+	return &CommandHandler{
+		commandSvc: commandSvc,
+		authSvc:    authSvc,
+		wsHandler:  wsHandler,
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  1024,
+			WriteBufferSize: 4096,
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+	}
+}
+
+// Run starts a command or script on the selected agents right away. The
+// output is streamed by Watch.
+func (h *CommandHandler) Run(c *gin.Context) {
+	var run models.CommandRun
+	if err := c.ShouldBindJSON(&run); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if user, ok := c.Get("user"); ok {
+		run.CreatedBy = user.(*models.User).Username
+	}
+
+	checksum, err := h.commandSvc.Create(c.Request.Context(), &run)
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidCommand) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	h.wsHandler.RunCommand(c.Request.Context(), &run, checksum)
+
+	created, err := h.commandSvc.GetByID(c.Request.Context(), run.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusCreated, created)
+}
+
+// List returns the latest runs without their output, 50 by default.
+func (h *CommandHandler) List(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	runs, err := h.commandSvc.List(c.Request.Context(), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, runs)
+}
+
+func (h *CommandHandler) Get(c *gin.Context) {
+	run, err := h.commandSvc.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "command run not found"})
+		return
+	}
+	c.JSON(http.StatusOK, run)
+}
+
+func (h *CommandHandler) Cancel(c *gin.Context) {
+	run, err := h.commandSvc.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if run == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "command run not found"})
+		return
+	}
+	if run.Status != models.CommandRunning {
+		c.JSON(http.StatusConflict, gin.H{"error": "command run has already ended"})
+		return
+	}
+
+	if err := h.wsHandler.CancelCommand(c.Request.Context(), run.ID); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Watch streams a run's events (see models.CommandEvent) over WebSocket
+// until it ends. Browsers cannot set headers on WebSocket requests, so the
+// admin token is passed in the token query parameter.
+func (h *CommandHandler) Watch(c *gin.Context) {
+	user, err := h.authSvc.ValidateToken(c.Query("token"))
+	if err != nil || user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+
+	events, stop, err := h.commandSvc.Watch(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if events == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "command run not found"})
+		return
+	}
+	defer stop()
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Command WebSocket upgrade failed: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// The browser only closes the connection.
+	go func() {
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				stop()
+				return
+			}
+		}
+	}()
+
+	for event := range events {
+		if err := conn.WriteJSON(event); err != nil {
+			return
+		}
+	}
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/service"
+)
+
+type MaintenanceHandler struct {
+	retentionSvc service.RetentionService
+	settingsSvc  service.SettingsService
+	backupSvc    service.BackupService
+}
+
+func NewMaintenanceHandler(retentionSvc service.RetentionService, settingsSvc service.SettingsService, backupSvc service.BackupService) *MaintenanceHandler {
+	return &MaintenanceHandler{
+		retentionSvc: retentionSvc,
+		settingsSvc:  settingsSvc,
+		backupSvc:    backupSvc,
+	}
+}
+
+// GetRetention returns the configured retention periods and recent runs.
+func (h *MaintenanceHandler) GetRetention(c *gin.Context) {
+	settings, err := h.settingsSvc.Get(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	autoVacuum, err := h.retentionSvc.AutoVacuum(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"policies": gin.H{
+			"metrics":          settings.DataRetentionDays,
+			"task_results":     settings.TaskResultRetentionDays,
+			"task_series":      settings.TaskSeriesRetentionDays,
+			"traffic":          settings.TrafficRetentionDays,
+			"alerts":           settings.AlertRetentionDays,
+			"traffic_archives": settings.TrafficArchiveRetentionDays,
+		},
+		"vacuum_mode": settings.VacuumMode,
+		"auto_vacuum": autoVacuum,
+		"runs":        h.retentionSvc.Reports(),
+	})
+}
+
+func (h *MaintenanceHandler) RunRetention(c *gin.Context) {
+	report, err := h.retentionSvc.Enforce(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// EnableIncrementalVacuum converts the database to incremental auto-vacuum.
+// It runs a full VACUUM, so every request waits until it finishes.
+func (h *MaintenanceHandler) EnableIncrementalVacuum(c *gin.Context) {
+	if err := h.retentionSvc.EnableIncrementalVacuum(c.Request.Context()); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"auto_vacuum": models.VacuumModeIncremental})
+}
+
+func (h *MaintenanceHandler) ListBackups(c *gin.Context) {
+	backups, err := h.backupSvc.List()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, backups)
+}
+
+// CreateBackup takes a snapshot of the live database without stopping core.
+func (h *MaintenanceHandler) CreateBackup(c *gin.Context) {
+	backup, err := h.backupSvc.Create(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusCreated, backup)
+}
+
+func (h *MaintenanceHandler) DownloadBackup(c *gin.Context) {
+	name := c.Param("name")
+	path, err := h.backupSvc.Path(name)
+	if err != nil {
+		if errors.Is(err, service.ErrBackupNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.FileAttachment(path, name)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/gin-gonic/gin"
+	"github.com/probe-system/core/internal/models"
 	"github.com/probe-system/core/internal/service"
 )
 
@@ -42,9 +43,42 @@ type PublicLocationResponse struct {
 }
 
 type PublicMetricsResponse struct {
-	CPU           float64 `json:"cpu"`
-	MemoryPercent float64 `json:"memory_percent"`
-	DiskPercent   float64 `json:"disk_percent"`
+	CPU           float64   `json:"cpu"`
+	CPUCores      []float64 `json:"cpu_cores,omitempty"`
+	MemoryPercent float64   `json:"memory_percent"`
+	SwapPercent   float64   `json:"swap_percent"`
+	DiskPercent   float64   `json:"disk_percent"`
+	Load1         float64   `json:"load1"`
+	Load5         float64   `json:"load5"`
+	Load15        float64   `json:"load15"`
+	Uptime        uint64    `json:"uptime"`
+	BootTime      uint64    `json:"boot_time"`
+	Processes     uint64    `json:"processes"`
+	Threads       uint64    `json:"threads"`
+}
+
+func newPublicMetricsResponse(metrics *models.Metrics) *PublicMetricsResponse {
+	var maxDisk float64
+	for _, d := range metrics.Disks {
+		if d.Percent > maxDisk {
+			maxDisk = d.Percent
+		}
+	}
+
+	return &PublicMetricsResponse{
+		CPU:           metrics.CPU,
+		CPUCores:      metrics.CPUCores,
+		MemoryPercent: metrics.Memory.Percent,
+		SwapPercent:   metrics.Swap.Percent,
+		DiskPercent:   maxDisk,
+		Load1:         metrics.Load.Load1,
+		Load5:         metrics.Load.Load5,
+		Load15:        metrics.Load.Load15,
+		Uptime:        metrics.Host.Uptime,
+		BootTime:      metrics.Host.BootTime,
+		Processes:     metrics.Host.Processes,
+		Threads:       metrics.Host.Threads,
+	}
 }
 
 type PublicTrafficResponse struct {
@@ -83,18 +117,7 @@ func (h *PublicHandler) ListAgents(c *gin.Context) {
 
 		// Get latest metrics
 		if metrics, err := h.metricSvc.GetLatest(ctx, agent.ID); err == nil && metrics != nil {
-			var maxDisk float64
-			for _, d := range metrics.Disks {
-				if d.Percent > maxDisk {
-					maxDisk = d.Percent
-				}
-			}
-
-			resp.Metrics = &PublicMetricsResponse{
-				CPU:           metrics.CPU,
-				MemoryPercent: metrics.Memory.Percent,
-				DiskPercent:   maxDisk,
-			}
+			resp.Metrics = newPublicMetricsResponse(metrics)
 		}
 
 		// Get traffic stats
@@ -142,18 +165,7 @@ func (h *PublicHandler) GetAgent(c *gin.Context) {
 	}
 
 	if metrics, err := h.metricSvc.GetLatest(ctx, agent.ID); err == nil && metrics != nil {
-		var maxDisk float64
-		for _, d := range metrics.Disks {
-			if d.Percent > maxDisk {
-				maxDisk = d.Percent
-			}
-		}
-
-		resp.Metrics = &PublicMetricsResponse{
-			CPU:           metrics.CPU,
-			MemoryPercent: metrics.Memory.Percent,
-			DiskPercent:   maxDisk,
-		}
+		resp.Metrics = newPublicMetricsResponse(metrics)
 	}
 
 	if traffic, err := h.trafficSvc.GetStats(ctx, agent.ID); err == nil && traffic != nil {
@@ -0,0 +1,264 @@
+package handler
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gorilla/websocket"
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/service"
+	"github.com/probe-system/core/internal/ws"
+)
+
+const (
+	terminalPingPeriod = 30 * time.Second
+	terminalWriteWait  = 10 * time.Second
+	// maxTerminalMessage bounds what the browser may send at once, e.g. a
+	// paste.
+	maxTerminalMessage = 64 * 1024
+)
+
+type TerminalHandler struct {
+	terminalSvc service.TerminalService
+	authSvc     service.AuthService
+	wsHandler   *ws.Handler
+	// idleTimeout is in seconds; 0 keeps idle sessions open.
+	idleTimeout int
+	upgrader    websocket.Upgrader
+}
+
+func NewTerminalHandler(terminalSvc service.TerminalService, authSvc service.AuthService, wsHandler *ws.Handler, idleTimeout time.Duration) *TerminalHandler {
+	return &TerminalHandler{
+		terminalSvc: terminalSvc,
+		authSvc:     authSvc,
+		wsHandler:   wsHandler,
+		idleTimeout: int(idleTimeout.Seconds()),
+		upgrader: websocket.Upgrader{
+			ReadBufferSize:  4096,
+			WriteBufferSize: 16 * 1024,
+			CheckOrigin: func(r *http.Request) bool {
+				return true
+			},
+		},
+	}
+}
+
+// terminalMessage is what the browser sends: "input" with the typed Data,
+// or "resize" with the new size.
+type terminalMessage struct {
+	Type string `json:"type"`
+	Data string `json:"data"`
+	Cols int    `json:"cols"`
+	Rows int    `json:"rows"`
+}
+
+// Connect opens a shell on the agent and bridges it to the browser over
+// WebSocket. Output is sent as binary messages; the session itself as a
+// "session" text message first and an "exit" one when it ends. Browsers
+// cannot set headers on WebSocket requests, so the admin token is passed in
+// the token query parameter.
+func (h *TerminalHandler) Connect(c *gin.Context) {
+	user, err := h.authSvc.ValidateToken(c.Query("token"))
+	if err != nil || user == nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
+		return
+	}
+
+	cols, _ := strconv.Atoi(c.DefaultQuery("cols", "80"))
+	rows, _ := strconv.Atoi(c.DefaultQuery("rows", "24"))
+	if cols <= 0 || cols > 1000 || rows <= 0 || rows > 1000 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "cols and rows must be between 1 and 1000"})
+		return
+	}
+
+	ctx := c.Request.Context()
+	session := &models.TerminalSession{
+		AgentID:    c.Param("agentId"),
+		Username:   user.Username,
+		RemoteAddr: c.ClientIP(),
+		Width:      cols,
+		Height:     rows,
+	}
+	output, err := h.terminalSvc.Start(ctx, session)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.wsHandler.OpenTerminal(session, h.idleTimeout); err != nil {
+		h.terminalSvc.End(ctx, session.ID, err.Error())
+		if errors.Is(err, ws.ErrAgentNotConnected) {
+			c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	conn, err := h.upgrader.Upgrade(c.Writer, c.Request, nil)
+	if err != nil {
+		log.Printf("Terminal WebSocket upgrade failed: %v", err)
+		h.wsHandler.CloseTerminal(ctx, session.AgentID, session.ID, "browser connection failed")
+		return
+	}
+	defer conn.Close()
+	// The request's context ends with the handler; the session may not.
+	ctx = context.Background()
+	log.Printf("Terminal session %s opened on %s by %s", session.ID, session.AgentID, session.Username)
+
+	go h.readBrowser(conn, session)
+
+	// session itself belongs to the service now.
+	snapshot, err := h.terminalSvc.GetByID(ctx, session.ID)
+	if err == nil {
+		conn.SetWriteDeadline(time.Now().Add(terminalWriteWait))
+		err = conn.WriteJSON(gin.H{"type": "session", "session": snapshot})
+	}
+	if err != nil {
+		h.wsHandler.CloseTerminal(ctx, session.AgentID, session.ID, "browser connection lost")
+		return
+	}
+
+	ticker := time.NewTicker(terminalPingPeriod)
+	defer ticker.Stop()
+	for {
+		select {
+		case data, ok := <-output:
+			if !ok {
+				h.sendExit(conn, session.ID)
+				return
+			}
+			conn.SetWriteDeadline(time.Now().Add(terminalWriteWait))
+			if err := conn.WriteMessage(websocket.BinaryMessage, data); err != nil {
+				h.wsHandler.CloseTerminal(ctx, session.AgentID, session.ID, "browser connection lost")
+				return
+			}
+		case <-ticker.C:
+			conn.SetWriteDeadline(time.Now().Add(terminalWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				h.wsHandler.CloseTerminal(ctx, session.AgentID, session.ID, "browser connection lost")
+				return
+			}
+		}
+	}
+}
+
+// readBrowser passes the browser's input on to the agent until it closes the
+// connection, which ends the session.
+func (h *TerminalHandler) readBrowser(conn *websocket.Conn, session *models.TerminalSession) {
+	ctx := context.Background()
+	conn.SetReadLimit(maxTerminalMessage)
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			h.wsHandler.CloseTerminal(ctx, session.AgentID, session.ID, "closed by user")
+			return
+		}
+		var msg terminalMessage
+		if err := json.Unmarshal(data, &msg); err != nil {
+			continue
+		}
+		switch msg.Type {
+		case "input":
+			err = h.wsHandler.TerminalInput(ctx, session.AgentID, session.ID, []byte(msg.Data))
+		case "resize":
+			if msg.Cols > 0 && msg.Cols <= 1000 && msg.Rows > 0 && msg.Rows <= 1000 {
+				err = h.wsHandler.ResizeTerminal(ctx, session.AgentID, session.ID, msg.Cols, msg.Rows)
+			}
+		}
+		if err != nil {
+			log.Printf("Failed to pass %s to terminal session %s: %v", msg.Type, session.ID, err)
+		}
+	}
+}
+
+func (h *TerminalHandler) sendExit(conn *websocket.Conn, sessionID string) {
+	session, err := h.terminalSvc.GetByID(context.Background(), sessionID)
+	if err != nil || session == nil {
+		return
+	}
+	log.Printf("Terminal session %s on %s closed: %s", session.ID, session.AgentID, session.Reason)
+	conn.SetWriteDeadline(time.Now().Add(terminalWriteWait))
+	conn.WriteJSON(gin.H{"type": "exit", "session": session})
+	conn.WriteMessage(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""))
+}
+
+// List returns the latest sessions, of one agent with agent_id, 50 by
+// default.
+func (h *TerminalHandler) List(c *gin.Context) {
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if limit <= 0 || limit > 500 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be between 1 and 500"})
+		return
+	}
+
+	sessions, err := h.terminalSvc.List(c.Request.Context(), c.Query("agent_id"), limit)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (h *TerminalHandler) Get(c *gin.Context) {
+	session, err := h.terminalSvc.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "terminal session not found"})
+		return
+	}
+	c.JSON(http.StatusOK, session)
+}
+
+// Close ends a session that is still open, e.g. another admin's.
+func (h *TerminalHandler) Close(c *gin.Context) {
+	session, err := h.terminalSvc.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "terminal session not found"})
+		return
+	}
+	if session.Status == models.TerminalClosed {
+		c.JSON(http.StatusConflict, gin.H{"error": "terminal session is already closed"})
+		return
+	}
+
+	reason := "closed by an admin"
+	if user, ok := c.Get("user"); ok {
+		reason = "closed by " + user.(*models.User).Username
+	}
+	h.wsHandler.CloseTerminal(c.Request.Context(), session.AgentID, session.ID, reason)
+	c.JSON(http.StatusOK, gin.H{"success": true})
+}
+
+// Recording downloads the session's recording as an asciicast v2 file, which
+// asciinema and its web player can replay.
+func (h *TerminalHandler) Recording(c *gin.Context) {
+	session, err := h.terminalSvc.GetByID(c.Request.Context(), c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+	if session == nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "terminal session not found"})
+		return
+	}
+
+	c.Header("Content-Type", "application/x-asciicast")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="terminal-%s.cast"`, session.ID))
+	if err := h.terminalSvc.WriteRecording(c.Request.Context(), session.ID, c.Writer); err != nil {
+		log.Printf("Failed to send the recording of terminal session %s: %v", session.ID, err)
+	}
+}
@@ -0,0 +1,116 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/service"
+)
+
+type TransferHandler struct {
+	transferSvc service.TransferService
+}
+
+func NewTransferHandler(transferSvc service.TransferService) *TransferHandler {
+	return &TransferHandler{transferSvc: transferSvc}
+}
+
+// Export streams a dataset as CSV or NDJSON, optionally filtered by agent and
+// time range (RFC3339 from/to).
+func (h *TransferHandler) Export(c *gin.Context) {
+	dataset := models.Dataset(c.Param("dataset"))
+	format := models.TransferFormat(c.DefaultQuery("format", "csv"))
+
+	filter := &models.ExportFilter{AgentID: c.Query("agent_id")}
+	for _, p := range []struct {
+		name string
+		dst  *time.Time
+	}{{"from", &filter.From}, {"to", &filter.To}} {
+		if v := c.Query(p.name); v != "" {
+			t, err := time.Parse(time.RFC3339, v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "invalid " + p.name + ", expected RFC3339"})
+				return
+			}
+			*p.dst = t
+		}
+	}
+
+	contentType := "text/csv; charset=utf-8"
+	if format == models.TransferFormatNDJSON {
+		contentType = "application/x-ndjson"
+	}
+	c.Header("Content-Type", contentType)
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s-%s.%s"`,
+		dataset, time.Now().Format("20060102-150405"), format))
+
+	err := h.transferSvc.Export(c.Request.Context(), dataset, format, filter, c.Writer)
+	if err == nil {
+		return
+	}
+	if c.Writer.Written() {
+		// Headers are already sent, the client sees a truncated stream.
+		log.Printf("Export of %s aborted: %v", dataset, err)
+		return
+	}
+	if errors.Is(err, service.ErrInvalidTransfer) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+}
+
+// Import reads an export from the request body. agent_map and task_map take
+// comma separated old:new ID pairs for agents and tasks that were recreated
+// under a different ID on this core.
+func (h *TransferHandler) Import(c *gin.Context) {
+	dataset := models.Dataset(c.Param("dataset"))
+	format := models.TransferFormat(c.DefaultQuery("format", "csv"))
+
+	agentMap, err := parseIDMap(c.Query("agent_map"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid agent_map: " + err.Error()})
+		return
+	}
+	taskMap, err := parseIDMap(c.Query("task_map"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid task_map: " + err.Error()})
+		return
+	}
+
+	result, err := h.transferSvc.Import(c.Request.Context(), dataset, format, c.Request.Body, &models.ImportOptions{
+		AgentMap: agentMap,
+		TaskMap:  taskMap,
+	})
+	if errors.Is(err, service.ErrInvalidTransfer) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+func parseIDMap(s string) (map[string]string, error) {
+	m := make(map[string]string)
+	if s == "" {
+		return m, nil
+	}
+	for _, pair := range strings.Split(s, ",") {
+		parts := strings.SplitN(pair, ":", 2)
+		if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return nil, fmt.Errorf("expected old:new, got %q", pair)
+		}
+		m[parts[0]] = parts[1]
+	}
+	return m, nil
+}
@@ -18,8 +18,73 @@ const (
 	MetricTypeMemory  MetricType = "memory"
 	MetricTypeDisk    MetricType = "disk"
 	MetricTypeTraffic MetricType = "traffic"
+	MetricTypeNetIn   MetricType = "net_in"
+	MetricTypeNetOut  MetricType = "net_out"
+
+	MetricTypeCPUCoreMax MetricType = "cpu_core_max"
+	MetricTypeSwap       MetricType = "swap"
+	MetricTypeLoad1      MetricType = "load1"
+	MetricTypeLoad5      MetricType = "load5"
+	MetricTypeLoad15     MetricType = "load15"
+	MetricTypeUptime     MetricType = "uptime"
+	MetricTypeProcesses  MetricType = "processes"
+	MetricTypeThreads    MetricType = "threads"
+
+	// Disk I/O rates are summed across devices; util and await take the
+	// busiest device.
+	MetricTypeDiskRead  MetricType = "disk_read"
+	MetricTypeDiskWrite MetricType = "disk_write"
+	MetricTypeDiskIOPS  MetricType = "disk_iops"
+	MetricTypeDiskUtil  MetricType = "disk_util"
+	MetricTypeDiskAwait MetricType = "disk_await"
+
+	// Socket counts. The TCP state metrics count connections in that state;
+	// tcp_total counts all connections except listening sockets.
+	MetricTypeTCPEstablished MetricType = "tcp_established"
+	MetricTypeTCPTimeWait    MetricType = "tcp_time_wait"
+	MetricTypeTCPSynRecv     MetricType = "tcp_syn_recv"
+	MetricTypeTCPCloseWait   MetricType = "tcp_close_wait"
+	MetricTypeTCPTotal       MetricType = "tcp_total"
+	MetricTypeUDPSockets     MetricType = "udp_sockets"
+	MetricTypeListenSockets  MetricType = "listen_sockets"
+
+	// Container metrics are evaluated per container matching the rule's
+	// Container pattern: CPU and memory take the highest, restarts are summed
+	// since the previous report and down counts containers not running.
+	MetricTypeContainerCPU      MetricType = "container_cpu"
+	MetricTypeContainerMemory   MetricType = "container_memory"
+	MetricTypeContainerRestarts MetricType = "container_restarts"
+	MetricTypeContainerDown     MetricType = "container_down"
+
+	// MetricTypeWatchDown counts the watched processes and ports that are
+	// down among the watches matching the rule's Watch pattern.
+	MetricTypeWatchDown MetricType = "watch_down"
+
+	// MetricTypeCustom takes the highest value among the plugin series whose
+	// name matches the rule's CustomMetric pattern.
+	MetricTypeCustom MetricType = "custom"
+
+	// MetricTypeCertExpiry is the fewest days until expiry among the
+	// certificates checked by tls tasks whose target matches the rule's
+	// Certificate pattern.
+	MetricTypeCertExpiry MetricType = "cert_expiry"
+
+	// DNS metrics are evaluated over the latest result of each dns task
+	// whose name matches the rule's DNS pattern: dns_failed counts the
+	// failed checks and dns_time takes the slowest resolution in ms.
+	MetricTypeDNSFailed MetricType = "dns_failed"
+	MetricTypeDNSTime   MetricType = "dns_time"
 )
 
+func (t MetricType) IsContainer() bool {
+	switch t {
+	case MetricTypeContainerCPU, MetricTypeContainerMemory,
+		MetricTypeContainerRestarts, MetricTypeContainerDown:
+		return true
+	}
+	return false
+}
+
 type Operator string
 
 const (
@@ -38,6 +103,11 @@ type AlertRule struct {
 	Cooldown     int        `json:"cooldown" db:"cooldown_sec"`
 	AgentIDs     []string   `json:"agent_ids" db:"-"`
 	AgentIDsJSON string     `json:"-" db:"agent_ids"`
+	Container    string     `json:"container" db:"container"`
+	Watch        string     `json:"watch" db:"watch"`
+	CustomMetric string     `json:"custom_metric" db:"custom_metric"`
+	Certificate  string     `json:"certificate" db:"certificate"`
+	DNS          string     `json:"dns" db:"dns"`
 	Enabled      bool       `json:"enabled" db:"enabled"`
 	CreatedAt    time.Time  `json:"created_at" db:"created_at"`
 	UpdatedAt    time.Time  `json:"updated_at" db:"updated_at"`
@@ -56,6 +126,9 @@ type Alert struct {
 	Message     string      `json:"message" db:"message"`
 	TriggeredAt time.Time   `json:"triggered_at" db:"triggered_at"`
 	ResolvedAt  *time.Time  `json:"resolved_at" db:"resolved_at"`
+
+	// Processes is the agent's top-processes snapshot when the alert fired.
+	Processes *ProcessSnapshot `json:"processes,omitempty" db:"processes"`
 }
 
 func (r *AlertRule) CheckThreshold(value float64) bool {
@@ -0,0 +1,84 @@
+package models
+
+import "time"
+
+type CommandStatus string
+
+const (
+	CommandPending  CommandStatus = "pending"
+	CommandRunning  CommandStatus = "running"
+	CommandExited   CommandStatus = "exited"
+	CommandFailed   CommandStatus = "failed"
+	CommandCanceled CommandStatus = "canceled"
+	CommandFinished CommandStatus = "finished"
+)
+
+// MaxCommandOutput is how many bytes of output are kept per agent and run.
+const MaxCommandOutput = 1 << 20
+
+// CommandRun is a shell command, or a predefined script, run once on some
+// agents. It is running until every agent finished it, then finished or
+// canceled.
+type CommandRun struct {
+	ID         string            `json:"id"`
+	Command    string            `json:"command"`
+	ScriptID   string            `json:"script_id"`
+	Params     map[string]string `json:"params"`
+	Timeout    int               `json:"timeout"`
+	AgentIDs   []string          `json:"agent_ids"`
+	CreatedBy  string            `json:"created_by"`
+	Status     CommandStatus     `json:"status"`
+	CreatedAt  time.Time         `json:"created_at"`
+	FinishedAt *time.Time        `json:"finished_at"`
+	Targets    []*CommandTarget  `json:"targets"`
+}
+
+// CommandTarget is a run on one agent. It is pending until the agent starts
+// the command, then running, and exited with ExitCode once it ends. It
+// failed if the command could not run or did not end by itself, with the
+// reason in Error. Output is left out of run lists; Truncated is set when
+// output beyond MaxCommandOutput was dropped.
+type CommandTarget struct {
+	RunID      string        `json:"run_id"`
+	AgentID    string        `json:"agent_id"`
+	Status     CommandStatus `json:"status"`
+	ExitCode   *int          `json:"exit_code"`
+	Error      string        `json:"error"`
+	Output     []CommandLine `json:"output,omitempty"`
+	Truncated  bool          `json:"truncated"`
+	Duration   int64         `json:"duration"`
+	StartedAt  *time.Time    `json:"started_at"`
+	FinishedAt *time.Time    `json:"finished_at"`
+
+	// OutputBytes is the size of Output's text.
+	OutputBytes int `json:"-"`
+}
+
+// Done reports whether the agent is finished with the run.
+func (t *CommandTarget) Done() bool {
+	return t.Status != CommandPending && t.Status != CommandRunning
+}
+
+// Summary copies the target without its output.
+func (t *CommandTarget) Summary() *CommandTarget {
+	c := *t
+	c.Output = nil
+	return &c
+}
+
+// CommandLine is a line of output. Stream is "stdout" or "stderr".
+type CommandLine struct {
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
+
+// CommandEvent is sent to those watching a run: first a "snapshot" with
+// the run and its output so far, then "output" lines of an agent, "target"
+// when an agent's status changes and "run" when the run ends.
+type CommandEvent struct {
+	Type    string         `json:"type"`
+	AgentID string         `json:"agent_id,omitempty"`
+	Lines   []CommandLine  `json:"lines,omitempty"`
+	Target  *CommandTarget `json:"target,omitempty"`
+	Run     *CommandRun    `json:"run,omitempty"`
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+)
+
+const ContainerStateRemoved = "removed"
+
+// Container is a container on an agent with its most recent usage. Containers
+// the agent stops reporting are kept with state "removed" until retention
+// deletes them.
+type Container struct {
+	AgentID       string     `json:"agent_id" db:"agent_id"`
+	ID            string     `json:"id" db:"id"`
+	Name          string     `json:"name" db:"name"`
+	Image         string     `json:"image" db:"image"`
+	Runtime       string     `json:"runtime" db:"runtime"`
+	State         string     `json:"state" db:"state"`
+	RestartCount  int        `json:"restart_count" db:"restart_count"`
+	StartedAt     *time.Time `json:"started_at,omitempty" db:"started_at"`
+	CPU           float64    `json:"cpu" db:"cpu"`
+	MemoryUsage   uint64     `json:"memory_usage" db:"memory_usage"`
+	MemoryLimit   uint64     `json:"memory_limit" db:"memory_limit"`
+	MemoryPercent float64    `json:"memory_percent" db:"memory_percent"`
+	NetRx         uint64     `json:"net_rx" db:"net_rx"`
+	NetTx         uint64     `json:"net_tx" db:"net_tx"`
+	NetRxRate     uint64     `json:"net_rx_rate" db:"net_rx_rate"`
+	NetTxRate     uint64     `json:"net_tx_rate" db:"net_tx_rate"`
+	FirstSeenAt   time.Time  `json:"first_seen_at" db:"first_seen_at"`
+	LastSeenAt    time.Time  `json:"last_seen_at" db:"last_seen_at"`
+
+	// Restarts is how many times the container restarted since the previous
+	// report. It is only set for alert evaluation.
+	Restarts int `json:"-" db:"-"`
+}
+
+// ContainerMetric is one usage sample of a container.
+type ContainerMetric struct {
+	ContainerID   string    `json:"container_id" db:"container_id"`
+	CPU           float64   `json:"cpu" db:"cpu"`
+	MemoryUsage   uint64    `json:"memory_usage" db:"memory_usage"`
+	MemoryPercent float64   `json:"memory_percent" db:"memory_percent"`
+	NetRxRate     uint64    `json:"net_rx_rate" db:"net_rx_rate"`
+	NetTxRate     uint64    `json:"net_tx_rate" db:"net_tx_rate"`
+	Timestamp     time.Time `json:"timestamp" db:"timestamp"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// CustomMetric is one value reported by an agent plugin.
+type CustomMetric struct {
+	AgentID    string            `json:"agent_id" db:"agent_id"`
+	Plugin     string            `json:"plugin" db:"plugin"`
+	Name       string            `json:"name" db:"name"`
+	Labels     map[string]string `json:"labels" db:"-"`
+	LabelsJSON string            `json:"-" db:"labels"`
+	Value      float64           `json:"value" db:"value"`
+	Timestamp  time.Time         `json:"timestamp" db:"timestamp"`
+}
+
+// Series identifies the metric by its name and labels in Prometheus
+// notation, e.g. `queue_length{queue="mail"}`.
+func (m *CustomMetric) Series() string {
+	if len(m.Labels) == 0 {
+		return m.Name
+	}
+
+	keys := make([]string, 0, len(m.Labels))
+	for k := range m.Labels {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	pairs := make([]string, len(keys))
+	for i, k := range keys {
+		pairs[i] = k + "=" + strconv.Quote(m.Labels[k])
+	}
+	return m.Name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+type CustomMetricSeries struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels"`
+	Points []MetricPoint     `json:"points"`
+}
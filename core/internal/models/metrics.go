@@ -24,8 +24,42 @@ func (m *MemoryStats) Validate() bool {
 	return true
 }
 
+type SwapStats struct {
+	Total   uint64  `json:"total"`
+	Used    uint64  `json:"used"`
+	Free    uint64  `json:"free"`
+	Percent float64 `json:"percent"`
+}
+
+type LoadStats struct {
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+}
+
+// HostStats holds the uptime in seconds, the boot time as a Unix timestamp
+// and the number of processes and threads on the host.
+type HostStats struct {
+	Uptime    uint64 `json:"uptime"`
+	BootTime  uint64 `json:"boot_time"`
+	Processes uint64 `json:"processes"`
+	Threads   uint64 `json:"threads"`
+}
+
+// SocketStats counts the sockets on the host. TCP holds the number of
+// connections in each state, keyed by name such as "ESTABLISHED"; listening
+// sockets are counted in Listen instead.
+type SocketStats struct {
+	TCP      map[string]uint64 `json:"tcp,omitempty"`
+	TCPTotal uint64            `json:"tcp_total"`
+	UDP      uint64            `json:"udp"`
+	Listen   uint64            `json:"listen"`
+}
+
 type DiskStats struct {
 	Path      string  `json:"path"`
+	Device    string  `json:"device,omitempty"`
+	FSType    string  `json:"fstype,omitempty"`
 	Total     uint64  `json:"total"`
 	Used      uint64  `json:"used"`
 	Available uint64  `json:"available"`
@@ -45,24 +79,108 @@ func (d *DiskStats) Validate() bool {
 	return true
 }
 
+// DiskIOStats holds per-second rates for one block device. Util is the
+// percentage of time the device was busy and AwaitMs the average time an I/O
+// took to complete.
+type DiskIOStats struct {
+	Device         string  `json:"device"`
+	ReadBytesRate  uint64  `json:"read_bytes_rate"`
+	WriteBytesRate uint64  `json:"write_bytes_rate"`
+	ReadIOPS       float64 `json:"read_iops"`
+	WriteIOPS      float64 `json:"write_iops"`
+	Util           float64 `json:"util"`
+	AwaitMs        float64 `json:"await_ms"`
+}
+
+// NetworkStats totals only the interfaces marked Counted by the agent's
+// interface filter; Interfaces lists every interface it saw.
 type NetworkStats struct {
+	BytesSent     uint64           `json:"bytes_sent"`
+	BytesRecv     uint64           `json:"bytes_recv"`
+	BytesSentRate uint64           `json:"bytes_sent_rate"`
+	BytesRecvRate uint64           `json:"bytes_recv_rate"`
+	Interfaces    []InterfaceStats `json:"interfaces,omitempty"`
+}
+
+type InterfaceStats struct {
+	Name          string `json:"name"`
 	BytesSent     uint64 `json:"bytes_sent"`
 	BytesRecv     uint64 `json:"bytes_recv"`
 	BytesSentRate uint64 `json:"bytes_sent_rate"`
 	BytesRecvRate uint64 `json:"bytes_recv_rate"`
+	Counted       bool   `json:"counted"`
+}
+
+type InterfacePoint struct {
+	Timestamp     time.Time `json:"timestamp"`
+	BytesSent     uint64    `json:"bytes_sent"`
+	BytesRecv     uint64    `json:"bytes_recv"`
+	BytesSentRate uint64    `json:"bytes_sent_rate"`
+	BytesRecvRate uint64    `json:"bytes_recv_rate"`
+}
+
+// InterfaceSeries is the history of one network interface. Counted reflects
+// the most recent sample.
+type InterfaceSeries struct {
+	Name    string           `json:"name"`
+	Counted bool             `json:"counted"`
+	Points  []InterfacePoint `json:"points"`
+}
+
+// CollectorStatus is how one agent collector did in the latest sample.
+type CollectorStatus struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
+}
+
+// CollectorReport is the collector status of an agent's latest sample.
+type CollectorReport struct {
+	Collectors []CollectorStatus `json:"collectors"`
+	ReportedAt time.Time         `json:"reported_at"`
 }
 
 type Metrics struct {
-	ID        string       `json:"id" db:"id"`
-	AgentID   string       `json:"agent_id" db:"agent_id"`
-	CPU       float64      `json:"cpu" db:"cpu"`
-	Memory    MemoryStats  `json:"memory" db:"-"`
-	MemoryJSON string      `json:"-" db:"memory"`
-	Disks     []DiskStats  `json:"disks" db:"-"`
-	DisksJSON string       `json:"-" db:"disks"`
-	Network   NetworkStats `json:"network" db:"-"`
-	NetworkJSON string     `json:"-" db:"network"`
-	Timestamp time.Time    `json:"timestamp" db:"timestamp"`
+	ID           string        `json:"id" db:"id"`
+	AgentID      string        `json:"agent_id" db:"agent_id"`
+	CPU          float64       `json:"cpu" db:"cpu"`
+	CPUCores     []float64     `json:"cpu_cores" db:"-"`
+	CPUCoresJSON string        `json:"-" db:"cpu_cores"`
+	Memory       MemoryStats   `json:"memory" db:"-"`
+	MemoryJSON   string        `json:"-" db:"memory"`
+	Swap         SwapStats     `json:"swap" db:"-"`
+	SwapJSON     string        `json:"-" db:"swap"`
+	Load         LoadStats     `json:"load" db:"-"`
+	LoadJSON     string        `json:"-" db:"load_avg"`
+	Disks        []DiskStats   `json:"disks" db:"-"`
+	DisksJSON    string        `json:"-" db:"disks"`
+	DiskIO       []DiskIOStats `json:"disk_io" db:"-"`
+	DiskIOJSON   string        `json:"-" db:"disk_io"`
+	Network      NetworkStats  `json:"network" db:"-"`
+	NetworkJSON  string        `json:"-" db:"network"`
+	Host         HostStats     `json:"host" db:"-"`
+	HostJSON     string        `json:"-" db:"host"`
+	Sockets      SocketStats   `json:"sockets" db:"-"`
+	SocketsJSON  string        `json:"-" db:"sockets"`
+	Timestamp    time.Time     `json:"timestamp" db:"timestamp"`
+
+	// Containers is set for alert evaluation when the agent reports
+	// containers. They are stored in their own tables, not the metrics row.
+	Containers []*Container `json:"-" db:"-"`
+
+	// Watches is set for alert evaluation once the agent reports watches.
+	Watches []*WatchStatus `json:"-" db:"-"`
+
+	// Custom is set for alert evaluation to the agent's recent plugin values.
+	Custom []*CustomMetric `json:"-" db:"-"`
+
+	// Certificates is set for alert evaluation to the latest certificates the
+	// agent's tls tasks reported.
+	Certificates []*Certificate `json:"-" db:"-"`
+
+	// DNSResults is set for alert evaluation to the latest result of each of
+	// the agent's dns tasks.
+	DNSResults []*TaskResult `json:"-" db:"-"`
 }
 
 func (m *Metrics) ValidateCPU() bool {
@@ -90,6 +208,18 @@ type TrafficStats struct {
 	CycleEnd   time.Time `json:"cycle_end"`
 }
 
+type TrafficArchive struct {
+	ID         string    `json:"id" db:"id"`
+	CycleID    string    `json:"cycle_id" db:"cycle_id"`
+	AgentID    string    `json:"agent_id" db:"agent_id"`
+	CycleStart time.Time `json:"cycle_start" db:"cycle_start"`
+	CycleEnd   time.Time `json:"cycle_end" db:"cycle_end"`
+	BytesSent  uint64    `json:"bytes_sent" db:"bytes_sent"`
+	BytesRecv  uint64    `json:"bytes_recv" db:"bytes_recv"`
+	Limit      uint64    `json:"limit" db:"limit_bytes"`
+	CreatedAt  time.Time `json:"created_at" db:"created_at"`
+}
+
 type TrafficRecord struct {
 	ID        string    `json:"id" db:"id"`
 	CycleID   string    `json:"cycle_id" db:"cycle_id"`
@@ -98,3 +228,41 @@ type TrafficRecord struct {
 	BytesRecv uint64    `json:"bytes_recv" db:"bytes_recv"`
 	Timestamp time.Time `json:"timestamp" db:"timestamp"`
 }
+
+type Aggregation string
+
+const (
+	AggregationAvg  Aggregation = "avg"
+	AggregationMin  Aggregation = "min"
+	AggregationMax  Aggregation = "max"
+	AggregationP95  Aggregation = "p95"
+	AggregationLast Aggregation = "last"
+)
+
+// MetricQuery describes an aggregation over metric history. Series maps a
+// series key (agent ID, group ID or "all") to the agents it covers.
+type MetricQuery struct {
+	Metric      MetricType
+	Aggregation Aggregation
+	From        time.Time
+	To          time.Time
+	Step        time.Duration
+	Series      map[string][]string
+}
+
+type MetricSample struct {
+	AgentID   string
+	Timestamp time.Time
+	Value     float64
+}
+
+type MetricPoint struct {
+	Timestamp time.Time `json:"timestamp"`
+	Value     float64   `json:"value"`
+}
+
+type MetricSeries struct {
+	Key      string        `json:"key"`
+	AgentIDs []string      `json:"agent_ids"`
+	Points   []MetricPoint `json:"points"`
+}
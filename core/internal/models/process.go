@@ -0,0 +1,22 @@
+package models
+
+import (
+	"time"
+)
+
+// ProcessInfo describes one process. CPU is a percentage of one core, as in
+// top, so it can exceed 100 on multi-core hosts.
+type ProcessInfo struct {
+	PID     int32   `json:"pid"`
+	Name    string  `json:"name"`
+	User    string  `json:"user"`
+	CPU     float64 `json:"cpu"`
+	RSS     uint64  `json:"rss"`
+	Cmdline string  `json:"cmdline"`
+}
+
+type ProcessSnapshot struct {
+	TopCPU      []ProcessInfo `json:"top_cpu"`
+	TopMemory   []ProcessInfo `json:"top_memory"`
+	CollectedAt time.Time     `json:"collected_at"`
+}
@@ -1,21 +1,58 @@
 package models
 
+import (
+	"time"
+)
+
+type VacuumMode string
+
+const (
+	VacuumModeNone        VacuumMode = "none"
+	VacuumModeIncremental VacuumMode = "incremental"
+	VacuumModeFull        VacuumMode = "full"
+)
+
+// Valid reports whether m is a known mode; empty means none.
+func (m VacuumMode) Valid() bool {
+	switch m {
+	case "", VacuumModeNone, VacuumModeIncremental, VacuumModeFull:
+		return true
+	}
+	return false
+}
+
+// Settings holds runtime configuration. Retention periods are in days; 0
+// keeps a dataset forever. DataRetentionDays applies to metrics.
+// TaskSeriesRetentionDays is separate from the task results so that SLA
+// reports can cover longer periods than the raw results are kept for.
 type Settings struct {
-	DataRetentionDays int    `json:"data_retention_days" db:"data_retention_days"`
-	TelegramBotToken  string `json:"telegram_bot_token" db:"telegram_bot_token"`
-	TelegramChatID    string `json:"telegram_chat_id" db:"telegram_chat_id"`
-	SMTPHost          string `json:"smtp_host" db:"smtp_host"`
-	SMTPPort          int    `json:"smtp_port" db:"smtp_port"`
-	SMTPUsername      string `json:"smtp_username" db:"smtp_username"`
-	SMTPPassword      string `json:"smtp_password" db:"smtp_password"`
-	SMTPFrom          string `json:"smtp_from" db:"smtp_from"`
-	AlertEmailTo      string `json:"alert_email_to" db:"alert_email_to"`
+	DataRetentionDays           int        `json:"data_retention_days" db:"data_retention_days"`
+	TaskResultRetentionDays     int        `json:"task_result_retention_days" db:"task_result_retention_days"`
+	TaskSeriesRetentionDays     int        `json:"task_series_retention_days" db:"task_series_retention_days"`
+	TrafficRetentionDays        int        `json:"traffic_retention_days" db:"traffic_retention_days"`
+	AlertRetentionDays          int        `json:"alert_retention_days" db:"alert_retention_days"`
+	TrafficArchiveRetentionDays int        `json:"traffic_archive_retention_days" db:"traffic_archive_retention_days"`
+	VacuumMode                  VacuumMode `json:"vacuum_mode" db:"vacuum_mode"`
+	TelegramBotToken            string     `json:"telegram_bot_token" db:"telegram_bot_token"`
+	TelegramChatID              string     `json:"telegram_chat_id" db:"telegram_chat_id"`
+	SMTPHost                    string     `json:"smtp_host" db:"smtp_host"`
+	SMTPPort                    int        `json:"smtp_port" db:"smtp_port"`
+	SMTPUsername                string     `json:"smtp_username" db:"smtp_username"`
+	SMTPPassword                string     `json:"smtp_password" db:"smtp_password"`
+	SMTPFrom                    string     `json:"smtp_from" db:"smtp_from"`
+	AlertEmailTo                string     `json:"alert_email_to" db:"alert_email_to"`
 }
 
 func DefaultSettings() *Settings {
 	return &Settings{
-		DataRetentionDays: 7,
-		SMTPPort:          587,
+		DataRetentionDays:           7,
+		TaskResultRetentionDays:     30,
+		TaskSeriesRetentionDays:     180,
+		TrafficRetentionDays:        90,
+		AlertRetentionDays:          90,
+		TrafficArchiveRetentionDays: 365,
+		VacuumMode:                  VacuumModeIncremental,
+		SMTPPort:                    587,
 	}
 }
 
@@ -24,3 +61,20 @@ type User struct {
 	Username     string `json:"username" db:"username"`
 	PasswordHash string `json:"-" db:"password_hash"`
 }
+
+// RetentionReport summarises one retention run: rows deleted per dataset and
+// any per-dataset errors.
+type RetentionReport struct {
+	StartedAt  time.Time         `json:"started_at"`
+	FinishedAt time.Time         `json:"finished_at"`
+	Deleted    map[Dataset]int64 `json:"deleted"`
+	Vacuum     VacuumMode        `json:"vacuum"`
+	Errors     []string          `json:"errors,omitempty"`
+}
+
+// Backup describes one database snapshot in the backup directory.
+type Backup struct {
+	Name      string    `json:"name"`
+	Size      int64     `json:"size"`
+	CreatedAt time.Time `json:"created_at"`
+}
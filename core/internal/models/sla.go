@@ -0,0 +1,62 @@
+package models
+
+import "time"
+
+// SLAWindows are the periods availability is reported over by default.
+var SLAWindows = []struct {
+	Name     string
+	Duration time.Duration
+}{
+	{"24h", 24 * time.Hour},
+	{"7d", 7 * 24 * time.Hour},
+	{"30d", 30 * 24 * time.Hour},
+	{"90d", 90 * 24 * time.Hour},
+}
+
+// SLAReport is the availability of a task's target. The target is down while
+// at least Quorum of the task's agents report failures; Agents is how many
+// agents the task runs on.
+type SLAReport struct {
+	TaskID  string       `json:"task_id"`
+	Name    string       `json:"name"`
+	Type    TaskType     `json:"type"`
+	Target  string       `json:"target"`
+	Agents  int          `json:"agents"`
+	Quorum  int          `json:"quorum"`
+	Windows []*SLAWindow `json:"windows"`
+	// Outages in the longest window, oldest first. Left out of summaries.
+	Outages []*Outage `json:"outages,omitempty"`
+}
+
+// SLAWindow is the availability over one period. Uptime is the share of the
+// period the target was up, counted from CoveredFrom, the first result in it,
+// and is null when there is none. Partial is set when the results start well
+// after the period does, e.g. because older ones were deleted or the task is
+// newer, so Uptime covers only part of it. Downtime is in seconds.
+type SLAWindow struct {
+	Name        string         `json:"name"`
+	From        time.Time      `json:"from"`
+	To          time.Time      `json:"to"`
+	CoveredFrom *time.Time     `json:"covered_from"`
+	Partial     bool           `json:"partial"`
+	Uptime      *float64       `json:"uptime"`
+	Downtime    float64        `json:"downtime"`
+	Outages     int            `json:"outages"`
+	Agents      []*AgentUptime `json:"agents"`
+}
+
+// AgentUptime is the share of one agent's checks that succeeded, in percent.
+type AgentUptime struct {
+	AgentID  string   `json:"agent_id"`
+	Checks   int      `json:"checks"`
+	Failures int      `json:"failures"`
+	Uptime   *float64 `json:"uptime"`
+}
+
+// Outage is a period the target was down. An outage still going on has no
+// end; its duration, in seconds, runs to the end of the report.
+type Outage struct {
+	Start    time.Time  `json:"start"`
+	End      *time.Time `json:"end"`
+	Duration float64    `json:"duration"`
+}
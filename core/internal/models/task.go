@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 )
 
@@ -9,6 +10,11 @@ type TaskType string
 const (
 	TaskTypePing   TaskType = "ping"
 	TaskTypeScript TaskType = "script"
+	TaskTypeHTTP   TaskType = "http"
+	TaskTypeTLS    TaskType = "tls"
+	TaskTypeDNS    TaskType = "dns"
+
+	TaskTypeTraceroute TaskType = "traceroute"
 )
 
 type TaskStatus string
@@ -30,14 +36,134 @@ type Task struct {
 	Params     map[string]string `json:"params" db:"-"`
 	ParamsJSON string            `json:"-" db:"params"`
 	Interval   int               `json:"interval" db:"interval_sec"`
+	// Schedule is a cron expression evaluated by the agent in Timezone
+	// (UTC if empty); a scheduled task has no Interval. Jitter delays each
+	// scheduled run by up to that many seconds.
+	Schedule   string            `json:"schedule" db:"schedule"`
+	Timezone   string            `json:"timezone" db:"timezone"`
+	Jitter     int               `json:"jitter" db:"jitter_sec"`
+	NextRun    *time.Time        `json:"next_run,omitempty" db:"-"`
 	Timeout    int               `json:"timeout" db:"timeout_sec"`
 	Status     TaskStatus        `json:"status" db:"status"`
 	AgentIDs   []string          `json:"agent_ids" db:"-"`
 	AgentIDsJSON string          `json:"-" db:"agent_ids"`
+	// GroupIDs and Tags select agents dynamically, see Targets.
+	GroupIDs   []string          `json:"group_ids" db:"-"`
+	GroupIDsJSON string          `json:"-" db:"group_ids"`
+	Tags       []string          `json:"tags" db:"-"`
+	TagsJSON   string            `json:"-" db:"tags"`
+	Rollout    *TaskRollout      `json:"rollout,omitempty" db:"-"`
 	CreatedAt  time.Time         `json:"created_at" db:"created_at"`
 	UpdatedAt  time.Time         `json:"updated_at" db:"updated_at"`
 }
 
+// Targets reports whether the task runs on the agent: either the agent is
+// listed in AgentIDs, or the task selects agents by group or tag and the
+// agent is in one of GroupIDs (if any) and has all of Tags (if any). Tags
+// are compared without case, as in agent filters.
+func (t *Task) Targets(agent *Agent) bool {
+	for _, id := range t.AgentIDs {
+		if id == agent.ID {
+			return true
+		}
+	}
+	if len(t.GroupIDs) == 0 && len(t.Tags) == 0 {
+		return false
+	}
+
+	if len(t.GroupIDs) > 0 {
+		inGroup := false
+		for _, id := range t.GroupIDs {
+			inGroup = inGroup || (agent.GroupID != nil && *agent.GroupID == id)
+		}
+		if !inGroup {
+			return false
+		}
+	}
+	for _, tag := range t.Tags {
+		found := false
+		for _, have := range agent.Tags {
+			found = found || strings.EqualFold(have, tag)
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
+
+// Recurring reports whether the task runs on an interval or a schedule
+// rather than once.
+func (t *Task) Recurring() bool {
+	return t.Interval > 0 || t.Schedule != ""
+}
+
+type DeliveryState string
+
+const (
+	DeliveryAssigned     DeliveryState = "assigned"
+	DeliveryAcknowledged DeliveryState = "acknowledged"
+	DeliveryRunning      DeliveryState = "running"
+	DeliveryComplete     DeliveryState = "complete"
+	DeliveryFailed       DeliveryState = "failed"
+	DeliveryRejected     DeliveryState = "rejected"
+	DeliveryCanceled     DeliveryState = "canceled"
+)
+
+// TaskDelivery is the state of a task on one agent. An assignment stays
+// assigned until the agent acknowledges it, and is sent again until then;
+// Attempts counts the sends. A recurring task is running once it reports a
+// result, a one-time task complete or failed. Rejected means the agent could
+// not start the task, and Error says why. Error otherwise holds the error of
+// the latest result.
+type TaskDelivery struct {
+	TaskID       string        `json:"task_id" db:"task_id"`
+	AgentID      string        `json:"agent_id" db:"agent_id"`
+	State        DeliveryState `json:"state" db:"state"`
+	Attempts     int           `json:"attempts" db:"attempts"`
+	AssignedAt   time.Time     `json:"assigned_at" db:"assigned_at"`
+	AckedAt      *time.Time    `json:"acked_at" db:"acked_at"`
+	LastResultAt *time.Time    `json:"last_result_at" db:"last_result_at"`
+	LastSuccess  *bool         `json:"last_success" db:"last_success"`
+	Error        string        `json:"error" db:"error"`
+	UpdatedAt    time.Time     `json:"updated_at" db:"updated_at"`
+}
+
+// TaskRollout counts a task's deliveries by state. Total leaves out the
+// canceled ones.
+type TaskRollout struct {
+	Total        int `json:"total"`
+	Assigned     int `json:"assigned"`
+	Acknowledged int `json:"acknowledged"`
+	Running      int `json:"running"`
+	Complete     int `json:"complete"`
+	Failed       int `json:"failed"`
+	Rejected     int `json:"rejected"`
+	Canceled     int `json:"canceled"`
+}
+
+// Add counts n deliveries in the state.
+func (r *TaskRollout) Add(state DeliveryState, n int) {
+	switch state {
+	case DeliveryAssigned:
+		r.Assigned += n
+	case DeliveryAcknowledged:
+		r.Acknowledged += n
+	case DeliveryRunning:
+		r.Running += n
+	case DeliveryComplete:
+		r.Complete += n
+	case DeliveryFailed:
+		r.Failed += n
+	case DeliveryRejected:
+		r.Rejected += n
+	case DeliveryCanceled:
+		r.Canceled += n
+		return
+	}
+	r.Total += n
+}
+
 type TaskResult struct {
 	ID        string    `json:"id" db:"id"`
 	TaskID    string    `json:"task_id" db:"task_id"`
@@ -47,24 +173,302 @@ type TaskResult struct {
 	Error     string    `json:"error" db:"error"`
 	Duration  int64     `json:"duration" db:"duration_ms"`
 	Timestamp time.Time `json:"timestamp" db:"timestamp"`
+
+	// The structured result of the task's type, if it has one.
+	Ping     *PingResult `json:"ping,omitempty" db:"-"`
+	PingJSON string      `json:"-" db:"ping"`
+	HTTP     *HTTPResult `json:"http,omitempty" db:"-"`
+	HTTPJSON string      `json:"-" db:"http"`
+	TLS      *TLSResult  `json:"tls,omitempty" db:"-"`
+	TLSJSON  string      `json:"-" db:"tls"`
+	DNS      *DNSResult  `json:"dns,omitempty" db:"-"`
+	DNSJSON  string      `json:"-" db:"dns"`
+
+	Traceroute     *TracerouteResult `json:"traceroute,omitempty" db:"-"`
+	TracerouteJSON string            `json:"-" db:"traceroute"`
 }
 
+// Ping modes. Auto uses ICMP where the agent may open ICMP sockets and TCP
+// elsewhere.
+const (
+	PingModeAuto = "auto"
+	PingModeICMP = "icmp"
+	PingModeTCP  = "tcp"
+)
+
+// PingResult summarises one ping run. Latencies are in milliseconds and only
+// cover the probes that were answered.
 type PingResult struct {
 	Target     string  `json:"target"`
-	Latency    float64 `json:"latency"`
+	Address    string  `json:"address,omitempty"`
+	Mode       string  `json:"mode"`
+	Sent       int     `json:"sent"`
+	Received   int     `json:"received"`
 	PacketLoss float64 `json:"packet_loss"`
-	Success    bool    `json:"success"`
-	Error      string  `json:"error,omitempty"`
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+	Avg        float64 `json:"avg"`
+	StdDev     float64 `json:"stddev"`
+	Jitter     float64 `json:"jitter"`
+}
+
+// HTTPResult describes an http check. The phase timings, in milliseconds,
+// are those of the final request; TotalMs also covers the redirects before
+// it. StatusCode is 0 when no response was received.
+type HTTPResult struct {
+	URL        string  `json:"url"`
+	Method     string  `json:"method"`
+	FinalURL   string  `json:"final_url,omitempty"`
+	StatusCode int     `json:"status_code"`
+	Redirects  int     `json:"redirects"`
+	BodyBytes  int     `json:"body_bytes"`
+	DNSMs      float64 `json:"dns_ms"`
+	ConnectMs  float64 `json:"connect_ms"`
+	TLSMs      float64 `json:"tls_ms"`
+	TTFBMs     float64 `json:"ttfb_ms"`
+	TotalMs    float64 `json:"total_ms"`
+}
+
+// TLSResult describes the certificate chain a server presented, leaf first.
+// NotAfter and DaysRemaining are those of the leaf when it was checked.
+type TLSResult struct {
+	Target        string           `json:"target"`
+	ServerName    string           `json:"server_name,omitempty"`
+	Version       string           `json:"version"`
+	Verified      bool             `json:"verified"`
+	VerifyError   string           `json:"verify_error,omitempty"`
+	NotAfter      time.Time        `json:"not_after"`
+	DaysRemaining float64          `json:"days_remaining"`
+	Chain         []TLSCertificate `json:"chain"`
+}
+
+type TLSCertificate struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	SANs      []string  `json:"sans,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// Certificate is the latest result of a tls task on one agent.
+// DaysRemaining is counted from now rather than from the check.
+type Certificate struct {
+	TaskID        string     `json:"task_id"`
+	TaskName      string     `json:"task_name"`
+	AgentID       string     `json:"agent_id"`
+	Target        string     `json:"target"`
+	DaysRemaining float64    `json:"days_remaining"`
+	TLS           *TLSResult `json:"tls"`
+	CheckedAt     time.Time  `json:"checked_at"`
+}
+
+// DNS record types and answer checks of dns tasks. With DNSMatchAll every
+// expected value must be answered, with DNSMatchAny one of them and with
+// DNSMatchExact the answers must be exactly the expected values.
+var DNSRecordTypes = []string{"A", "AAAA", "CNAME", "MX", "TXT"}
+
+const (
+	DNSMatchAll   = "all"
+	DNSMatchAny   = "any"
+	DNSMatchExact = "exact"
+)
+
+// DNSResult describes a dns check. Resolver is empty when the agent's system
+// resolver was used.
+type DNSResult struct {
+	Name       string   `json:"name"`
+	RecordType string   `json:"record_type"`
+	Resolver   string   `json:"resolver,omitempty"`
+	Answers    []string `json:"answers"`
+	ResolveMs  float64  `json:"resolve_ms"`
+}
+
+// Traceroute protocols.
+const (
+	TracerouteUDP  = "udp"
+	TracerouteICMP = "icmp"
+	TracerouteTCP  = "tcp"
+)
+
+// TracerouteResult describes the path to a target over several rounds of
+// probes, hops in TTL order.
+type TracerouteResult struct {
+	Target   string          `json:"target"`
+	Address  string          `json:"address"`
+	Protocol string          `json:"protocol"`
+	Rounds   int             `json:"rounds"`
+	Reached  bool            `json:"reached"`
+	Hops     []TracerouteHop `json:"hops"`
+}
+
+// TracerouteHop summarizes the replies at one TTL. Address answered most
+// often and is empty when nothing did; Addresses lists every address that
+// answered when there was more than one.
+type TracerouteHop struct {
+	TTL       int      `json:"ttl"`
+	Address   string   `json:"address,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	Sent      int      `json:"sent"`
+	Received  int      `json:"received"`
+	Loss      float64  `json:"loss"`
+	Last      float64  `json:"last"`
+	Min       float64  `json:"min"`
+	Avg       float64  `json:"avg"`
+	Max       float64  `json:"max"`
+	StdDev    float64  `json:"stddev"`
+}
+
+// answeredBy reports whether addr answered at the hop.
+func (h *TracerouteHop) answeredBy(addr string) bool {
+	if addr == h.Address {
+		return true
+	}
+	for _, a := range h.Addresses {
+		if a == addr {
+			return true
+		}
+	}
+	return false
+}
+
+// PathDiff compares a traceroute task's latest path on one agent with an
+// earlier one. Before is nil when there is no earlier path.
+type PathDiff struct {
+	TaskID   string            `json:"task_id"`
+	AgentID  string            `json:"agent_id"`
+	After    *TracerouteResult `json:"after"`
+	AfterAt  time.Time         `json:"after_at"`
+	Before   *TracerouteResult `json:"before"`
+	BeforeAt *time.Time        `json:"before_at"`
+	Changed  bool              `json:"changed"`
+	Hops     []HopDiff         `json:"hops"`
+}
+
+// HopDiff compares one hop. A hop is changed when both paths had an answer
+// at it and neither path's addresses include the other's, or when only one
+// path reaches that far.
+type HopDiff struct {
+	TTL       int     `json:"ttl"`
+	Before    string  `json:"before,omitempty"`
+	After     string  `json:"after,omitempty"`
+	BeforeAvg float64 `json:"before_avg"`
+	AfterAvg  float64 `json:"after_avg"`
+	Changed   bool    `json:"changed"`
+}
+
+// DiffPaths compares two paths hop by hop.
+func DiffPaths(before, after *TracerouteResult) ([]HopDiff, bool) {
+	n := len(after.Hops)
+	if len(before.Hops) > n {
+		n = len(before.Hops)
+	}
+
+	hops := make([]HopDiff, 0, n)
+	changed := false
+	for i := 0; i < n; i++ {
+		d := HopDiff{TTL: i + 1}
+		var b, a *TracerouteHop
+		if i < len(before.Hops) {
+			b = &before.Hops[i]
+			d.Before, d.BeforeAvg = b.Address, b.Avg
+		}
+		if i < len(after.Hops) {
+			a = &after.Hops[i]
+			d.After, d.AfterAvg = a.Address, a.Avg
+		}
+		switch {
+		case a == nil || b == nil:
+			d.Changed = true
+		case a.Address != "" && b.Address != "":
+			d.Changed = !b.answeredBy(a.Address) && !a.answeredBy(b.Address)
+		}
+		changed = changed || d.Changed
+		hops = append(hops, d)
+	}
+	return hops, changed
+}
+
+// TaskSeriesFields lists the numeric series recorded for the results of each
+// task type. Every result also records "success" as 1 or 0.
+var TaskSeriesFields = map[TaskType][]string{
+	TaskTypePing:       {"success", "packet_loss", "rtt_min", "rtt_avg", "rtt_max", "rtt_stddev", "jitter"},
+	TaskTypeHTTP:       {"success", "status_code", "dns_ms", "connect_ms", "tls_ms", "ttfb_ms", "total_ms"},
+	TaskTypeTLS:        {"success", "days_remaining"},
+	TaskTypeDNS:        {"success", "resolve_ms"},
+	TaskTypeTraceroute: {"success", "hops", "rtt", "loss"},
+	TaskTypeScript:     {"success"},
+}
+
+// SeriesValues returns the numeric fields of the result by series name.
+// Latencies are left out when nothing answered, and so are the target's
+// latency and loss for a traceroute that did not reach it.
+func (r *TaskResult) SeriesValues() map[string]float64 {
+	values := map[string]float64{"success": 0}
+	if r.Success {
+		values["success"] = 1
+	}
+
+	if p := r.Ping; p != nil {
+		values["packet_loss"] = p.PacketLoss
+		if p.Received > 0 {
+			values["rtt_min"], values["rtt_avg"], values["rtt_max"] = p.Min, p.Avg, p.Max
+			values["rtt_stddev"], values["jitter"] = p.StdDev, p.Jitter
+		}
+	}
+	if h := r.HTTP; h != nil {
+		values["total_ms"] = h.TotalMs
+		if h.StatusCode > 0 {
+			values["status_code"] = float64(h.StatusCode)
+			values["dns_ms"], values["connect_ms"], values["tls_ms"], values["ttfb_ms"] = h.DNSMs, h.ConnectMs, h.TLSMs, h.TTFBMs
+		}
+	}
+	if t := r.TLS; t != nil {
+		values["days_remaining"] = t.DaysRemaining
+	}
+	if d := r.DNS; d != nil {
+		values["resolve_ms"] = d.ResolveMs
+	}
+	if t := r.Traceroute; t != nil {
+		values["hops"] = float64(len(t.Hops))
+		if t.Reached && len(t.Hops) > 0 {
+			last := t.Hops[len(t.Hops)-1]
+			values["loss"] = last.Loss
+			if last.Received > 0 {
+				values["rtt"] = last.Avg
+			}
+		}
+	}
+	return values
+}
+
+// TaskSeriesSample is one recorded value of a task result series.
+type TaskSeriesSample struct {
+	ResultID  string
+	AgentID   string
+	Value     float64
+	Timestamp time.Time
+}
+
+// TaskSeriesQuery describes an aggregation over a task's result series, one
+// series per agent. No AgentIDs means every agent that reported.
+type TaskSeriesQuery struct {
+	TaskID      string
+	Field       string
+	AgentIDs    []string
+	Aggregation Aggregation
+	From        time.Time
+	To          time.Time
+	Step        time.Duration
 }
 
 func (p *PingResult) Validate() bool {
-	if p.Latency < 0 && p.Success {
+	if p.Sent < 0 || p.Received < 0 || p.Received > p.Sent {
 		return false
 	}
 	if p.PacketLoss < 0 || p.PacketLoss > 100 {
 		return false
 	}
-	if p.PacketLoss >= 100 && p.Success {
+	if p.Received > 0 && (p.Min < 0 || p.Min > p.Avg || p.Avg > p.Max) {
 		return false
 	}
 	return true
@@ -0,0 +1,35 @@
+package models
+
+import "time"
+
+type TerminalStatus string
+
+const (
+	TerminalOpening TerminalStatus = "opening"
+	TerminalOpen    TerminalStatus = "open"
+	TerminalClosed  TerminalStatus = "closed"
+)
+
+// MaxTerminalRecording is how large a session's recording may grow. The
+// session is closed once it is reached, so that nothing goes unrecorded.
+const MaxTerminalRecording = 64 << 20
+
+// TerminalSession is an admin's shell on an agent. It is opening until the
+// agent started the shell and closed with the reason in Reason once it ends;
+// ExitCode is set if the shell exited by itself. Everything the terminal
+// showed is recorded with its timing, RecordingBytes long, and what the
+// admin typed too unless the terminal.record_input setting is off.
+type TerminalSession struct {
+	ID             string         `json:"id"`
+	AgentID        string         `json:"agent_id"`
+	Username       string         `json:"username"`
+	RemoteAddr     string         `json:"remote_addr"`
+	Width          int            `json:"width"`
+	Height         int            `json:"height"`
+	Status         TerminalStatus `json:"status"`
+	ExitCode       *int           `json:"exit_code"`
+	Reason         string         `json:"reason"`
+	RecordingBytes int64          `json:"recording_bytes"`
+	StartedAt      time.Time      `json:"started_at"`
+	EndedAt        *time.Time     `json:"ended_at"`
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+)
+
+type Dataset string
+
+const (
+	DatasetMetrics     Dataset = "metrics"
+	DatasetTraffic     Dataset = "traffic"
+	DatasetTaskResults Dataset = "task_results"
+
+	DatasetAlerts          Dataset = "alerts"
+	DatasetTrafficArchives Dataset = "traffic_archives"
+
+	// Container and custom metrics are only subject to retention; they
+	// follow the metrics retention period and are not exported.
+	DatasetContainerMetrics Dataset = "container_metrics"
+	DatasetCustomMetrics    Dataset = "custom_metrics"
+
+	// Task result series follow the task result retention period and are
+	// rebuilt from the results on import.
+	DatasetTaskSeries Dataset = "task_series"
+
+	// Command runs follow the task result retention period and are not
+	// exported.
+	DatasetCommandRuns Dataset = "command_runs"
+
+	// Terminal sessions and their recordings follow the same period.
+	DatasetTerminalSessions Dataset = "terminal_sessions"
+)
+
+type TransferFormat string
+
+const (
+	TransferFormatCSV    TransferFormat = "csv"
+	TransferFormatNDJSON TransferFormat = "ndjson"
+)
+
+type ExportFilter struct {
+	AgentID string
+	From    time.Time
+	To      time.Time
+}
+
+// ImportOptions remaps IDs from the exporting core to this one. Agents and
+// tasks get new IDs when they are recreated, merged or moved between cores.
+type ImportOptions struct {
+	AgentMap map[string]string
+	TaskMap  map[string]string
+}
+
+type ImportResult struct {
+	Imported int `json:"imported"`
+	Skipped  int `json:"skipped"`
+}
@@ -0,0 +1,52 @@
+package models
+
+import (
+	"time"
+)
+
+type WatchType string
+
+const (
+	WatchTypeProcess WatchType = "process"
+	WatchTypePort    WatchType = "port"
+)
+
+// Watch is a process or listening TCP port that agents check on every
+// metrics cycle. For process watches Target is a pattern matched against the
+// process name and then the full command line, where * matches any text
+// (slashes included) and ? one character; for port watches it is the TCP
+// port number. An empty AgentIDs applies the watch to every agent.
+type Watch struct {
+	ID           string    `json:"id" db:"id"`
+	Name         string    `json:"name" db:"name"`
+	Type         WatchType `json:"type" db:"type"`
+	Target       string    `json:"target" db:"target"`
+	AgentIDs     []string  `json:"agent_ids" db:"-"`
+	AgentIDsJSON string    `json:"-" db:"agent_ids"`
+	CreatedAt    time.Time `json:"created_at" db:"created_at"`
+	UpdatedAt    time.Time `json:"updated_at" db:"updated_at"`
+}
+
+func (w *Watch) AppliesTo(agentID string) bool {
+	if len(w.AgentIDs) == 0 {
+		return true
+	}
+	for _, id := range w.AgentIDs {
+		if id == agentID {
+			return true
+		}
+	}
+	return false
+}
+
+// WatchStatus is the latest result of one watch on one agent. Count is the
+// number of matching processes or listening sockets.
+type WatchStatus struct {
+	WatchID   string    `json:"watch_id"`
+	Name      string    `json:"name"`
+	Type      WatchType `json:"type"`
+	Target    string    `json:"target"`
+	Up        bool      `json:"up"`
+	Count     int       `json:"count"`
+	CheckedAt time.Time `json:"checked_at"`
+}
@@ -3,6 +3,7 @@ package notify
 import (
 	"context"
 	"fmt"
+	"html"
 	"net/smtp"
 	"strings"
 	"time"
@@ -98,7 +99,7 @@ func (n *EmailNotifier) formatAlertHTML(alert *models.Alert) string {
             <div class="field">
                 <div class="label">Time</div>
                 <div class="value">%s</div>
-            </div>
+            </div>%s
         </div>
         <div class="footer">
             This is an automated alert from Probe System.
@@ -112,9 +113,32 @@ func (n *EmailNotifier) formatAlertHTML(alert *models.Alert) string {
 		alert.Value,
 		alert.Threshold,
 		alert.TriggeredAt.Format("2006-01-02 15:04:05 MST"),
+		formatProcessesHTML(alert),
 	)
 }
 
+func formatProcessesHTML(alert *models.Alert) string {
+	procs := topCPUProcesses(alert)
+	if len(procs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(`
+            <div class="field">
+                <div class="label">Top Processes</div>
+                <table class="value" style="font-size: 14px; border-collapse: collapse;">`)
+	for _, p := range procs {
+		b.WriteString(fmt.Sprintf(`
+                    <tr><td style="padding-right: 12px;">%s</td><td style="padding-right: 12px;">%d</td><td style="padding-right: 12px;">%.1f%%</td><td>%s</td></tr>`,
+			html.EscapeString(p.Name), p.PID, p.CPU, formatRSS(p.RSS)))
+	}
+	b.WriteString(`
+                </table>
+            </div>`)
+	return b.String()
+}
+
 func (n *EmailNotifier) formatRecoveryHTML(alert *models.Alert) string {
 	duration := ""
 	if alert.ResolvedAt != nil {
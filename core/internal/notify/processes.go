@@ -0,0 +1,30 @@
+package notify
+
+import (
+	"fmt"
+
+	"github.com/probe-system/core/internal/models"
+)
+
+// maxNotifyProcesses caps how many processes are listed in a notification.
+const maxNotifyProcesses = 5
+
+// topCPUProcesses returns the processes attached to the alert, busiest first.
+func topCPUProcesses(alert *models.Alert) []models.ProcessInfo {
+	if alert.Processes == nil {
+		return nil
+	}
+	procs := alert.Processes.TopCPU
+	if len(procs) > maxNotifyProcesses {
+		procs = procs[:maxNotifyProcesses]
+	}
+	return procs
+}
+
+func formatRSS(bytes uint64) string {
+	const mb = 1024 * 1024
+	if bytes >= 1024*mb {
+		return fmt.Sprintf("%.1f GB", float64(bytes)/(1024*mb))
+	}
+	return fmt.Sprintf("%.1f MB", float64(bytes)/mb)
+}
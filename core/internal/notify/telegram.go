@@ -6,6 +6,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/probe-system/core/internal/models"
@@ -55,7 +56,7 @@ func (n *TelegramNotifier) formatAlertMessage(alert *models.Alert) string {
 *Threshold:* %.2f
 *Time:* %s
 
-%s`,
+%s%s`,
 		escapeMarkdown(alert.RuleName),
 		escapeMarkdown(alert.AgentName),
 		alert.MetricType,
@@ -63,9 +64,25 @@ func (n *TelegramNotifier) formatAlertMessage(alert *models.Alert) string {
 		alert.Threshold,
 		alert.TriggeredAt.Format("2006-01-02 15:04:05"),
 		escapeMarkdown(alert.Message),
+		formatProcessesMarkdown(alert),
 	)
 }
 
+func formatProcessesMarkdown(alert *models.Alert) string {
+	procs := topCPUProcesses(alert)
+	if len(procs) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString("\n\n*Top processes:*")
+	for _, p := range procs {
+		line := fmt.Sprintf("%s (pid %d) CPU %.1f%%, RSS %s", p.Name, p.PID, p.CPU, formatRSS(p.RSS))
+		b.WriteString("\n" + escapeMarkdown(line))
+	}
+	return b.String()
+}
+
 func (n *TelegramNotifier) formatRecoveryMessage(alert *models.Alert) string {
 	duration := ""
 	if alert.ResolvedAt != nil {
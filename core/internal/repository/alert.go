@@ -23,10 +23,10 @@ func (r *AlertRepository) CreateRule(ctx context.Context, rule *models.AlertRule
 
 	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO alert_rules (id, name, metric_type, operator, threshold, 
-			duration_sec, cooldown_sec, agent_ids, enabled, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			duration_sec, cooldown_sec, agent_ids, container, watch, custom_metric, certificate, dns, enabled, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, rule.ID, rule.Name, rule.MetricType, rule.Operator, rule.Threshold,
-		rule.Duration, rule.Cooldown, string(agentIDsJSON), rule.Enabled,
+		rule.Duration, rule.Cooldown, string(agentIDsJSON), rule.Container, rule.Watch, rule.CustomMetric, rule.Certificate, rule.DNS, rule.Enabled,
 		rule.CreatedAt, rule.UpdatedAt)
 
 	return err
@@ -37,10 +37,10 @@ func (r *AlertRepository) UpdateRule(ctx context.Context, rule *models.AlertRule
 
 	_, err := r.db.ExecContext(ctx, `
 		UPDATE alert_rules SET name=?, metric_type=?, operator=?, threshold=?, 
-			duration_sec=?, cooldown_sec=?, agent_ids=?, enabled=?, updated_at=?
+			duration_sec=?, cooldown_sec=?, agent_ids=?, container=?, watch=?, custom_metric=?, certificate=?, dns=?, enabled=?, updated_at=?
 		WHERE id=?
 	`, rule.Name, rule.MetricType, rule.Operator, rule.Threshold,
-		rule.Duration, rule.Cooldown, string(agentIDsJSON), rule.Enabled,
+		rule.Duration, rule.Cooldown, string(agentIDsJSON), rule.Container, rule.Watch, rule.CustomMetric, rule.Certificate, rule.DNS, rule.Enabled,
 		time.Now(), rule.ID)
 
 	return err
@@ -57,10 +57,10 @@ func (r *AlertRepository) GetRule(ctx context.Context, id string) (*models.Alert
 
 	err := r.db.QueryRowContext(ctx, `
 		SELECT id, name, metric_type, operator, threshold, duration_sec, 
-			cooldown_sec, agent_ids, enabled, created_at, updated_at
+			cooldown_sec, agent_ids, container, watch, custom_metric, certificate, dns, enabled, created_at, updated_at
 		FROM alert_rules WHERE id = ?
 	`, id).Scan(&rule.ID, &rule.Name, &rule.MetricType, &rule.Operator, &rule.Threshold,
-		&rule.Duration, &rule.Cooldown, &agentIDsJSON, &rule.Enabled,
+		&rule.Duration, &rule.Cooldown, &agentIDsJSON, &rule.Container, &rule.Watch, &rule.CustomMetric, &rule.Certificate, &rule.DNS, &rule.Enabled,
 		&rule.CreatedAt, &rule.UpdatedAt)
 
 	if err == sql.ErrNoRows {
@@ -77,7 +77,7 @@ func (r *AlertRepository) GetRule(ctx context.Context, id string) (*models.Alert
 func (r *AlertRepository) ListRules(ctx context.Context) ([]*models.AlertRule, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT id, name, metric_type, operator, threshold, duration_sec, 
-			cooldown_sec, agent_ids, enabled, created_at, updated_at
+			cooldown_sec, agent_ids, container, watch, custom_metric, certificate, dns, enabled, created_at, updated_at
 		FROM alert_rules ORDER BY name
 	`)
 	if err != nil {
@@ -92,7 +92,7 @@ func (r *AlertRepository) ListRules(ctx context.Context) ([]*models.AlertRule, e
 
 		if err := rows.Scan(&rule.ID, &rule.Name, &rule.MetricType, &rule.Operator,
 			&rule.Threshold, &rule.Duration, &rule.Cooldown, &agentIDsJSON,
-			&rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+			&rule.Container, &rule.Watch, &rule.CustomMetric, &rule.Certificate, &rule.DNS, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
 			return nil, err
 		}
 
@@ -106,7 +106,7 @@ func (r *AlertRepository) ListRules(ctx context.Context) ([]*models.AlertRule, e
 func (r *AlertRepository) ListEnabledRules(ctx context.Context) ([]*models.AlertRule, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT id, name, metric_type, operator, threshold, duration_sec, 
-			cooldown_sec, agent_ids, enabled, created_at, updated_at
+			cooldown_sec, agent_ids, container, watch, custom_metric, certificate, dns, enabled, created_at, updated_at
 		FROM alert_rules WHERE enabled = 1
 	`)
 	if err != nil {
@@ -121,7 +121,7 @@ func (r *AlertRepository) ListEnabledRules(ctx context.Context) ([]*models.Alert
 
 		if err := rows.Scan(&rule.ID, &rule.Name, &rule.MetricType, &rule.Operator,
 			&rule.Threshold, &rule.Duration, &rule.Cooldown, &agentIDsJSON,
-			&rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
+			&rule.Container, &rule.Watch, &rule.CustomMetric, &rule.Certificate, &rule.DNS, &rule.Enabled, &rule.CreatedAt, &rule.UpdatedAt); err != nil {
 			return nil, err
 		}
 
@@ -134,12 +134,17 @@ func (r *AlertRepository) ListEnabledRules(ctx context.Context) ([]*models.Alert
 
 // Alerts
 func (r *AlertRepository) CreateAlert(ctx context.Context, alert *models.Alert) error {
+	var processesJSON []byte
+	if alert.Processes != nil {
+		processesJSON, _ = json.Marshal(alert.Processes)
+	}
+
 	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO alerts (id, rule_id, agent_id, status, metric_type, value, 
-			threshold, message, triggered_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+			threshold, message, processes, triggered_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, alert.ID, alert.RuleID, alert.AgentID, alert.Status, alert.MetricType,
-		alert.Value, alert.Threshold, alert.Message, alert.TriggeredAt)
+		alert.Value, alert.Threshold, alert.Message, string(processesJSON), alert.TriggeredAt)
 
 	return err
 }
@@ -155,7 +160,7 @@ func (r *AlertRepository) ResolveAlert(ctx context.Context, id string) error {
 func (r *AlertRepository) GetActiveAlerts(ctx context.Context) ([]*models.Alert, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT a.id, a.rule_id, r.name, a.agent_id, ag.custom_name, a.status, 
-			a.metric_type, a.value, a.threshold, a.message, a.triggered_at, a.resolved_at
+			a.metric_type, a.value, a.threshold, a.message, a.processes, a.triggered_at, a.resolved_at
 		FROM alerts a
 		LEFT JOIN alert_rules r ON a.rule_id = r.id
 		LEFT JOIN agents ag ON a.agent_id = ag.id
@@ -173,7 +178,7 @@ func (r *AlertRepository) GetActiveAlerts(ctx context.Context) ([]*models.Alert,
 func (r *AlertRepository) GetAlertHistory(ctx context.Context, limit int) ([]*models.Alert, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT a.id, a.rule_id, r.name, a.agent_id, ag.custom_name, a.status, 
-			a.metric_type, a.value, a.threshold, a.message, a.triggered_at, a.resolved_at
+			a.metric_type, a.value, a.threshold, a.message, a.processes, a.triggered_at, a.resolved_at
 		FROM alerts a
 		LEFT JOIN alert_rules r ON a.rule_id = r.id
 		LEFT JOIN agents ag ON a.agent_id = ag.id
@@ -192,12 +197,12 @@ func (r *AlertRepository) scanAlerts(rows *sql.Rows) ([]*models.Alert, error) {
 	alerts := []*models.Alert{}
 	for rows.Next() {
 		alert := &models.Alert{}
-		var ruleName, agentName sql.NullString
+		var ruleName, agentName, processesJSON sql.NullString
 		var resolvedAt sql.NullTime
 
 		if err := rows.Scan(&alert.ID, &alert.RuleID, &ruleName, &alert.AgentID,
 			&agentName, &alert.Status, &alert.MetricType, &alert.Value,
-			&alert.Threshold, &alert.Message, &alert.TriggeredAt, &resolvedAt); err != nil {
+			&alert.Threshold, &alert.Message, &processesJSON, &alert.TriggeredAt, &resolvedAt); err != nil {
 			return nil, err
 		}
 
@@ -210,6 +215,10 @@ func (r *AlertRepository) scanAlerts(rows *sql.Rows) ([]*models.Alert, error) {
 		if resolvedAt.Valid {
 			alert.ResolvedAt = &resolvedAt.Time
 		}
+		if processesJSON.String != "" {
+			alert.Processes = &models.ProcessSnapshot{}
+			json.Unmarshal([]byte(processesJSON.String), alert.Processes)
+		}
 
 		alerts = append(alerts, alert)
 	}
@@ -265,3 +274,11 @@ func (r *AlertRepository) GetLastAlertTime(ctx context.Context, ruleID, agentID
 	}
 	return nil, nil
 }
+
+func (r *AlertRepository) CleanupResolved(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.deleteInBatches(ctx, "alerts", "status = 'resolved' AND resolved_at < ?", cutoff)
+}
@@ -0,0 +1,203 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/mattn/go-sqlite3"
+)
+
+var ErrInvalidBackup = errors.New("invalid backup")
+
+// Backup writes a consistent snapshot of the database to dest using the
+// SQLite online backup API. The copy runs on its own connection so the
+// application pool is not blocked, and WAL mode lets writers continue while
+// it reads. The snapshot is written to a temporary file and renamed into
+// place once complete.
+func (db *DB) Backup(ctx context.Context, dest string) error {
+	tmp := dest + ".tmp"
+	os.Remove(tmp)
+
+	src, err := sql.Open("sqlite3", db.path+"?_busy_timeout=5000")
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	dst, err := sql.Open("sqlite3", tmp)
+	if err != nil {
+		return err
+	}
+
+	if err := copyDatabase(ctx, dst, src); err != nil {
+		dst.Close()
+		os.Remove(tmp)
+		return fmt.Errorf("backup failed: %w", err)
+	}
+	if err := dst.Close(); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+
+	return os.Rename(tmp, dest)
+}
+
+func copyDatabase(ctx context.Context, dst, src *sql.DB) error {
+	srcConn, err := src.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer srcConn.Close()
+
+	dstConn, err := dst.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer dstConn.Close()
+
+	if err := dstConn.Raw(func(dstRaw interface{}) error {
+		return srcConn.Raw(func(srcRaw interface{}) error {
+			b, err := dstRaw.(*sqlite3.SQLiteConn).Backup("main", srcRaw.(*sqlite3.SQLiteConn), "main")
+			if err != nil {
+				return err
+			}
+			// Copying all pages in one step keeps a single read transaction
+			// open, so the snapshot is consistent even if writes happen.
+			if _, err := b.Step(-1); err != nil {
+				b.Finish()
+				return err
+			}
+			return b.Finish()
+		})
+	}); err != nil {
+		return err
+	}
+
+	// The copy inherits WAL mode; switch it back so the snapshot is a single
+	// self-contained file. NewDB re-enables WAL after a restore.
+	_, err = dstConn.ExecContext(ctx, "PRAGMA journal_mode = DELETE")
+	return err
+}
+
+// InspectBackup checks that path is an intact database written by a
+// compatible version of core and returns its schema version.
+func InspectBackup(path string) (int, error) {
+	if _, err := os.Stat(path); err != nil {
+		return 0, err
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return 0, err
+	}
+	defer db.Close()
+
+	var check string
+	if err := db.QueryRow("PRAGMA quick_check").Scan(&check); err != nil {
+		return 0, fmt.Errorf("%w: %v", ErrInvalidBackup, err)
+	}
+	if check != "ok" {
+		return 0, fmt.Errorf("%w: integrity check: %s", ErrInvalidBackup, check)
+	}
+
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return 0, err
+	}
+	if version == 0 {
+		return 0, fmt.Errorf("%w: no schema version, not a core backup", ErrInvalidBackup)
+	}
+	if version > SchemaVersion {
+		return 0, fmt.Errorf("%w: schema version %d is newer than supported version %d", ErrInvalidBackup, version, SchemaVersion)
+	}
+
+	return version, nil
+}
+
+// Restore replaces the database at dbPath with the backup at backupPath.
+// It fails with ErrDatabaseLocked while core is running. The current
+// database is checkpointed and kept next to the original as
+// <path>.pre-restore-<timestamp>; its name is returned, or "" when there was
+// no database to replace.
+func Restore(dbPath, backupPath string) (string, error) {
+	if _, err := InspectBackup(backupPath); err != nil {
+		return "", err
+	}
+
+	unlock, err := LockDatabase(dbPath)
+	if err != nil {
+		return "", err
+	}
+	defer unlock()
+
+	tmp := dbPath + ".restore.tmp"
+	if err := copyFile(backupPath, tmp); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	var previous string
+	if _, err := os.Stat(dbPath); err == nil {
+		if err := checkpoint(dbPath); err != nil {
+			os.Remove(tmp)
+			return "", err
+		}
+		previous = dbPath + ".pre-restore-" + time.Now().Format("20060102-150405")
+		if err := os.Rename(dbPath, previous); err != nil {
+			os.Remove(tmp)
+			return "", err
+		}
+	}
+	os.Remove(dbPath + "-wal")
+	os.Remove(dbPath + "-shm")
+
+	if err := os.Rename(tmp, dbPath); err != nil {
+		return previous, err
+	}
+
+	return previous, nil
+}
+
+// checkpoint folds the WAL back into the main database file so the file can
+// be moved aside on its own.
+func checkpoint(path string) error {
+	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	if _, err := db.Exec("PRAGMA wal_checkpoint(TRUNCATE)"); err != nil {
+		return fmt.Errorf("checkpoint failed: %w", err)
+	}
+
+	return nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,207 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/probe-system/core/internal/models"
+)
+
+type CommandRepository struct {
+	db *DB
+}
+
+func NewCommandRepository(db *DB) *CommandRepository {
+	return &CommandRepository{db: db}
+}
+
+// Create stores the run together with its targets.
+func (r *CommandRepository) Create(ctx context.Context, run *models.CommandRun) error {
+	paramsJSON, _ := json.Marshal(run.Params)
+	agentIDsJSON, _ := json.Marshal(run.AgentIDs)
+
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO command_runs (id, command, script_id, params, timeout_sec, agent_ids, created_by, status, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, run.ID, run.Command, run.ScriptID, string(paramsJSON), run.Timeout, string(agentIDsJSON),
+		run.CreatedBy, run.Status, run.CreatedAt); err != nil {
+		return err
+	}
+	for _, t := range run.Targets {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT INTO command_targets (run_id, agent_id, status) VALUES (?, ?, ?)
+		`, t.RunID, t.AgentID, t.Status); err != nil {
+			return err
+		}
+	}
+	return tx.Commit()
+}
+
+func (r *CommandRepository) UpdateRun(ctx context.Context, run *models.CommandRun) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE command_runs SET status = ?, finished_at = ? WHERE id = ?
+	`, run.Status, run.FinishedAt, run.ID)
+	return err
+}
+
+func (r *CommandRepository) UpdateTarget(ctx context.Context, t *models.CommandTarget) error {
+	outputJSON, _ := json.Marshal(t.Output)
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE command_targets SET status = ?, exit_code = ?, error = ?, output = ?, truncated = ?,
+			duration_ms = ?, started_at = ?, finished_at = ?
+		WHERE run_id = ? AND agent_id = ?
+	`, t.Status, t.ExitCode, t.Error, string(outputJSON), t.Truncated, t.Duration,
+		t.StartedAt, t.FinishedAt, t.RunID, t.AgentID)
+	return err
+}
+
+func (r *CommandRepository) GetByID(ctx context.Context, id string) (*models.CommandRun, error) {
+	runs, err := r.queryRuns(ctx, `WHERE id = ?`, id)
+	if err != nil || len(runs) == 0 {
+		return nil, err
+	}
+	run := runs[0]
+
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT run_id, agent_id, status, exit_code, error, truncated, duration_ms, started_at, finished_at, output
+		FROM command_targets WHERE run_id = ? ORDER BY agent_id
+	`, id)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var outputJSON string
+		t, err := scanCommandTarget(rows, &outputJSON)
+		if err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(outputJSON), &t.Output)
+		run.Targets = append(run.Targets, t)
+	}
+	return run, rows.Err()
+}
+
+// List returns the latest runs, newest first, with their targets but
+// without output.
+func (r *CommandRepository) List(ctx context.Context, limit int) ([]*models.CommandRun, error) {
+	runs, err := r.queryRuns(ctx, `ORDER BY created_at DESC LIMIT ?`, limit)
+	if err != nil || len(runs) == 0 {
+		return runs, err
+	}
+
+	byID := make(map[string]*models.CommandRun, len(runs))
+	for _, run := range runs {
+		byID[run.ID] = run
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT run_id, agent_id, status, exit_code, error, truncated, duration_ms, started_at, finished_at
+		FROM command_targets
+		WHERE run_id IN (SELECT id FROM command_runs ORDER BY created_at DESC LIMIT ?)
+		ORDER BY agent_id
+	`, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		t, err := scanCommandTarget(rows)
+		if err != nil {
+			return nil, err
+		}
+		if run := byID[t.RunID]; run != nil {
+			run.Targets = append(run.Targets, t)
+		}
+	}
+	return runs, rows.Err()
+}
+
+func (r *CommandRepository) queryRuns(ctx context.Context, clause string, args ...interface{}) ([]*models.CommandRun, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, command, script_id, params, timeout_sec, agent_ids, created_by, status, created_at, finished_at
+		FROM command_runs `+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	runs := []*models.CommandRun{}
+	for rows.Next() {
+		run := &models.CommandRun{Targets: []*models.CommandTarget{}}
+		var paramsJSON, agentIDsJSON string
+		var finishedAt sql.NullTime
+		if err := rows.Scan(&run.ID, &run.Command, &run.ScriptID, &paramsJSON, &run.Timeout, &agentIDsJSON,
+			&run.CreatedBy, &run.Status, &run.CreatedAt, &finishedAt); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(paramsJSON), &run.Params)
+		json.Unmarshal([]byte(agentIDsJSON), &run.AgentIDs)
+		if finishedAt.Valid {
+			run.FinishedAt = &finishedAt.Time
+		}
+		runs = append(runs, run)
+	}
+	return runs, rows.Err()
+}
+
+// scanCommandTarget reads a target row, followed by the extra columns given.
+func scanCommandTarget(rows *sql.Rows, extra ...interface{}) (*models.CommandTarget, error) {
+	t := &models.CommandTarget{}
+	var exitCode sql.NullInt64
+	var startedAt, finishedAt sql.NullTime
+	dest := append([]interface{}{&t.RunID, &t.AgentID, &t.Status, &exitCode, &t.Error, &t.Truncated,
+		&t.Duration, &startedAt, &finishedAt}, extra...)
+	if err := rows.Scan(dest...); err != nil {
+		return nil, err
+	}
+	if exitCode.Valid {
+		code := int(exitCode.Int64)
+		t.ExitCode = &code
+	}
+	if startedAt.Valid {
+		t.StartedAt = &startedAt.Time
+	}
+	if finishedAt.Valid {
+		t.FinishedAt = &finishedAt.Time
+	}
+	return t, nil
+}
+
+// AbortUnfinished ends the runs left running when core stopped; their agents
+// are no longer reporting to anyone.
+func (r *CommandRepository) AbortUnfinished(ctx context.Context, reason string) error {
+	now := time.Now()
+	if _, err := r.db.ExecContext(ctx, `
+		UPDATE command_targets SET status = ?, error = ?, finished_at = ? WHERE status IN (?, ?)
+	`, models.CommandFailed, reason, now, models.CommandPending, models.CommandRunning); err != nil {
+		return err
+	}
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE command_runs SET status = ?, finished_at = ? WHERE status = ?
+	`, models.CommandFinished, now, models.CommandRunning)
+	return err
+}
+
+// Cleanup deletes the runs older than the retention period and returns how
+// many.
+func (r *CommandRepository) Cleanup(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	if _, err := r.db.deleteInBatches(ctx, "command_targets",
+		"run_id IN (SELECT id FROM command_runs WHERE created_at < ? AND status != ?)", cutoff, models.CommandRunning); err != nil {
+		return 0, err
+	}
+	return r.db.deleteInBatches(ctx, "command_runs", "created_at < ? AND status != ?", cutoff, models.CommandRunning)
+}
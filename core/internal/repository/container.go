@@ -0,0 +1,159 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/probe-system/core/internal/models"
+)
+
+type ContainerRepository struct {
+	db *DB
+}
+
+func NewContainerRepository(db *DB) *ContainerRepository {
+	return &ContainerRepository{db: db}
+}
+
+const containerColumns = `agent_id, id, name, image, runtime, state, restart_count, started_at,
+	cpu, memory_usage, memory_limit, memory_percent, net_rx, net_tx, net_rx_rate, net_tx_rate,
+	first_seen_at, last_seen_at`
+
+// Sync records a full container report from an agent in one transaction:
+// reported containers are upserted and sampled, and containers missing from
+// the report are marked removed.
+func (r *ContainerRepository) Sync(ctx context.Context, agentID string, containers []*models.Container, now time.Time) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	upsert, err := tx.PrepareContext(ctx, `
+		INSERT INTO containers (`+containerColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT (agent_id, id) DO UPDATE SET
+			name = excluded.name, image = excluded.image, runtime = excluded.runtime,
+			state = excluded.state, restart_count = excluded.restart_count,
+			started_at = excluded.started_at, cpu = excluded.cpu,
+			memory_usage = excluded.memory_usage, memory_limit = excluded.memory_limit,
+			memory_percent = excluded.memory_percent, net_rx = excluded.net_rx,
+			net_tx = excluded.net_tx, net_rx_rate = excluded.net_rx_rate,
+			net_tx_rate = excluded.net_tx_rate, last_seen_at = excluded.last_seen_at
+	`)
+	if err != nil {
+		return err
+	}
+	defer upsert.Close()
+
+	sample, err := tx.PrepareContext(ctx, `
+		INSERT INTO container_metrics (id, agent_id, container_id, cpu, memory_usage,
+			memory_percent, net_rx_rate, net_tx_rate, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer sample.Close()
+
+	for _, c := range containers {
+		if _, err := upsert.ExecContext(ctx, agentID, c.ID, c.Name, c.Image, c.Runtime,
+			c.State, c.RestartCount, c.StartedAt, c.CPU, c.MemoryUsage, c.MemoryLimit,
+			c.MemoryPercent, c.NetRx, c.NetTx, c.NetRxRate, c.NetTxRate, now, now); err != nil {
+			return err
+		}
+
+		// Stopped containers have no usage worth charting.
+		if c.State != "running" {
+			continue
+		}
+		if _, err := sample.ExecContext(ctx, uuid.New().String(), agentID, c.ID, c.CPU,
+			c.MemoryUsage, c.MemoryPercent, c.NetRxRate, c.NetTxRate, now); err != nil {
+			return err
+		}
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE containers SET state = ?, cpu = 0, net_rx_rate = 0, net_tx_rate = 0
+		WHERE agent_id = ? AND last_seen_at < ? AND state != ?
+	`, models.ContainerStateRemoved, agentID, now, models.ContainerStateRemoved); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// List returns the agent's containers, removed ones last.
+func (r *ContainerRepository) List(ctx context.Context, agentID string) ([]*models.Container, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+containerColumns+`
+		FROM containers WHERE agent_id = ?
+		ORDER BY state = ?, name
+	`, agentID, models.ContainerStateRemoved)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	containers := []*models.Container{}
+	for rows.Next() {
+		c := &models.Container{}
+		var startedAt sql.NullTime
+		if err := rows.Scan(&c.AgentID, &c.ID, &c.Name, &c.Image, &c.Runtime, &c.State,
+			&c.RestartCount, &startedAt, &c.CPU, &c.MemoryUsage, &c.MemoryLimit,
+			&c.MemoryPercent, &c.NetRx, &c.NetTx, &c.NetRxRate, &c.NetTxRate,
+			&c.FirstSeenAt, &c.LastSeenAt); err != nil {
+			return nil, err
+		}
+		if startedAt.Valid {
+			c.StartedAt = &startedAt.Time
+		}
+		containers = append(containers, c)
+	}
+
+	return containers, rows.Err()
+}
+
+func (r *ContainerRepository) GetHistory(ctx context.Context, agentID, containerID string, from, to time.Time) ([]*models.ContainerMetric, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT container_id, cpu, memory_usage, memory_percent, net_rx_rate, net_tx_rate, timestamp
+		FROM container_metrics
+		WHERE agent_id = ? AND container_id = ? AND timestamp >= ? AND timestamp <= ?
+		ORDER BY timestamp ASC
+	`, agentID, containerID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*models.ContainerMetric{}
+	for rows.Next() {
+		m := &models.ContainerMetric{}
+		if err := rows.Scan(&m.ContainerID, &m.CPU, &m.MemoryUsage, &m.MemoryPercent,
+			&m.NetRxRate, &m.NetTxRate, &m.Timestamp); err != nil {
+			return nil, err
+		}
+		result = append(result, m)
+	}
+
+	return result, rows.Err()
+}
+
+// Cleanup deletes container samples and removed containers older than the
+// retention period.
+func (r *ContainerRepository) Cleanup(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+
+	deleted, err := r.db.deleteInBatches(ctx, "container_metrics", "timestamp < ?", cutoff)
+	if err != nil {
+		return deleted, err
+	}
+	removed, err := r.db.deleteInBatches(ctx, "containers", "state = ? AND last_seen_at < ?",
+		models.ContainerStateRemoved, cutoff)
+	return deleted + removed, err
+}
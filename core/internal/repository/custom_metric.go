@@ -0,0 +1,125 @@
+package repository
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/probe-system/core/internal/models"
+)
+
+type CustomMetricRepository struct {
+	db *DB
+}
+
+func NewCustomMetricRepository(db *DB) *CustomMetricRepository {
+	return &CustomMetricRepository{db: db}
+}
+
+// Store inserts one plugin report in a single transaction. Labels are stored
+// as JSON with sorted keys, so equal label sets compare equal in SQL.
+func (r *CustomMetricRepository) Store(ctx context.Context, metrics []*models.CustomMetric) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT INTO custom_metrics (id, agent_id, plugin, name, labels, value, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return err
+	}
+	defer stmt.Close()
+
+	for _, m := range metrics {
+		labels := m.Labels
+		if labels == nil {
+			labels = map[string]string{}
+		}
+		labelsJSON, _ := json.Marshal(labels)
+		if _, err := stmt.ExecContext(ctx, uuid.New().String(), m.AgentID, m.Plugin, m.Name,
+			string(labelsJSON), m.Value, m.Timestamp); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// ListLatest returns the most recent value of every series the agent reported
+// since the given time.
+func (r *CustomMetricRepository) ListLatest(ctx context.Context, agentID string, since time.Time) ([]*models.CustomMetric, error) {
+	// SQLite takes the bare columns from the row holding MAX(timestamp).
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT agent_id, plugin, name, labels, value, timestamp, MAX(timestamp)
+		FROM custom_metrics
+		WHERE agent_id = ? AND timestamp >= ?
+		GROUP BY name, labels
+		ORDER BY name, labels
+	`, agentID, since)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*models.CustomMetric{}
+	for rows.Next() {
+		m := &models.CustomMetric{}
+		var latest interface{}
+		if err := rows.Scan(&m.AgentID, &m.Plugin, &m.Name, &m.LabelsJSON, &m.Value,
+			&m.Timestamp, &latest); err != nil {
+			return nil, err
+		}
+		json.Unmarshal([]byte(m.LabelsJSON), &m.Labels)
+		result = append(result, m)
+	}
+
+	return result, rows.Err()
+}
+
+// GetHistory returns the history of a metric name with one series per label
+// set.
+func (r *CustomMetricRepository) GetHistory(ctx context.Context, agentID, name string, from, to time.Time) ([]*models.CustomMetricSeries, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT labels, value, timestamp
+		FROM custom_metrics
+		WHERE agent_id = ? AND name = ? AND timestamp >= ? AND timestamp <= ?
+		ORDER BY labels, timestamp ASC
+	`, agentID, name, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	result := []*models.CustomMetricSeries{}
+	var current *models.CustomMetricSeries
+	var currentLabels string
+	for rows.Next() {
+		var labels string
+		var point models.MetricPoint
+		if err := rows.Scan(&labels, &point.Value, &point.Timestamp); err != nil {
+			return nil, err
+		}
+		if current == nil || labels != currentLabels {
+			current = &models.CustomMetricSeries{Name: name, Points: []models.MetricPoint{}}
+			json.Unmarshal([]byte(labels), &current.Labels)
+			currentLabels = labels
+			result = append(result, current)
+		}
+		current.Points = append(current.Points, point)
+	}
+
+	return result, rows.Err()
+}
+
+func (r *CustomMetricRepository) Cleanup(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.deleteInBatches(ctx, "custom_metrics", "timestamp < ?", cutoff)
+}
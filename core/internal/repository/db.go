@@ -1,18 +1,62 @@
 package repository
 
 import (
+	"context"
 	"database/sql"
+	"errors"
 	"fmt"
+	"time"
 
 	_ "github.com/mattn/go-sqlite3"
+	"github.com/probe-system/core/internal/models"
 )
 
+// ErrIncrementalVacuumDisabled is returned by an incremental Vacuum on a
+// database that was created without incremental auto-vacuum.
+var ErrIncrementalVacuumDisabled = errors.New("incremental auto-vacuum is not enabled on this database")
+
 type DB struct {
 	*sql.DB
+	path string
+}
+
+// SchemaVersion is stored in PRAGMA user_version by Migrate. Bump it whenever
+// a migration changes the schema so restore can reject newer backups.
+const SchemaVersion = 21
+
+// exportPageSize is the number of rows read per query when iterating over a
+// table, so the single connection is not held while the caller consumes rows.
+const exportPageSize = 1000
+
+// pageClause appends the time range, optional agent filter and keyset
+// pagination condition shared by the Iterate methods.
+func pageClause(query string, filter *models.ExportFilter, lastTS time.Time, lastID string) (string, []interface{}) {
+	args := []interface{}{}
+	if !filter.From.IsZero() {
+		query += " AND timestamp >= ?"
+		args = append(args, filter.From)
+	}
+	if !filter.To.IsZero() {
+		query += " AND timestamp <= ?"
+		args = append(args, filter.To)
+	}
+	if filter.AgentID != "" {
+		query += " AND agent_id = ?"
+		args = append(args, filter.AgentID)
+	}
+	if lastID != "" {
+		query += " AND (timestamp > ? OR (timestamp = ? AND id > ?))"
+		args = append(args, lastTS, lastTS, lastID)
+	}
+	query += " ORDER BY timestamp, id LIMIT ?"
+	args = append(args, exportPageSize)
+	return query, args
 }
 
 func NewDB(path string) (*DB, error) {
-	db, err := sql.Open("sqlite3", path+"?_journal_mode=WAL&_busy_timeout=5000")
+	// New databases start with incremental auto-vacuum; existing ones keep
+	// theirs until EnableIncrementalVacuum.
+	db, err := sql.Open("sqlite3", path+"?_auto_vacuum=incremental&_journal_mode=WAL&_busy_timeout=5000")
 	if err != nil {
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
@@ -23,20 +67,34 @@ func NewDB(path string) (*DB, error) {
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
-	return &DB{db}, nil
+	return &DB{DB: db, path: path}, nil
 }
 
 func (db *DB) Migrate() error {
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
 	migrations := []string{
 		migrationGroups,
 		migrationAgents,
 		migrationMetrics,
+		migrationContainers,
+		migrationCustomMetrics,
 		migrationBillingCycles,
 		migrationTrafficRecords,
+		migrationTrafficArchives,
+		migrationTrafficImports,
 		migrationTasks,
 		migrationTaskResults,
+		migrationTaskSeries,
+		migrationTaskDeliveries,
 		migrationScripts,
+		migrationCommandRuns,
+		migrationTerminalSessions,
 		migrationAlertRules,
+		migrationWatches,
 		migrationAlerts,
 		migrationSettings,
 		migrationUsers,
@@ -48,9 +106,181 @@ func (db *DB) Migrate() error {
 		}
 	}
 
+	for _, c := range columnMigrations {
+		if err := db.addColumnIfMissing(c.table, c.column, c.definition); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	// Results recorded before version 15 have no series yet.
+	if version > 0 && version < 15 {
+		if err := db.backfillTaskSeries(); err != nil {
+			return fmt.Errorf("migration failed: %w", err)
+		}
+	}
+
+	if _, err := db.Exec(fmt.Sprintf("PRAGMA user_version = %d", SchemaVersion)); err != nil {
+		return fmt.Errorf("migration failed: %w", err)
+	}
+
 	return db.seedDefaults()
 }
 
+// columnMigrations lists columns added to tables after their first release.
+// CREATE TABLE statements already include them; this brings older databases
+// up to date.
+var columnMigrations = []struct {
+	table      string
+	column     string
+	definition string
+}{
+	{"settings", "task_result_retention_days", "INTEGER DEFAULT 30"},
+	{"settings", "traffic_retention_days", "INTEGER DEFAULT 90"},
+	{"settings", "alert_retention_days", "INTEGER DEFAULT 90"},
+	{"settings", "traffic_archive_retention_days", "INTEGER DEFAULT 365"},
+	{"settings", "vacuum_mode", "TEXT DEFAULT 'incremental'"},
+	{"settings", "task_series_retention_days", "INTEGER DEFAULT 180"},
+	{"metrics", "cpu_cores", "TEXT DEFAULT '[]'"},
+	{"metrics", "swap", "TEXT DEFAULT '{}'"},
+	{"metrics", "load_avg", "TEXT DEFAULT '{}'"},
+	{"metrics", "host", "TEXT DEFAULT '{}'"},
+	{"metrics", "disk_io", "TEXT DEFAULT '[]'"},
+	{"metrics", "sockets", "TEXT DEFAULT '{}'"},
+	{"alerts", "processes", "TEXT DEFAULT ''"},
+	{"alert_rules", "container", "TEXT DEFAULT ''"},
+	{"alert_rules", "watch", "TEXT DEFAULT ''"},
+	{"alert_rules", "custom_metric", "TEXT DEFAULT ''"},
+	{"task_results", "ping", "TEXT DEFAULT ''"},
+	{"task_results", "http", "TEXT DEFAULT ''"},
+	{"task_results", "tls", "TEXT DEFAULT ''"},
+	{"alert_rules", "certificate", "TEXT DEFAULT ''"},
+	{"task_results", "dns", "TEXT DEFAULT ''"},
+	{"alert_rules", "dns", "TEXT DEFAULT ''"},
+	{"task_results", "traceroute", "TEXT DEFAULT ''"},
+	{"tasks", "schedule", "TEXT DEFAULT ''"},
+	{"tasks", "timezone", "TEXT DEFAULT ''"},
+	{"tasks", "jitter_sec", "INTEGER DEFAULT 0"},
+	{"tasks", "group_ids", "TEXT DEFAULT '[]'"},
+	{"tasks", "tags", "TEXT DEFAULT '[]'"},
+}
+
+func (db *DB) addColumnIfMissing(table, column, definition string) error {
+	rows, err := db.Query(fmt.Sprintf("PRAGMA table_info(%s)", table))
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var cid, notNull, pk int
+		var name, colType string
+		var defaultValue sql.NullString
+		if err := rows.Scan(&cid, &name, &colType, &notNull, &defaultValue, &pk); err != nil {
+			return err
+		}
+		if name == column {
+			return nil
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	rows.Close()
+
+	_, err = db.Exec(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s %s", table, column, definition))
+	return err
+}
+
+// deleteBatchSize bounds how many rows a single DELETE removes, so retention
+// never holds the write lock long enough to stall incoming metrics.
+const deleteBatchSize = 1000
+
+// deleteInBatches deletes rows of table matching where, deleteBatchSize rows
+// per statement, and returns the total number of rows removed.
+func (db *DB) deleteInBatches(ctx context.Context, table, where string, args ...interface{}) (int64, error) {
+	query := fmt.Sprintf(`DELETE FROM %s WHERE rowid IN (SELECT rowid FROM %s WHERE %s LIMIT %d)`,
+		table, table, where, deleteBatchSize)
+
+	var total int64
+	for {
+		result, err := db.ExecContext(ctx, query, args...)
+		if err != nil {
+			return total, err
+		}
+		n, err := result.RowsAffected()
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if n < deleteBatchSize {
+			return total, nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return total, ctx.Err()
+		case <-time.After(10 * time.Millisecond):
+		}
+	}
+}
+
+// Vacuum reclaims free pages after large deletes. "incremental" only
+// releases free pages and needs incremental auto-vacuum, which databases
+// created before it was the default lack until EnableIncrementalVacuum;
+// "full" rebuilds the file.
+func (db *DB) Vacuum(ctx context.Context, mode models.VacuumMode) error {
+	switch mode {
+	case "", models.VacuumModeNone:
+		return nil
+	case models.VacuumModeFull:
+		_, err := db.ExecContext(ctx, "VACUUM")
+		return err
+	case models.VacuumModeIncremental:
+		autoVacuum, err := db.AutoVacuum(ctx)
+		if err != nil {
+			return err
+		}
+		if autoVacuum != models.VacuumModeIncremental {
+			return ErrIncrementalVacuumDisabled
+		}
+		_, err = db.ExecContext(ctx, "PRAGMA incremental_vacuum")
+		return err
+	default:
+		return fmt.Errorf("unknown vacuum mode: %s", mode)
+	}
+}
+
+// AutoVacuum returns the database's auto-vacuum mode.
+func (db *DB) AutoVacuum(ctx context.Context) (models.VacuumMode, error) {
+	var autoVacuum int
+	if err := db.QueryRowContext(ctx, "PRAGMA auto_vacuum").Scan(&autoVacuum); err != nil {
+		return "", err
+	}
+	switch autoVacuum {
+	case 1:
+		return models.VacuumModeFull, nil
+	case 2:
+		return models.VacuumModeIncremental, nil
+	default:
+		return models.VacuumModeNone, nil
+	}
+}
+
+// EnableIncrementalVacuum switches the database to incremental auto-vacuum.
+// That takes one full VACUUM, which rewrites the whole file and blocks every
+// other query until it is done.
+func (db *DB) EnableIncrementalVacuum(ctx context.Context) error {
+	autoVacuum, err := db.AutoVacuum(ctx)
+	if err != nil || autoVacuum == models.VacuumModeIncremental {
+		return err
+	}
+	if _, err := db.ExecContext(ctx, "PRAGMA auto_vacuum = INCREMENTAL"); err != nil {
+		return err
+	}
+	_, err = db.ExecContext(ctx, "VACUUM")
+	return err
+}
+
 func (db *DB) seedDefaults() error {
 	// Seed default settings
 	_, err := db.Exec(`
@@ -112,10 +342,58 @@ CREATE TABLE IF NOT EXISTS metrics (
 	memory TEXT DEFAULT '{}',
 	disks TEXT DEFAULT '[]',
 	network TEXT DEFAULT '{}',
+	cpu_cores TEXT DEFAULT '[]',
+	swap TEXT DEFAULT '{}',
+	load_avg TEXT DEFAULT '{}',
+	host TEXT DEFAULT '{}',
+	disk_io TEXT DEFAULT '[]',
+	sockets TEXT DEFAULT '{}',
 	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
 	FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
 );
 CREATE INDEX IF NOT EXISTS idx_metrics_agent_time ON metrics(agent_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_metrics_time ON metrics(timestamp);
+`
+
+const migrationContainers = `
+CREATE TABLE IF NOT EXISTS containers (
+	agent_id TEXT NOT NULL,
+	id TEXT NOT NULL,
+	name TEXT NOT NULL,
+	image TEXT DEFAULT '',
+	runtime TEXT DEFAULT '',
+	state TEXT DEFAULT '',
+	restart_count INTEGER DEFAULT 0,
+	started_at DATETIME,
+	cpu REAL DEFAULT 0,
+	memory_usage INTEGER DEFAULT 0,
+	memory_limit INTEGER DEFAULT 0,
+	memory_percent REAL DEFAULT 0,
+	net_rx INTEGER DEFAULT 0,
+	net_tx INTEGER DEFAULT 0,
+	net_rx_rate INTEGER DEFAULT 0,
+	net_tx_rate INTEGER DEFAULT 0,
+	first_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	last_seen_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (agent_id, id),
+	FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+);
+
+CREATE TABLE IF NOT EXISTS container_metrics (
+	id TEXT PRIMARY KEY,
+	agent_id TEXT NOT NULL,
+	container_id TEXT NOT NULL,
+	cpu REAL DEFAULT 0,
+	memory_usage INTEGER DEFAULT 0,
+	memory_percent REAL DEFAULT 0,
+	net_rx_rate INTEGER DEFAULT 0,
+	net_tx_rate INTEGER DEFAULT 0,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (agent_id, container_id) REFERENCES containers(agent_id, id) ON DELETE CASCADE
+);
+
+CREATE INDEX IF NOT EXISTS idx_container_metrics_container_time ON container_metrics(agent_id, container_id, timestamp);
+CREATE INDEX IF NOT EXISTS idx_container_metrics_time ON container_metrics(timestamp);
 `
 
 const migrationBillingCycles = `
@@ -142,6 +420,34 @@ CREATE TABLE IF NOT EXISTS traffic_records (
 	FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
 );
 CREATE INDEX IF NOT EXISTS idx_traffic_cycle ON traffic_records(cycle_id);
+CREATE INDEX IF NOT EXISTS idx_traffic_time ON traffic_records(timestamp);
+`
+
+const migrationTrafficArchives = `
+CREATE TABLE IF NOT EXISTS traffic_archives (
+	id TEXT PRIMARY KEY,
+	cycle_id TEXT NOT NULL,
+	agent_id TEXT NOT NULL,
+	cycle_start DATETIME NOT NULL,
+	cycle_end DATETIME NOT NULL,
+	bytes_sent INTEGER DEFAULT 0,
+	bytes_recv INTEGER DEFAULT 0,
+	limit_bytes INTEGER DEFAULT 0,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_traffic_archives_agent ON traffic_archives(agent_id, cycle_end DESC);
+`
+
+// traffic_imports remembers imported traffic records, which may since have
+// been folded into an archive, so that importing them again counts nothing.
+const migrationTrafficImports = `
+CREATE TABLE IF NOT EXISTS traffic_imports (
+	record_id TEXT PRIMARY KEY,
+	agent_id TEXT NOT NULL,
+	timestamp DATETIME NOT NULL
+);
+CREATE INDEX IF NOT EXISTS idx_traffic_imports_time ON traffic_imports(timestamp);
 `
 
 const migrationTasks = `
@@ -153,9 +459,14 @@ CREATE TABLE IF NOT EXISTS tasks (
 	script_id TEXT DEFAULT '',
 	params TEXT DEFAULT '{}',
 	interval_sec INTEGER DEFAULT 0,
+	schedule TEXT DEFAULT '',
+	timezone TEXT DEFAULT '',
+	jitter_sec INTEGER DEFAULT 0,
 	timeout_sec INTEGER DEFAULT 60,
 	status TEXT DEFAULT 'pending',
 	agent_ids TEXT DEFAULT '[]',
+	group_ids TEXT DEFAULT '[]',
+	tags TEXT DEFAULT '[]',
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
@@ -171,11 +482,55 @@ CREATE TABLE IF NOT EXISTS task_results (
 	output TEXT DEFAULT '',
 	error TEXT DEFAULT '',
 	duration_ms INTEGER DEFAULT 0,
+	ping TEXT DEFAULT '',
+	http TEXT DEFAULT '',
+	tls TEXT DEFAULT '',
+	dns TEXT DEFAULT '',
+	traceroute TEXT DEFAULT '',
 	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
 	FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
 	FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
 );
 CREATE INDEX IF NOT EXISTS idx_results_task ON task_results(task_id, timestamp DESC);
+CREATE INDEX IF NOT EXISTS idx_results_time ON task_results(timestamp);
+`
+
+// task_series holds the numeric fields of task results, one row per result
+// and field, so they can be charted without decoding every result.
+const migrationTaskSeries = `
+CREATE TABLE IF NOT EXISTS task_series (
+	result_id TEXT NOT NULL,
+	task_id TEXT NOT NULL,
+	agent_id TEXT NOT NULL,
+	field TEXT NOT NULL,
+	value REAL DEFAULT 0,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (result_id, field),
+	FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+	FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_task_series_field_time ON task_series(task_id, field, timestamp);
+CREATE INDEX IF NOT EXISTS idx_task_series_time ON task_series(timestamp);
+`
+
+// task_deliveries tracks each task on each agent it was assigned to.
+const migrationTaskDeliveries = `
+CREATE TABLE IF NOT EXISTS task_deliveries (
+	task_id TEXT NOT NULL,
+	agent_id TEXT NOT NULL,
+	state TEXT NOT NULL,
+	attempts INTEGER DEFAULT 0,
+	assigned_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	acked_at DATETIME,
+	last_result_at DATETIME,
+	last_success INTEGER,
+	error TEXT DEFAULT '',
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	PRIMARY KEY (task_id, agent_id),
+	FOREIGN KEY (task_id) REFERENCES tasks(id) ON DELETE CASCADE,
+	FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_task_deliveries_state ON task_deliveries(state, assigned_at);
 `
 
 const migrationScripts = `
@@ -190,6 +545,69 @@ CREATE TABLE IF NOT EXISTS scripts (
 );
 `
 
+// command_runs holds the history of commands run on agents from the admin
+// UI; command_targets the outcome and output on each agent.
+const migrationCommandRuns = `
+CREATE TABLE IF NOT EXISTS command_runs (
+	id TEXT PRIMARY KEY,
+	command TEXT DEFAULT '',
+	script_id TEXT DEFAULT '',
+	params TEXT DEFAULT '{}',
+	timeout_sec INTEGER DEFAULT 0,
+	agent_ids TEXT DEFAULT '[]',
+	created_by TEXT DEFAULT '',
+	status TEXT NOT NULL,
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	finished_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_command_runs_created ON command_runs(created_at);
+
+CREATE TABLE IF NOT EXISTS command_targets (
+	run_id TEXT NOT NULL,
+	agent_id TEXT NOT NULL,
+	status TEXT NOT NULL,
+	exit_code INTEGER,
+	error TEXT DEFAULT '',
+	output TEXT DEFAULT '[]',
+	truncated INTEGER DEFAULT 0,
+	duration_ms INTEGER DEFAULT 0,
+	started_at DATETIME,
+	finished_at DATETIME,
+	PRIMARY KEY (run_id, agent_id),
+	FOREIGN KEY (run_id) REFERENCES command_runs(id) ON DELETE CASCADE
+);
+`
+
+// terminal_sessions is the audit log of admin shells on agents;
+// terminal_recordings holds each session's recording in chunks of asciicast
+// v2 event lines, in seq order.
+const migrationTerminalSessions = `
+CREATE TABLE IF NOT EXISTS terminal_sessions (
+	id TEXT PRIMARY KEY,
+	agent_id TEXT NOT NULL,
+	username TEXT DEFAULT '',
+	remote_addr TEXT DEFAULT '',
+	width INTEGER DEFAULT 80,
+	height INTEGER DEFAULT 24,
+	status TEXT NOT NULL,
+	exit_code INTEGER,
+	reason TEXT DEFAULT '',
+	recording_bytes INTEGER DEFAULT 0,
+	started_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	ended_at DATETIME
+);
+CREATE INDEX IF NOT EXISTS idx_terminal_sessions_started ON terminal_sessions(started_at);
+CREATE INDEX IF NOT EXISTS idx_terminal_sessions_agent ON terminal_sessions(agent_id, started_at);
+
+CREATE TABLE IF NOT EXISTS terminal_recordings (
+	session_id TEXT NOT NULL,
+	seq INTEGER NOT NULL,
+	data TEXT NOT NULL,
+	PRIMARY KEY (session_id, seq),
+	FOREIGN KEY (session_id) REFERENCES terminal_sessions(id) ON DELETE CASCADE
+);
+`
+
 const migrationAlertRules = `
 CREATE TABLE IF NOT EXISTS alert_rules (
 	id TEXT PRIMARY KEY,
@@ -200,12 +618,44 @@ CREATE TABLE IF NOT EXISTS alert_rules (
 	duration_sec INTEGER DEFAULT 0,
 	cooldown_sec INTEGER DEFAULT 300,
 	agent_ids TEXT DEFAULT '[]',
+	container TEXT DEFAULT '',
+	watch TEXT DEFAULT '',
+	custom_metric TEXT DEFAULT '',
+	certificate TEXT DEFAULT '',
+	dns TEXT DEFAULT '',
 	enabled INTEGER DEFAULT 1,
 	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
 );
 `
 
+const migrationCustomMetrics = `
+CREATE TABLE IF NOT EXISTS custom_metrics (
+	id TEXT PRIMARY KEY,
+	agent_id TEXT NOT NULL,
+	plugin TEXT DEFAULT '',
+	name TEXT NOT NULL,
+	labels TEXT DEFAULT '{}',
+	value REAL DEFAULT 0,
+	timestamp DATETIME DEFAULT CURRENT_TIMESTAMP,
+	FOREIGN KEY (agent_id) REFERENCES agents(id) ON DELETE CASCADE
+);
+CREATE INDEX IF NOT EXISTS idx_custom_metrics_name_time ON custom_metrics(agent_id, name, timestamp);
+CREATE INDEX IF NOT EXISTS idx_custom_metrics_time ON custom_metrics(timestamp);
+`
+
+const migrationWatches = `
+CREATE TABLE IF NOT EXISTS watches (
+	id TEXT PRIMARY KEY,
+	name TEXT NOT NULL,
+	type TEXT NOT NULL,
+	target TEXT NOT NULL,
+	agent_ids TEXT DEFAULT '[]',
+	created_at DATETIME DEFAULT CURRENT_TIMESTAMP,
+	updated_at DATETIME DEFAULT CURRENT_TIMESTAMP
+);
+`
+
 const migrationAlerts = `
 CREATE TABLE IF NOT EXISTS alerts (
 	id TEXT PRIMARY KEY,
@@ -216,6 +666,7 @@ CREATE TABLE IF NOT EXISTS alerts (
 	value REAL DEFAULT 0,
 	threshold REAL DEFAULT 0,
 	message TEXT DEFAULT '',
+	processes TEXT DEFAULT '',
 	triggered_at DATETIME DEFAULT CURRENT_TIMESTAMP,
 	resolved_at DATETIME,
 	FOREIGN KEY (rule_id) REFERENCES alert_rules(id) ON DELETE CASCADE,
@@ -236,7 +687,13 @@ CREATE TABLE IF NOT EXISTS settings (
 	smtp_username TEXT DEFAULT '',
 	smtp_password TEXT DEFAULT '',
 	smtp_from TEXT DEFAULT '',
-	alert_email_to TEXT DEFAULT ''
+	alert_email_to TEXT DEFAULT '',
+	task_result_retention_days INTEGER DEFAULT 30,
+	traffic_retention_days INTEGER DEFAULT 90,
+	alert_retention_days INTEGER DEFAULT 90,
+	traffic_archive_retention_days INTEGER DEFAULT 365,
+	vacuum_mode TEXT DEFAULT 'incremental',
+	task_series_retention_days INTEGER DEFAULT 180
 );
 `
 
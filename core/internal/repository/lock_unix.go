@@ -0,0 +1,34 @@
+//go:build !windows
+
+package repository
+
+import (
+	"errors"
+	"os"
+	"syscall"
+)
+
+var ErrDatabaseLocked = errors.New("database is in use by another core process")
+
+// LockDatabase takes an exclusive advisory lock on <path>.lock for the life of
+// the process. The server holds it while running so restore can refuse to
+// swap the database underneath it.
+func LockDatabase(path string) (func(), error) {
+	f, err := os.OpenFile(path+".lock", os.O_CREATE|os.O_RDWR, 0600)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := syscall.Flock(int(f.Fd()), syscall.LOCK_EX|syscall.LOCK_NB); err != nil {
+		f.Close()
+		if errors.Is(err, syscall.EWOULDBLOCK) {
+			return nil, ErrDatabaseLocked
+		}
+		return nil, err
+	}
+
+	return func() {
+		syscall.Flock(int(f.Fd()), syscall.LOCK_UN)
+		f.Close()
+	}, nil
+}
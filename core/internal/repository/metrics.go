@@ -4,11 +4,92 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/probe-system/core/internal/models"
 )
 
+// metricExpressions maps queryable metric types to the SQL expression that
+// extracts their value from a metrics row.
+var metricExpressions = map[models.MetricType]string{
+	models.MetricTypeCPU:        "cpu",
+	models.MetricTypeMemory:     "json_extract(memory, '$.percent')",
+	models.MetricTypeDisk:       "(SELECT MAX(json_extract(value, '$.percent')) FROM json_each(disks))",
+	models.MetricTypeNetIn:      "json_extract(network, '$.bytes_recv_rate')",
+	models.MetricTypeNetOut:     "json_extract(network, '$.bytes_sent_rate')",
+	models.MetricTypeCPUCoreMax: "(SELECT MAX(value) FROM json_each(cpu_cores))",
+	models.MetricTypeSwap:       "json_extract(swap, '$.percent')",
+	models.MetricTypeLoad1:      "json_extract(load_avg, '$.load1')",
+	models.MetricTypeLoad5:      "json_extract(load_avg, '$.load5')",
+	models.MetricTypeLoad15:     "json_extract(load_avg, '$.load15')",
+	models.MetricTypeUptime:     "json_extract(host, '$.uptime')",
+	models.MetricTypeProcesses:  "json_extract(host, '$.processes')",
+	models.MetricTypeThreads:    "json_extract(host, '$.threads')",
+	models.MetricTypeDiskRead:   "(SELECT SUM(json_extract(value, '$.read_bytes_rate')) FROM json_each(disk_io))",
+	models.MetricTypeDiskWrite:  "(SELECT SUM(json_extract(value, '$.write_bytes_rate')) FROM json_each(disk_io))",
+	models.MetricTypeDiskIOPS:   "(SELECT SUM(json_extract(value, '$.read_iops') + json_extract(value, '$.write_iops')) FROM json_each(disk_io))",
+	models.MetricTypeDiskUtil:   "(SELECT MAX(json_extract(value, '$.util')) FROM json_each(disk_io))",
+	models.MetricTypeDiskAwait:  "(SELECT MAX(json_extract(value, '$.await_ms')) FROM json_each(disk_io))",
+
+	models.MetricTypeTCPEstablished: "json_extract(sockets, '$.tcp.ESTABLISHED')",
+	models.MetricTypeTCPTimeWait:    "json_extract(sockets, '$.tcp.TIME_WAIT')",
+	models.MetricTypeTCPSynRecv:     "json_extract(sockets, '$.tcp.SYN_RECV')",
+	models.MetricTypeTCPCloseWait:   "json_extract(sockets, '$.tcp.CLOSE_WAIT')",
+	models.MetricTypeTCPTotal:       "json_extract(sockets, '$.tcp_total')",
+	models.MetricTypeUDPSockets:     "json_extract(sockets, '$.udp')",
+	models.MetricTypeListenSockets:  "json_extract(sockets, '$.listen')",
+}
+
+func IsQueryableMetric(metric models.MetricType) bool {
+	_, ok := metricExpressions[metric]
+	return ok
+}
+
+const metricsColumns = "id, agent_id, cpu, cpu_cores, memory, swap, load_avg, disks, disk_io, network, host, sockets, timestamp"
+
+// scanMetrics reads a row selected with metricsColumns and decodes the JSON
+// columns into their structs.
+func scanMetrics(scan func(dest ...interface{}) error) (*models.Metrics, error) {
+	m := &models.Metrics{}
+	if err := scan(&m.ID, &m.AgentID, &m.CPU, &m.CPUCoresJSON, &m.MemoryJSON, &m.SwapJSON,
+		&m.LoadJSON, &m.DisksJSON, &m.DiskIOJSON, &m.NetworkJSON, &m.HostJSON, &m.SocketsJSON, &m.Timestamp); err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal([]byte(m.CPUCoresJSON), &m.CPUCores)
+	json.Unmarshal([]byte(m.MemoryJSON), &m.Memory)
+	json.Unmarshal([]byte(m.SwapJSON), &m.Swap)
+	json.Unmarshal([]byte(m.LoadJSON), &m.Load)
+	json.Unmarshal([]byte(m.DisksJSON), &m.Disks)
+	json.Unmarshal([]byte(m.DiskIOJSON), &m.DiskIO)
+	json.Unmarshal([]byte(m.NetworkJSON), &m.Network)
+	json.Unmarshal([]byte(m.HostJSON), &m.Host)
+	json.Unmarshal([]byte(m.SocketsJSON), &m.Sockets)
+
+	return m, nil
+}
+
+// metricsArgs returns the insert arguments in metricsColumns order.
+func metricsArgs(m *models.Metrics) []interface{} {
+	cpuCoresJSON, _ := json.Marshal(m.CPUCores)
+	memoryJSON, _ := json.Marshal(m.Memory)
+	swapJSON, _ := json.Marshal(m.Swap)
+	loadJSON, _ := json.Marshal(m.Load)
+	disksJSON, _ := json.Marshal(m.Disks)
+	diskIOJSON, _ := json.Marshal(m.DiskIO)
+	networkJSON, _ := json.Marshal(m.Network)
+	hostJSON, _ := json.Marshal(m.Host)
+	socketsJSON, _ := json.Marshal(m.Sockets)
+
+	return []interface{}{
+		m.ID, m.AgentID, m.CPU, string(cpuCoresJSON), string(memoryJSON), string(swapJSON),
+		string(loadJSON), string(disksJSON), string(diskIOJSON), string(networkJSON), string(hostJSON),
+		string(socketsJSON), m.Timestamp,
+	}
+}
+
 type MetricsRepository struct {
 	db *DB
 }
@@ -18,28 +99,19 @@ func NewMetricsRepository(db *DB) *MetricsRepository {
 }
 
 func (r *MetricsRepository) Store(ctx context.Context, metrics *models.Metrics) error {
-	memoryJSON, _ := json.Marshal(metrics.Memory)
-	disksJSON, _ := json.Marshal(metrics.Disks)
-	networkJSON, _ := json.Marshal(metrics.Network)
-
 	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO metrics (id, agent_id, cpu, memory, disks, network, timestamp)
-		VALUES (?, ?, ?, ?, ?, ?, ?)
-	`, metrics.ID, metrics.AgentID, metrics.CPU, string(memoryJSON),
-		string(disksJSON), string(networkJSON), metrics.Timestamp)
+		INSERT INTO metrics (`+metricsColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, metricsArgs(metrics)...)
 
 	return err
 }
 
 func (r *MetricsRepository) GetLatest(ctx context.Context, agentID string) (*models.Metrics, error) {
-	metrics := &models.Metrics{}
-	var memoryJSON, disksJSON, networkJSON string
-
-	err := r.db.QueryRowContext(ctx, `
-		SELECT id, agent_id, cpu, memory, disks, network, timestamp
+	metrics, err := scanMetrics(r.db.QueryRowContext(ctx, `
+		SELECT `+metricsColumns+`
 		FROM metrics WHERE agent_id = ? ORDER BY timestamp DESC LIMIT 1
-	`, agentID).Scan(&metrics.ID, &metrics.AgentID, &metrics.CPU,
-		&memoryJSON, &disksJSON, &networkJSON, &metrics.Timestamp)
+	`, agentID).Scan)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -48,16 +120,12 @@ func (r *MetricsRepository) GetLatest(ctx context.Context, agentID string) (*mod
 		return nil, err
 	}
 
-	json.Unmarshal([]byte(memoryJSON), &metrics.Memory)
-	json.Unmarshal([]byte(disksJSON), &metrics.Disks)
-	json.Unmarshal([]byte(networkJSON), &metrics.Network)
-
 	return metrics, nil
 }
 
 func (r *MetricsRepository) GetHistory(ctx context.Context, agentID string, from, to time.Time) ([]*models.Metrics, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, agent_id, cpu, memory, disks, network, timestamp
+		SELECT `+metricsColumns+`
 		FROM metrics 
 		WHERE agent_id = ? AND timestamp >= ? AND timestamp <= ?
 		ORDER BY timestamp ASC
@@ -69,18 +137,11 @@ func (r *MetricsRepository) GetHistory(ctx context.Context, agentID string, from
 
 	result := []*models.Metrics{}
 	for rows.Next() {
-		metrics := &models.Metrics{}
-		var memoryJSON, disksJSON, networkJSON string
-
-		if err := rows.Scan(&metrics.ID, &metrics.AgentID, &metrics.CPU,
-			&memoryJSON, &disksJSON, &networkJSON, &metrics.Timestamp); err != nil {
+		metrics, err := scanMetrics(rows.Scan)
+		if err != nil {
 			return nil, err
 		}
 
-		json.Unmarshal([]byte(memoryJSON), &metrics.Memory)
-		json.Unmarshal([]byte(disksJSON), &metrics.Disks)
-		json.Unmarshal([]byte(networkJSON), &metrics.Network)
-
 		result = append(result, metrics)
 	}
 
@@ -88,17 +149,126 @@ func (r *MetricsRepository) GetHistory(ctx context.Context, agentID string, from
 }
 
 func (r *MetricsRepository) Cleanup(ctx context.Context, retentionDays int) (int64, error) {
-	cutoff := time.Now().AddDate(0, 0, -retentionDays)
-	result, err := r.db.ExecContext(ctx, `
-		DELETE FROM metrics WHERE timestamp < ?
-	`, cutoff)
-	if err != nil {
-		return 0, err
+	if retentionDays <= 0 {
+		return 0, nil
 	}
-	return result.RowsAffected()
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.deleteInBatches(ctx, "metrics", "timestamp < ?", cutoff)
 }
 
 func (r *MetricsRepository) GetAggregated(ctx context.Context, agentID string, from, to time.Time, interval string) ([]*models.Metrics, error) {
 	// For simplicity, return raw data - aggregation can be done in service layer
 	return r.GetHistory(ctx, agentID, from, to)
 }
+
+func (r *MetricsRepository) GetSamples(ctx context.Context, agentIDs []string, metric models.MetricType, from, to time.Time) ([]*models.MetricSample, error) {
+	expr, ok := metricExpressions[metric]
+	if !ok {
+		return nil, fmt.Errorf("unsupported metric: %s", metric)
+	}
+	if len(agentIDs) == 0 {
+		return []*models.MetricSample{}, nil
+	}
+
+	placeholders := strings.TrimSuffix(strings.Repeat("?,", len(agentIDs)), ",")
+	args := make([]interface{}, 0, len(agentIDs)+2)
+	for _, id := range agentIDs {
+		args = append(args, id)
+	}
+	args = append(args, from, to)
+
+	rows, err := r.db.QueryContext(ctx, fmt.Sprintf(`
+		SELECT agent_id, timestamp, COALESCE(%s, 0)
+		FROM metrics
+		WHERE agent_id IN (%s) AND timestamp >= ? AND timestamp <= ?
+		ORDER BY timestamp ASC
+	`, expr, placeholders), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []*models.MetricSample{}
+	for rows.Next() {
+		sample := &models.MetricSample{}
+		if err := rows.Scan(&sample.AgentID, &sample.Timestamp, &sample.Value); err != nil {
+			return nil, err
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, rows.Err()
+}
+
+func (r *MetricsRepository) Iterate(ctx context.Context, filter *models.ExportFilter, fn func(*models.Metrics) error) error {
+	var lastTS time.Time
+	var lastID string
+
+	for {
+		query, args := pageClause(`
+			SELECT `+metricsColumns+`
+			FROM metrics WHERE 1=1`, filter, lastTS, lastID)
+
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		page := []*models.Metrics{}
+		for rows.Next() {
+			metrics, err := scanMetrics(rows.Scan)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			page = append(page, metrics)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, m := range page {
+			if err := fn(m); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < exportPageSize {
+			return nil
+		}
+		lastTS, lastID = page[len(page)-1].Timestamp, page[len(page)-1].ID
+	}
+}
+
+// Import inserts a batch of metrics in one transaction, ignoring rows whose ID
+// already exists so an import can be re-run safely. It returns the number of
+// rows actually inserted.
+func (r *MetricsRepository) Import(ctx context.Context, batch []*models.Metrics) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO metrics (`+metricsColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var inserted int64
+	for _, metrics := range batch {
+		result, err := stmt.ExecContext(ctx, metricsArgs(metrics)...)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		inserted += n
+	}
+
+	return inserted, tx.Commit()
+}
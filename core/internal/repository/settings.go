@@ -18,10 +18,14 @@ func NewSettingsRepository(db *DB) *SettingsRepository {
 func (r *SettingsRepository) Get(ctx context.Context) (*models.Settings, error) {
 	settings := &models.Settings{}
 	err := r.db.QueryRowContext(ctx, `
-		SELECT data_retention_days, telegram_bot_token, telegram_chat_id,
+		SELECT data_retention_days, task_result_retention_days, task_series_retention_days, traffic_retention_days,
+			alert_retention_days, traffic_archive_retention_days, vacuum_mode,
+			telegram_bot_token, telegram_chat_id,
 			smtp_host, smtp_port, smtp_username, smtp_password, smtp_from, alert_email_to
 		FROM settings WHERE id = 1
-	`).Scan(&settings.DataRetentionDays, &settings.TelegramBotToken, &settings.TelegramChatID,
+	`).Scan(&settings.DataRetentionDays, &settings.TaskResultRetentionDays, &settings.TaskSeriesRetentionDays, &settings.TrafficRetentionDays,
+		&settings.AlertRetentionDays, &settings.TrafficArchiveRetentionDays, &settings.VacuumMode,
+		&settings.TelegramBotToken, &settings.TelegramChatID,
 		&settings.SMTPHost, &settings.SMTPPort, &settings.SMTPUsername, &settings.SMTPPassword,
 		&settings.SMTPFrom, &settings.AlertEmailTo)
 
@@ -38,6 +42,12 @@ func (r *SettingsRepository) Update(ctx context.Context, settings *models.Settin
 	_, err := r.db.ExecContext(ctx, `
 		UPDATE settings SET 
 			data_retention_days = ?,
+			task_result_retention_days = ?,
+			task_series_retention_days = ?,
+			traffic_retention_days = ?,
+			alert_retention_days = ?,
+			traffic_archive_retention_days = ?,
+			vacuum_mode = ?,
 			telegram_bot_token = ?,
 			telegram_chat_id = ?,
 			smtp_host = ?,
@@ -47,7 +57,9 @@ func (r *SettingsRepository) Update(ctx context.Context, settings *models.Settin
 			smtp_from = ?,
 			alert_email_to = ?
 		WHERE id = 1
-	`, settings.DataRetentionDays, settings.TelegramBotToken, settings.TelegramChatID,
+	`, settings.DataRetentionDays, settings.TaskResultRetentionDays, settings.TaskSeriesRetentionDays, settings.TrafficRetentionDays,
+		settings.AlertRetentionDays, settings.TrafficArchiveRetentionDays, settings.VacuumMode,
+		settings.TelegramBotToken, settings.TelegramChatID,
 		settings.SMTPHost, settings.SMTPPort, settings.SMTPUsername, settings.SMTPPassword,
 		settings.SMTPFrom, settings.AlertEmailTo)
 
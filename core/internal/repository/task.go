@@ -4,6 +4,8 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"fmt"
+	"strings"
 	"time"
 
 	"github.com/probe-system/core/internal/models"
@@ -20,13 +22,16 @@ func NewTaskRepository(db *DB) *TaskRepository {
 func (r *TaskRepository) Create(ctx context.Context, task *models.Task) error {
 	paramsJSON, _ := json.Marshal(task.Params)
 	agentIDsJSON, _ := json.Marshal(task.AgentIDs)
+	groupIDsJSON, _ := json.Marshal(task.GroupIDs)
+	tagsJSON, _ := json.Marshal(task.Tags)
 
 	_, err := r.db.ExecContext(ctx, `
 		INSERT INTO tasks (id, type, name, target, script_id, params, interval_sec, 
-			timeout_sec, status, agent_ids, created_at, updated_at)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+			schedule, timezone, jitter_sec, timeout_sec, status, agent_ids, group_ids, tags, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
 	`, task.ID, task.Type, task.Name, task.Target, task.ScriptID, string(paramsJSON),
-		task.Interval, task.Timeout, task.Status, string(agentIDsJSON),
+		task.Interval, task.Schedule, task.Timezone, task.Jitter, task.Timeout, task.Status, string(agentIDsJSON),
+		string(groupIDsJSON), string(tagsJSON),
 		task.CreatedAt, task.UpdatedAt)
 
 	return err
@@ -35,13 +40,17 @@ func (r *TaskRepository) Create(ctx context.Context, task *models.Task) error {
 func (r *TaskRepository) Update(ctx context.Context, task *models.Task) error {
 	paramsJSON, _ := json.Marshal(task.Params)
 	agentIDsJSON, _ := json.Marshal(task.AgentIDs)
+	groupIDsJSON, _ := json.Marshal(task.GroupIDs)
+	tagsJSON, _ := json.Marshal(task.Tags)
 
 	_, err := r.db.ExecContext(ctx, `
 		UPDATE tasks SET type=?, name=?, target=?, script_id=?, params=?, 
-			interval_sec=?, timeout_sec=?, status=?, agent_ids=?, updated_at=?
+			interval_sec=?, schedule=?, timezone=?, jitter_sec=?, timeout_sec=?, status=?, agent_ids=?,
+			group_ids=?, tags=?, updated_at=?
 		WHERE id=?
 	`, task.Type, task.Name, task.Target, task.ScriptID, string(paramsJSON),
-		task.Interval, task.Timeout, task.Status, string(agentIDsJSON),
+		task.Interval, task.Schedule, task.Timezone, task.Jitter, task.Timeout, task.Status, string(agentIDsJSON),
+		string(groupIDsJSON), string(tagsJSON),
 		time.Now(), task.ID)
 
 	return err
@@ -49,15 +58,16 @@ func (r *TaskRepository) Update(ctx context.Context, task *models.Task) error {
 
 func (r *TaskRepository) GetByID(ctx context.Context, id string) (*models.Task, error) {
 	task := &models.Task{}
-	var paramsJSON, agentIDsJSON string
+	var paramsJSON, agentIDsJSON, groupIDsJSON, tagsJSON string
 
 	err := r.db.QueryRowContext(ctx, `
 		SELECT id, type, name, target, script_id, params, interval_sec, 
-			timeout_sec, status, agent_ids, created_at, updated_at
+			schedule, timezone, jitter_sec, timeout_sec, status, agent_ids, group_ids, tags,
+			created_at, updated_at
 		FROM tasks WHERE id = ?
 	`, id).Scan(&task.ID, &task.Type, &task.Name, &task.Target, &task.ScriptID,
-		&paramsJSON, &task.Interval, &task.Timeout, &task.Status, &agentIDsJSON,
-		&task.CreatedAt, &task.UpdatedAt)
+		&paramsJSON, &task.Interval, &task.Schedule, &task.Timezone, &task.Jitter, &task.Timeout, &task.Status, &agentIDsJSON,
+		&groupIDsJSON, &tagsJSON, &task.CreatedAt, &task.UpdatedAt)
 
 	if err == sql.ErrNoRows {
 		return nil, nil
@@ -68,6 +78,8 @@ func (r *TaskRepository) GetByID(ctx context.Context, id string) (*models.Task,
 
 	json.Unmarshal([]byte(paramsJSON), &task.Params)
 	json.Unmarshal([]byte(agentIDsJSON), &task.AgentIDs)
+	json.Unmarshal([]byte(groupIDsJSON), &task.GroupIDs)
+	json.Unmarshal([]byte(tagsJSON), &task.Tags)
 
 	return task, nil
 }
@@ -75,7 +87,8 @@ func (r *TaskRepository) GetByID(ctx context.Context, id string) (*models.Task,
 func (r *TaskRepository) List(ctx context.Context) ([]*models.Task, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT id, type, name, target, script_id, params, interval_sec, 
-			timeout_sec, status, agent_ids, created_at, updated_at
+			schedule, timezone, jitter_sec, timeout_sec, status, agent_ids, group_ids, tags,
+			created_at, updated_at
 		FROM tasks ORDER BY created_at DESC
 	`)
 	if err != nil {
@@ -86,30 +99,34 @@ func (r *TaskRepository) List(ctx context.Context) ([]*models.Task, error) {
 	tasks := []*models.Task{}
 	for rows.Next() {
 		task := &models.Task{}
-		var paramsJSON, agentIDsJSON string
+		var paramsJSON, agentIDsJSON, groupIDsJSON, tagsJSON string
 
 		if err := rows.Scan(&task.ID, &task.Type, &task.Name, &task.Target, &task.ScriptID,
-			&paramsJSON, &task.Interval, &task.Timeout, &task.Status, &agentIDsJSON,
-			&task.CreatedAt, &task.UpdatedAt); err != nil {
+			&paramsJSON, &task.Interval, &task.Schedule, &task.Timezone, &task.Jitter, &task.Timeout, &task.Status, &agentIDsJSON,
+			&groupIDsJSON, &tagsJSON, &task.CreatedAt, &task.UpdatedAt); err != nil {
 			return nil, err
 		}
 
 		json.Unmarshal([]byte(paramsJSON), &task.Params)
 		json.Unmarshal([]byte(agentIDsJSON), &task.AgentIDs)
+		json.Unmarshal([]byte(groupIDsJSON), &task.GroupIDs)
+		json.Unmarshal([]byte(tagsJSON), &task.Tags)
 		tasks = append(tasks, task)
 	}
 
 	return tasks, nil
 }
 
-func (r *TaskRepository) ListByAgent(ctx context.Context, agentID string) ([]*models.Task, error) {
+// ListActive returns the pending and running tasks.
+func (r *TaskRepository) ListActive(ctx context.Context) ([]*models.Task, error) {
 	rows, err := r.db.QueryContext(ctx, `
 		SELECT id, type, name, target, script_id, params, interval_sec, 
-			timeout_sec, status, agent_ids, created_at, updated_at
+			schedule, timezone, jitter_sec, timeout_sec, status, agent_ids, group_ids, tags,
+			created_at, updated_at
 		FROM tasks 
-		WHERE agent_ids LIKE ? AND status IN ('pending', 'running')
+		WHERE status IN ('pending', 'running')
 		ORDER BY created_at DESC
-	`, "%"+agentID+"%")
+	`)
 	if err != nil {
 		return nil, err
 	}
@@ -118,16 +135,18 @@ func (r *TaskRepository) ListByAgent(ctx context.Context, agentID string) ([]*mo
 	tasks := []*models.Task{}
 	for rows.Next() {
 		task := &models.Task{}
-		var paramsJSON, agentIDsJSON string
+		var paramsJSON, agentIDsJSON, groupIDsJSON, tagsJSON string
 
 		if err := rows.Scan(&task.ID, &task.Type, &task.Name, &task.Target, &task.ScriptID,
-			&paramsJSON, &task.Interval, &task.Timeout, &task.Status, &agentIDsJSON,
-			&task.CreatedAt, &task.UpdatedAt); err != nil {
+			&paramsJSON, &task.Interval, &task.Schedule, &task.Timezone, &task.Jitter, &task.Timeout, &task.Status, &agentIDsJSON,
+			&groupIDsJSON, &tagsJSON, &task.CreatedAt, &task.UpdatedAt); err != nil {
 			return nil, err
 		}
 
 		json.Unmarshal([]byte(paramsJSON), &task.Params)
 		json.Unmarshal([]byte(agentIDsJSON), &task.AgentIDs)
+		json.Unmarshal([]byte(groupIDsJSON), &task.GroupIDs)
+		json.Unmarshal([]byte(tagsJSON), &task.Tags)
 		tasks = append(tasks, task)
 	}
 
@@ -146,19 +165,414 @@ func (r *TaskRepository) Delete(ctx context.Context, id string) error {
 	return err
 }
 
-// Task Results
-func (r *TaskRepository) RecordResult(ctx context.Context, result *models.TaskResult) error {
+// Task Deliveries
+const taskDeliveryColumns = "task_id, agent_id, state, attempts, assigned_at, acked_at, last_result_at, last_success, error, updated_at"
+
+// MarkAssigned records that the task is being sent to the agent. Attempts
+// counts up while the agent has not acknowledged it and starts over
+// otherwise. A one-time task the agent completed, failed or rejected is left
+// as it is, and MarkAssigned reports false: it must not be sent again.
+func (r *TaskRepository) MarkAssigned(ctx context.Context, task *models.Task, agentID string, at time.Time) (bool, error) {
+	result, err := r.db.ExecContext(ctx, `
+		INSERT INTO task_deliveries (task_id, agent_id, state, attempts, assigned_at, error, updated_at)
+		VALUES (?, ?, ?, 1, ?, '', ?)
+		ON CONFLICT(task_id, agent_id) DO UPDATE SET
+			attempts = CASE WHEN state = excluded.state THEN attempts + 1 ELSE 1 END,
+			state = excluded.state,
+			assigned_at = excluded.assigned_at,
+			acked_at = NULL,
+			error = '',
+			updated_at = excluded.updated_at
+		WHERE ? OR state NOT IN (?, ?, ?)
+	`, task.ID, agentID, models.DeliveryAssigned, at, at,
+		task.Recurring(), models.DeliveryComplete, models.DeliveryFailed, models.DeliveryRejected)
+	if err != nil {
+		return false, err
+	}
+	n, err := result.RowsAffected()
+	return n > 0, err
+}
+
+// AckDelivery records the agent's answer to the latest assignment. Answers
+// to assignments that were canceled or already answered are ignored.
+func (r *TaskRepository) AckDelivery(ctx context.Context, taskID, agentID string, state models.DeliveryState, errMsg string, at time.Time) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE task_deliveries SET state = ?, acked_at = ?, error = ?, updated_at = ?
+		WHERE task_id = ? AND agent_id = ? AND state = ?
+	`, state, at, errMsg, at, taskID, agentID, models.DeliveryAssigned)
+	return err
+}
+
+// RecordDelivery stores the result on the delivery it came from, creating
+// the delivery for agents that do not acknowledge assignments.
+func (r *TaskRepository) RecordDelivery(ctx context.Context, result *models.TaskResult, state models.DeliveryState) error {
 	_, err := r.db.ExecContext(ctx, `
-		INSERT INTO task_results (id, task_id, agent_id, success, output, error, duration_ms, timestamp)
-		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
-	`, result.ID, result.TaskID, result.AgentID, result.Success, result.Output,
-		result.Error, result.Duration, result.Timestamp)
+		INSERT INTO task_deliveries (task_id, agent_id, state, attempts, assigned_at, last_result_at, last_success, error, updated_at)
+		VALUES (?, ?, ?, 0, ?, ?, ?, ?, ?)
+		ON CONFLICT(task_id, agent_id) DO UPDATE SET
+			state = CASE WHEN state = ? THEN state ELSE excluded.state END,
+			last_result_at = excluded.last_result_at,
+			last_success = excluded.last_success,
+			error = excluded.error,
+			updated_at = excluded.updated_at
+	`, result.TaskID, result.AgentID, state, result.Timestamp, result.Timestamp, result.Success, result.Error,
+		result.Timestamp, models.DeliveryCanceled)
 	return err
 }
 
+// CancelDeliveries marks the task's unfinished deliveries canceled, on the
+// agent or, if agentID is empty, on every agent.
+func (r *TaskRepository) CancelDeliveries(ctx context.Context, taskID, agentID string) error {
+	query := `
+		UPDATE task_deliveries SET state = ?, updated_at = ?
+		WHERE task_id = ? AND state IN (?, ?, ?)`
+	args := []interface{}{models.DeliveryCanceled, time.Now(), taskID,
+		models.DeliveryAssigned, models.DeliveryAcknowledged, models.DeliveryRunning}
+	if agentID != "" {
+		query += " AND agent_id = ?"
+		args = append(args, agentID)
+	}
+	_, err := r.db.ExecContext(ctx, query, args...)
+	return err
+}
+
+func (r *TaskRepository) ListDeliveries(ctx context.Context, taskID string) ([]*models.TaskDelivery, error) {
+	return r.queryDeliveries(ctx, `
+		SELECT `+taskDeliveryColumns+` FROM task_deliveries WHERE task_id = ? ORDER BY agent_id
+	`, taskID)
+}
+
+// PendingDeliveries returns the assignments no agent has acknowledged yet,
+// oldest first.
+func (r *TaskRepository) PendingDeliveries(ctx context.Context) ([]*models.TaskDelivery, error) {
+	return r.queryDeliveries(ctx, `
+		SELECT `+taskDeliveryColumns+` FROM task_deliveries WHERE state = ? ORDER BY assigned_at
+	`, models.DeliveryAssigned)
+}
+
+func (r *TaskRepository) queryDeliveries(ctx context.Context, query string, args ...interface{}) ([]*models.TaskDelivery, error) {
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []*models.TaskDelivery{}
+	for rows.Next() {
+		d := &models.TaskDelivery{}
+		var ackedAt, resultAt sql.NullTime
+		var success sql.NullBool
+		if err := rows.Scan(&d.TaskID, &d.AgentID, &d.State, &d.Attempts, &d.AssignedAt,
+			&ackedAt, &resultAt, &success, &d.Error, &d.UpdatedAt); err != nil {
+			return nil, err
+		}
+		if ackedAt.Valid {
+			d.AckedAt = &ackedAt.Time
+		}
+		if resultAt.Valid {
+			d.LastResultAt = &resultAt.Time
+		}
+		if success.Valid {
+			d.LastSuccess = &success.Bool
+		}
+		deliveries = append(deliveries, d)
+	}
+	return deliveries, rows.Err()
+}
+
+// CountDeliveries returns the rollout of the task or, if taskID is empty, of
+// every task, by task ID. Tasks without deliveries are left out.
+func (r *TaskRepository) CountDeliveries(ctx context.Context, taskID string) (map[string]*models.TaskRollout, error) {
+	query := `SELECT task_id, state, COUNT(*) FROM task_deliveries`
+	args := []interface{}{}
+	if taskID != "" {
+		query += " WHERE task_id = ?"
+		args = append(args, taskID)
+	}
+	query += " GROUP BY task_id, state"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	rollouts := map[string]*models.TaskRollout{}
+	for rows.Next() {
+		var id string
+		var state models.DeliveryState
+		var n int
+		if err := rows.Scan(&id, &state, &n); err != nil {
+			return nil, err
+		}
+		if rollouts[id] == nil {
+			rollouts[id] = &models.TaskRollout{}
+		}
+		rollouts[id].Add(state, n)
+	}
+	return rollouts, rows.Err()
+}
+
+// Task Results
+const taskResultColumns = "id, task_id, agent_id, success, output, error, duration_ms, ping, http, tls, dns, traceroute, timestamp"
+
+// scanTaskResult reads a row selected with taskResultColumns and decodes the
+// structured result of its task type.
+func scanTaskResult(scan func(dest ...interface{}) error) (*models.TaskResult, error) {
+	result := &models.TaskResult{}
+	if err := scan(&result.ID, &result.TaskID, &result.AgentID, &result.Success, &result.Output,
+		&result.Error, &result.Duration, &result.PingJSON, &result.HTTPJSON, &result.TLSJSON, &result.DNSJSON, &result.TracerouteJSON, &result.Timestamp); err != nil {
+		return nil, err
+	}
+
+	decodeDetail(result.PingJSON, &result.Ping)
+	decodeDetail(result.HTTPJSON, &result.HTTP)
+	decodeDetail(result.TLSJSON, &result.TLS)
+	decodeDetail(result.DNSJSON, &result.DNS)
+	decodeDetail(result.TracerouteJSON, &result.Traceroute)
+	return result, nil
+}
+
+// taskResultArgs returns the insert arguments in taskResultColumns order.
+func taskResultArgs(result *models.TaskResult) []interface{} {
+	return []interface{}{
+		result.ID, result.TaskID, result.AgentID, result.Success, result.Output,
+		result.Error, result.Duration, encodeDetail(result.Ping), encodeDetail(result.HTTP),
+		encodeDetail(result.TLS), encodeDetail(result.DNS),
+		encodeDetail(result.Traceroute), result.Timestamp,
+	}
+}
+
+// encodeDetail encodes a task type's result for its column; results of other
+// task types leave it empty.
+func encodeDetail(detail interface{}) string {
+	data, _ := json.Marshal(detail)
+	if string(data) == "null" {
+		return ""
+	}
+	return string(data)
+}
+
+// decodeDetail decodes a column written by encodeDetail into dest, a pointer
+// to a result pointer, which stays nil for an empty or unreadable column.
+func decodeDetail(data string, dest interface{}) {
+	if data == "" {
+		return
+	}
+	json.Unmarshal([]byte(data), dest)
+}
+
+// RecordResult stores the result together with its series values.
+func (r *TaskRepository) RecordResult(ctx context.Context, result *models.TaskResult) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO task_results (`+taskResultColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, taskResultArgs(result)...); err != nil {
+		return err
+	}
+	if err := insertSeries(ctx, tx, result); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+func insertSeries(ctx context.Context, tx *sql.Tx, result *models.TaskResult) error {
+	for field, value := range result.SeriesValues() {
+		if _, err := tx.ExecContext(ctx, `
+			INSERT OR IGNORE INTO task_series (result_id, task_id, agent_id, field, value, timestamp)
+			VALUES (?, ?, ?, ?, ?, ?)
+		`, result.ID, result.TaskID, result.AgentID, field, value, result.Timestamp); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSeries returns the values of a task's result field in time order. No
+// agent IDs means every agent.
+func (r *TaskRepository) GetSeries(ctx context.Context, taskID, field string, agentIDs []string, from, to time.Time) ([]*models.TaskSeriesSample, error) {
+	query := `
+		SELECT agent_id, value, timestamp FROM task_series
+		WHERE task_id = ? AND field = ? AND timestamp >= ? AND timestamp <= ?`
+	args := []interface{}{taskID, field, from, to}
+	if len(agentIDs) > 0 {
+		query += " AND agent_id IN (?" + strings.Repeat(", ?", len(agentIDs)-1) + ")"
+		for _, id := range agentIDs {
+			args = append(args, id)
+		}
+	}
+	query += " ORDER BY timestamp"
+
+	rows, err := r.db.QueryContext(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	samples := []*models.TaskSeriesSample{}
+	for rows.Next() {
+		s := &models.TaskSeriesSample{}
+		if err := rows.Scan(&s.AgentID, &s.Value, &s.Timestamp); err != nil {
+			return nil, err
+		}
+		samples = append(samples, s)
+	}
+	return samples, rows.Err()
+}
+
+// SeriesAgents returns the agents that recorded a task's result field between
+// from and to.
+func (r *TaskRepository) SeriesAgents(ctx context.Context, taskID, field string, from, to time.Time) ([]string, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT DISTINCT agent_id FROM task_series
+		WHERE task_id = ? AND field = ? AND timestamp >= ? AND timestamp <= ?`,
+		taskID, field, from, to)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	agentIDs := []string{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		agentIDs = append(agentIDs, id)
+	}
+	return agentIDs, rows.Err()
+}
+
+// IterateSeries calls fn with the values of a task's result field in time
+// order, reading them a page at a time.
+func (r *TaskRepository) IterateSeries(ctx context.Context, taskID, field string, from, to time.Time, fn func(*models.TaskSeriesSample) error) error {
+	var lastTS time.Time
+	var lastID string
+
+	for {
+		query := `
+			SELECT result_id, agent_id, value, timestamp FROM task_series
+			WHERE task_id = ? AND field = ? AND timestamp >= ? AND timestamp <= ?`
+		args := []interface{}{taskID, field, from, to}
+		if lastID != "" {
+			query += " AND (timestamp > ? OR (timestamp = ? AND result_id > ?))"
+			args = append(args, lastTS, lastTS, lastID)
+		}
+		query += " ORDER BY timestamp, result_id LIMIT ?"
+		args = append(args, exportPageSize)
+
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		page := []*models.TaskSeriesSample{}
+		for rows.Next() {
+			s := &models.TaskSeriesSample{}
+			if err := rows.Scan(&s.ResultID, &s.AgentID, &s.Value, &s.Timestamp); err != nil {
+				rows.Close()
+				return err
+			}
+			page = append(page, s)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, s := range page {
+			if err := fn(s); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < exportPageSize {
+			return nil
+		}
+		lastTS, lastID = page[len(page)-1].Timestamp, page[len(page)-1].ResultID
+	}
+}
+
+func (r *TaskRepository) CleanupSeries(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.deleteInBatches(ctx, "task_series", "timestamp < ?", cutoff)
+}
+
+// backfillTaskSeries records the series of results stored before series
+// were. Ping results from before the ping column have their statistics as
+// JSON in the output.
+func (db *DB) backfillTaskSeries() error {
+	ctx := context.Background()
+	var lastTS time.Time
+	var lastID string
+	for {
+		query, args := pageClause(`SELECT `+taskResultColumns+` FROM task_results WHERE 1 = 1`,
+			&models.ExportFilter{}, lastTS, lastID)
+		rows, err := db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+		var page []*models.TaskResult
+		for rows.Next() {
+			result, err := scanTaskResult(rows.Scan)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			page = append(page, result)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			return err
+		}
+		for _, result := range page {
+			if result.Ping == nil && strings.HasPrefix(result.Output, "{") {
+				var legacy struct {
+					Latency    float64 `json:"latency"`
+					PacketLoss float64 `json:"packet_loss"`
+				}
+				if json.Unmarshal([]byte(result.Output), &legacy) == nil {
+					result.Ping = &models.PingResult{PacketLoss: legacy.PacketLoss, Avg: legacy.Latency}
+					if legacy.PacketLoss < 100 {
+						result.Ping.Sent, result.Ping.Received = 1, 1
+						result.Ping.Min, result.Ping.Max = legacy.Latency, legacy.Latency
+					}
+				}
+			}
+			if err := insertSeries(ctx, tx, result); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			return err
+		}
+
+		last := page[len(page)-1]
+		lastTS, lastID = last.Timestamp, last.ID
+	}
+}
+
 func (r *TaskRepository) GetResults(ctx context.Context, taskID string, limit int) ([]*models.TaskResult, error) {
 	rows, err := r.db.QueryContext(ctx, `
-		SELECT id, task_id, agent_id, success, output, error, duration_ms, timestamp
+		SELECT `+taskResultColumns+`
 		FROM task_results WHERE task_id = ?
 		ORDER BY timestamp DESC LIMIT ?
 	`, taskID, limit)
@@ -169,9 +583,8 @@ func (r *TaskRepository) GetResults(ctx context.Context, taskID string, limit in
 
 	results := []*models.TaskResult{}
 	for rows.Next() {
-		result := &models.TaskResult{}
-		if err := rows.Scan(&result.ID, &result.TaskID, &result.AgentID, &result.Success,
-			&result.Output, &result.Error, &result.Duration, &result.Timestamp); err != nil {
+		result, err := scanTaskResult(rows.Scan)
+		if err != nil {
 			return nil, err
 		}
 		results = append(results, result)
@@ -180,6 +593,150 @@ func (r *TaskRepository) GetResults(ctx context.Context, taskID string, limit in
 	return results, nil
 }
 
+// LatestTracerouteAt returns the agent's most recent result of the task with
+// a path, taken at or before the given time, or nil if there is none.
+func (r *TaskRepository) LatestTracerouteAt(ctx context.Context, taskID, agentID string, at time.Time) (*models.TaskResult, error) {
+	row := r.db.QueryRowContext(ctx, `
+		SELECT `+taskResultColumns+`
+		FROM task_results
+		WHERE task_id = ? AND agent_id = ? AND traceroute != '' AND timestamp <= ?
+		ORDER BY timestamp DESC LIMIT 1
+	`, taskID, agentID, at)
+
+	result, err := scanTaskResult(row.Scan)
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// latestDetailColumns names the column holding the structured result of the
+// task types LatestResults supports.
+var latestDetailColumns = map[models.TaskType]string{
+	models.TaskTypeTLS: "tls",
+	models.TaskTypeDNS: "dns",
+}
+
+// LatestResults returns the most recent result with a structured result for
+// each agent of every task of the type that is not canceled.
+func (r *TaskRepository) LatestResults(ctx context.Context, taskType models.TaskType) ([]*models.TaskResult, error) {
+	column, ok := latestDetailColumns[taskType]
+	if !ok {
+		return nil, fmt.Errorf("no structured results for task type %q", taskType)
+	}
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT `+taskResultColumns+`
+		FROM task_results
+		WHERE (task_id, agent_id, timestamp) IN (
+			SELECT task_id, agent_id, MAX(timestamp) FROM task_results
+			WHERE `+column+` != '' AND task_id IN (SELECT id FROM tasks WHERE type = ? AND status != ?)
+			GROUP BY task_id, agent_id
+		)
+	`, taskType, models.TaskStatusCanceled)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	results := []*models.TaskResult{}
+	for rows.Next() {
+		result, err := scanTaskResult(rows.Scan)
+		if err != nil {
+			return nil, err
+		}
+		if result.TLS != nil || result.DNS != nil {
+			results = append(results, result)
+		}
+	}
+	return results, rows.Err()
+}
+
+func (r *TaskRepository) CleanupResults(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.deleteInBatches(ctx, "task_results", "timestamp < ?", cutoff)
+}
+
+func (r *TaskRepository) IterateResults(ctx context.Context, filter *models.ExportFilter, fn func(*models.TaskResult) error) error {
+	var lastTS time.Time
+	var lastID string
+
+	for {
+		query, args := pageClause(`
+			SELECT `+taskResultColumns+`
+			FROM task_results WHERE 1=1`, filter, lastTS, lastID)
+
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		page := []*models.TaskResult{}
+		for rows.Next() {
+			result, err := scanTaskResult(rows.Scan)
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			page = append(page, result)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, result := range page {
+			if err := fn(result); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < exportPageSize {
+			return nil
+		}
+		lastTS, lastID = page[len(page)-1].Timestamp, page[len(page)-1].ID
+	}
+}
+
+func (r *TaskRepository) ImportResults(ctx context.Context, batch []*models.TaskResult) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO task_results (`+taskResultColumns+`)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var inserted int64
+	for _, result := range batch {
+		res, err := stmt.ExecContext(ctx, taskResultArgs(result)...)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := res.RowsAffected()
+		if n > 0 {
+			if err := insertSeries(ctx, tx, result); err != nil {
+				return 0, err
+			}
+		}
+		inserted += n
+	}
+
+	return inserted, tx.Commit()
+}
+
 // Script Repository
 type ScriptRepository struct {
 	db *DB
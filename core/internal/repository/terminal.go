@@ -0,0 +1,146 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"io"
+	"time"
+
+	"github.com/probe-system/core/internal/models"
+)
+
+type TerminalRepository struct {
+	db *DB
+}
+
+func NewTerminalRepository(db *DB) *TerminalRepository {
+	return &TerminalRepository{db: db}
+}
+
+func (r *TerminalRepository) Create(ctx context.Context, s *models.TerminalSession) error {
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO terminal_sessions (id, agent_id, username, remote_addr, width, height, status, started_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+	`, s.ID, s.AgentID, s.Username, s.RemoteAddr, s.Width, s.Height, s.Status, s.StartedAt)
+	return err
+}
+
+func (r *TerminalRepository) Update(ctx context.Context, s *models.TerminalSession) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE terminal_sessions SET status = ?, exit_code = ?, reason = ?, ended_at = ? WHERE id = ?
+	`, s.Status, s.ExitCode, s.Reason, s.EndedAt, s.ID)
+	return err
+}
+
+// AppendRecording stores the next chunk of the session's recording.
+func (r *TerminalRepository) AppendRecording(ctx context.Context, sessionID string, seq int, data []byte) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT INTO terminal_recordings (session_id, seq, data) VALUES (?, ?, ?)
+	`, sessionID, seq, string(data)); err != nil {
+		return err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE terminal_sessions SET recording_bytes = recording_bytes + ? WHERE id = ?
+	`, len(data), sessionID); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// WriteRecording writes the session's recorded event lines to w in order.
+func (r *TerminalRepository) WriteRecording(ctx context.Context, sessionID string, w io.Writer) error {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT data FROM terminal_recordings WHERE session_id = ? ORDER BY seq
+	`, sessionID)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var data string
+		if err := rows.Scan(&data); err != nil {
+			return err
+		}
+		if _, err := io.WriteString(w, data); err != nil {
+			return err
+		}
+	}
+	return rows.Err()
+}
+
+func (r *TerminalRepository) GetByID(ctx context.Context, id string) (*models.TerminalSession, error) {
+	sessions, err := r.query(ctx, `WHERE id = ?`, id)
+	if err != nil || len(sessions) == 0 {
+		return nil, err
+	}
+	return sessions[0], nil
+}
+
+// List returns the latest sessions, newest first, of one agent or of all
+// if agentID is empty.
+func (r *TerminalRepository) List(ctx context.Context, agentID string, limit int) ([]*models.TerminalSession, error) {
+	if agentID != "" {
+		return r.query(ctx, `WHERE agent_id = ? ORDER BY started_at DESC LIMIT ?`, agentID, limit)
+	}
+	return r.query(ctx, `ORDER BY started_at DESC LIMIT ?`, limit)
+}
+
+func (r *TerminalRepository) query(ctx context.Context, clause string, args ...interface{}) ([]*models.TerminalSession, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, agent_id, username, remote_addr, width, height, status, exit_code, reason,
+			recording_bytes, started_at, ended_at
+		FROM terminal_sessions `+clause, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sessions := []*models.TerminalSession{}
+	for rows.Next() {
+		s := &models.TerminalSession{}
+		var exitCode sql.NullInt64
+		var endedAt sql.NullTime
+		if err := rows.Scan(&s.ID, &s.AgentID, &s.Username, &s.RemoteAddr, &s.Width, &s.Height, &s.Status,
+			&exitCode, &s.Reason, &s.RecordingBytes, &s.StartedAt, &endedAt); err != nil {
+			return nil, err
+		}
+		if exitCode.Valid {
+			code := int(exitCode.Int64)
+			s.ExitCode = &code
+		}
+		if endedAt.Valid {
+			s.EndedAt = &endedAt.Time
+		}
+		sessions = append(sessions, s)
+	}
+	return sessions, rows.Err()
+}
+
+// AbortUnfinished closes the sessions left open when core stopped.
+func (r *TerminalRepository) AbortUnfinished(ctx context.Context, reason string) error {
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE terminal_sessions SET status = ?, reason = ?, ended_at = ? WHERE status != ?
+	`, models.TerminalClosed, reason, time.Now(), models.TerminalClosed)
+	return err
+}
+
+// Cleanup deletes the closed sessions older than the retention period with
+// their recordings and returns how many.
+func (r *TerminalRepository) Cleanup(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	if _, err := r.db.deleteInBatches(ctx, "terminal_recordings",
+		"session_id IN (SELECT id FROM terminal_sessions WHERE started_at < ? AND status = ?)", cutoff, models.TerminalClosed); err != nil {
+		return 0, err
+	}
+	return r.db.deleteInBatches(ctx, "terminal_sessions", "started_at < ? AND status = ?", cutoff, models.TerminalClosed)
+}
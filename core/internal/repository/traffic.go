@@ -127,7 +127,213 @@ func (r *TrafficRepository) GetExpiredCycles(ctx context.Context) ([]*models.Bil
 	return cycles, nil
 }
 
-func (r *TrafficRepository) ArchiveCycle(ctx context.Context, cycleID string, bytesSent, bytesRecv uint64) error {
-	// For now, just delete old records - could store in archive table if needed
-	return r.DeleteCycleRecords(ctx, cycleID)
+// ArchiveCycle stores the totals of a finished cycle and drops its records.
+func (r *TrafficRepository) ArchiveCycle(ctx context.Context, archive *models.TrafficArchive) error {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.ExecContext(ctx, `
+		INSERT INTO traffic_archives (id, cycle_id, agent_id, cycle_start, cycle_end,
+			bytes_sent, bytes_recv, limit_bytes, created_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+	`, archive.ID, archive.CycleID, archive.AgentID, archive.CycleStart, archive.CycleEnd,
+		archive.BytesSent, archive.BytesRecv, archive.Limit, archive.CreatedAt)
+	if err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM traffic_records WHERE cycle_id = ?`, archive.CycleID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (r *TrafficRepository) ListArchives(ctx context.Context, agentID string) ([]*models.TrafficArchive, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, cycle_id, agent_id, cycle_start, cycle_end, bytes_sent, bytes_recv, limit_bytes, created_at
+		FROM traffic_archives WHERE agent_id = ?
+		ORDER BY cycle_end DESC
+	`, agentID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	archives := []*models.TrafficArchive{}
+	for rows.Next() {
+		archive := &models.TrafficArchive{}
+		if err := rows.Scan(&archive.ID, &archive.CycleID, &archive.AgentID, &archive.CycleStart,
+			&archive.CycleEnd, &archive.BytesSent, &archive.BytesRecv, &archive.Limit,
+			&archive.CreatedAt); err != nil {
+			return nil, err
+		}
+		archives = append(archives, archive)
+	}
+	return archives, nil
+}
+
+// CleanupRecords removes records older than the retention period whose cycle
+// no longer exists, e.g. those left behind when a cycle is reconfigured.
+// Records of a live cycle are never touched since they make up its usage.
+func (r *TrafficRepository) CleanupRecords(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	return r.db.deleteInBatches(ctx, "traffic_records",
+		"timestamp < ? AND cycle_id NOT IN (SELECT id FROM billing_cycles)", cutoff)
+}
+
+// CleanupArchives removes archives older than the retention period, along
+// with the imports remembered for that time.
+func (r *TrafficRepository) CleanupArchives(ctx context.Context, retentionDays int) (int64, error) {
+	if retentionDays <= 0 {
+		return 0, nil
+	}
+	cutoff := time.Now().AddDate(0, 0, -retentionDays)
+	if _, err := r.db.deleteInBatches(ctx, "traffic_imports", "timestamp < ?", cutoff); err != nil {
+		return 0, err
+	}
+	return r.db.deleteInBatches(ctx, "traffic_archives", "cycle_end < ?", cutoff)
+}
+
+func (r *TrafficRepository) IterateRecords(ctx context.Context, filter *models.ExportFilter, fn func(*models.TrafficRecord) error) error {
+	var lastTS time.Time
+	var lastID string
+
+	for {
+		query, args := pageClause(`
+			SELECT id, cycle_id, agent_id, bytes_sent, bytes_recv, timestamp
+			FROM traffic_records WHERE 1=1`, filter, lastTS, lastID)
+
+		rows, err := r.db.QueryContext(ctx, query, args...)
+		if err != nil {
+			return err
+		}
+
+		page := []*models.TrafficRecord{}
+		for rows.Next() {
+			record := &models.TrafficRecord{}
+			if err := rows.Scan(&record.ID, &record.CycleID, &record.AgentID,
+				&record.BytesSent, &record.BytesRecv, &record.Timestamp); err != nil {
+				rows.Close()
+				return err
+			}
+			page = append(page, record)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return err
+		}
+
+		for _, record := range page {
+			if err := fn(record); err != nil {
+				return err
+			}
+		}
+
+		if len(page) < exportPageSize {
+			return nil
+		}
+		lastTS, lastID = page[len(page)-1].Timestamp, page[len(page)-1].ID
+	}
+}
+
+// ImportRecords adds records to their cycle. Records that are already
+// present or were imported before are skipped.
+func (r *TrafficRepository) ImportRecords(ctx context.Context, batch []*models.TrafficRecord) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	stmt, err := tx.PrepareContext(ctx, `
+		INSERT OR IGNORE INTO traffic_records (id, cycle_id, agent_id, bytes_sent, bytes_recv, timestamp)
+		VALUES (?, ?, ?, ?, ?, ?)
+	`)
+	if err != nil {
+		return 0, err
+	}
+	defer stmt.Close()
+
+	var inserted int64
+	for _, record := range batch {
+		remembered, err := rememberImport(ctx, tx, record)
+		if err != nil {
+			return 0, err
+		}
+		if remembered == 0 {
+			continue
+		}
+		result, err := stmt.ExecContext(ctx, record.ID, record.CycleID, record.AgentID,
+			record.BytesSent, record.BytesRecv, record.Timestamp)
+		if err != nil {
+			return 0, err
+		}
+		n, _ := result.RowsAffected()
+		inserted += n
+	}
+
+	return inserted, tx.Commit()
+}
+
+// ImportArchivedRecords adds the totals of records of a finished cycle to its
+// archive, creating the archive if it does not exist yet. Records imported
+// before are skipped.
+func (r *TrafficRepository) ImportArchivedRecords(ctx context.Context, archive *models.TrafficArchive, batch []*models.TrafficRecord) (int64, error) {
+	tx, err := r.db.BeginTx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
+	var inserted int64
+	var bytesSent, bytesRecv uint64
+	for _, record := range batch {
+		n, err := rememberImport(ctx, tx, record)
+		if err != nil {
+			return 0, err
+		}
+		if n > 0 {
+			inserted++
+			bytesSent += record.BytesSent
+			bytesRecv += record.BytesRecv
+		}
+	}
+	if inserted == 0 {
+		return 0, nil
+	}
+
+	if _, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO traffic_archives (id, cycle_id, agent_id, cycle_start, cycle_end,
+			bytes_sent, bytes_recv, limit_bytes, created_at)
+		VALUES (?, ?, ?, ?, ?, 0, 0, ?, ?)
+	`, archive.ID, archive.CycleID, archive.AgentID, archive.CycleStart, archive.CycleEnd,
+		archive.Limit, archive.CreatedAt); err != nil {
+		return 0, err
+	}
+	if _, err := tx.ExecContext(ctx, `
+		UPDATE traffic_archives SET bytes_sent = bytes_sent + ?, bytes_recv = bytes_recv + ? WHERE id = ?
+	`, bytesSent, bytesRecv, archive.ID); err != nil {
+		return 0, err
+	}
+
+	return inserted, tx.Commit()
+}
+
+// rememberImport records that the record was imported and returns 0 if it
+// was imported before.
+func rememberImport(ctx context.Context, tx *sql.Tx, record *models.TrafficRecord) (int64, error) {
+	result, err := tx.ExecContext(ctx, `
+		INSERT OR IGNORE INTO traffic_imports (record_id, agent_id, timestamp) VALUES (?, ?, ?)
+	`, record.ID, record.AgentID, record.Timestamp)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
@@ -0,0 +1,93 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+
+	"github.com/probe-system/core/internal/models"
+)
+
+type WatchRepository struct {
+	db *DB
+}
+
+func NewWatchRepository(db *DB) *WatchRepository {
+	return &WatchRepository{db: db}
+}
+
+func (r *WatchRepository) Create(ctx context.Context, watch *models.Watch) error {
+	agentIDsJSON, _ := json.Marshal(watch.AgentIDs)
+
+	_, err := r.db.ExecContext(ctx, `
+		INSERT INTO watches (id, name, type, target, agent_ids, created_at, updated_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?)
+	`, watch.ID, watch.Name, watch.Type, watch.Target, string(agentIDsJSON),
+		watch.CreatedAt, watch.UpdatedAt)
+
+	return err
+}
+
+func (r *WatchRepository) Update(ctx context.Context, watch *models.Watch) error {
+	agentIDsJSON, _ := json.Marshal(watch.AgentIDs)
+
+	_, err := r.db.ExecContext(ctx, `
+		UPDATE watches SET name=?, type=?, target=?, agent_ids=?, updated_at=?
+		WHERE id=?
+	`, watch.Name, watch.Type, watch.Target, string(agentIDsJSON), watch.UpdatedAt, watch.ID)
+
+	return err
+}
+
+func (r *WatchRepository) Delete(ctx context.Context, id string) error {
+	_, err := r.db.ExecContext(ctx, `DELETE FROM watches WHERE id = ?`, id)
+	return err
+}
+
+func (r *WatchRepository) Get(ctx context.Context, id string) (*models.Watch, error) {
+	watch := &models.Watch{}
+	var agentIDsJSON string
+
+	err := r.db.QueryRowContext(ctx, `
+		SELECT id, name, type, target, agent_ids, created_at, updated_at
+		FROM watches WHERE id = ?
+	`, id).Scan(&watch.ID, &watch.Name, &watch.Type, &watch.Target, &agentIDsJSON,
+		&watch.CreatedAt, &watch.UpdatedAt)
+
+	if err == sql.ErrNoRows {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	json.Unmarshal([]byte(agentIDsJSON), &watch.AgentIDs)
+	return watch, nil
+}
+
+func (r *WatchRepository) List(ctx context.Context) ([]*models.Watch, error) {
+	rows, err := r.db.QueryContext(ctx, `
+		SELECT id, name, type, target, agent_ids, created_at, updated_at
+		FROM watches ORDER BY name
+	`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	watches := []*models.Watch{}
+	for rows.Next() {
+		watch := &models.Watch{}
+		var agentIDsJSON string
+
+		if err := rows.Scan(&watch.ID, &watch.Name, &watch.Type, &watch.Target,
+			&agentIDsJSON, &watch.CreatedAt, &watch.UpdatedAt); err != nil {
+			return nil, err
+		}
+
+		json.Unmarshal([]byte(agentIDsJSON), &watch.AgentIDs)
+		watches = append(watches, watch)
+	}
+
+	return watches, nil
+}
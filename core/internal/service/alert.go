@@ -2,7 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"math"
+	"path"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -10,15 +14,23 @@ import (
 	"github.com/probe-system/core/internal/repository"
 )
 
+var ErrInvalidAlertRule = errors.New("invalid alert rule")
+
+// processSnapshotMaxAge is how old a top-processes snapshot may be and still
+// be attached to a new alert.
+const processSnapshotMaxAge = 5 * time.Minute
+
 type AlertServiceImpl struct {
-	repo      *repository.AlertRepository
-	notifiers []Notifier
+	repo       *repository.AlertRepository
+	processSvc ProcessService
+	notifiers  []Notifier
 }
 
-func NewAlertService(repo *repository.AlertRepository) *AlertServiceImpl {
+func NewAlertService(repo *repository.AlertRepository, processSvc ProcessService) *AlertServiceImpl {
 	return &AlertServiceImpl{
-		repo:      repo,
-		notifiers: []Notifier{},
+		repo:       repo,
+		processSvc: processSvc,
+		notifiers:  []Notifier{},
 	}
 }
 
@@ -27,6 +39,9 @@ func (s *AlertServiceImpl) AddNotifier(n Notifier) {
 }
 
 func (s *AlertServiceImpl) CreateRule(ctx context.Context, rule *models.AlertRule) error {
+	if err := validateRule(rule); err != nil {
+		return err
+	}
 	rule.ID = uuid.New().String()
 	rule.CreatedAt = time.Now()
 	rule.UpdatedAt = time.Now()
@@ -34,6 +49,9 @@ func (s *AlertServiceImpl) CreateRule(ctx context.Context, rule *models.AlertRul
 }
 
 func (s *AlertServiceImpl) UpdateRule(ctx context.Context, rule *models.AlertRule) error {
+	if err := validateRule(rule); err != nil {
+		return err
+	}
 	rule.UpdatedAt = time.Now()
 	return s.repo.UpdateRule(ctx, rule)
 }
@@ -63,7 +81,42 @@ func (s *AlertServiceImpl) CheckAndTrigger(ctx context.Context, agentID string,
 		}
 
 		// Get metric value based on type
-		value := s.getMetricValue(rule.MetricType, metrics)
+		var value float64
+		var subjects string
+		switch {
+		case rule.MetricType.IsContainer():
+			// Agents with container collection disabled report none at all.
+			if metrics.Containers == nil {
+				continue
+			}
+			value, subjects = containerValue(rule, metrics.Containers)
+		case rule.MetricType == models.MetricTypeWatchDown:
+			// Likewise until the agent has received its watch list.
+			if metrics.Watches == nil {
+				continue
+			}
+			value, subjects = watchDownValue(rule, metrics.Watches)
+		case rule.MetricType == models.MetricTypeCustom:
+			// Rules on series the agent is not reporting are not evaluated.
+			var ok bool
+			if value, subjects, ok = customValue(rule, metrics.Custom); !ok {
+				continue
+			}
+		case rule.MetricType == models.MetricTypeCertExpiry:
+			// Agents that run no matching tls task are not evaluated.
+			var ok bool
+			if value, subjects, ok = certExpiryValue(rule, metrics.Certificates); !ok {
+				continue
+			}
+		case rule.MetricType == models.MetricTypeDNSFailed || rule.MetricType == models.MetricTypeDNSTime:
+			// Agents that run no matching dns task are not evaluated.
+			var ok bool
+			if value, subjects, ok = dnsValue(rule, metrics.DNSResults); !ok {
+				continue
+			}
+		default:
+			value = s.getMetricValue(rule.MetricType, metrics)
+		}
 
 		// Check threshold
 		exceeded := rule.CheckThreshold(value)
@@ -90,7 +143,8 @@ func (s *AlertServiceImpl) CheckAndTrigger(ctx context.Context, agentID string,
 					MetricType:  rule.MetricType,
 					Value:       value,
 					Threshold:   rule.Threshold,
-					Message:     s.formatAlertMessage(rule, value),
+					Message:     s.formatAlertMessage(rule, value, subjects),
+					Processes:   s.recentProcesses(agentID),
 					TriggeredAt: time.Now(),
 				}
 
@@ -137,11 +191,236 @@ func (s *AlertServiceImpl) getMetricValue(metricType models.MetricType, metrics
 			return max
 		}
 		return 0
+	case models.MetricTypeNetIn:
+		return float64(metrics.Network.BytesRecvRate)
+	case models.MetricTypeNetOut:
+		return float64(metrics.Network.BytesSentRate)
+	case models.MetricTypeCPUCoreMax:
+		var max float64
+		for _, c := range metrics.CPUCores {
+			if c > max {
+				max = c
+			}
+		}
+		return max
+	case models.MetricTypeSwap:
+		return metrics.Swap.Percent
+	case models.MetricTypeLoad1:
+		return metrics.Load.Load1
+	case models.MetricTypeLoad5:
+		return metrics.Load.Load5
+	case models.MetricTypeLoad15:
+		return metrics.Load.Load15
+	case models.MetricTypeUptime:
+		return float64(metrics.Host.Uptime)
+	case models.MetricTypeProcesses:
+		return float64(metrics.Host.Processes)
+	case models.MetricTypeThreads:
+		return float64(metrics.Host.Threads)
+	case models.MetricTypeTCPEstablished:
+		return float64(metrics.Sockets.TCP["ESTABLISHED"])
+	case models.MetricTypeTCPTimeWait:
+		return float64(metrics.Sockets.TCP["TIME_WAIT"])
+	case models.MetricTypeTCPSynRecv:
+		return float64(metrics.Sockets.TCP["SYN_RECV"])
+	case models.MetricTypeTCPCloseWait:
+		return float64(metrics.Sockets.TCP["CLOSE_WAIT"])
+	case models.MetricTypeTCPTotal:
+		return float64(metrics.Sockets.TCPTotal)
+	case models.MetricTypeUDPSockets:
+		return float64(metrics.Sockets.UDP)
+	case models.MetricTypeListenSockets:
+		return float64(metrics.Sockets.Listen)
+	case models.MetricTypeDiskRead, models.MetricTypeDiskWrite, models.MetricTypeDiskIOPS,
+		models.MetricTypeDiskUtil, models.MetricTypeDiskAwait:
+		return diskIOValue(metricType, metrics.DiskIO)
 	default:
 		return 0
 	}
 }
 
+func diskIOValue(metricType models.MetricType, stats []models.DiskIOStats) float64 {
+	var value float64
+	for _, d := range stats {
+		switch metricType {
+		case models.MetricTypeDiskRead:
+			value += float64(d.ReadBytesRate)
+		case models.MetricTypeDiskWrite:
+			value += float64(d.WriteBytesRate)
+		case models.MetricTypeDiskIOPS:
+			value += d.ReadIOPS + d.WriteIOPS
+		case models.MetricTypeDiskUtil:
+			value = math.Max(value, d.Util)
+		case models.MetricTypeDiskAwait:
+			value = math.Max(value, d.AwaitMs)
+		}
+	}
+	return value
+}
+
+// containerValue evaluates a container metric over the containers matching
+// the rule. It also returns the names of the containers behind the value.
+func containerValue(rule *models.AlertRule, containers []*models.Container) (float64, string) {
+	var value float64
+	var names []string
+	for _, c := range containers {
+		if rule.Container != "" {
+			if ok, _ := path.Match(rule.Container, c.Name); !ok {
+				continue
+			}
+		}
+
+		switch rule.MetricType {
+		case models.MetricTypeContainerCPU:
+			if len(names) == 0 || c.CPU > value {
+				value, names = c.CPU, []string{c.Name}
+			}
+		case models.MetricTypeContainerMemory:
+			if len(names) == 0 || c.MemoryPercent > value {
+				value, names = c.MemoryPercent, []string{c.Name}
+			}
+		case models.MetricTypeContainerRestarts:
+			if c.Restarts > 0 {
+				value += float64(c.Restarts)
+				names = append(names, c.Name)
+			}
+		case models.MetricTypeContainerDown:
+			if c.State != "running" {
+				value++
+				names = append(names, c.Name)
+			}
+		}
+	}
+	return value, strings.Join(names, ", ")
+}
+
+// customValue returns the highest value among the series matching the
+// rule's metric name pattern and names that series.
+func customValue(rule *models.AlertRule, metrics []*models.CustomMetric) (float64, string, bool) {
+	var max *models.CustomMetric
+	for _, m := range metrics {
+		if ok, _ := path.Match(rule.CustomMetric, m.Name); !ok {
+			continue
+		}
+		if max == nil || m.Value > max.Value {
+			max = m
+		}
+	}
+	if max == nil {
+		return 0, "", false
+	}
+	return max.Value, max.Series(), true
+}
+
+// certExpiryValue returns the fewest days until expiry among the
+// certificates whose target matches the rule's Certificate pattern, and the
+// target and expiry date of that certificate.
+func certExpiryValue(rule *models.AlertRule, certs []*models.Certificate) (float64, string, bool) {
+	var soonest *models.Certificate
+	for _, c := range certs {
+		if rule.Certificate != "" {
+			if ok, _ := path.Match(rule.Certificate, c.Target); !ok {
+				continue
+			}
+		}
+		if soonest == nil || c.TLS.NotAfter.Before(soonest.TLS.NotAfter) {
+			soonest = c
+		}
+	}
+	if soonest == nil {
+		return 0, "", false
+	}
+	return soonest.DaysRemaining, fmt.Sprintf("%s expires %s", soonest.Target,
+		soonest.TLS.NotAfter.UTC().Format("2006-01-02")), true
+}
+
+// dnsValue evaluates the latest results of the dns tasks whose name matches
+// the rule's DNS pattern. dns_failed counts the failed checks and names them;
+// dns_time is the slowest resolution and names its query.
+func dnsValue(rule *models.AlertRule, results []*models.TaskResult) (float64, string, bool) {
+	var matched int
+	var failed []string
+	var slowest *models.DNSResult
+	for _, r := range results {
+		if rule.DNS != "" {
+			if ok, _ := path.Match(rule.DNS, r.DNS.Name); !ok {
+				continue
+			}
+		}
+		matched++
+		if !r.Success {
+			failed = append(failed, r.DNS.Name+" "+r.DNS.RecordType)
+		}
+		if slowest == nil || r.DNS.ResolveMs > slowest.ResolveMs {
+			slowest = r.DNS
+		}
+	}
+	if matched == 0 {
+		return 0, "", false
+	}
+	if rule.MetricType == models.MetricTypeDNSTime {
+		return slowest.ResolveMs, slowest.Name + " " + slowest.RecordType, true
+	}
+	return float64(len(failed)), strings.Join(failed, ", "), true
+}
+
+// watchDownValue counts the watches matching the rule that are down and
+// returns their names.
+func watchDownValue(rule *models.AlertRule, statuses []*models.WatchStatus) (float64, string) {
+	var names []string
+	for _, w := range statuses {
+		if rule.Watch != "" {
+			if ok, _ := path.Match(rule.Watch, w.Name); !ok {
+				continue
+			}
+		}
+		if !w.Up {
+			names = append(names, w.Name)
+		}
+	}
+	return float64(len(names)), strings.Join(names, ", ")
+}
+
+func validateRule(rule *models.AlertRule) error {
+	if rule.Container != "" {
+		if _, err := path.Match(rule.Container, ""); err != nil {
+			return fmt.Errorf("%w: container pattern %q: %v", ErrInvalidAlertRule, rule.Container, err)
+		}
+	}
+	if rule.MetricType == models.MetricTypeCustom {
+		if rule.CustomMetric == "" {
+			return fmt.Errorf("%w: custom_metric is required", ErrInvalidAlertRule)
+		}
+		if _, err := path.Match(rule.CustomMetric, ""); err != nil {
+			return fmt.Errorf("%w: custom metric pattern %q: %v", ErrInvalidAlertRule, rule.CustomMetric, err)
+		}
+	}
+	if rule.Certificate != "" {
+		if _, err := path.Match(rule.Certificate, ""); err != nil {
+			return fmt.Errorf("%w: certificate pattern %q: %v", ErrInvalidAlertRule, rule.Certificate, err)
+		}
+	}
+	if rule.DNS != "" {
+		if _, err := path.Match(rule.DNS, ""); err != nil {
+			return fmt.Errorf("%w: dns pattern %q: %v", ErrInvalidAlertRule, rule.DNS, err)
+		}
+	}
+	if rule.Watch != "" {
+		if _, err := path.Match(rule.Watch, ""); err != nil {
+			return fmt.Errorf("%w: watch pattern %q: %v", ErrInvalidAlertRule, rule.Watch, err)
+		}
+	}
+	return nil
+}
+
+func (s *AlertServiceImpl) recentProcesses(agentID string) *models.ProcessSnapshot {
+	snapshot := s.processSvc.Latest(agentID)
+	if snapshot == nil || time.Since(snapshot.CollectedAt) > processSnapshotMaxAge {
+		return nil
+	}
+	return snapshot
+}
+
 func (s *AlertServiceImpl) isInCooldown(ctx context.Context, rule *models.AlertRule, agentID string) bool {
 	lastTime, err := s.repo.GetLastAlertTime(ctx, rule.ID, agentID)
 	if err != nil || lastTime == nil {
@@ -152,9 +431,15 @@ func (s *AlertServiceImpl) isInCooldown(ctx context.Context, rule *models.AlertR
 	return time.Now().Before(cooldownEnd)
 }
 
-func (s *AlertServiceImpl) formatAlertMessage(rule *models.AlertRule, value float64) string {
-	return fmt.Sprintf("[%s] %s: %.2f (threshold: %.2f)",
+// formatAlertMessage names the containers or watches behind the value, if
+// any, after the threshold.
+func (s *AlertServiceImpl) formatAlertMessage(rule *models.AlertRule, value float64, subjects string) string {
+	message := fmt.Sprintf("[%s] %s: %.2f (threshold: %.2f)",
 		rule.MetricType, rule.Name, value, rule.Threshold)
+	if subjects != "" {
+		message += " - " + subjects
+	}
+	return message
 }
 
 func (s *AlertServiceImpl) notify(ctx context.Context, alert *models.Alert) {
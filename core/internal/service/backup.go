@@ -0,0 +1,154 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/repository"
+)
+
+var ErrBackupNotFound = errors.New("backup not found")
+
+const (
+	backupPrefix     = "probe-"
+	backupSuffix     = ".db"
+	backupTimeFormat = "20060102-150405"
+)
+
+type BackupServiceImpl struct {
+	db   *repository.DB
+	dir  string
+	keep int
+	mu   sync.Mutex
+}
+
+// NewBackupService stores snapshots in dir and keeps the newest keep of them;
+// keep <= 0 disables rotation.
+func NewBackupService(db *repository.DB, dir string, keep int) *BackupServiceImpl {
+	return &BackupServiceImpl{
+		db:   db,
+		dir:  dir,
+		keep: keep,
+	}
+}
+
+// Create takes a snapshot of the running database and rotates old ones.
+func (s *BackupServiceImpl) Create(ctx context.Context) (*models.Backup, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.dir, 0700); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	name := backupPrefix + now.Format(backupTimeFormat) + backupSuffix
+	path := filepath.Join(s.dir, name)
+	if _, err := os.Stat(path); err == nil {
+		return nil, fmt.Errorf("backup %s already exists", name)
+	}
+
+	if err := s.db.Backup(ctx, path); err != nil {
+		return nil, err
+	}
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.rotate(); err != nil {
+		return nil, err
+	}
+
+	return &models.Backup{Name: name, Size: info.Size(), CreatedAt: now}, nil
+}
+
+// List returns the snapshots in the backup directory, newest first.
+func (s *BackupServiceImpl) List() ([]*models.Backup, error) {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return []*models.Backup{}, nil
+		}
+		return nil, err
+	}
+
+	backups := []*models.Backup{}
+	for _, e := range entries {
+		createdAt, ok := parseBackupName(e.Name())
+		if !ok || e.IsDir() {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		backups = append(backups, &models.Backup{
+			Name:      e.Name(),
+			Size:      info.Size(),
+			CreatedAt: createdAt,
+		})
+	}
+
+	sort.Slice(backups, func(i, j int) bool {
+		return backups[i].CreatedAt.After(backups[j].CreatedAt)
+	})
+
+	return backups, nil
+}
+
+// Path resolves a backup name from List to its file path.
+func (s *BackupServiceImpl) Path(name string) (string, error) {
+	if _, ok := parseBackupName(name); !ok || filepath.Base(name) != name {
+		return "", ErrBackupNotFound
+	}
+
+	path := filepath.Join(s.dir, name)
+	if _, err := os.Stat(path); err != nil {
+		return "", ErrBackupNotFound
+	}
+
+	return path, nil
+}
+
+func (s *BackupServiceImpl) rotate() error {
+	if s.keep <= 0 {
+		return nil
+	}
+
+	backups, err := s.List()
+	if err != nil {
+		return err
+	}
+
+	for i := s.keep; i < len(backups); i++ {
+		if err := os.Remove(filepath.Join(s.dir, backups[i].Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func parseBackupName(name string) (time.Time, bool) {
+	if !strings.HasPrefix(name, backupPrefix) || !strings.HasSuffix(name, backupSuffix) {
+		return time.Time{}, false
+	}
+
+	ts := strings.TrimSuffix(strings.TrimPrefix(name, backupPrefix), backupSuffix)
+	t, err := time.Parse(backupTimeFormat, ts)
+	if err != nil {
+		return time.Time{}, false
+	}
+
+	return t, true
+}
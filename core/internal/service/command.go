@@ -0,0 +1,336 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/repository"
+)
+
+var ErrInvalidCommand = errors.New("invalid command")
+
+const (
+	defaultCommandTimeout = 300
+	maxCommandTimeout     = 3600
+
+	// commandWatchBuffer is how many events a watcher may fall behind
+	// before it is dropped.
+	commandWatchBuffer = 256
+)
+
+// CommandServiceImpl stores command runs. Runs still going are also kept in
+// memory with their output, which is written when each agent finishes, and
+// their events are passed to the admins watching them.
+type CommandServiceImpl struct {
+	repo       *repository.CommandRepository
+	scriptRepo *repository.ScriptRepository
+
+	mu   sync.Mutex
+	live map[string]*liveCommand
+}
+
+type liveCommand struct {
+	run      *models.CommandRun
+	targets  map[string]*models.CommandTarget
+	canceled bool
+	watchers map[chan *models.CommandEvent]struct{}
+}
+
+func NewCommandService(repo *repository.CommandRepository, scriptRepo *repository.ScriptRepository) *CommandServiceImpl {
+	return &CommandServiceImpl{
+		repo:       repo,
+		scriptRepo: scriptRepo,
+		live:       make(map[string]*liveCommand),
+	}
+}
+
+// Create stores a new run with every agent pending. It returns the script's
+// checksum for the agents to check, if the run is a script.
+func (s *CommandServiceImpl) Create(ctx context.Context, run *models.CommandRun) (string, error) {
+	run.Command = strings.TrimSpace(run.Command)
+	if (run.Command == "") == (run.ScriptID == "") {
+		return "", fmt.Errorf("%w: give either a command or a script", ErrInvalidCommand)
+	}
+	var checksum string
+	if run.ScriptID != "" {
+		script, err := s.scriptRepo.GetByID(ctx, run.ScriptID)
+		if err != nil {
+			return "", err
+		}
+		if script == nil {
+			return "", fmt.Errorf("%w: script %s not found", ErrInvalidCommand, run.ScriptID)
+		}
+		checksum = script.Checksum
+	}
+
+	agentIDs := []string{}
+	for _, id := range compactList(run.AgentIDs) {
+		if !contains(agentIDs, id) {
+			agentIDs = append(agentIDs, id)
+		}
+	}
+	if len(agentIDs) == 0 {
+		return "", fmt.Errorf("%w: select at least one agent", ErrInvalidCommand)
+	}
+	run.AgentIDs = agentIDs
+
+	if run.Timeout == 0 {
+		run.Timeout = defaultCommandTimeout
+	}
+	if run.Timeout < 0 || run.Timeout > maxCommandTimeout {
+		return "", fmt.Errorf("%w: timeout must be between 1 and %d seconds", ErrInvalidCommand, maxCommandTimeout)
+	}
+
+	run.ID = uuid.New().String()
+	run.Status = models.CommandRunning
+	run.CreatedAt = time.Now()
+	run.FinishedAt = nil
+	run.Targets = make([]*models.CommandTarget, 0, len(agentIDs))
+	lc := &liveCommand{
+		run:      run,
+		targets:  make(map[string]*models.CommandTarget, len(agentIDs)),
+		watchers: make(map[chan *models.CommandEvent]struct{}),
+	}
+	for _, id := range agentIDs {
+		t := &models.CommandTarget{RunID: run.ID, AgentID: id, Status: models.CommandPending}
+		run.Targets = append(run.Targets, t)
+		lc.targets[id] = t
+	}
+	if err := s.repo.Create(ctx, run); err != nil {
+		return "", err
+	}
+
+	s.mu.Lock()
+	s.live[run.ID] = lc
+	s.mu.Unlock()
+	return checksum, nil
+}
+
+// Output adds lines an agent's command wrote, marking it running. Lines
+// past models.MaxCommandOutput are dropped.
+func (s *CommandServiceImpl) Output(ctx context.Context, runID, agentID string, lines []models.CommandLine) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lc := s.live[runID]
+	if lc == nil || lc.targets[agentID] == nil || lc.targets[agentID].Done() {
+		return nil
+	}
+	t := lc.targets[agentID]
+
+	if t.Status == models.CommandPending {
+		now := time.Now()
+		t.Status = models.CommandRunning
+		t.StartedAt = &now
+		lc.publish(&models.CommandEvent{Type: "target", AgentID: agentID, Target: t.Summary()})
+		if err := s.repo.UpdateTarget(ctx, t); err != nil {
+			return err
+		}
+	}
+
+	kept := lines[:0:0]
+	for _, line := range lines {
+		if t.OutputBytes+len(line.Text) > models.MaxCommandOutput {
+			t.Truncated = true
+			break
+		}
+		t.OutputBytes += len(line.Text)
+		kept = append(kept, line)
+	}
+	if len(kept) > 0 {
+		t.Output = append(t.Output, kept...)
+		lc.publish(&models.CommandEvent{Type: "output", AgentID: agentID, Lines: kept})
+	}
+	return nil
+}
+
+// Exit ends the run on an agent. An exit without an error is the command's
+// own exit code; with one, the command failed or was canceled.
+func (s *CommandServiceImpl) Exit(ctx context.Context, runID, agentID string, exitCode int, errMsg string, duration int64) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lc := s.live[runID]
+	if lc == nil || lc.targets[agentID] == nil || lc.targets[agentID].Done() {
+		return nil
+	}
+	t := lc.targets[agentID]
+
+	now := time.Now()
+	t.FinishedAt = &now
+	t.Duration = duration
+	t.Error = errMsg
+	switch {
+	case errMsg == "":
+		t.Status = models.CommandExited
+		t.ExitCode = &exitCode
+	case lc.canceled:
+		t.Status = models.CommandCanceled
+	default:
+		t.Status = models.CommandFailed
+	}
+	lc.publish(&models.CommandEvent{Type: "target", AgentID: agentID, Target: t.Summary()})
+	if err := s.repo.UpdateTarget(ctx, t); err != nil {
+		return err
+	}
+
+	for _, other := range lc.targets {
+		if !other.Done() {
+			return nil
+		}
+	}
+	return s.finish(ctx, lc)
+}
+
+// finish ends a run every agent is done with. The caller holds s.mu.
+func (s *CommandServiceImpl) finish(ctx context.Context, lc *liveCommand) error {
+	now := time.Now()
+	lc.run.FinishedAt = &now
+	lc.run.Status = models.CommandFinished
+	if lc.canceled {
+		lc.run.Status = models.CommandCanceled
+	}
+
+	run := *lc.run
+	run.Targets = nil
+	lc.publish(&models.CommandEvent{Type: "run", Run: &run})
+	for ch := range lc.watchers {
+		delete(lc.watchers, ch)
+		close(ch)
+	}
+	delete(s.live, lc.run.ID)
+	return s.repo.UpdateRun(ctx, lc.run)
+}
+
+// Cancel marks the run canceled and returns the agents that have to be told
+// to stop it. Agents that have not started it yet are done with it right
+// away.
+func (s *CommandServiceImpl) Cancel(ctx context.Context, runID string) ([]string, error) {
+	s.mu.Lock()
+	lc := s.live[runID]
+	if lc == nil {
+		s.mu.Unlock()
+		return nil, nil
+	}
+	lc.canceled = true
+	var agentIDs, pending []string
+	for id, t := range lc.targets {
+		if t.Done() {
+			continue
+		}
+		agentIDs = append(agentIDs, id)
+		if t.Status == models.CommandPending {
+			pending = append(pending, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, id := range pending {
+		if err := s.Exit(ctx, runID, id, -1, "canceled", 0); err != nil {
+			return nil, err
+		}
+	}
+	return agentIDs, nil
+}
+
+// AgentDisconnected fails the runs the agent had not finished; it will not
+// report them after reconnecting.
+func (s *CommandServiceImpl) AgentDisconnected(ctx context.Context, agentID string) {
+	s.mu.Lock()
+	var runIDs []string
+	for id, lc := range s.live {
+		if t := lc.targets[agentID]; t != nil && !t.Done() {
+			runIDs = append(runIDs, id)
+		}
+	}
+	s.mu.Unlock()
+
+	for _, id := range runIDs {
+		if err := s.Exit(ctx, id, agentID, -1, "agent disconnected", 0); err != nil {
+			log.Printf("Failed to end command run %s on %s: %v", id, agentID, err)
+		}
+	}
+}
+
+// AbortUnfinished ends the runs left over from before core restarted.
+func (s *CommandServiceImpl) AbortUnfinished(ctx context.Context) error {
+	return s.repo.AbortUnfinished(ctx, "core restarted")
+}
+
+func (s *CommandServiceImpl) GetByID(ctx context.Context, runID string) (*models.CommandRun, error) {
+	s.mu.Lock()
+	if lc := s.live[runID]; lc != nil {
+		run := lc.snapshot()
+		s.mu.Unlock()
+		return run, nil
+	}
+	s.mu.Unlock()
+	return s.repo.GetByID(ctx, runID)
+}
+
+func (s *CommandServiceImpl) List(ctx context.Context, limit int) ([]*models.CommandRun, error) {
+	return s.repo.List(ctx, limit)
+}
+
+// Watch returns the run's events, starting with a snapshot, until the run
+// ends or stop is called. The channel is also closed if the watcher falls
+// too far behind. A finished run gives its snapshot alone.
+func (s *CommandServiceImpl) Watch(ctx context.Context, runID string) (<-chan *models.CommandEvent, func(), error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ch := make(chan *models.CommandEvent, commandWatchBuffer)
+	lc := s.live[runID]
+	if lc == nil {
+		run, err := s.repo.GetByID(ctx, runID)
+		if err != nil || run == nil {
+			return nil, nil, err
+		}
+		ch <- &models.CommandEvent{Type: "snapshot", Run: run}
+		close(ch)
+		return ch, func() {}, nil
+	}
+
+	ch <- &models.CommandEvent{Type: "snapshot", Run: lc.snapshot()}
+	lc.watchers[ch] = struct{}{}
+	stop := func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if _, ok := lc.watchers[ch]; ok {
+			delete(lc.watchers, ch)
+			close(ch)
+		}
+	}
+	return ch, stop, nil
+}
+
+// publish sends the event to every watcher, dropping those that are full.
+func (lc *liveCommand) publish(event *models.CommandEvent) {
+	for ch := range lc.watchers {
+		select {
+		case ch <- event:
+		default:
+			delete(lc.watchers, ch)
+			close(ch)
+		}
+	}
+}
+
+// snapshot copies the run with its targets and their output so far.
+func (lc *liveCommand) snapshot() *models.CommandRun {
+	run := *lc.run
+	run.Targets = make([]*models.CommandTarget, 0, len(lc.run.Targets))
+	for _, t := range lc.run.Targets {
+		c := *t
+		c.Output = append([]models.CommandLine(nil), t.Output...)
+		run.Targets = append(run.Targets, &c)
+	}
+	return &run
+}
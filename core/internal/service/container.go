@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"time"
+
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/repository"
+)
+
+type ContainerServiceImpl struct {
+	repo *repository.ContainerRepository
+}
+
+func NewContainerService(repo *repository.ContainerRepository) *ContainerServiceImpl {
+	return &ContainerServiceImpl{repo: repo}
+}
+
+// Sync stores a container report and sets Restarts on each container from
+// the change in its restart count since the previous report.
+func (s *ContainerServiceImpl) Sync(ctx context.Context, agentID string, containers []*models.Container) error {
+	previous, err := s.repo.List(ctx, agentID)
+	if err != nil {
+		return err
+	}
+
+	restartCounts := make(map[string]int, len(previous))
+	for _, c := range previous {
+		restartCounts[c.ID] = c.RestartCount
+	}
+	for _, c := range containers {
+		// A lower count means the agent restarted and reset its own count.
+		if prev, ok := restartCounts[c.ID]; ok && c.RestartCount > prev {
+			c.Restarts = c.RestartCount - prev
+		}
+	}
+
+	return s.repo.Sync(ctx, agentID, containers, time.Now())
+}
+
+func (s *ContainerServiceImpl) List(ctx context.Context, agentID string) ([]*models.Container, error) {
+	return s.repo.List(ctx, agentID)
+}
+
+func (s *ContainerServiceImpl) GetHistory(ctx context.Context, agentID, containerID string, from, to time.Time) ([]*models.ContainerMetric, error) {
+	return s.repo.GetHistory(ctx, agentID, containerID, from, to)
+}
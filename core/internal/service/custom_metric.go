@@ -0,0 +1,90 @@
+package service
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/repository"
+)
+
+// customMetricMaxAge is how long a plugin value is used for alerting. A
+// series the plugin stops reporting no longer matches rules after that.
+const customMetricMaxAge = 10 * time.Minute
+
+// customMetricListWindow bounds how far back List looks for series.
+const customMetricListWindow = 24 * time.Hour
+
+// CustomMetricServiceImpl stores plugin values and keeps the latest value of
+// each series in memory for alert evaluation.
+type CustomMetricServiceImpl struct {
+	repo *repository.CustomMetricRepository
+
+	mu     sync.RWMutex
+	latest map[string]map[string]*models.CustomMetric
+}
+
+func NewCustomMetricService(repo *repository.CustomMetricRepository) *CustomMetricServiceImpl {
+	return &CustomMetricServiceImpl{
+		repo:   repo,
+		latest: make(map[string]map[string]*models.CustomMetric),
+	}
+}
+
+// Store records one plugin report. It replaces every series the plugin
+// reported before, so series missing from the report stop being evaluated.
+func (s *CustomMetricServiceImpl) Store(ctx context.Context, agentID, plugin string, metrics []*models.CustomMetric) error {
+	now := time.Now()
+	for _, m := range metrics {
+		m.AgentID = agentID
+		m.Plugin = plugin
+		m.Timestamp = now
+	}
+
+	s.mu.Lock()
+	series := s.latest[agentID]
+	if series == nil {
+		series = make(map[string]*models.CustomMetric)
+		s.latest[agentID] = series
+	}
+	for key, m := range series {
+		if m.Plugin == plugin {
+			delete(series, key)
+		}
+	}
+	for _, m := range metrics {
+		series[m.Series()] = m
+	}
+	s.mu.Unlock()
+
+	if len(metrics) == 0 {
+		return nil
+	}
+	return s.repo.Store(ctx, metrics)
+}
+
+// Latest returns the agent's recent plugin values, or nil if it has none.
+func (s *CustomMetricServiceImpl) Latest(agentID string) []*models.CustomMetric {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var result []*models.CustomMetric
+	cutoff := time.Now().Add(-customMetricMaxAge)
+	for _, m := range s.latest[agentID] {
+		if m.Timestamp.After(cutoff) {
+			result = append(result, m)
+		}
+	}
+	return result
+}
+
+// List returns the latest value of every series the agent reported in the
+// last day.
+func (s *CustomMetricServiceImpl) List(ctx context.Context, agentID string) ([]*models.CustomMetric, error) {
+	return s.repo.ListLatest(ctx, agentID, time.Now().Add(-customMetricListWindow))
+}
+
+func (s *CustomMetricServiceImpl) GetHistory(ctx context.Context, agentID, name string, from, to time.Time) ([]*models.CustomMetricSeries, error) {
+	return s.repo.GetHistory(ctx, agentID, name, from, to)
+}
@@ -2,6 +2,7 @@ package service
 
 import (
 	"context"
+	"io"
 	"time"
 
 	"github.com/probe-system/core/internal/models"
@@ -40,12 +41,17 @@ type MetricService interface {
 	Store(ctx context.Context, agentID string, metrics *models.Metrics) error
 	GetLatest(ctx context.Context, agentID string) (*models.Metrics, error)
 	GetHistory(ctx context.Context, agentID string, from, to time.Time) ([]*models.Metrics, error)
+	Query(ctx context.Context, q *models.MetricQuery) ([]*models.MetricSeries, error)
+	GetInterfaceSeries(ctx context.Context, agentID string, from, to time.Time) ([]*models.InterfaceSeries, error)
 	Cleanup(ctx context.Context, retentionDays int) (int64, error)
+	SetCollectorReport(agentID string, report *models.CollectorReport)
+	CollectorReport(agentID string) *models.CollectorReport
 }
 
 type TrafficService interface {
 	RecordTraffic(ctx context.Context, agentID string, bytesSent, bytesRecv uint64) error
 	GetStats(ctx context.Context, agentID string) (*models.TrafficStats, error)
+	ListArchives(ctx context.Context, agentID string) ([]*models.TrafficArchive, error)
 	ConfigureCycle(ctx context.Context, agentID string, startDate time.Time, durationDays int, limitBytes uint64) error
 	CheckAndResetCycles(ctx context.Context) error
 }
@@ -56,9 +62,48 @@ type TaskService interface {
 	Cancel(ctx context.Context, taskID string) error
 	GetByID(ctx context.Context, taskID string) (*models.Task, error)
 	List(ctx context.Context) ([]*models.Task, error)
-	ListByAgent(ctx context.Context, agentID string) ([]*models.Task, error)
+	ListByAgent(ctx context.Context, agent *models.Agent) ([]*models.Task, error)
+	ListActive(ctx context.Context) ([]*models.Task, error)
 	RecordResult(ctx context.Context, result *models.TaskResult) error
+	MarkAssigned(ctx context.Context, task *models.Task, agentID string) (bool, error)
+	Acknowledge(ctx context.Context, taskID, agentID string, success bool, errMsg string) error
+	CancelDelivery(ctx context.Context, taskID, agentID string) error
+	ListDeliveries(ctx context.Context, taskID string) ([]*models.TaskDelivery, error)
+	PendingDeliveries(ctx context.Context) ([]*models.TaskDelivery, error)
 	GetResults(ctx context.Context, taskID string, limit int) ([]*models.TaskResult, error)
+	ListCertificates(ctx context.Context) ([]*models.Certificate, error)
+	AgentCertificates(ctx context.Context, agentID string) []*models.Certificate
+	AgentDNSResults(ctx context.Context, agentID string) []*models.TaskResult
+	DiffPath(ctx context.Context, taskID, agentID string, before time.Duration) (*models.PathDiff, error)
+	QuerySeries(ctx context.Context, q *models.TaskSeriesQuery) ([]*models.MetricSeries, error)
+	SLA(ctx context.Context, taskID string, quorum int, from, to time.Time) (*models.SLAReport, error)
+	ListSLA(ctx context.Context) ([]*models.SLAReport, error)
+}
+
+type CommandService interface {
+	Create(ctx context.Context, run *models.CommandRun) (string, error)
+	Output(ctx context.Context, runID, agentID string, lines []models.CommandLine) error
+	Exit(ctx context.Context, runID, agentID string, exitCode int, errMsg string, duration int64) error
+	Cancel(ctx context.Context, runID string) ([]string, error)
+	AgentDisconnected(ctx context.Context, agentID string)
+	AbortUnfinished(ctx context.Context) error
+	GetByID(ctx context.Context, runID string) (*models.CommandRun, error)
+	List(ctx context.Context, limit int) ([]*models.CommandRun, error)
+	Watch(ctx context.Context, runID string) (<-chan *models.CommandEvent, func(), error)
+}
+
+type TerminalService interface {
+	Start(ctx context.Context, session *models.TerminalSession) (<-chan []byte, error)
+	Output(ctx context.Context, sessionID, agentID string, data []byte) bool
+	Input(ctx context.Context, sessionID string, data []byte) bool
+	Resize(ctx context.Context, sessionID string, width, height int)
+	Exit(ctx context.Context, sessionID, agentID string, exitCode int, errMsg string)
+	End(ctx context.Context, sessionID, reason string) bool
+	AgentDisconnected(ctx context.Context, agentID string)
+	AbortUnfinished(ctx context.Context) error
+	GetByID(ctx context.Context, sessionID string) (*models.TerminalSession, error)
+	List(ctx context.Context, agentID string, limit int) ([]*models.TerminalSession, error)
+	WriteRecording(ctx context.Context, sessionID string, w io.Writer) error
 }
 
 type ScriptService interface {
@@ -82,6 +127,52 @@ type AlertService interface {
 	AddNotifier(n Notifier)
 }
 
+type TransferService interface {
+	Export(ctx context.Context, dataset models.Dataset, format models.TransferFormat, filter *models.ExportFilter, w io.Writer) error
+	Import(ctx context.Context, dataset models.Dataset, format models.TransferFormat, r io.Reader, opts *models.ImportOptions) (*models.ImportResult, error)
+}
+
+type RetentionService interface {
+	Enforce(ctx context.Context) (*models.RetentionReport, error)
+	Reports() []*models.RetentionReport
+	EnableIncrementalVacuum(ctx context.Context) error
+	AutoVacuum(ctx context.Context) (models.VacuumMode, error)
+}
+
+type BackupService interface {
+	Create(ctx context.Context) (*models.Backup, error)
+	List() ([]*models.Backup, error)
+	Path(name string) (string, error)
+}
+
+type ProcessService interface {
+	Update(agentID string, snapshot *models.ProcessSnapshot)
+	Latest(agentID string) *models.ProcessSnapshot
+}
+
+type ContainerService interface {
+	Sync(ctx context.Context, agentID string, containers []*models.Container) error
+	List(ctx context.Context, agentID string) ([]*models.Container, error)
+	GetHistory(ctx context.Context, agentID, containerID string, from, to time.Time) ([]*models.ContainerMetric, error)
+}
+
+type WatchService interface {
+	Create(ctx context.Context, watch *models.Watch) error
+	Update(ctx context.Context, watch *models.Watch) error
+	Delete(ctx context.Context, id string) error
+	List(ctx context.Context) ([]*models.Watch, error)
+	ForAgent(ctx context.Context, agentID string) ([]*models.Watch, error)
+	Record(ctx context.Context, agentID string, results []*models.WatchStatus) ([]*models.WatchStatus, error)
+	Status(agentID string) []*models.WatchStatus
+}
+
+type CustomMetricService interface {
+	Store(ctx context.Context, agentID, plugin string, metrics []*models.CustomMetric) error
+	Latest(agentID string) []*models.CustomMetric
+	List(ctx context.Context, agentID string) ([]*models.CustomMetric, error)
+	GetHistory(ctx context.Context, agentID, name string, from, to time.Time) ([]*models.CustomMetricSeries, error)
+}
+
 type GeoService interface {
 	Lookup(ip string) (*models.GeoLocation, error)
 }
@@ -2,6 +2,11 @@ package service
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,12 +14,33 @@ import (
 	"github.com/probe-system/core/internal/repository"
 )
 
+var ErrInvalidMetricQuery = errors.New("invalid metric query")
+
 type MetricServiceImpl struct {
 	repo *repository.MetricsRepository
+
+	// Collector reports are kept in memory, latest per agent.
+	mu      sync.RWMutex
+	reports map[string]*models.CollectorReport
 }
 
 func NewMetricService(repo *repository.MetricsRepository) *MetricServiceImpl {
-	return &MetricServiceImpl{repo: repo}
+	return &MetricServiceImpl{
+		repo:    repo,
+		reports: make(map[string]*models.CollectorReport),
+	}
+}
+
+func (s *MetricServiceImpl) SetCollectorReport(agentID string, report *models.CollectorReport) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.reports[agentID] = report
+}
+
+func (s *MetricServiceImpl) CollectorReport(agentID string) *models.CollectorReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.reports[agentID]
 }
 
 func (s *MetricServiceImpl) Store(ctx context.Context, agentID string, metrics *models.Metrics) error {
@@ -32,10 +58,177 @@ func (s *MetricServiceImpl) GetHistory(ctx context.Context, agentID string, from
 	return s.repo.GetHistory(ctx, agentID, from, to)
 }
 
+// GetInterfaceSeries splits the stored network samples into one series per
+// interface, ordered by name.
+func (s *MetricServiceImpl) GetInterfaceSeries(ctx context.Context, agentID string, from, to time.Time) ([]*models.InterfaceSeries, error) {
+	history, err := s.repo.GetHistory(ctx, agentID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]*models.InterfaceSeries{}
+	for _, m := range history {
+		for _, iface := range m.Network.Interfaces {
+			series, ok := byName[iface.Name]
+			if !ok {
+				series = &models.InterfaceSeries{Name: iface.Name, Points: []models.InterfacePoint{}}
+				byName[iface.Name] = series
+			}
+			series.Counted = iface.Counted
+			series.Points = append(series.Points, models.InterfacePoint{
+				Timestamp:     m.Timestamp,
+				BytesSent:     iface.BytesSent,
+				BytesRecv:     iface.BytesRecv,
+				BytesSentRate: iface.BytesSentRate,
+				BytesRecvRate: iface.BytesRecvRate,
+			})
+		}
+	}
+
+	result := make([]*models.InterfaceSeries, 0, len(byName))
+	for _, series := range byName {
+		result = append(result, series)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Name < result[j].Name
+	})
+
+	return result, nil
+}
+
 func (s *MetricServiceImpl) Cleanup(ctx context.Context, retentionDays int) (int64, error) {
 	return s.repo.Cleanup(ctx, retentionDays)
 }
 
+// Query aggregates metric history into fixed-width buckets for every series
+// in the query. Aggregation is done here rather than in SQL so percentiles and
+// "last" can share the same code path as the simple aggregates.
+func (s *MetricServiceImpl) Query(ctx context.Context, q *models.MetricQuery) ([]*models.MetricSeries, error) {
+	if err := validateMetricQuery(q); err != nil {
+		return nil, err
+	}
+
+	seriesByAgent := make(map[string][]string)
+	for key, agentIDs := range q.Series {
+		for _, id := range agentIDs {
+			seriesByAgent[id] = append(seriesByAgent[id], key)
+		}
+	}
+
+	agentIDs := make([]string, 0, len(seriesByAgent))
+	for id := range seriesByAgent {
+		agentIDs = append(agentIDs, id)
+	}
+
+	samples, err := s.repo.GetSamples(ctx, agentIDs, q.Metric, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+
+	// series key -> bucket start (unix seconds) -> values in timestamp order.
+	// Buckets are aligned to the step so 5m buckets start at :00, :05, ...
+	buckets := make(map[string]map[int64][]float64)
+	for _, sample := range samples {
+		idx := sample.Timestamp.Truncate(q.Step).Unix()
+		for _, key := range seriesByAgent[sample.AgentID] {
+			if buckets[key] == nil {
+				buckets[key] = make(map[int64][]float64)
+			}
+			buckets[key][idx] = append(buckets[key][idx], sample.Value)
+		}
+	}
+
+	keys := make([]string, 0, len(q.Series))
+	for key := range q.Series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]*models.MetricSeries, 0, len(keys))
+	for _, key := range keys {
+		series := &models.MetricSeries{
+			Key:      key,
+			AgentIDs: q.Series[key],
+			Points:   []models.MetricPoint{},
+		}
+
+		indexes := make([]int64, 0, len(buckets[key]))
+		for idx := range buckets[key] {
+			indexes = append(indexes, idx)
+		}
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+		for _, idx := range indexes {
+			series.Points = append(series.Points, models.MetricPoint{
+				Timestamp: time.Unix(idx, 0),
+				Value:     aggregate(q.Aggregation, buckets[key][idx]),
+			})
+		}
+		result = append(result, series)
+	}
+
+	return result, nil
+}
+
+// maxQueryBuckets bounds the number of buckets a single query may produce.
+const maxQueryBuckets = 10000
+
+func validateMetricQuery(q *models.MetricQuery) error {
+	if !repository.IsQueryableMetric(q.Metric) {
+		return fmt.Errorf("%w: unsupported metric %q", ErrInvalidMetricQuery, q.Metric)
+	}
+	switch q.Aggregation {
+	case models.AggregationAvg, models.AggregationMin, models.AggregationMax,
+		models.AggregationP95, models.AggregationLast:
+	default:
+		return fmt.Errorf("%w: unsupported aggregation %q", ErrInvalidMetricQuery, q.Aggregation)
+	}
+	if !q.To.After(q.From) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidMetricQuery)
+	}
+	if q.Step < time.Second {
+		return fmt.Errorf("%w: step must be at least 1s", ErrInvalidMetricQuery)
+	}
+	if q.To.Sub(q.From)/q.Step > maxQueryBuckets {
+		return fmt.Errorf("%w: too many buckets, increase step", ErrInvalidMetricQuery)
+	}
+	return nil
+}
+
+func aggregate(agg models.Aggregation, values []float64) float64 {
+	if len(values) == 0 {
+		return 0
+	}
+
+	switch agg {
+	case models.AggregationMin:
+		min := values[0]
+		for _, v := range values[1:] {
+			min = math.Min(min, v)
+		}
+		return min
+	case models.AggregationMax:
+		max := values[0]
+		for _, v := range values[1:] {
+			max = math.Max(max, v)
+		}
+		return max
+	case models.AggregationP95:
+		sorted := append([]float64(nil), values...)
+		sort.Float64s(sorted)
+		rank := int(math.Ceil(0.95*float64(len(sorted)))) - 1
+		return sorted[rank]
+	case models.AggregationLast:
+		return values[len(values)-1]
+	default:
+		var sum float64
+		for _, v := range values {
+			sum += v
+		}
+		return sum / float64(len(values))
+	}
+}
+
 // Traffic Service
 type TrafficServiceImpl struct {
 	repo *repository.TrafficRepository
@@ -82,6 +275,10 @@ func (s *TrafficServiceImpl) GetStats(ctx context.Context, agentID string) (*mod
 	return s.repo.GetTrafficStats(ctx, agentID)
 }
 
+func (s *TrafficServiceImpl) ListArchives(ctx context.Context, agentID string) ([]*models.TrafficArchive, error) {
+	return s.repo.ListArchives(ctx, agentID)
+}
+
 func (s *TrafficServiceImpl) ConfigureCycle(ctx context.Context, agentID string, startDate time.Time, durationDays int, limitBytes uint64) error {
 	cycle := &models.BillingCycle{
 		ID:        uuid.New().String(),
@@ -108,7 +305,18 @@ func (s *TrafficServiceImpl) CheckAndResetCycles(ctx context.Context) error {
 		}
 
 		// Archive old data
-		if err := s.repo.ArchiveCycle(ctx, cycle.ID, bytesSent, bytesRecv); err != nil {
+		archive := &models.TrafficArchive{
+			ID:         uuid.New().String(),
+			CycleID:    cycle.ID,
+			AgentID:    cycle.AgentID,
+			CycleStart: cycle.StartDate,
+			CycleEnd:   cycle.StartDate.AddDate(0, 0, cycle.Duration),
+			BytesSent:  bytesSent,
+			BytesRecv:  bytesRecv,
+			Limit:      cycle.Limit,
+			CreatedAt:  time.Now(),
+		}
+		if err := s.repo.ArchiveCycle(ctx, archive); err != nil {
 			continue
 		}
 
@@ -0,0 +1,32 @@
+package service
+
+import (
+	"sync"
+
+	"github.com/probe-system/core/internal/models"
+)
+
+// ProcessServiceImpl keeps the most recent top-processes snapshot per agent
+// in memory. Snapshots are replaced on every report and are not persisted.
+type ProcessServiceImpl struct {
+	mu        sync.RWMutex
+	snapshots map[string]*models.ProcessSnapshot
+}
+
+func NewProcessService() *ProcessServiceImpl {
+	return &ProcessServiceImpl{
+		snapshots: make(map[string]*models.ProcessSnapshot),
+	}
+}
+
+func (s *ProcessServiceImpl) Update(agentID string, snapshot *models.ProcessSnapshot) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.snapshots[agentID] = snapshot
+}
+
+func (s *ProcessServiceImpl) Latest(agentID string) *models.ProcessSnapshot {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.snapshots[agentID]
+}
@@ -0,0 +1,153 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/repository"
+)
+
+// maxRetentionReports is how many past runs are kept for the admin API.
+const maxRetentionReports = 20
+
+type RetentionServiceImpl struct {
+	db            *repository.DB
+	settingsRepo  *repository.SettingsRepository
+	metricsRepo   *repository.MetricsRepository
+	containerRepo *repository.ContainerRepository
+	customRepo    *repository.CustomMetricRepository
+	trafficRepo   *repository.TrafficRepository
+	taskRepo      *repository.TaskRepository
+	alertRepo     *repository.AlertRepository
+	commandRepo   *repository.CommandRepository
+	terminalRepo  *repository.TerminalRepository
+
+	runMu   sync.Mutex
+	mu      sync.RWMutex
+	reports []*models.RetentionReport
+}
+
+func NewRetentionService(
+	db *repository.DB,
+	settingsRepo *repository.SettingsRepository,
+	metricsRepo *repository.MetricsRepository,
+	containerRepo *repository.ContainerRepository,
+	customRepo *repository.CustomMetricRepository,
+	trafficRepo *repository.TrafficRepository,
+	taskRepo *repository.TaskRepository,
+	alertRepo *repository.AlertRepository,
+	commandRepo *repository.CommandRepository,
+	terminalRepo *repository.TerminalRepository,
+) *RetentionServiceImpl {
+	return &RetentionServiceImpl{
+		db:            db,
+		settingsRepo:  settingsRepo,
+		metricsRepo:   metricsRepo,
+		containerRepo: containerRepo,
+		customRepo:    customRepo,
+		trafficRepo:   trafficRepo,
+		taskRepo:      taskRepo,
+		alertRepo:     alertRepo,
+		commandRepo:   commandRepo,
+		terminalRepo:  terminalRepo,
+		reports:       []*models.RetentionReport{},
+	}
+}
+
+// Enforce applies every retention policy from the current settings and then
+// vacuums. A failing dataset is recorded in the report and does not stop the
+// others. Concurrent calls are serialised.
+func (s *RetentionServiceImpl) Enforce(ctx context.Context) (*models.RetentionReport, error) {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+
+	settings, err := s.settingsRepo.Get(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &models.RetentionReport{
+		StartedAt: time.Now(),
+		Deleted:   make(map[models.Dataset]int64),
+		Vacuum:    settings.VacuumMode,
+	}
+
+	policies := []struct {
+		dataset models.Dataset
+		days    int
+		cleanup func(context.Context, int) (int64, error)
+	}{
+		{models.DatasetMetrics, settings.DataRetentionDays, s.metricsRepo.Cleanup},
+		{models.DatasetContainerMetrics, settings.DataRetentionDays, s.containerRepo.Cleanup},
+		{models.DatasetCustomMetrics, settings.DataRetentionDays, s.customRepo.Cleanup},
+		{models.DatasetTaskResults, settings.TaskResultRetentionDays, s.taskRepo.CleanupResults},
+		{models.DatasetTaskSeries, settings.TaskSeriesRetentionDays, s.taskRepo.CleanupSeries},
+		{models.DatasetCommandRuns, settings.TaskResultRetentionDays, s.commandRepo.Cleanup},
+		{models.DatasetTerminalSessions, settings.TaskResultRetentionDays, s.terminalRepo.Cleanup},
+		{models.DatasetTraffic, settings.TrafficRetentionDays, s.trafficRepo.CleanupRecords},
+		{models.DatasetAlerts, settings.AlertRetentionDays, s.alertRepo.CleanupResolved},
+		{models.DatasetTrafficArchives, settings.TrafficArchiveRetentionDays, s.trafficRepo.CleanupArchives},
+	}
+
+	var total int64
+	for _, p := range policies {
+		deleted, err := p.cleanup(ctx, p.days)
+		report.Deleted[p.dataset] = deleted
+		total += deleted
+		if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("%s: %v", p.dataset, err))
+		}
+	}
+
+	if total > 0 {
+		err := s.db.Vacuum(ctx, settings.VacuumMode)
+		if errors.Is(err, repository.ErrIncrementalVacuumDisabled) {
+			// Upgraded databases stay as they are until an admin converts
+			// them; the conversion is too expensive to run unannounced.
+			report.Vacuum = models.VacuumModeNone
+		} else if err != nil {
+			report.Errors = append(report.Errors, fmt.Sprintf("vacuum: %v", err))
+		}
+	} else {
+		report.Vacuum = models.VacuumModeNone
+	}
+
+	report.FinishedAt = time.Now()
+
+	s.mu.Lock()
+	s.reports = append([]*models.RetentionReport{report}, s.reports...)
+	if len(s.reports) > maxRetentionReports {
+		s.reports = s.reports[:maxRetentionReports]
+	}
+	s.mu.Unlock()
+
+	if total > 0 || len(report.Errors) > 0 {
+		log.Printf("Retention run deleted %d rows %v, errors: %v", total, report.Deleted, report.Errors)
+	}
+
+	return report, nil
+}
+
+// EnableIncrementalVacuum switches the database to incremental auto-vacuum so
+// the "incremental" vacuum mode can run. It rewrites the database once.
+func (s *RetentionServiceImpl) EnableIncrementalVacuum(ctx context.Context) error {
+	s.runMu.Lock()
+	defer s.runMu.Unlock()
+	return s.db.EnableIncrementalVacuum(ctx)
+}
+
+// AutoVacuum returns the database's current auto-vacuum mode.
+func (s *RetentionServiceImpl) AutoVacuum(ctx context.Context) (models.VacuumMode, error) {
+	return s.db.AutoVacuum(ctx)
+}
+
+func (s *RetentionServiceImpl) Reports() []*models.RetentionReport {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return append([]*models.RetentionReport(nil), s.reports...)
+}
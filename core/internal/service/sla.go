@@ -0,0 +1,289 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/probe-system/core/internal/models"
+)
+
+var ErrInvalidSLAQuery = errors.New("invalid sla query")
+
+// maxSLAPeriod bounds a custom report period.
+const maxSLAPeriod = 366 * 24 * time.Hour
+
+// SLA reports the availability of the task's target. A quorum of 0 means a
+// majority of the task's agents, which are the ones it lists and the ones
+// that reported in the report period. If from is set the report covers from to to
+// alone, otherwise each of models.SLAWindows up to now. A task that does not
+// exist gives nil.
+func (s *TaskServiceImpl) SLA(ctx context.Context, taskID string, quorum int, from, to time.Time) (*models.SLAReport, error) {
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil || task == nil {
+		return nil, err
+	}
+
+	if to.IsZero() {
+		to = time.Now()
+	}
+	var windows []*models.SLAWindow
+	if !from.IsZero() {
+		if !to.After(from) {
+			return nil, fmt.Errorf("%w: from must be before to", ErrInvalidSLAQuery)
+		}
+		if to.Sub(from) > maxSLAPeriod {
+			return nil, fmt.Errorf("%w: the period may be at most 366 days", ErrInvalidSLAQuery)
+		}
+		windows = []*models.SLAWindow{{Name: "custom", From: from, To: to}}
+	} else {
+		for _, w := range models.SLAWindows {
+			windows = append(windows, &models.SLAWindow{Name: w.Name, From: to.Add(-w.Duration), To: to})
+		}
+	}
+
+	if quorum < 0 {
+		return nil, fmt.Errorf("%w: quorum must not be negative", ErrInvalidSLAQuery)
+	}
+	return s.buildSLA(ctx, task, quorum, windows)
+}
+
+// ListSLA reports the availability of every recurring task's target over
+// models.SLAWindows, with the default quorum and without the outages.
+func (s *TaskServiceImpl) ListSLA(ctx context.Context) ([]*models.SLAReport, error) {
+	tasks, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	to := time.Now()
+	reports := []*models.SLAReport{}
+	for _, task := range tasks {
+		if !task.Recurring() {
+			continue
+		}
+		var windows []*models.SLAWindow
+		for _, w := range models.SLAWindows {
+			windows = append(windows, &models.SLAWindow{Name: w.Name, From: to.Add(-w.Duration), To: to})
+		}
+		report, err := s.buildSLA(ctx, task, 0, windows)
+		if err != nil {
+			return nil, err
+		}
+		report.Outages = nil
+		reports = append(reports, report)
+	}
+	return reports, nil
+}
+
+func (s *TaskServiceImpl) buildSLA(ctx context.Context, task *models.Task, quorum int, windows []*models.SLAWindow) (*models.SLAReport, error) {
+	from, to := windows[0].From, windows[0].To
+	for _, w := range windows {
+		if w.From.Before(from) {
+			from = w.From
+		}
+	}
+
+	// Tasks that target groups or tags run on agents they do not list.
+	agentIDs := append([]string{}, task.AgentIDs...)
+	reporting, err := s.repo.SeriesAgents(ctx, task.ID, "success", from, to)
+	if err != nil {
+		return nil, err
+	}
+	for _, id := range reporting {
+		if !contains(agentIDs, id) {
+			agentIDs = append(agentIDs, id)
+		}
+	}
+
+	if quorum == 0 {
+		quorum = len(agentIDs)/2 + 1
+	} else if len(agentIDs) > 0 && quorum > len(agentIDs) {
+		return nil, fmt.Errorf("%w: quorum must be between 1 and the task's %d agents", ErrInvalidSLAQuery, len(agentIDs))
+	}
+	report := &models.SLAReport{
+		TaskID:  task.ID,
+		Name:    task.Name,
+		Type:    task.Type,
+		Target:  task.Target,
+		Agents:  len(agentIDs),
+		Quorum:  quorum,
+		Windows: windows,
+	}
+
+	// A failing agent that stops reporting no longer counts against the
+	// target after three intervals.
+	sweep := &slaSweep{quorum: quorum, states: map[string]*slaAgentState{}}
+	if task.Interval > 0 {
+		sweep.stale = 3 * time.Duration(task.Interval) * time.Second
+	}
+
+	// window -> agent -> checks and failures.
+	counts := make([]map[string]*models.AgentUptime, len(windows))
+	for i := range windows {
+		counts[i] = map[string]*models.AgentUptime{}
+		for _, id := range agentIDs {
+			counts[i][id] = &models.AgentUptime{AgentID: id}
+		}
+	}
+
+	err = s.repo.IterateSeries(ctx, task.ID, "success", from, to, func(sample *models.TaskSeriesSample) error {
+		sweep.add(sample)
+		for i, w := range windows {
+			if sample.Timestamp.Before(w.From) {
+				continue
+			}
+			c := counts[i][sample.AgentID]
+			if c == nil {
+				c = &models.AgentUptime{AgentID: sample.AgentID}
+				counts[i][sample.AgentID] = c
+			}
+			c.Checks++
+			if sample.Value < 1 {
+				c.Failures++
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sweep.finish(to)
+
+	// The first result comes up to an interval after a window starts; one
+	// later than that means the window is not fully covered.
+	slack := sweep.stale
+	if slack <= 0 {
+		slack = time.Hour
+	}
+
+	for i, w := range windows {
+		w.Agents = make([]*models.AgentUptime, 0, len(counts[i]))
+		for _, c := range counts[i] {
+			if c.Checks > 0 {
+				uptime := float64(c.Checks-c.Failures) / float64(c.Checks) * 100
+				c.Uptime = &uptime
+			}
+			w.Agents = append(w.Agents, c)
+		}
+		sort.Slice(w.Agents, func(a, b int) bool { return w.Agents[a].AgentID < w.Agents[b].AgentID })
+
+		start := w.From
+		if sweep.first.After(start) {
+			start = sweep.first
+		}
+		for _, o := range sweep.outages {
+			end := w.To
+			if o.End != nil && o.End.Before(end) {
+				end = *o.End
+			}
+			begin := o.Start
+			if begin.Before(w.From) {
+				begin = w.From
+			}
+			if end.After(begin) {
+				w.Downtime += end.Sub(begin).Seconds()
+				w.Outages++
+			}
+		}
+		if covered := w.To.Sub(start).Seconds(); !sweep.first.IsZero() && covered > 0 {
+			uptime := (covered - w.Downtime) / covered * 100
+			w.Uptime = &uptime
+			w.CoveredFrom = &start
+			w.Partial = start.Sub(w.From) > slack
+		}
+	}
+
+	report.Outages = sweep.outages
+	if report.Outages == nil {
+		report.Outages = []*models.Outage{}
+	}
+	return report, nil
+}
+
+type slaAgentState struct {
+	ok bool
+	at time.Time
+	// counted is cleared once the agent's latest result is too old.
+	counted bool
+}
+
+// slaSweep follows the agents' latest results in time order and records
+// when the target went down and came back.
+type slaSweep struct {
+	quorum  int
+	stale   time.Duration
+	states  map[string]*slaAgentState
+	failing int
+	down    bool
+	first   time.Time
+	outages []*models.Outage
+}
+
+func (w *slaSweep) add(sample *models.TaskSeriesSample) {
+	w.expire(sample.Timestamp)
+	if w.first.IsZero() {
+		w.first = sample.Timestamp
+	}
+
+	st := w.states[sample.AgentID]
+	if st == nil {
+		st = &slaAgentState{}
+		w.states[sample.AgentID] = st
+	}
+	if st.counted && !st.ok {
+		w.failing--
+	}
+	st.ok, st.at, st.counted = sample.Value >= 1, sample.Timestamp, true
+	if !st.ok {
+		w.failing++
+	}
+	w.update(sample.Timestamp)
+}
+
+// expire stops counting failing agents whose latest result went stale
+// before t, in the order they went stale.
+func (w *slaSweep) expire(t time.Time) {
+	if w.stale <= 0 {
+		return
+	}
+	for {
+		var oldest *slaAgentState
+		for _, st := range w.states {
+			if st.counted && !st.ok && st.at.Add(w.stale).Before(t) && (oldest == nil || st.at.Before(oldest.at)) {
+				oldest = st
+			}
+		}
+		if oldest == nil {
+			return
+		}
+		oldest.counted = false
+		w.failing--
+		w.update(oldest.at.Add(w.stale))
+	}
+}
+
+func (w *slaSweep) update(t time.Time) {
+	down := w.failing >= w.quorum
+	switch {
+	case down && !w.down:
+		w.outages = append(w.outages, &models.Outage{Start: t})
+	case !down && w.down:
+		end := t
+		w.outages[len(w.outages)-1].End = &end
+	}
+	w.down = down
+}
+
+func (w *slaSweep) finish(to time.Time) {
+	w.expire(to)
+	for _, o := range w.outages {
+		end := to
+		if o.End != nil {
+			end = *o.End
+		}
+		o.Duration = end.Sub(o.Start).Seconds()
+	}
+}
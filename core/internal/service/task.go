@@ -4,22 +4,50 @@ import (
 	"context"
 	"crypto/sha256"
 	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
 	"github.com/probe-system/core/internal/models"
 	"github.com/probe-system/core/internal/repository"
+	"github.com/probe-system/core/pkg/cron"
+)
+
+var (
+	ErrInvalidTask        = errors.New("invalid task")
+	ErrInvalidSeriesQuery = errors.New("invalid series query")
 )
 
 type TaskServiceImpl struct {
 	repo *repository.TaskRepository
+
+	// The latest result per agent and task of the tls and dns tasks, for
+	// alert evaluation.
+	certs *latestResults
+	dns   *latestResults
 }
 
 func NewTaskService(repo *repository.TaskRepository) *TaskServiceImpl {
-	return &TaskServiceImpl{repo: repo}
+	return &TaskServiceImpl{
+		repo:  repo,
+		certs: newLatestResults(repo, models.TaskTypeTLS),
+		dns:   newLatestResults(repo, models.TaskTypeDNS),
+	}
 }
 
 func (s *TaskServiceImpl) Create(ctx context.Context, task *models.Task) error {
+	if err := validateTask(task); err != nil {
+		return err
+	}
 	task.ID = uuid.New().String()
 	task.Status = models.TaskStatusPending
 	task.CreatedAt = time.Now()
@@ -31,30 +59,329 @@ func (s *TaskServiceImpl) Create(ctx context.Context, task *models.Task) error {
 }
 
 func (s *TaskServiceImpl) Update(ctx context.Context, task *models.Task) error {
+	if err := validateTask(task); err != nil {
+		return err
+	}
 	task.UpdatedAt = time.Now()
 	return s.repo.Update(ctx, task)
 }
 
 func (s *TaskServiceImpl) Cancel(ctx context.Context, taskID string) error {
-	return s.repo.UpdateStatus(ctx, taskID, models.TaskStatusCanceled)
+	if err := s.repo.UpdateStatus(ctx, taskID, models.TaskStatusCanceled); err != nil {
+		return err
+	}
+	if err := s.repo.CancelDeliveries(ctx, taskID, ""); err != nil {
+		return err
+	}
+
+	s.certs.drop(taskID)
+	s.dns.drop(taskID)
+	return nil
 }
 
 func (s *TaskServiceImpl) GetByID(ctx context.Context, taskID string) (*models.Task, error) {
-	return s.repo.GetByID(ctx, taskID)
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil || task == nil {
+		return nil, err
+	}
+	setNextRun(task)
+
+	rollouts, err := s.repo.CountDeliveries(ctx, taskID)
+	if err != nil {
+		return nil, err
+	}
+	task.Rollout = rollouts[taskID]
+	return task, nil
 }
 
 func (s *TaskServiceImpl) List(ctx context.Context) ([]*models.Task, error) {
-	return s.repo.List(ctx)
+	tasks, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	rollouts, err := s.repo.CountDeliveries(ctx, "")
+	if err != nil {
+		return nil, err
+	}
+	for _, task := range tasks {
+		setNextRun(task)
+		task.Rollout = rollouts[task.ID]
+	}
+	return tasks, nil
 }
 
-func (s *TaskServiceImpl) ListByAgent(ctx context.Context, agentID string) ([]*models.Task, error) {
-	return s.repo.ListByAgent(ctx, agentID)
+// ListByAgent returns the active tasks that target the agent.
+func (s *TaskServiceImpl) ListByAgent(ctx context.Context, agent *models.Agent) ([]*models.Task, error) {
+	tasks, err := s.repo.ListActive(ctx)
+	if err != nil {
+		return nil, err
+	}
+	result := []*models.Task{}
+	for _, task := range tasks {
+		if task.Targets(agent) {
+			result = append(result, task)
+		}
+	}
+	return result, nil
+}
+
+func (s *TaskServiceImpl) ListActive(ctx context.Context) ([]*models.Task, error) {
+	return s.repo.ListActive(ctx)
 }
 
 func (s *TaskServiceImpl) RecordResult(ctx context.Context, result *models.TaskResult) error {
 	result.ID = uuid.New().String()
 	result.Timestamp = time.Now()
-	return s.repo.RecordResult(ctx, result)
+	if err := s.repo.RecordResult(ctx, result); err != nil {
+		return err
+	}
+
+	if result.TLS != nil {
+		s.certs.put(result)
+	}
+	if result.DNS != nil {
+		s.dns.put(result)
+	}
+
+	task, err := s.repo.GetByID(ctx, result.TaskID)
+	if err != nil || task == nil {
+		return err
+	}
+	state := models.DeliveryRunning
+	if !task.Recurring() {
+		state = models.DeliveryFailed
+		if result.Success {
+			state = models.DeliveryComplete
+		}
+	}
+	if err := s.repo.RecordDelivery(ctx, result, state); err != nil {
+		return err
+	}
+	return s.updateStatus(ctx, task)
+}
+
+// MarkAssigned records that the task is being sent to the agent. It reports
+// false for a one-time task the agent already finished or rejected, which
+// must not be sent again.
+func (s *TaskServiceImpl) MarkAssigned(ctx context.Context, task *models.Task, agentID string) (bool, error) {
+	return s.repo.MarkAssigned(ctx, task, agentID, time.Now())
+}
+
+// Acknowledge records whether the agent started the task it was assigned.
+func (s *TaskServiceImpl) Acknowledge(ctx context.Context, taskID, agentID string, success bool, errMsg string) error {
+	state := models.DeliveryAcknowledged
+	if !success {
+		state = models.DeliveryRejected
+	}
+	if err := s.repo.AckDelivery(ctx, taskID, agentID, state, errMsg, time.Now()); err != nil {
+		return err
+	}
+
+	task, err := s.repo.GetByID(ctx, taskID)
+	if err != nil || task == nil {
+		return err
+	}
+	return s.updateStatus(ctx, task)
+}
+
+// CancelDelivery records that the task was stopped on the agent.
+func (s *TaskServiceImpl) CancelDelivery(ctx context.Context, taskID, agentID string) error {
+	return s.repo.CancelDeliveries(ctx, taskID, agentID)
+}
+
+func (s *TaskServiceImpl) ListDeliveries(ctx context.Context, taskID string) ([]*models.TaskDelivery, error) {
+	return s.repo.ListDeliveries(ctx, taskID)
+}
+
+// PendingDeliveries returns the assignments agents have not acknowledged.
+func (s *TaskServiceImpl) PendingDeliveries(ctx context.Context) ([]*models.TaskDelivery, error) {
+	return s.repo.PendingDeliveries(ctx)
+}
+
+// updateStatus moves a pending or running task along as its deliveries
+// progress.
+func (s *TaskServiceImpl) updateStatus(ctx context.Context, task *models.Task) error {
+	if task.Status != models.TaskStatusPending && task.Status != models.TaskStatusRunning {
+		return nil
+	}
+	rollouts, err := s.repo.CountDeliveries(ctx, task.ID)
+	if err != nil {
+		return err
+	}
+	r := rollouts[task.ID]
+	if r == nil {
+		return nil
+	}
+	if status := rolloutStatus(task, r); status != task.Status {
+		return s.repo.UpdateStatus(ctx, task.ID, status)
+	}
+	return nil
+}
+
+// rolloutStatus is running once an agent started the task. A one-time task
+// is complete when no agent has it left to run and at least one succeeded,
+// or failed if none did. A task every agent rejected fails.
+func rolloutStatus(task *models.Task, r *models.TaskRollout) models.TaskStatus {
+	unfinished := r.Assigned + r.Acknowledged + r.Running
+	switch {
+	case r.Total > 0 && r.Rejected == r.Total:
+		return models.TaskStatusFailed
+	case !task.Recurring() && unfinished == 0 && r.Complete+r.Failed > 0:
+		if r.Complete > 0 {
+			return models.TaskStatusComplete
+		}
+		return models.TaskStatusFailed
+	case r.Acknowledged+r.Running+r.Complete+r.Failed > 0:
+		return models.TaskStatusRunning
+	}
+	return task.Status
+}
+
+// ListCertificates returns the latest certificate every tls task saw from
+// each agent, soonest to expire first.
+func (s *TaskServiceImpl) ListCertificates(ctx context.Context) ([]*models.Certificate, error) {
+	results, err := s.repo.LatestResults(ctx, models.TaskTypeTLS)
+	if err != nil {
+		return nil, err
+	}
+	tasks, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	names := make(map[string]string, len(tasks))
+	for _, t := range tasks {
+		names[t.ID] = t.Name
+	}
+
+	certs := make([]*models.Certificate, 0, len(results))
+	for _, r := range results {
+		cert := newCertificate(r)
+		cert.TaskName = names[r.TaskID]
+		cert.DaysRemaining = daysUntil(cert.TLS.NotAfter)
+		certs = append(certs, cert)
+	}
+	sort.Slice(certs, func(i, j int) bool {
+		return certs[i].TLS.NotAfter.Before(certs[j].TLS.NotAfter)
+	})
+	return certs, nil
+}
+
+// AgentCertificates returns the latest certificates the agent's tls tasks
+// reported, or nil if they cannot be loaded.
+func (s *TaskServiceImpl) AgentCertificates(ctx context.Context, agentID string) []*models.Certificate {
+	results := s.certs.agent(ctx, agentID)
+	certs := make([]*models.Certificate, 0, len(results))
+	for _, r := range results {
+		cert := newCertificate(r)
+		cert.DaysRemaining = daysUntil(cert.TLS.NotAfter)
+		certs = append(certs, cert)
+	}
+	return certs
+}
+
+// AgentDNSResults returns the latest result of each of the agent's dns
+// tasks, or nil if they cannot be loaded.
+func (s *TaskServiceImpl) AgentDNSResults(ctx context.Context, agentID string) []*models.TaskResult {
+	return s.dns.agent(ctx, agentID)
+}
+
+func newCertificate(result *models.TaskResult) *models.Certificate {
+	return &models.Certificate{
+		TaskID:    result.TaskID,
+		AgentID:   result.AgentID,
+		Target:    result.TLS.Target,
+		TLS:       result.TLS,
+		CheckedAt: result.Timestamp,
+	}
+}
+
+func daysUntil(t time.Time) float64 {
+	return time.Until(t).Hours() / 24
+}
+
+// latestResults caches the latest result per agent and task of one task
+// type. It is loaded from the database on first use.
+type latestResults struct {
+	repo     *repository.TaskRepository
+	taskType models.TaskType
+
+	mu      sync.Mutex
+	results map[string]map[string]*models.TaskResult
+}
+
+func newLatestResults(repo *repository.TaskRepository, taskType models.TaskType) *latestResults {
+	return &latestResults{repo: repo, taskType: taskType}
+}
+
+func (c *latestResults) agent(ctx context.Context, agentID string) []*models.TaskResult {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results == nil {
+		results, err := c.repo.LatestResults(ctx, c.taskType)
+		if err != nil {
+			return nil
+		}
+		c.results = make(map[string]map[string]*models.TaskResult)
+		for _, r := range results {
+			c.store(r)
+		}
+	}
+
+	results := make([]*models.TaskResult, 0, len(c.results[agentID]))
+	for _, r := range c.results[agentID] {
+		results = append(results, r)
+	}
+	return results
+}
+
+// put records a new result. Until the cache is loaded the database has it
+// already.
+func (c *latestResults) put(result *models.TaskResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.results != nil {
+		c.store(result)
+	}
+}
+
+// store adds result to the cache. mu must be held.
+func (c *latestResults) store(result *models.TaskResult) {
+	if c.results[result.AgentID] == nil {
+		c.results[result.AgentID] = make(map[string]*models.TaskResult)
+	}
+	c.results[result.AgentID][result.TaskID] = result
+}
+
+func (c *latestResults) drop(taskID string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, results := range c.results {
+		delete(results, taskID)
+	}
+}
+
+// DiffPath compares the task's latest path on the agent with the latest one
+// from at least the given time before it. It returns nil if the agent has
+// no path for the task yet.
+func (s *TaskServiceImpl) DiffPath(ctx context.Context, taskID, agentID string, before time.Duration) (*models.PathDiff, error) {
+	after, err := s.repo.LatestTracerouteAt(ctx, taskID, agentID, time.Now())
+	if err != nil || after == nil {
+		return nil, err
+	}
+	diff := &models.PathDiff{
+		TaskID:  taskID,
+		AgentID: agentID,
+		After:   after.Traceroute,
+		AfterAt: after.Timestamp,
+	}
+
+	earlier, err := s.repo.LatestTracerouteAt(ctx, taskID, agentID, after.Timestamp.Add(-before))
+	if err != nil || earlier == nil {
+		return diff, err
+	}
+	diff.Before, diff.BeforeAt = earlier.Traceroute, &earlier.Timestamp
+	diff.Hops, diff.Changed = models.DiffPaths(earlier.Traceroute, after.Traceroute)
+	return diff, nil
 }
 
 func (s *TaskServiceImpl) GetResults(ctx context.Context, taskID string, limit int) ([]*models.TaskResult, error) {
@@ -64,6 +391,310 @@ func (s *TaskServiceImpl) GetResults(ctx context.Context, taskID string, limit i
 	return s.repo.GetResults(ctx, taskID, limit)
 }
 
+// QuerySeries aggregates a field of the task's results into fixed-width
+// buckets, one series per agent ordered by agent ID. A task that does not
+// exist gives nil.
+func (s *TaskServiceImpl) QuerySeries(ctx context.Context, q *models.TaskSeriesQuery) ([]*models.MetricSeries, error) {
+	task, err := s.repo.GetByID(ctx, q.TaskID)
+	if err != nil || task == nil {
+		return nil, err
+	}
+	if err := validateSeriesQuery(task, q); err != nil {
+		return nil, err
+	}
+
+	samples, err := s.repo.GetSeries(ctx, q.TaskID, q.Field, q.AgentIDs, q.From, q.To)
+	if err != nil {
+		return nil, err
+	}
+
+	// agent -> bucket start (unix seconds) -> values in timestamp order.
+	buckets := make(map[string]map[int64][]float64)
+	for _, sample := range samples {
+		if buckets[sample.AgentID] == nil {
+			buckets[sample.AgentID] = make(map[int64][]float64)
+		}
+		idx := sample.Timestamp.Truncate(q.Step).Unix()
+		buckets[sample.AgentID][idx] = append(buckets[sample.AgentID][idx], sample.Value)
+	}
+
+	agentIDs := make([]string, 0, len(buckets))
+	for id := range buckets {
+		agentIDs = append(agentIDs, id)
+	}
+	sort.Strings(agentIDs)
+
+	result := make([]*models.MetricSeries, 0, len(agentIDs))
+	for _, id := range agentIDs {
+		series := &models.MetricSeries{Key: id, AgentIDs: []string{id}, Points: []models.MetricPoint{}}
+
+		indexes := make([]int64, 0, len(buckets[id]))
+		for idx := range buckets[id] {
+			indexes = append(indexes, idx)
+		}
+		sort.Slice(indexes, func(i, j int) bool { return indexes[i] < indexes[j] })
+
+		for _, idx := range indexes {
+			series.Points = append(series.Points, models.MetricPoint{
+				Timestamp: time.Unix(idx, 0),
+				Value:     aggregate(q.Aggregation, buckets[id][idx]),
+			})
+		}
+		result = append(result, series)
+	}
+	return result, nil
+}
+
+func validateSeriesQuery(task *models.Task, q *models.TaskSeriesQuery) error {
+	known := false
+	for _, field := range models.TaskSeriesFields[task.Type] {
+		known = known || field == q.Field
+	}
+	if !known {
+		return fmt.Errorf("%w: %s tasks have no field %q", ErrInvalidSeriesQuery, task.Type, q.Field)
+	}
+	switch q.Aggregation {
+	case models.AggregationAvg, models.AggregationMin, models.AggregationMax,
+		models.AggregationP95, models.AggregationLast:
+	default:
+		return fmt.Errorf("%w: unsupported aggregation %q", ErrInvalidSeriesQuery, q.Aggregation)
+	}
+	if !q.To.After(q.From) {
+		return fmt.Errorf("%w: from must be before to", ErrInvalidSeriesQuery)
+	}
+	if q.Step < time.Second {
+		return fmt.Errorf("%w: step must be at least 1s", ErrInvalidSeriesQuery)
+	}
+	if q.To.Sub(q.From)/q.Step > maxQueryBuckets {
+		return fmt.Errorf("%w: too many buckets, increase step", ErrInvalidSeriesQuery)
+	}
+	return nil
+}
+
+// validateTask checks the parameters of the task's type.
+func validateTask(task *models.Task) error {
+	if err := validateSchedule(task); err != nil {
+		return err
+	}
+	task.GroupIDs = compactList(task.GroupIDs)
+	task.Tags = compactList(task.Tags)
+
+	switch task.Type {
+	case models.TaskTypePing:
+		if strings.TrimSpace(task.Target) == "" {
+			return fmt.Errorf("%w: target is required", ErrInvalidTask)
+		}
+		switch task.Params["mode"] {
+		case "", models.PingModeAuto, models.PingModeICMP, models.PingModeTCP:
+		default:
+			return fmt.Errorf("%w: unknown ping mode %q", ErrInvalidTask, task.Params["mode"])
+		}
+	case models.TaskTypeHTTP:
+		return validateHTTPTask(task)
+	case models.TaskTypeDNS:
+		return validateDNSTask(task)
+	case models.TaskTypeTraceroute:
+		return validateTracerouteTask(task)
+	case models.TaskTypeTLS:
+		if strings.TrimSpace(task.Target) == "" {
+			return fmt.Errorf("%w: target is required", ErrInvalidTask)
+		}
+		if _, port, err := net.SplitHostPort(task.Target); err == nil {
+			if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+				return fmt.Errorf("%w: port must be between 1 and 65535", ErrInvalidTask)
+			}
+		}
+	}
+	return nil
+}
+
+// compactList trims the values and drops the empty ones.
+func compactList(values []string) []string {
+	result := []string{}
+	for _, v := range values {
+		if v = strings.TrimSpace(v); v != "" {
+			result = append(result, v)
+		}
+	}
+	return result
+}
+
+// maxJitter bounds how long a scheduled run may be delayed, in seconds.
+const maxJitter = 3600
+
+// validateSchedule checks the task's cron schedule with the parser the
+// agents use.
+func validateSchedule(task *models.Task) error {
+	task.Schedule = strings.TrimSpace(task.Schedule)
+	if task.Schedule == "" {
+		if task.Timezone != "" || task.Jitter != 0 {
+			return fmt.Errorf("%w: timezone and jitter need a schedule", ErrInvalidTask)
+		}
+		return nil
+	}
+	if task.Interval != 0 {
+		return fmt.Errorf("%w: a task has either an interval or a schedule", ErrInvalidTask)
+	}
+	if task.Jitter < 0 || task.Jitter > maxJitter {
+		return fmt.Errorf("%w: jitter must be between 0 and %d seconds", ErrInvalidTask, maxJitter)
+	}
+	schedule, err := cron.Parse(task.Schedule, task.Timezone)
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidTask, err)
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return fmt.Errorf("%w: schedule %q never runs", ErrInvalidTask, task.Schedule)
+	}
+	return nil
+}
+
+// setNextRun fills in when an active scheduled task runs next, before
+// jitter.
+func setNextRun(task *models.Task) {
+	if task.Schedule == "" || (task.Status != models.TaskStatusPending && task.Status != models.TaskStatusRunning) {
+		return
+	}
+	if schedule, err := cron.Parse(task.Schedule, task.Timezone); err == nil {
+		if next := schedule.Next(time.Now()); !next.IsZero() {
+			task.NextRun = &next
+		}
+	}
+}
+
+// validateDNSTask checks the parameters the agent reads for dns tasks:
+// record_type, resolver, expected and match.
+func validateDNSTask(task *models.Task) error {
+	if name := strings.TrimSpace(task.Target); name == "" || strings.ContainsAny(name, " /:") {
+		return fmt.Errorf("%w: target must be a domain name", ErrInvalidTask)
+	}
+
+	params := task.Params
+	recordType := strings.ToUpper(params["record_type"])
+	if recordType == "" {
+		recordType = "A"
+	}
+	if !contains(models.DNSRecordTypes, recordType) {
+		return fmt.Errorf("%w: unsupported record type %q", ErrInvalidTask, params["record_type"])
+	}
+	switch strings.ToLower(params["match"]) {
+	case "", models.DNSMatchAll, models.DNSMatchAny, models.DNSMatchExact:
+	default:
+		return fmt.Errorf("%w: unknown match %q", ErrInvalidTask, params["match"])
+	}
+	if resolver := strings.TrimSpace(params["resolver"]); resolver != "" {
+		if _, port, err := net.SplitHostPort(resolver); err == nil {
+			if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+				return fmt.Errorf("%w: resolver port must be between 1 and 65535", ErrInvalidTask)
+			}
+		}
+	}
+	if recordType == "A" || recordType == "AAAA" {
+		for _, v := range strings.FieldsFunc(params["expected"], func(r rune) bool { return r == ',' || r == '\n' }) {
+			if v = strings.TrimSpace(v); v != "" && net.ParseIP(v) == nil {
+				return fmt.Errorf("%w: expected value %q is not an IP address", ErrInvalidTask, v)
+			}
+		}
+	}
+	return nil
+}
+
+// validateTracerouteTask checks the parameters the agent reads for
+// traceroute tasks: protocol, max_hops and rounds.
+func validateTracerouteTask(task *models.Task) error {
+	if strings.TrimSpace(task.Target) == "" {
+		return fmt.Errorf("%w: target is required", ErrInvalidTask)
+	}
+	if _, port, err := net.SplitHostPort(task.Target); err == nil {
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("%w: port must be between 1 and 65535", ErrInvalidTask)
+		}
+	}
+
+	params := task.Params
+	switch strings.ToLower(params["protocol"]) {
+	case "", models.TracerouteUDP, models.TracerouteICMP, models.TracerouteTCP:
+	default:
+		return fmt.Errorf("%w: unknown protocol %q", ErrInvalidTask, params["protocol"])
+	}
+	if v := params["max_hops"]; v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 || n > 64 {
+			return fmt.Errorf("%w: max_hops must be between 1 and 64", ErrInvalidTask)
+		}
+	}
+	if v := params["rounds"]; v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 1 || n > 100 {
+			return fmt.Errorf("%w: rounds must be between 1 and 100", ErrInvalidTask)
+		}
+	}
+	return nil
+}
+
+var httpMethods = map[string]bool{
+	http.MethodGet: true, http.MethodHead: true, http.MethodPost: true, http.MethodPut: true,
+	http.MethodPatch: true, http.MethodDelete: true, http.MethodOptions: true,
+}
+
+// validateHTTPTask checks the parameters the agent reads for http tasks:
+// method, headers (one "Name: value" per line), body, expected_status,
+// keyword, regex, follow_redirects and max_redirects.
+func validateHTTPTask(task *models.Task) error {
+	u, err := url.Parse(task.Target)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: target must be an http or https URL", ErrInvalidTask)
+	}
+
+	params := task.Params
+	if method := strings.ToUpper(params["method"]); method != "" && !httpMethods[method] {
+		return fmt.Errorf("%w: unsupported method %q", ErrInvalidTask, params["method"])
+	}
+	for _, line := range strings.Split(params["headers"], "\n") {
+		if line = strings.TrimSpace(line); line != "" && !strings.Contains(line, ":") {
+			return fmt.Errorf("%w: header %q is not \"Name: value\"", ErrInvalidTask, line)
+		}
+	}
+	if spec := params["expected_status"]; spec != "" {
+		if err := validateStatusSpec(spec); err != nil {
+			return err
+		}
+	}
+	if params["regex"] != "" {
+		if _, err := regexp.Compile(params["regex"]); err != nil {
+			return fmt.Errorf("%w: regex: %v", ErrInvalidTask, err)
+		}
+	}
+	if v := params["follow_redirects"]; v != "" {
+		if _, err := strconv.ParseBool(v); err != nil {
+			return fmt.Errorf("%w: follow_redirects must be true or false", ErrInvalidTask)
+		}
+	}
+	if v := params["max_redirects"]; v != "" {
+		if n, err := strconv.Atoi(v); err != nil || n < 0 {
+			return fmt.Errorf("%w: max_redirects must be a non-negative number", ErrInvalidTask)
+		}
+	}
+	return nil
+}
+
+// validateStatusSpec checks a comma-separated list of status codes ("200"),
+// ranges ("200-299") and classes ("2xx").
+func validateStatusSpec(spec string) error {
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		lo, hi := part, part
+		if len(part) == 3 && strings.HasSuffix(part, "xx") {
+			lo, hi = part[:1]+"00", part[:1]+"99"
+		} else if before, after, ok := strings.Cut(part, "-"); ok {
+			lo, hi = strings.TrimSpace(before), strings.TrimSpace(after)
+		}
+		min, err1 := strconv.Atoi(lo)
+		max, err2 := strconv.Atoi(hi)
+		if err1 != nil || err2 != nil || min < 100 || max > 599 || min > max {
+			return fmt.Errorf("%w: invalid expected status %q", ErrInvalidTask, part)
+		}
+	}
+	return nil
+}
+
 // Script Service
 type ScriptServiceImpl struct {
 	repo *repository.ScriptRepository
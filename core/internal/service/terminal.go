@@ -0,0 +1,324 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"math"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/google/uuid"
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/repository"
+)
+
+var ErrTerminalNotFound = errors.New("terminal session not found")
+
+const (
+	// terminalOpenTimeout is how long the agent has to start the shell.
+	// Agents without terminal support never answer.
+	terminalOpenTimeout = 15 * time.Second
+	// terminalOutputBuffer is how many output chunks the browser may fall
+	// behind before the session is closed.
+	terminalOutputBuffer = 1024
+	// The recording is written every terminalFlushBytes, or on the next
+	// output after terminalFlushInterval.
+	terminalFlushBytes    = 64 * 1024
+	terminalFlushInterval = 5 * time.Second
+)
+
+// TerminalServiceImpl keeps the open terminal sessions, passes their output
+// to the browser and records it, and the input too if recordInput is set.
+type TerminalServiceImpl struct {
+	repo        *repository.TerminalRepository
+	recordInput bool
+
+	mu   sync.Mutex
+	live map[string]*liveTerminal
+}
+
+type liveTerminal struct {
+	session *models.TerminalSession
+	output  chan []byte
+
+	// recording holds event lines not written yet; partial holds the start
+	// of a UTF-8 sequence split across output chunks.
+	recording bytes.Buffer
+	partial   []byte
+	seq       int
+	flushedAt time.Time
+}
+
+func NewTerminalService(repo *repository.TerminalRepository, recordInput bool) *TerminalServiceImpl {
+	return &TerminalServiceImpl{
+		repo:        repo,
+		recordInput: recordInput,
+		live:        make(map[string]*liveTerminal),
+	}
+}
+
+// Start records a new session, opening until the agent's first output. The
+// returned channel carries the terminal's output and is closed when the
+// session ends.
+func (s *TerminalServiceImpl) Start(ctx context.Context, session *models.TerminalSession) (<-chan []byte, error) {
+	session.ID = uuid.New().String()
+	session.Status = models.TerminalOpening
+	session.StartedAt = time.Now()
+	if err := s.repo.Create(ctx, session); err != nil {
+		return nil, err
+	}
+
+	lt := &liveTerminal{
+		session:   session,
+		output:    make(chan []byte, terminalOutputBuffer),
+		flushedAt: session.StartedAt,
+	}
+	s.mu.Lock()
+	s.live[session.ID] = lt
+	s.mu.Unlock()
+
+	time.AfterFunc(terminalOpenTimeout, func() {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		if lt.session.Status == models.TerminalOpening && s.live[session.ID] == lt {
+			s.end(context.Background(), lt, "the agent did not start the terminal; it may be disabled or not supported", nil)
+		}
+	})
+	return lt.output, nil
+}
+
+// Output records and passes on what the agent's terminal showed. It reports
+// whether the session is still open; the agent should close it otherwise.
+func (s *TerminalServiceImpl) Output(ctx context.Context, sessionID, agentID string, data []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lt := s.live[sessionID]
+	if lt == nil || lt.session.AgentID != agentID {
+		return false
+	}
+	if lt.session.Status == models.TerminalOpening {
+		lt.session.Status = models.TerminalOpen
+		if err := s.repo.Update(ctx, lt.session); err != nil {
+			log.Printf("Failed to update terminal session %s: %v", sessionID, err)
+		}
+	}
+	if len(data) == 0 {
+		return true
+	}
+
+	text := append(lt.partial, data...)
+	cut := completeUTF8(text)
+	lt.partial = append([]byte(nil), text[cut:]...)
+	if cut > 0 {
+		lt.record("o", string(text[:cut]))
+	}
+	if err := s.flush(ctx, lt, false); err != nil {
+		s.end(ctx, lt, err.Error(), nil)
+		return false
+	}
+
+	select {
+	case lt.output <- data:
+	default:
+		s.end(ctx, lt, "the browser could not keep up with the output", nil)
+		return false
+	}
+	return true
+}
+
+// Input records what the browser sent to the terminal. It reports whether
+// the session is still open; the input must not be passed on otherwise.
+func (s *TerminalServiceImpl) Input(ctx context.Context, sessionID string, data []byte) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lt := s.live[sessionID]
+	if lt == nil {
+		return false
+	}
+	if !s.recordInput || len(data) == 0 {
+		return true
+	}
+	lt.record("i", string(data))
+	if err := s.flush(ctx, lt, false); err != nil {
+		s.end(ctx, lt, err.Error(), nil)
+		return false
+	}
+	return true
+}
+
+// Resize records the browser's new terminal size.
+func (s *TerminalServiceImpl) Resize(ctx context.Context, sessionID string, width, height int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if lt := s.live[sessionID]; lt != nil {
+		lt.record("r", fmt.Sprintf("%dx%d", width, height))
+	}
+}
+
+// Exit ends the session once the agent's shell exited. An exit without an
+// error is the shell's own exit code.
+func (s *TerminalServiceImpl) Exit(ctx context.Context, sessionID, agentID string, exitCode int, errMsg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lt := s.live[sessionID]
+	if lt == nil || lt.session.AgentID != agentID {
+		return
+	}
+	if errMsg != "" {
+		s.end(ctx, lt, errMsg, nil)
+		return
+	}
+	s.end(ctx, lt, "exited", &exitCode)
+}
+
+// End closes the session for the given reason. It reports whether the
+// session was open.
+func (s *TerminalServiceImpl) End(ctx context.Context, sessionID, reason string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	lt := s.live[sessionID]
+	if lt == nil {
+		return false
+	}
+	s.end(ctx, lt, reason, nil)
+	return true
+}
+
+// end writes the rest of the recording and closes the session. The caller
+// holds s.mu.
+func (s *TerminalServiceImpl) end(ctx context.Context, lt *liveTerminal, reason string, exitCode *int) {
+	delete(s.live, lt.session.ID)
+	close(lt.output)
+
+	if len(lt.partial) > 0 {
+		lt.record("o", string(lt.partial))
+		lt.partial = nil
+	}
+	if err := s.flush(ctx, lt, true); err != nil {
+		log.Printf("Failed to write the recording of terminal session %s: %v", lt.session.ID, err)
+	}
+
+	now := time.Now()
+	lt.session.Status = models.TerminalClosed
+	lt.session.Reason = reason
+	lt.session.ExitCode = exitCode
+	lt.session.EndedAt = &now
+	if err := s.repo.Update(ctx, lt.session); err != nil {
+		log.Printf("Failed to update terminal session %s: %v", lt.session.ID, err)
+	}
+}
+
+// flush writes the recorded events when enough are waiting, or always if
+// force is set. A recording that cannot be written or grew too large is an
+// error; the session must not go on unrecorded.
+func (s *TerminalServiceImpl) flush(ctx context.Context, lt *liveTerminal, force bool) error {
+	if lt.recording.Len() == 0 {
+		return nil
+	}
+	if !force && lt.recording.Len() < terminalFlushBytes && time.Since(lt.flushedAt) < terminalFlushInterval {
+		return nil
+	}
+	if lt.session.RecordingBytes+int64(lt.recording.Len()) > models.MaxTerminalRecording {
+		lt.recording.Reset()
+		return fmt.Errorf("the recording reached its limit of %d MiB", models.MaxTerminalRecording>>20)
+	}
+
+	data := lt.recording.Bytes()
+	if err := s.repo.AppendRecording(ctx, lt.session.ID, lt.seq, data); err != nil {
+		return fmt.Errorf("the recording could not be written: %w", err)
+	}
+	lt.seq++
+	lt.session.RecordingBytes += int64(len(data))
+	lt.recording.Reset()
+	lt.flushedAt = time.Now()
+	return nil
+}
+
+// AgentDisconnected closes the agent's sessions; it stops their shells
+// itself.
+func (s *TerminalServiceImpl) AgentDisconnected(ctx context.Context, agentID string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, lt := range s.live {
+		if lt.session.AgentID == agentID {
+			s.end(ctx, lt, "agent disconnected", nil)
+		}
+	}
+}
+
+// AbortUnfinished closes the sessions left open from before core restarted.
+func (s *TerminalServiceImpl) AbortUnfinished(ctx context.Context) error {
+	return s.repo.AbortUnfinished(ctx, "core restarted")
+}
+
+func (s *TerminalServiceImpl) GetByID(ctx context.Context, sessionID string) (*models.TerminalSession, error) {
+	s.mu.Lock()
+	if lt := s.live[sessionID]; lt != nil {
+		session := *lt.session
+		s.mu.Unlock()
+		return &session, nil
+	}
+	s.mu.Unlock()
+	return s.repo.GetByID(ctx, sessionID)
+}
+
+func (s *TerminalServiceImpl) List(ctx context.Context, agentID string, limit int) ([]*models.TerminalSession, error) {
+	return s.repo.List(ctx, agentID, limit)
+}
+
+// WriteRecording writes the session's recording to w as an asciicast v2
+// file, as far as it was written yet.
+func (s *TerminalServiceImpl) WriteRecording(ctx context.Context, sessionID string, w io.Writer) error {
+	session, err := s.GetByID(ctx, sessionID)
+	if err != nil {
+		return err
+	}
+	if session == nil {
+		return ErrTerminalNotFound
+	}
+
+	header, _ := json.Marshal(map[string]interface{}{
+		"version":   2,
+		"width":     session.Width,
+		"height":    session.Height,
+		"timestamp": session.StartedAt.Unix(),
+		"title":     fmt.Sprintf("%s on %s", session.Username, session.AgentID),
+		"env":       map[string]string{"TERM": "xterm-256color"},
+	})
+	if _, err := w.Write(append(header, '\n')); err != nil {
+		return err
+	}
+	return s.repo.WriteRecording(ctx, sessionID, w)
+}
+
+// record adds an asciicast event line at the current time.
+func (lt *liveTerminal) record(code, data string) {
+	elapsed := math.Round(time.Since(lt.session.StartedAt).Seconds()*1e6) / 1e6
+	line, _ := json.Marshal([]interface{}{elapsed, code, data})
+	lt.recording.Write(line)
+	lt.recording.WriteByte('\n')
+}
+
+// completeUTF8 returns the length of b without a trailing incomplete UTF-8
+// sequence.
+func completeUTF8(b []byte) int {
+	for i := len(b) - 1; i >= 0 && i >= len(b)-utf8.UTFMax; i-- {
+		if utf8.RuneStart(b[i]) {
+			if !utf8.FullRune(b[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return len(b)
+}
@@ -0,0 +1,652 @@
+package service
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/repository"
+)
+
+var ErrInvalidTransfer = errors.New("invalid transfer request")
+
+// importBatchSize is the number of rows written per transaction on import.
+const importBatchSize = 500
+
+var (
+	metricsColumns     = []string{"id", "agent_id", "timestamp", "cpu", "memory", "disks", "network", "cpu_cores", "swap", "load", "host", "disk_io", "sockets"}
+	trafficColumns     = []string{"id", "cycle_id", "agent_id", "timestamp", "bytes_sent", "bytes_recv"}
+	taskResultsColumns = []string{"id", "task_id", "agent_id", "timestamp", "success", "output", "error", "duration_ms", "ping", "http", "tls", "dns", "traceroute"}
+)
+
+type TransferServiceImpl struct {
+	agentRepo   *repository.AgentRepository
+	metricsRepo *repository.MetricsRepository
+	trafficRepo *repository.TrafficRepository
+	taskRepo    *repository.TaskRepository
+}
+
+func NewTransferService(
+	agentRepo *repository.AgentRepository,
+	metricsRepo *repository.MetricsRepository,
+	trafficRepo *repository.TrafficRepository,
+	taskRepo *repository.TaskRepository,
+) *TransferServiceImpl {
+	return &TransferServiceImpl{
+		agentRepo:   agentRepo,
+		metricsRepo: metricsRepo,
+		trafficRepo: trafficRepo,
+		taskRepo:    taskRepo,
+	}
+}
+
+func (s *TransferServiceImpl) Export(ctx context.Context, dataset models.Dataset, format models.TransferFormat, filter *models.ExportFilter, w io.Writer) error {
+	var columns []string
+	switch dataset {
+	case models.DatasetMetrics:
+		columns = metricsColumns
+	case models.DatasetTraffic:
+		columns = trafficColumns
+	case models.DatasetTaskResults:
+		columns = taskResultsColumns
+	default:
+		return fmt.Errorf("%w: unknown dataset %q", ErrInvalidTransfer, dataset)
+	}
+
+	rw, err := newRowWriter(format, w, columns)
+	if err != nil {
+		return err
+	}
+
+	switch dataset {
+	case models.DatasetMetrics:
+		err = s.metricsRepo.Iterate(ctx, filter, func(m *models.Metrics) error {
+			return rw.write(m, func() []string { return metricsRow(m) })
+		})
+	case models.DatasetTraffic:
+		err = s.trafficRepo.IterateRecords(ctx, filter, func(r *models.TrafficRecord) error {
+			return rw.write(r, func() []string { return trafficRow(r) })
+		})
+	case models.DatasetTaskResults:
+		err = s.taskRepo.IterateResults(ctx, filter, func(r *models.TaskResult) error {
+			return rw.write(r, func() []string { return taskResultRow(r) })
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	return rw.flush()
+}
+
+// Import restores exported rows. Rows are re-keyed through opts and skipped
+// when their agent (or task, for task results) does not exist here, so an
+// import never leaves dangling history behind. Rows already present are
+// skipped as well, which makes re-running an import harmless.
+func (s *TransferServiceImpl) Import(ctx context.Context, dataset models.Dataset, format models.TransferFormat, r io.Reader, opts *models.ImportOptions) (*models.ImportResult, error) {
+	if opts == nil {
+		opts = &models.ImportOptions{}
+	}
+
+	rr, err := newRowReader(format, r)
+	if err != nil {
+		return nil, err
+	}
+
+	agents, err := s.agentRepo.List(ctx, nil)
+	if err != nil {
+		return nil, err
+	}
+	knownAgents := make(map[string]bool, len(agents))
+	for _, a := range agents {
+		knownAgents[a.ID] = true
+	}
+	mapAgent := func(id string) (string, bool) {
+		if mapped, ok := opts.AgentMap[id]; ok {
+			id = mapped
+		}
+		return id, knownAgents[id]
+	}
+
+	switch dataset {
+	case models.DatasetMetrics:
+		return s.importMetrics(ctx, rr, mapAgent)
+	case models.DatasetTraffic:
+		return s.importTraffic(ctx, rr, mapAgent)
+	case models.DatasetTaskResults:
+		return s.importTaskResults(ctx, rr, mapAgent, opts.TaskMap)
+	default:
+		return nil, fmt.Errorf("%w: unknown dataset %q", ErrInvalidTransfer, dataset)
+	}
+}
+
+func (s *TransferServiceImpl) importMetrics(ctx context.Context, rr *rowReader, mapAgent func(string) (string, bool)) (*models.ImportResult, error) {
+	result := &models.ImportResult{}
+	batch := []*models.Metrics{}
+
+	flush := func() error {
+		n, err := s.metricsRepo.Import(ctx, batch)
+		if err != nil {
+			return err
+		}
+		result.Imported += int(n)
+		result.Skipped += len(batch) - int(n)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		m := &models.Metrics{}
+		row, err := rr.next(m)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			if err := parseMetricsRow(row, m); err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidTransfer, rr.line, err)
+			}
+		}
+
+		agentID, ok := mapAgent(m.AgentID)
+		if !ok || m.ID == "" {
+			result.Skipped++
+			continue
+		}
+		m.AgentID = agentID
+
+		batch = append(batch, m)
+		if len(batch) >= importBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// importTraffic adds records of the agent's current billing cycle to it, and
+// the totals of older records to the archive of the earlier cycle they fall
+// in, so that they do not inflate current usage. Where no archive covers a
+// record one is created for its period of the cycle's schedule, and an agent
+// without a cycle gets the default one first.
+func (s *TransferServiceImpl) importTraffic(ctx context.Context, rr *rowReader, mapAgent func(string) (string, bool)) (*models.ImportResult, error) {
+	result := &models.ImportResult{}
+	batch := []*models.TrafficRecord{}
+	archived := make(map[*models.TrafficArchive][]*models.TrafficRecord)
+	archivedCount := 0
+	histories := make(map[string]*trafficHistory)
+
+	flush := func() error {
+		n, err := s.trafficRepo.ImportRecords(ctx, batch)
+		if err != nil {
+			return err
+		}
+		result.Imported += int(n)
+		result.Skipped += len(batch) - int(n)
+		batch = batch[:0]
+
+		for archive, records := range archived {
+			n, err := s.trafficRepo.ImportArchivedRecords(ctx, archive, records)
+			if err != nil {
+				return err
+			}
+			result.Imported += int(n)
+			result.Skipped += len(records) - int(n)
+		}
+		archived = make(map[*models.TrafficArchive][]*models.TrafficRecord)
+		archivedCount = 0
+		return nil
+	}
+
+	for {
+		record := &models.TrafficRecord{}
+		row, err := rr.next(record)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			if err := parseTrafficRow(row, record); err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidTransfer, rr.line, err)
+			}
+		}
+
+		agentID, ok := mapAgent(record.AgentID)
+		if !ok || record.ID == "" {
+			result.Skipped++
+			continue
+		}
+		if record.Timestamp.IsZero() {
+			return nil, fmt.Errorf("%w: line %d: record %s has no timestamp", ErrInvalidTransfer, rr.line, record.ID)
+		}
+
+		history, cached := histories[agentID]
+		if !cached {
+			history, err = s.trafficHistory(ctx, agentID)
+			if err != nil {
+				return nil, err
+			}
+			histories[agentID] = history
+		}
+
+		record.AgentID = agentID
+		record.CycleID = history.cycle.ID
+
+		if record.Timestamp.Before(history.cycle.StartDate) {
+			archive := history.archiveFor(record.Timestamp)
+			archived[archive] = append(archived[archive], record)
+			archivedCount++
+		} else {
+			batch = append(batch, record)
+		}
+		if len(batch)+archivedCount >= importBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// trafficHistory is an agent's billing cycle and its archives, including
+// those created by the running import.
+type trafficHistory struct {
+	cycle    *models.BillingCycle
+	archives []*models.TrafficArchive
+}
+
+func (s *TransferServiceImpl) trafficHistory(ctx context.Context, agentID string) (*trafficHistory, error) {
+	cycle, err := s.trafficRepo.GetCycleByAgent(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	if cycle == nil {
+		// The default cycle, as created for the agent's first traffic.
+		cycle = &models.BillingCycle{
+			ID:        uuid.New().String(),
+			AgentID:   agentID,
+			StartDate: time.Now(),
+			Duration:  30,
+			CreatedAt: time.Now(),
+		}
+		if err := s.trafficRepo.CreateCycle(ctx, cycle); err != nil {
+			return nil, err
+		}
+	}
+	if cycle.Duration <= 0 {
+		return nil, fmt.Errorf("%w: the billing cycle of agent %s is %d days long, so older traffic cannot be placed in an earlier cycle",
+			ErrInvalidTransfer, agentID, cycle.Duration)
+	}
+
+	archives, err := s.trafficRepo.ListArchives(ctx, agentID)
+	if err != nil {
+		return nil, err
+	}
+	return &trafficHistory{cycle: cycle, archives: archives}, nil
+}
+
+// archiveFor returns the archive t falls in. Without one, it adds an archive
+// for the earlier cycle around t, going back from the current cycle's start
+// by its duration, shortened so that it does not overlap existing archives.
+func (h *trafficHistory) archiveFor(t time.Time) *models.TrafficArchive {
+	for _, a := range h.archives {
+		if !t.Before(a.CycleStart) && t.Before(a.CycleEnd) {
+			return a
+		}
+	}
+
+	end := h.cycle.StartDate
+	start := end.AddDate(0, 0, -h.cycle.Duration)
+	for t.Before(start) {
+		end = start
+		start = end.AddDate(0, 0, -h.cycle.Duration)
+	}
+	for _, a := range h.archives {
+		if a.CycleEnd.After(start) && !a.CycleEnd.After(t) {
+			start = a.CycleEnd
+		}
+		if a.CycleStart.Before(end) && a.CycleStart.After(t) {
+			end = a.CycleStart
+		}
+	}
+
+	archive := &models.TrafficArchive{
+		ID:         uuid.New().String(),
+		CycleID:    h.cycle.ID,
+		AgentID:    h.cycle.AgentID,
+		CycleStart: start,
+		CycleEnd:   end,
+		Limit:      h.cycle.Limit,
+		CreatedAt:  time.Now(),
+	}
+	h.archives = append(h.archives, archive)
+	return archive
+}
+
+func (s *TransferServiceImpl) importTaskResults(ctx context.Context, rr *rowReader, mapAgent func(string) (string, bool), taskMap map[string]string) (*models.ImportResult, error) {
+	result := &models.ImportResult{}
+	batch := []*models.TaskResult{}
+	knownTasks := make(map[string]bool)
+
+	flush := func() error {
+		n, err := s.taskRepo.ImportResults(ctx, batch)
+		if err != nil {
+			return err
+		}
+		result.Imported += int(n)
+		result.Skipped += len(batch) - int(n)
+		batch = batch[:0]
+		return nil
+	}
+
+	for {
+		taskResult := &models.TaskResult{}
+		row, err := rr.next(taskResult)
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if row != nil {
+			if err := parseTaskResultRow(row, taskResult); err != nil {
+				return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidTransfer, rr.line, err)
+			}
+		}
+
+		agentID, ok := mapAgent(taskResult.AgentID)
+		if !ok || taskResult.ID == "" {
+			result.Skipped++
+			continue
+		}
+
+		taskID := taskResult.TaskID
+		if mapped, ok := taskMap[taskID]; ok {
+			taskID = mapped
+		}
+		exists, cached := knownTasks[taskID]
+		if !cached {
+			task, err := s.taskRepo.GetByID(ctx, taskID)
+			if err != nil {
+				return nil, err
+			}
+			exists = task != nil
+			knownTasks[taskID] = exists
+		}
+		if !exists {
+			result.Skipped++
+			continue
+		}
+
+		taskResult.AgentID = agentID
+		taskResult.TaskID = taskID
+
+		batch = append(batch, taskResult)
+		if len(batch) >= importBatchSize {
+			if err := flush(); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	if err := flush(); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// rowWriter writes records either as CSV rows or as one JSON object per line.
+type rowWriter struct {
+	csv  *csv.Writer
+	json *json.Encoder
+}
+
+func newRowWriter(format models.TransferFormat, w io.Writer, columns []string) (*rowWriter, error) {
+	switch format {
+	case models.TransferFormatCSV:
+		cw := csv.NewWriter(w)
+		if err := cw.Write(columns); err != nil {
+			return nil, err
+		}
+		return &rowWriter{csv: cw}, nil
+	case models.TransferFormatNDJSON:
+		return &rowWriter{json: json.NewEncoder(w)}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidTransfer, format)
+	}
+}
+
+func (w *rowWriter) write(v interface{}, row func() []string) error {
+	if w.csv != nil {
+		return w.csv.Write(row())
+	}
+	return w.json.Encode(v)
+}
+
+func (w *rowWriter) flush() error {
+	if w.csv != nil {
+		w.csv.Flush()
+		return w.csv.Error()
+	}
+	return nil
+}
+
+// rowReader is the counterpart of rowWriter. NDJSON records are decoded
+// straight into the target value; CSV rows are returned keyed by column name
+// for the caller to parse.
+type rowReader struct {
+	csv    *csv.Reader
+	json   *json.Decoder
+	header []string
+	line   int
+}
+
+func newRowReader(format models.TransferFormat, r io.Reader) (*rowReader, error) {
+	switch format {
+	case models.TransferFormatCSV:
+		cr := csv.NewReader(r)
+		header, err := cr.Read()
+		if err != nil {
+			return nil, fmt.Errorf("%w: missing CSV header", ErrInvalidTransfer)
+		}
+		return &rowReader{csv: cr, header: header, line: 1}, nil
+	case models.TransferFormatNDJSON:
+		return &rowReader{json: json.NewDecoder(r)}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown format %q", ErrInvalidTransfer, format)
+	}
+}
+
+func (r *rowReader) next(v interface{}) (map[string]string, error) {
+	r.line++
+
+	if r.json != nil {
+		if err := r.json.Decode(v); err != nil {
+			if err == io.EOF {
+				return nil, err
+			}
+			return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidTransfer, r.line, err)
+		}
+		return nil, nil
+	}
+
+	record, err := r.csv.Read()
+	if err != nil {
+		if err == io.EOF {
+			return nil, err
+		}
+		return nil, fmt.Errorf("%w: line %d: %v", ErrInvalidTransfer, r.line, err)
+	}
+
+	row := make(map[string]string, len(r.header))
+	for i, col := range r.header {
+		if i < len(record) {
+			row[col] = record[i]
+		}
+	}
+	return row, nil
+}
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339Nano)
+}
+
+func metricsRow(m *models.Metrics) []string {
+	memoryJSON, _ := json.Marshal(m.Memory)
+	disksJSON, _ := json.Marshal(m.Disks)
+	networkJSON, _ := json.Marshal(m.Network)
+	cpuCoresJSON, _ := json.Marshal(m.CPUCores)
+	swapJSON, _ := json.Marshal(m.Swap)
+	loadJSON, _ := json.Marshal(m.Load)
+	hostJSON, _ := json.Marshal(m.Host)
+	diskIOJSON, _ := json.Marshal(m.DiskIO)
+	socketsJSON, _ := json.Marshal(m.Sockets)
+	return []string{
+		m.ID, m.AgentID, formatTime(m.Timestamp),
+		strconv.FormatFloat(m.CPU, 'f', -1, 64),
+		string(memoryJSON), string(disksJSON), string(networkJSON),
+		string(cpuCoresJSON), string(swapJSON), string(loadJSON), string(hostJSON),
+		string(diskIOJSON), string(socketsJSON),
+	}
+}
+
+func parseMetricsRow(row map[string]string, m *models.Metrics) error {
+	var err error
+	m.ID = row["id"]
+	m.AgentID = row["agent_id"]
+	if m.Timestamp, err = time.Parse(time.RFC3339Nano, row["timestamp"]); err != nil {
+		return fmt.Errorf("timestamp: %v", err)
+	}
+	if m.CPU, err = strconv.ParseFloat(row["cpu"], 64); err != nil {
+		return fmt.Errorf("cpu: %v", err)
+	}
+	if err := json.Unmarshal([]byte(row["memory"]), &m.Memory); err != nil {
+		return fmt.Errorf("memory: %v", err)
+	}
+	if err := json.Unmarshal([]byte(row["disks"]), &m.Disks); err != nil {
+		return fmt.Errorf("disks: %v", err)
+	}
+	if err := json.Unmarshal([]byte(row["network"]), &m.Network); err != nil {
+		return fmt.Errorf("network: %v", err)
+	}
+	// Host metric columns were added later; older exports omit them.
+	optional := []struct {
+		column string
+		dest   interface{}
+	}{
+		{"cpu_cores", &m.CPUCores},
+		{"swap", &m.Swap},
+		{"load", &m.Load},
+		{"host", &m.Host},
+		{"disk_io", &m.DiskIO},
+		{"sockets", &m.Sockets},
+	}
+	for _, o := range optional {
+		if row[o.column] == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(row[o.column]), o.dest); err != nil {
+			return fmt.Errorf("%s: %v", o.column, err)
+		}
+	}
+	return nil
+}
+
+func trafficRow(r *models.TrafficRecord) []string {
+	return []string{
+		r.ID, r.CycleID, r.AgentID, formatTime(r.Timestamp),
+		strconv.FormatUint(r.BytesSent, 10),
+		strconv.FormatUint(r.BytesRecv, 10),
+	}
+}
+
+func parseTrafficRow(row map[string]string, r *models.TrafficRecord) error {
+	var err error
+	r.ID = row["id"]
+	r.CycleID = row["cycle_id"]
+	r.AgentID = row["agent_id"]
+	if r.Timestamp, err = time.Parse(time.RFC3339Nano, row["timestamp"]); err != nil {
+		return fmt.Errorf("timestamp: %v", err)
+	}
+	if r.BytesSent, err = strconv.ParseUint(row["bytes_sent"], 10, 64); err != nil {
+		return fmt.Errorf("bytes_sent: %v", err)
+	}
+	if r.BytesRecv, err = strconv.ParseUint(row["bytes_recv"], 10, 64); err != nil {
+		return fmt.Errorf("bytes_recv: %v", err)
+	}
+	return nil
+}
+
+func taskResultRow(r *models.TaskResult) []string {
+	detail := func(v interface{}) string {
+		data, _ := json.Marshal(v)
+		if string(data) == "null" {
+			return ""
+		}
+		return string(data)
+	}
+	return []string{
+		r.ID, r.TaskID, r.AgentID, formatTime(r.Timestamp),
+		strconv.FormatBool(r.Success), r.Output, r.Error,
+		strconv.FormatInt(r.Duration, 10), detail(r.Ping), detail(r.HTTP), detail(r.TLS), detail(r.DNS),
+		detail(r.Traceroute),
+	}
+}
+
+func parseTaskResultRow(row map[string]string, r *models.TaskResult) error {
+	var err error
+	r.ID = row["id"]
+	r.TaskID = row["task_id"]
+	r.AgentID = row["agent_id"]
+	r.Output = row["output"]
+	r.Error = row["error"]
+	if r.Timestamp, err = time.Parse(time.RFC3339Nano, row["timestamp"]); err != nil {
+		return fmt.Errorf("timestamp: %v", err)
+	}
+	if r.Success, err = strconv.ParseBool(row["success"]); err != nil {
+		return fmt.Errorf("success: %v", err)
+	}
+	if r.Duration, err = strconv.ParseInt(row["duration_ms"], 10, 64); err != nil {
+		return fmt.Errorf("duration_ms: %v", err)
+	}
+	// Structured result columns were added later; older exports omit them.
+	details := []struct {
+		column string
+		dest   interface{}
+	}{
+		{"ping", &r.Ping},
+		{"http", &r.HTTP},
+		{"tls", &r.TLS},
+		{"dns", &r.DNS},
+		{"traceroute", &r.Traceroute},
+	}
+	for _, d := range details {
+		if row[d.column] == "" {
+			continue
+		}
+		if err := json.Unmarshal([]byte(row[d.column]), d.dest); err != nil {
+			return fmt.Errorf("%s: %v", d.column, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,137 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/probe-system/core/internal/models"
+	"github.com/probe-system/core/internal/repository"
+)
+
+var ErrInvalidWatch = errors.New("invalid watch")
+
+// WatchServiceImpl manages the watch list and keeps the latest status each
+// agent reported for it in memory.
+type WatchServiceImpl struct {
+	repo *repository.WatchRepository
+
+	mu       sync.RWMutex
+	statuses map[string][]*models.WatchStatus
+}
+
+func NewWatchService(repo *repository.WatchRepository) *WatchServiceImpl {
+	return &WatchServiceImpl{
+		repo:     repo,
+		statuses: make(map[string][]*models.WatchStatus),
+	}
+}
+
+func (s *WatchServiceImpl) Create(ctx context.Context, watch *models.Watch) error {
+	if err := validateWatch(watch); err != nil {
+		return err
+	}
+	watch.ID = uuid.New().String()
+	watch.CreatedAt = time.Now()
+	watch.UpdatedAt = watch.CreatedAt
+	return s.repo.Create(ctx, watch)
+}
+
+func (s *WatchServiceImpl) Update(ctx context.Context, watch *models.Watch) error {
+	if err := validateWatch(watch); err != nil {
+		return err
+	}
+	watch.UpdatedAt = time.Now()
+	return s.repo.Update(ctx, watch)
+}
+
+func (s *WatchServiceImpl) Delete(ctx context.Context, id string) error {
+	return s.repo.Delete(ctx, id)
+}
+
+func (s *WatchServiceImpl) List(ctx context.Context) ([]*models.Watch, error) {
+	return s.repo.List(ctx)
+}
+
+// ForAgent returns the watches that apply to an agent.
+func (s *WatchServiceImpl) ForAgent(ctx context.Context, agentID string) ([]*models.Watch, error) {
+	watches, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result := []*models.Watch{}
+	for _, w := range watches {
+		if w.AppliesTo(agentID) {
+			result = append(result, w)
+		}
+	}
+	return result, nil
+}
+
+// Record replaces the agent's statuses with a new report, filling in the
+// watch details. Results for watches that were deleted meanwhile are dropped.
+func (s *WatchServiceImpl) Record(ctx context.Context, agentID string, results []*models.WatchStatus) ([]*models.WatchStatus, error) {
+	watches, err := s.repo.List(ctx)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*models.Watch, len(watches))
+	for _, w := range watches {
+		byID[w.ID] = w
+	}
+
+	now := time.Now()
+	statuses := []*models.WatchStatus{}
+	for _, r := range results {
+		w, ok := byID[r.WatchID]
+		if !ok {
+			continue
+		}
+		r.Name, r.Type, r.Target, r.CheckedAt = w.Name, w.Type, w.Target, now
+		statuses = append(statuses, r)
+	}
+
+	s.mu.Lock()
+	s.statuses[agentID] = statuses
+	s.mu.Unlock()
+
+	return statuses, nil
+}
+
+func (s *WatchServiceImpl) Status(agentID string) []*models.WatchStatus {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if statuses, ok := s.statuses[agentID]; ok {
+		return statuses
+	}
+	return []*models.WatchStatus{}
+}
+
+func validateWatch(watch *models.Watch) error {
+	watch.Target = strings.TrimSpace(watch.Target)
+	if watch.Target == "" {
+		return fmt.Errorf("%w: target is required", ErrInvalidWatch)
+	}
+
+	switch watch.Type {
+	case models.WatchTypeProcess:
+	case models.WatchTypePort:
+		port, err := strconv.Atoi(watch.Target)
+		if err != nil || port < 1 || port > 65535 {
+			return fmt.Errorf("%w: port must be between 1 and 65535", ErrInvalidWatch)
+		}
+	default:
+		return fmt.Errorf("%w: unknown type %q", ErrInvalidWatch, watch.Type)
+	}
+
+	if watch.Name == "" {
+		watch.Name = watch.Target
+	}
+	return nil
+}
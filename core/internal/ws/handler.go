@@ -3,9 +3,11 @@ package ws
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
 	"strings"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/gorilla/websocket"
@@ -14,6 +16,15 @@ import (
 	"github.com/probe-system/core/pkg/protocol"
 )
 
+var ErrAgentNotConnected = errors.New("agent not connected")
+
+// An assignment the agent has not acknowledged is sent again after
+// deliveryRetry, doubling with each attempt up to maxDeliveryRetry.
+const (
+	deliveryRetry    = 30 * time.Second
+	maxDeliveryRetry = 10 * time.Minute
+)
+
 type Handler struct {
 	hub          *Hub
 	upgrader     websocket.Upgrader
@@ -23,6 +34,12 @@ type Handler struct {
 	trafficSvc   service.TrafficService
 	taskSvc      service.TaskService
 	alertSvc     service.AlertService
+	processSvc   service.ProcessService
+	containerSvc service.ContainerService
+	watchSvc     service.WatchService
+	customSvc    service.CustomMetricService
+	commandSvc   service.CommandService
+	terminalSvc  service.TerminalService
 }
 
 func NewHandler(hub *Hub, agentToken string) *Handler {
@@ -50,12 +67,24 @@ func (h *Handler) SetServices(
 	trafficSvc service.TrafficService,
 	taskSvc service.TaskService,
 	alertSvc service.AlertService,
+	processSvc service.ProcessService,
+	containerSvc service.ContainerService,
+	watchSvc service.WatchService,
+	customSvc service.CustomMetricService,
+	commandSvc service.CommandService,
+	terminalSvc service.TerminalService,
 ) {
 	h.agentSvc = agentSvc
 	h.metricSvc = metricSvc
 	h.trafficSvc = trafficSvc
 	h.taskSvc = taskSvc
 	h.alertSvc = alertSvc
+	h.processSvc = processSvc
+	h.containerSvc = containerSvc
+	h.watchSvc = watchSvc
+	h.customSvc = customSvc
+	h.commandSvc = commandSvc
+	h.terminalSvc = terminalSvc
 }
 
 func (h *Handler) ServeWS(w http.ResponseWriter, r *http.Request) {
@@ -150,6 +179,9 @@ func (h *Handler) ServeWS(w http.ResponseWriter, r *http.Request) {
 
 	// Send pending tasks
 	h.sendPendingTasks(agent.ID)
+	if err := h.sendWatches(ctx, agent.ID); err != nil {
+		log.Printf("Failed to send watches to %s: %v", agent.ID, err)
+	}
 }
 
 func (h *Handler) handleMessage(agentID string, msg *protocol.Message) {
@@ -166,24 +198,61 @@ func (h *Handler) handleMessage(agentID string, msg *protocol.Message) {
 		}
 
 		metrics := &models.Metrics{
-			CPU: payload.CPU,
+			CPU:      payload.CPU,
+			CPUCores: payload.CPUCores,
 			Memory: models.MemoryStats{
 				Total:     payload.Memory.Total,
 				Used:      payload.Memory.Used,
 				Available: payload.Memory.Available,
 				Percent:   payload.Memory.Percent,
 			},
+			Swap: models.SwapStats{
+				Total:   payload.Swap.Total,
+				Used:    payload.Swap.Used,
+				Free:    payload.Swap.Free,
+				Percent: payload.Swap.Percent,
+			},
+			Load: models.LoadStats{
+				Load1:  payload.Load.Load1,
+				Load5:  payload.Load.Load5,
+				Load15: payload.Load.Load15,
+			},
 			Network: models.NetworkStats{
 				BytesSent:     payload.Network.BytesSent,
 				BytesRecv:     payload.Network.BytesRecv,
 				BytesSentRate: payload.Network.BytesSentRate,
 				BytesRecvRate: payload.Network.BytesRecvRate,
 			},
+			Host: models.HostStats{
+				Uptime:    payload.Host.Uptime,
+				BootTime:  payload.Host.BootTime,
+				Processes: payload.Host.Processes,
+				Threads:   payload.Host.Threads,
+			},
+			Sockets: models.SocketStats{
+				TCP:      payload.Sockets.TCP,
+				TCPTotal: payload.Sockets.TCPTotal,
+				UDP:      payload.Sockets.UDP,
+				Listen:   payload.Sockets.Listen,
+			},
+		}
+
+		for _, n := range payload.Network.Interfaces {
+			metrics.Network.Interfaces = append(metrics.Network.Interfaces, models.InterfaceStats{
+				Name:          n.Name,
+				BytesSent:     n.BytesSent,
+				BytesRecv:     n.BytesRecv,
+				BytesSentRate: n.BytesSentRate,
+				BytesRecvRate: n.BytesRecvRate,
+				Counted:       n.Counted,
+			})
 		}
 
 		for _, d := range payload.Disks {
 			metrics.Disks = append(metrics.Disks, models.DiskStats{
 				Path:      d.Path,
+				Device:    d.Device,
+				FSType:    d.FSType,
 				Total:     d.Total,
 				Used:      d.Used,
 				Available: d.Available,
@@ -191,6 +260,53 @@ func (h *Handler) handleMessage(agentID string, msg *protocol.Message) {
 			})
 		}
 
+		for _, d := range payload.DiskIO {
+			metrics.DiskIO = append(metrics.DiskIO, models.DiskIOStats{
+				Device:         d.Device,
+				ReadBytesRate:  d.ReadBytesRate,
+				WriteBytesRate: d.WriteBytesRate,
+				ReadIOPS:       d.ReadIOPS,
+				WriteIOPS:      d.WriteIOPS,
+				Util:           d.Util,
+				AwaitMs:        d.AwaitMs,
+			})
+		}
+
+		if payload.Collectors != nil {
+			report := &models.CollectorReport{ReportedAt: time.Now()}
+			for _, c := range payload.Collectors {
+				report.Collectors = append(report.Collectors, models.CollectorStatus{
+					Name:       c.Name,
+					DurationMs: c.DurationMs,
+					Error:      c.Error,
+				})
+			}
+			h.metricSvc.SetCollectorReport(agentID, report)
+		}
+
+		if payload.Processes != nil {
+			h.processSvc.Update(agentID, newProcessSnapshot(payload.Processes))
+		}
+
+		if payload.Containers != nil {
+			containers := newContainers(payload.Containers)
+			if err := h.containerSvc.Sync(ctx, agentID, containers); err != nil {
+				log.Printf("Failed to store containers for %s: %v", agentID, err)
+			} else {
+				metrics.Containers = containers
+			}
+		}
+
+		if payload.Watches != nil {
+			results := make([]*models.WatchStatus, 0, len(payload.Watches))
+			for _, w := range payload.Watches {
+				results = append(results, &models.WatchStatus{WatchID: w.ID, Up: w.Up, Count: w.Count})
+			}
+			if statuses, err := h.watchSvc.Record(ctx, agentID, results); err == nil {
+				metrics.Watches = statuses
+			}
+		}
+
 		h.metricSvc.Store(ctx, agentID, metrics)
 		h.agentSvc.UpdateLastSeen(ctx, agentID)
 
@@ -198,8 +314,86 @@ func (h *Handler) handleMessage(agentID string, msg *protocol.Message) {
 		h.trafficSvc.RecordTraffic(ctx, agentID, payload.Network.BytesSentRate, payload.Network.BytesRecvRate)
 
 		// Check alerts
+		metrics.Custom = h.customSvc.Latest(agentID)
+		metrics.Certificates = h.taskSvc.AgentCertificates(ctx, agentID)
+		metrics.DNSResults = h.taskSvc.AgentDNSResults(ctx, agentID)
 		h.alertSvc.CheckAndTrigger(ctx, agentID, metrics)
 
+	case protocol.MsgTypeProcesses:
+		var payload protocol.ProcessesPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return
+		}
+		h.processSvc.Update(agentID, newProcessSnapshot(&payload))
+
+	case protocol.MsgTypeCustomMetrics:
+		var payload protocol.CustomMetricsPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return
+		}
+		if payload.Error != "" {
+			log.Printf("Plugin %s on agent %s: %s", payload.Plugin, agentID, payload.Error)
+		}
+
+		metrics := make([]*models.CustomMetric, 0, len(payload.Metrics))
+		for _, m := range payload.Metrics {
+			metrics = append(metrics, &models.CustomMetric{Name: m.Name, Labels: m.Labels, Value: m.Value})
+		}
+		if err := h.customSvc.Store(ctx, agentID, payload.Plugin, metrics); err != nil {
+			log.Printf("Failed to store custom metrics from %s: %v", agentID, err)
+		}
+
+	case protocol.MsgTypeCommandOutput:
+		var payload protocol.CommandOutputPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return
+		}
+		lines := make([]models.CommandLine, 0, len(payload.Lines))
+		for _, l := range payload.Lines {
+			lines = append(lines, models.CommandLine{Stream: l.Stream, Text: l.Text})
+		}
+		if err := h.commandSvc.Output(ctx, payload.RunID, agentID, lines); err != nil {
+			log.Printf("Failed to record output of command run %s from %s: %v", payload.RunID, agentID, err)
+		}
+
+	case protocol.MsgTypeCommandExit:
+		var payload protocol.CommandExitPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return
+		}
+		if err := h.commandSvc.Exit(ctx, payload.RunID, agentID, payload.ExitCode, payload.Error, payload.Duration); err != nil {
+			log.Printf("Failed to record exit of command run %s from %s: %v", payload.RunID, agentID, err)
+		}
+
+	case protocol.MsgTypeTerminalOutput:
+		var payload protocol.TerminalDataPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return
+		}
+		// The agent still runs a shell core no longer knows about.
+		if !h.terminalSvc.Output(ctx, payload.SessionID, agentID, payload.Data) {
+			h.sendTerminalClose(agentID, payload.SessionID)
+		}
+
+	case protocol.MsgTypeTerminalExit:
+		var payload protocol.TerminalExitPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return
+		}
+		h.terminalSvc.Exit(ctx, payload.SessionID, agentID, payload.ExitCode, payload.Error)
+
+	case protocol.MsgTypeTaskAck:
+		var payload protocol.TaskAckPayload
+		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
+			return
+		}
+		if !payload.Success {
+			log.Printf("Agent %s rejected task %s: %s", agentID, payload.TaskID, payload.Error)
+		}
+		if err := h.taskSvc.Acknowledge(ctx, payload.TaskID, agentID, payload.Success, payload.Error); err != nil {
+			log.Printf("Failed to record acknowledgement of task %s from %s: %v", payload.TaskID, agentID, err)
+		}
+
 	case protocol.MsgTypeTaskResult:
 		var payload protocol.TaskResultPayload
 		if err := json.Unmarshal(msg.Payload, &payload); err != nil {
@@ -215,35 +409,325 @@ func (h *Handler) handleMessage(agentID string, msg *protocol.Message) {
 			Duration: payload.Duration,
 		}
 
-		h.taskSvc.RecordResult(ctx, result)
+		if p := payload.Ping; p != nil {
+			ping := &models.PingResult{
+				Target:     p.Target,
+				Address:    p.Address,
+				Mode:       p.Mode,
+				Sent:       p.Sent,
+				Received:   p.Received,
+				PacketLoss: p.PacketLoss,
+				Min:        p.Min,
+				Max:        p.Max,
+				Avg:        p.Avg,
+				StdDev:     p.StdDev,
+				Jitter:     p.Jitter,
+			}
+			if ping.Validate() {
+				result.Ping = ping
+			} else {
+				log.Printf("Dropping invalid ping result for task %s from %s", payload.TaskID, agentID)
+			}
+		}
+
+		if p := payload.HTTP; p != nil {
+			result.HTTP = &models.HTTPResult{
+				URL:        p.URL,
+				Method:     p.Method,
+				FinalURL:   p.FinalURL,
+				StatusCode: p.StatusCode,
+				Redirects:  p.Redirects,
+				BodyBytes:  p.BodyBytes,
+				DNSMs:      p.DNSMs,
+				ConnectMs:  p.ConnectMs,
+				TLSMs:      p.TLSMs,
+				TTFBMs:     p.TTFBMs,
+				TotalMs:    p.TotalMs,
+			}
+		}
+
+		if p := payload.TLS; p != nil {
+			result.TLS = &models.TLSResult{
+				Target:        p.Target,
+				ServerName:    p.ServerName,
+				Version:       p.Version,
+				Verified:      p.Verified,
+				VerifyError:   p.VerifyError,
+				NotAfter:      p.NotAfter,
+				DaysRemaining: p.DaysRemaining,
+			}
+			for _, c := range p.Chain {
+				result.TLS.Chain = append(result.TLS.Chain, models.TLSCertificate{
+					Subject:   c.Subject,
+					Issuer:    c.Issuer,
+					SANs:      c.SANs,
+					NotBefore: c.NotBefore,
+					NotAfter:  c.NotAfter,
+				})
+			}
+		}
+
+		if p := payload.DNS; p != nil {
+			result.DNS = &models.DNSResult{
+				Name:       p.Name,
+				RecordType: p.RecordType,
+				Resolver:   p.Resolver,
+				Answers:    p.Answers,
+				ResolveMs:  p.ResolveMs,
+			}
+		}
+
+		if p := payload.Traceroute; p != nil {
+			result.Traceroute = &models.TracerouteResult{
+				Target:   p.Target,
+				Address:  p.Address,
+				Protocol: p.Protocol,
+				Rounds:   p.Rounds,
+				Reached:  p.Reached,
+			}
+			for _, hop := range p.Hops {
+				result.Traceroute.Hops = append(result.Traceroute.Hops, models.TracerouteHop{
+					TTL:       hop.TTL,
+					Address:   hop.Address,
+					Addresses: hop.Addresses,
+					Sent:      hop.Sent,
+					Received:  hop.Received,
+					Loss:      hop.Loss,
+					Last:      hop.Last,
+					Min:       hop.Min,
+					Avg:       hop.Avg,
+					Max:       hop.Max,
+					StdDev:    hop.StdDev,
+				})
+			}
+		}
+
+		if err := h.taskSvc.RecordResult(ctx, result); err != nil {
+			log.Printf("Failed to record result of task %s from %s: %v", payload.TaskID, agentID, err)
+		}
+	}
+}
+
+func newContainers(stats []protocol.ContainerStats) []*models.Container {
+	containers := make([]*models.Container, 0, len(stats))
+	for _, s := range stats {
+		c := &models.Container{
+			ID:            s.ID,
+			Name:          s.Name,
+			Image:         s.Image,
+			Runtime:       s.Runtime,
+			State:         s.State,
+			RestartCount:  s.RestartCount,
+			CPU:           s.CPU,
+			MemoryUsage:   s.MemoryUsage,
+			MemoryLimit:   s.MemoryLimit,
+			MemoryPercent: s.MemoryPercent,
+			NetRx:         s.NetRx,
+			NetTx:         s.NetTx,
+			NetRxRate:     s.NetRxRate,
+			NetTxRate:     s.NetTxRate,
+		}
+		if s.StartedAt > 0 {
+			startedAt := time.Unix(s.StartedAt, 0)
+			c.StartedAt = &startedAt
+		}
+		containers = append(containers, c)
+	}
+	return containers
+}
+
+func newProcessSnapshot(payload *protocol.ProcessesPayload) *models.ProcessSnapshot {
+	convert := func(list []protocol.ProcessInfo) []models.ProcessInfo {
+		result := make([]models.ProcessInfo, 0, len(list))
+		for _, p := range list {
+			result = append(result, models.ProcessInfo{
+				PID:     p.PID,
+				Name:    p.Name,
+				User:    p.User,
+				CPU:     p.CPU,
+				RSS:     p.RSS,
+				Cmdline: p.Cmdline,
+			})
+		}
+		return result
+	}
+
+	return &models.ProcessSnapshot{
+		TopCPU:      convert(payload.TopCPU),
+		TopMemory:   convert(payload.TopMemory),
+		CollectedAt: time.Now(),
+	}
+}
+
+// RequestProcesses asks a connected agent for a fresh top-processes snapshot.
+// The reply arrives asynchronously as a processes message.
+func (h *Handler) RequestProcesses(agentID string, limit int) error {
+	if h.hub.GetAgent(agentID) == nil {
+		return ErrAgentNotConnected
+	}
+
+	msg, err := protocol.NewMessage(protocol.MsgTypeProcessesRequest, uuid.New().String(), protocol.ProcessesRequestPayload{
+		Limit: limit,
+	})
+	if err != nil {
+		return err
 	}
+
+	return h.hub.SendToAgent(agentID, msg)
 }
 
 func (h *Handler) handleDisconnect(agentID string) {
 	ctx := context.Background()
 	h.agentSvc.UpdateStatus(ctx, agentID, models.AgentStatusOffline)
+	h.commandSvc.AgentDisconnected(ctx, agentID)
+	h.terminalSvc.AgentDisconnected(ctx, agentID)
 }
 
+// sendPendingTasks assigns a connecting agent the active tasks that target
+// it, apart from one-time tasks it already ran, and cancels the others in
+// case it still runs them from before its group or tags changed.
 func (h *Handler) sendPendingTasks(agentID string) {
 	ctx := context.Background()
-	tasks, err := h.taskSvc.ListByAgent(ctx, agentID)
+	agent, err := h.agentSvc.GetByID(ctx, agentID)
+	if err != nil || agent == nil {
+		return
+	}
+	tasks, err := h.taskSvc.ListActive(ctx)
 	if err != nil {
 		return
 	}
 
 	for _, task := range tasks {
-		payload := protocol.TaskAssignPayload{
-			TaskID:   task.ID,
-			Type:     string(task.Type),
-			Target:   task.Target,
-			ScriptID: task.ScriptID,
-			Params:   task.Params,
-			Interval: task.Interval,
-			Timeout:  task.Timeout,
+		if task.Targets(agent) {
+			h.AssignTask(agentID, task)
+		} else {
+			h.CancelTask(agentID, task.ID)
 		}
+	}
+}
 
-		msg, _ := protocol.NewMessage(protocol.MsgTypeTaskAssign, uuid.New().String(), payload)
-		h.hub.SendToAgent(agentID, msg)
+// PushTask assigns a new task to the online agents it targets. The others
+// receive it when they connect.
+func (h *Handler) PushTask(ctx context.Context, task *models.Task) {
+	for _, agentID := range h.hub.GetOnlineAgentIDs() {
+		agent, err := h.agentSvc.GetByID(ctx, agentID)
+		if err != nil || agent == nil || !task.Targets(agent) {
+			continue
+		}
+		if err := h.AssignTask(agentID, task); err != nil {
+			log.Printf("Failed to assign task %s to %s: %v", task.ID, agentID, err)
+		}
+	}
+}
+
+// RetargetTasks follows a change of the agent's group or tags from before to
+// after: tasks that now target it are assigned, and tasks that no longer do
+// are canceled on it.
+func (h *Handler) RetargetTasks(ctx context.Context, before, after *models.Agent) {
+	tasks, err := h.taskSvc.ListActive(ctx)
+	if err != nil {
+		log.Printf("Failed to list tasks for %s: %v", after.ID, err)
+		return
+	}
+	for _, task := range tasks {
+		was, is := task.Targets(before), task.Targets(after)
+		switch {
+		case is && !was:
+			h.AssignTask(after.ID, task)
+		case was && !is:
+			h.CancelTask(after.ID, task.ID)
+		}
+	}
+}
+
+// RetryDeliveries sends the assignments online agents have not acknowledged
+// again once they are due. Assignments of tasks that are no longer active or
+// no longer target the agent are canceled instead. Offline agents get their
+// tasks when they connect.
+func (h *Handler) RetryDeliveries(ctx context.Context) error {
+	pending, err := h.taskSvc.PendingDeliveries(ctx)
+	if err != nil || len(pending) == 0 {
+		return err
+	}
+	active, err := h.taskSvc.ListActive(ctx)
+	if err != nil {
+		return err
+	}
+	tasks := make(map[string]*models.Task, len(active))
+	for _, task := range active {
+		tasks[task.ID] = task
+	}
+	online := map[string]bool{}
+	for _, id := range h.hub.GetOnlineAgentIDs() {
+		online[id] = true
+	}
+
+	for _, d := range pending {
+		if !online[d.AgentID] {
+			continue
+		}
+		wait := deliveryRetry
+		for i := 1; i < d.Attempts && wait < maxDeliveryRetry; i++ {
+			wait *= 2
+		}
+		if wait > maxDeliveryRetry {
+			wait = maxDeliveryRetry
+		}
+		if time.Since(d.AssignedAt) < wait {
+			continue
+		}
+
+		task := tasks[d.TaskID]
+		agent, err := h.agentSvc.GetByID(ctx, d.AgentID)
+		if err != nil {
+			return err
+		}
+		if task == nil || agent == nil || !task.Targets(agent) {
+			h.CancelTask(d.AgentID, d.TaskID)
+			continue
+		}
+		log.Printf("Resending task %s to %s (attempt %d)", d.TaskID, d.AgentID, d.Attempts+1)
+		h.AssignTask(d.AgentID, task)
+	}
+	return nil
+}
+
+// StopTask cancels the task on every online agent.
+func (h *Handler) StopTask(taskID string) {
+	for _, agentID := range h.hub.GetOnlineAgentIDs() {
+		h.CancelTask(agentID, taskID)
+	}
+}
+
+// sendWatches sends an agent the complete list of watches that apply to it.
+func (h *Handler) sendWatches(ctx context.Context, agentID string) error {
+	watches, err := h.watchSvc.ForAgent(ctx, agentID)
+	if err != nil {
+		return err
+	}
+
+	payload := protocol.WatchConfigPayload{Watches: []protocol.WatchSpec{}}
+	for _, w := range watches {
+		payload.Watches = append(payload.Watches, protocol.WatchSpec{
+			ID:     w.ID,
+			Type:   string(w.Type),
+			Target: w.Target,
+		})
+	}
+
+	msg, err := protocol.NewMessage(protocol.MsgTypeWatchConfig, uuid.New().String(), payload)
+	if err != nil {
+		return err
+	}
+	return h.hub.SendToAgent(agentID, msg)
+}
+
+// PushWatches resends the watch list to every online agent after it changed.
+func (h *Handler) PushWatches(ctx context.Context) {
+	for _, agentID := range h.hub.GetOnlineAgentIDs() {
+		if err := h.sendWatches(ctx, agentID); err != nil {
+			log.Printf("Failed to send watches to %s: %v", agentID, err)
+		}
 	}
 }
 
@@ -256,6 +740,132 @@ func (h *Handler) sendError(conn *websocket.Conn, message string) {
 	conn.WriteMessage(websocket.TextMessage, data)
 }
 
+// RunCommand sends a new run to its agents. Agents that are offline are done
+// with it right away.
+func (h *Handler) RunCommand(ctx context.Context, run *models.CommandRun, checksum string) {
+	payload := protocol.CommandRunPayload{
+		RunID:    run.ID,
+		Command:  run.Command,
+		ScriptID: run.ScriptID,
+		Checksum: checksum,
+		Params:   run.Params,
+		Timeout:  run.Timeout,
+	}
+	msg, msgErr := protocol.NewMessage(protocol.MsgTypeCommandRun, uuid.New().String(), payload)
+
+	for _, agentID := range run.AgentIDs {
+		err := msgErr
+		if err == nil && h.hub.GetAgent(agentID) == nil {
+			err = ErrAgentNotConnected
+		}
+		if err == nil {
+			err = h.hub.SendToAgent(agentID, msg)
+		}
+		if err != nil {
+			h.commandSvc.Exit(ctx, run.ID, agentID, -1, err.Error(), 0)
+		}
+	}
+}
+
+// CancelCommand stops the run on the agents still running it.
+func (h *Handler) CancelCommand(ctx context.Context, runID string) error {
+	agentIDs, err := h.commandSvc.Cancel(ctx, runID)
+	if err != nil {
+		return err
+	}
+
+	msg, err := protocol.NewMessage(protocol.MsgTypeCommandCancel, uuid.New().String(), protocol.CommandCancelPayload{
+		RunID: runID,
+	})
+	if err != nil {
+		return err
+	}
+	for _, agentID := range agentIDs {
+		h.hub.SendToAgent(agentID, msg)
+	}
+	return nil
+}
+
+// OpenTerminal asks the agent to start a shell for the session. The agent
+// closes it after idleTimeout seconds without input.
+func (h *Handler) OpenTerminal(session *models.TerminalSession, idleTimeout int) error {
+	if h.hub.GetAgent(session.AgentID) == nil {
+		return ErrAgentNotConnected
+	}
+	msg, err := protocol.NewMessage(protocol.MsgTypeTerminalOpen, uuid.New().String(), protocol.TerminalOpenPayload{
+		SessionID:   session.ID,
+		Cols:        session.Width,
+		Rows:        session.Height,
+		IdleTimeout: idleTimeout,
+	})
+	if err != nil {
+		return err
+	}
+	return h.hub.SendToAgent(session.AgentID, msg)
+}
+
+// TerminalInput records the input and passes it to the agent, or stops the
+// agent's shell if the session has ended.
+func (h *Handler) TerminalInput(ctx context.Context, agentID, sessionID string, data []byte) error {
+	if !h.terminalSvc.Input(ctx, sessionID, data) {
+		h.sendTerminalClose(agentID, sessionID)
+		return nil
+	}
+	msg, err := protocol.NewMessage(protocol.MsgTypeTerminalInput, uuid.New().String(), protocol.TerminalDataPayload{
+		SessionID: sessionID,
+		Data:      data,
+	})
+	if err != nil {
+		return err
+	}
+	return h.hub.SendToAgent(agentID, msg)
+}
+
+// ResizeTerminal records the new size and passes it to the agent.
+func (h *Handler) ResizeTerminal(ctx context.Context, agentID, sessionID string, cols, rows int) error {
+	h.terminalSvc.Resize(ctx, sessionID, cols, rows)
+	msg, err := protocol.NewMessage(protocol.MsgTypeTerminalResize, uuid.New().String(), protocol.TerminalResizePayload{
+		SessionID: sessionID,
+		Cols:      cols,
+		Rows:      rows,
+	})
+	if err != nil {
+		return err
+	}
+	return h.hub.SendToAgent(agentID, msg)
+}
+
+// CloseTerminal ends the session and stops the agent's shell.
+func (h *Handler) CloseTerminal(ctx context.Context, agentID, sessionID, reason string) {
+	h.terminalSvc.End(ctx, sessionID, reason)
+	h.sendTerminalClose(agentID, sessionID)
+}
+
+func (h *Handler) sendTerminalClose(agentID, sessionID string) {
+	msg, err := protocol.NewMessage(protocol.MsgTypeTerminalClose, uuid.New().String(), protocol.TerminalClosePayload{
+		SessionID: sessionID,
+	})
+	if err != nil {
+		return
+	}
+	h.hub.SendToAgent(agentID, msg)
+}
+
+func (h *Handler) CancelTask(agentID, taskID string) error {
+	if err := h.taskSvc.CancelDelivery(context.Background(), taskID, agentID); err != nil {
+		log.Printf("Failed to record cancellation of task %s on %s: %v", taskID, agentID, err)
+	}
+
+	msg, err := protocol.NewMessage(protocol.MsgTypeTaskCancel, uuid.New().String(), protocol.TaskCancelPayload{
+		TaskID: taskID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return h.hub.SendToAgent(agentID, msg)
+}
+
 func (h *Handler) AssignTask(agentID string, task *models.Task) error {
 	payload := protocol.TaskAssignPayload{
 		TaskID:   task.ID,
@@ -265,6 +875,9 @@ func (h *Handler) AssignTask(agentID string, task *models.Task) error {
 		Params:   task.Params,
 		Interval: task.Interval,
 		Timeout:  task.Timeout,
+		Schedule: task.Schedule,
+		Timezone: task.Timezone,
+		Jitter:   task.Jitter,
 	}
 
 	msg, err := protocol.NewMessage(protocol.MsgTypeTaskAssign, uuid.New().String(), payload)
@@ -272,5 +885,13 @@ func (h *Handler) AssignTask(agentID string, task *models.Task) error {
 		return err
 	}
 
+	// Recorded before sending, so a quick acknowledgement finds it. A
+	// one-time task the agent is done with is not run again.
+	assigned, err := h.taskSvc.MarkAssigned(context.Background(), task, agentID)
+	if err != nil {
+		log.Printf("Failed to record assignment of task %s to %s: %v", task.ID, agentID, err)
+	} else if !assigned {
+		return nil
+	}
 	return h.hub.SendToAgent(agentID, msg)
 }
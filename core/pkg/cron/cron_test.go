@@ -0,0 +1,140 @@
+package cron
+
+import (
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		spec     string
+		timezone string
+	}{
+		{"", ""},
+		{"* * * *", ""},
+		{"* * * * * *", ""},
+		{"60 * * * *", ""},
+		{"* 24 * * *", ""},
+		{"* * 0 * *", ""},
+		{"* * 32 * *", ""},
+		{"* * * 0 *", ""},
+		{"* * * 13 *", ""},
+		{"* * * * 8", ""},
+		{"*/0 * * * *", ""},
+		{"*/x * * * *", ""},
+		{"5-1 * * * *", ""},
+		{"* * * foo *", ""},
+		{"* * * * mon-", ""},
+		{"@reboot", ""},
+		{"* * * * *", "Mars/Olympus_Mons"},
+	}
+	for _, tt := range tests {
+		if _, err := Parse(tt.spec, tt.timezone); !errors.Is(err, ErrInvalidSchedule) {
+			t.Errorf("Parse(%q, %q) error = %v, want ErrInvalidSchedule", tt.spec, tt.timezone, err)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name string
+		spec string
+		from time.Time
+		want time.Time
+	}{
+		{"every minute", "* * * * *", utc(2024, 1, 1, 10, 7), utc(2024, 1, 1, 10, 8)},
+		{"seconds truncated", "* * * * *", time.Date(2024, 1, 1, 10, 7, 59, 0, time.UTC), utc(2024, 1, 1, 10, 8)},
+		{"step", "*/15 * * * *", utc(2024, 1, 1, 10, 7), utc(2024, 1, 1, 10, 15)},
+		{"step from value", "5/20 * * * *", utc(2024, 1, 1, 10, 46), utc(2024, 1, 1, 11, 5)},
+		{"step over range", "0 8-18/5 * * *", utc(2024, 1, 1, 13, 0), utc(2024, 1, 1, 18, 0)},
+		{"list", "0 6,18 * * *", utc(2024, 1, 1, 7, 0), utc(2024, 1, 1, 18, 0)},
+		{"weekday names", "0 9 * * mon-fri", utc(2024, 1, 6, 12, 0), utc(2024, 1, 8, 9, 0)},
+		{"names any case", "0 0 1 JAN *", utc(2024, 3, 1, 0, 0), utc(2025, 1, 1, 0, 0)},
+		{"7 is sunday", "0 0 * * 7", utc(2024, 1, 1, 0, 0), utc(2024, 1, 7, 0, 0)},
+		{"range to 7", "0 0 * * 6-7", utc(2024, 1, 1, 0, 0), utc(2024, 1, 6, 0, 0)},
+		{"range to 7 includes sunday", "0 0 * * 6-7", utc(2024, 1, 6, 0, 0), utc(2024, 1, 7, 0, 0)},
+		{"dom or dow by dow", "0 0 13 * fri", utc(2024, 1, 1, 0, 0), utc(2024, 1, 5, 0, 0)},
+		{"dom or dow by dom", "0 0 13 * fri", utc(2024, 1, 12, 0, 0), utc(2024, 1, 13, 0, 0)},
+		{"dom with any dow", "0 0 13 * *", utc(2024, 1, 1, 0, 0), utc(2024, 1, 13, 0, 0)},
+		{"dow with any dom", "0 0 * * fri", utc(2024, 1, 6, 0, 0), utc(2024, 1, 12, 0, 0)},
+		// As in Vixie cron, a field starting with "*" counts as unrestricted
+		// for the OR, so both day fields must match.
+		{"stepped dom and dow", "0 0 */10 * mon", utc(2024, 1, 2, 0, 0), utc(2024, 3, 11, 0, 0)},
+		{"short month skipped", "0 0 31 * *", utc(2024, 4, 1, 0, 0), utc(2024, 5, 31, 0, 0)},
+		{"leap day", "0 0 29 2 *", utc(2024, 3, 1, 0, 0), utc(2028, 2, 29, 0, 0)},
+		{"year rollover", "0 0 * * *", utc(2024, 12, 31, 12, 0), utc(2025, 1, 1, 0, 0)},
+		{"hourly macro", "@hourly", utc(2024, 1, 1, 10, 30), utc(2024, 1, 1, 11, 0)},
+		{"weekly macro", "@weekly", utc(2024, 1, 1, 0, 0), utc(2024, 1, 7, 0, 0)},
+		{"never", "0 0 30 2 *", utc(2024, 1, 1, 0, 0), time.Time{}},
+		{"never 31st", "0 0 31 4,6,9,11 *", utc(2024, 1, 1, 0, 0), time.Time{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec, "")
+			if err != nil {
+				t.Fatalf("Parse(%q): %v", tt.spec, err)
+			}
+			if got := s.Next(tt.from); !got.Equal(tt.want) {
+				t.Errorf("Next(%v) = %v, want %v", tt.from, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNextDaylightSaving(t *testing.T) {
+	utc := func(year int, month time.Month, day, hour, min int) time.Time {
+		return time.Date(year, month, day, hour, min, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name     string
+		spec     string
+		timezone string
+		from     time.Time
+		want     []time.Time
+	}{
+		// 2024-03-10 02:00 EST becomes 03:00 EDT.
+		{"spring forward skips the gap", "30 2 * * *", "America/New_York",
+			utc(2024, 3, 9, 8, 0), []time.Time{utc(2024, 3, 11, 6, 30)}},
+		{"spring forward hourly", "0 * * * *", "America/New_York",
+			utc(2024, 3, 10, 6, 30), []time.Time{utc(2024, 3, 10, 7, 0)}},
+		{"spring forward after gap", "30 3 * * *", "America/New_York",
+			utc(2024, 3, 10, 5, 0), []time.Time{utc(2024, 3, 10, 7, 30)}},
+		// 2024-11-03 02:00 EDT becomes 01:00 EST, so 01:30 happens twice.
+		{"fall back repeats", "30 1 * * *", "America/New_York",
+			utc(2024, 11, 3, 4, 0), []time.Time{utc(2024, 11, 3, 5, 30), utc(2024, 11, 3, 6, 30), utc(2024, 11, 4, 6, 30)}},
+		{"fall back daily", "0 3 * * *", "America/New_York",
+			utc(2024, 11, 2, 12, 0), []time.Time{utc(2024, 11, 3, 8, 0), utc(2024, 11, 4, 8, 0)}},
+		// 2018-11-04 00:00 -03 became 01:00 -02 in Sao Paulo, so the day
+		// had no midnight.
+		{"midnight skipped", "0 0 * * *", "America/Sao_Paulo",
+			utc(2018, 11, 3, 15, 0), []time.Time{utc(2018, 11, 5, 2, 0)}},
+		{"day without midnight", "* * * * *", "America/Sao_Paulo",
+			utc(2018, 11, 4, 2, 59), []time.Time{utc(2018, 11, 4, 3, 0)}},
+		{"first hour after skipped midnight", "0 1 * * *", "America/Sao_Paulo",
+			utc(2018, 11, 3, 15, 0), []time.Time{utc(2018, 11, 4, 3, 0)}},
+		{"fixed zone", "0 9 * * *", "Asia/Shanghai",
+			utc(2024, 1, 1, 2, 0), []time.Time{utc(2024, 1, 2, 1, 0)}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := Parse(tt.spec, tt.timezone)
+			if err != nil {
+				t.Fatalf("Parse(%q, %q): %v", tt.spec, tt.timezone, err)
+			}
+			from := tt.from
+			for _, want := range tt.want {
+				got := s.Next(from)
+				if !got.Equal(want) {
+					t.Fatalf("Next(%v) = %v, want %v", from, got, want.In(s.Location()))
+				}
+				from = got
+			}
+		})
+	}
+}
@@ -14,8 +14,28 @@ const (
 	MsgTypeTaskAssign  = "task_assign"
 	MsgTypeTaskAck     = "task_ack"
 	MsgTypeTaskResult  = "task_result"
+	MsgTypeTaskCancel  = "task_cancel"
 	MsgTypeConfig      = "config"
 	MsgTypeError       = "error"
+
+	MsgTypeProcessesRequest = "processes_request"
+	MsgTypeProcesses        = "processes"
+
+	MsgTypeWatchConfig = "watch_config"
+
+	MsgTypeCustomMetrics = "custom_metrics"
+
+	MsgTypeCommandRun    = "command_run"
+	MsgTypeCommandCancel = "command_cancel"
+	MsgTypeCommandOutput = "command_output"
+	MsgTypeCommandExit   = "command_exit"
+
+	MsgTypeTerminalOpen   = "terminal_open"
+	MsgTypeTerminalInput  = "terminal_input"
+	MsgTypeTerminalResize = "terminal_resize"
+	MsgTypeTerminalClose  = "terminal_close"
+	MsgTypeTerminalOutput = "terminal_output"
+	MsgTypeTerminalExit   = "terminal_exit"
 )
 
 type Message struct {
@@ -53,10 +73,36 @@ type RegisterAckPayload struct {
 }
 
 type MetricsPayload struct {
-	CPU     float64      `json:"cpu"`
-	Memory  MemoryStats  `json:"memory"`
-	Disks   []DiskStats  `json:"disks"`
-	Network NetworkStats `json:"network"`
+	CPU      float64       `json:"cpu"`
+	CPUCores []float64     `json:"cpu_cores,omitempty"`
+	Memory   MemoryStats   `json:"memory"`
+	Swap     SwapStats     `json:"swap"`
+	Load     LoadStats     `json:"load"`
+	Disks    []DiskStats   `json:"disks"`
+	DiskIO   []DiskIOStats `json:"disk_io,omitempty"`
+	Network  NetworkStats  `json:"network"`
+	Host     HostStats     `json:"host"`
+	Sockets  SocketStats   `json:"sockets"`
+
+	Processes *ProcessesPayload `json:"processes,omitempty"`
+
+	// Containers is null when container collection is disabled and an empty
+	// list when it is enabled but nothing is running, so it has no omitempty.
+	Containers []ContainerStats `json:"containers"`
+
+	// Watches is null until the agent has received its watch list.
+	Watches []WatchStatus `json:"watches"`
+
+	// Collectors lists every collector that ran for this sample.
+	Collectors []CollectorStatus `json:"collectors,omitempty"`
+}
+
+// CollectorStatus reports how long one collector took and the error it
+// returned, if any. Fields it could not fill are left at zero.
+type CollectorStatus struct {
+	Name       string  `json:"name"`
+	DurationMs float64 `json:"duration_ms"`
+	Error      string  `json:"error,omitempty"`
 }
 
 type MemoryStats struct {
@@ -66,19 +112,233 @@ type MemoryStats struct {
 	Percent   float64 `json:"percent"`
 }
 
+type SwapStats struct {
+	Total   uint64  `json:"total"`
+	Used    uint64  `json:"used"`
+	Free    uint64  `json:"free"`
+	Percent float64 `json:"percent"`
+}
+
+type LoadStats struct {
+	Load1  float64 `json:"load1"`
+	Load5  float64 `json:"load5"`
+	Load15 float64 `json:"load15"`
+}
+
+// HostStats holds the uptime in seconds, the boot time as a Unix timestamp
+// and the number of processes and threads on the host.
+type HostStats struct {
+	Uptime    uint64 `json:"uptime"`
+	BootTime  uint64 `json:"boot_time"`
+	Processes uint64 `json:"processes"`
+	Threads   uint64 `json:"threads"`
+}
+
+// SocketStats counts the sockets on the host. TCP holds the number of
+// connections in each state, keyed by name such as "ESTABLISHED" or
+// "TIME_WAIT"; listening sockets are counted in Listen instead.
+type SocketStats struct {
+	TCP      map[string]uint64 `json:"tcp,omitempty"`
+	TCPTotal uint64            `json:"tcp_total"`
+	UDP      uint64            `json:"udp"`
+	Listen   uint64            `json:"listen"`
+}
+
 type DiskStats struct {
 	Path      string  `json:"path"`
+	Device    string  `json:"device,omitempty"`
+	FSType    string  `json:"fstype,omitempty"`
 	Total     uint64  `json:"total"`
 	Used      uint64  `json:"used"`
 	Available uint64  `json:"available"`
 	Percent   float64 `json:"percent"`
 }
 
+// DiskIOStats holds per-second rates for one block device since the previous
+// sample. Util is the percentage of time the device was busy and AwaitMs the
+// average time an I/O took to complete.
+type DiskIOStats struct {
+	Device         string  `json:"device"`
+	ReadBytesRate  uint64  `json:"read_bytes_rate"`
+	WriteBytesRate uint64  `json:"write_bytes_rate"`
+	ReadIOPS       float64 `json:"read_iops"`
+	WriteIOPS      float64 `json:"write_iops"`
+	Util           float64 `json:"util"`
+	AwaitMs        float64 `json:"await_ms"`
+}
+
+// NetworkStats totals only the interfaces marked Counted; Interfaces lists
+// every interface the agent saw.
 type NetworkStats struct {
+	BytesSent     uint64           `json:"bytes_sent"`
+	BytesRecv     uint64           `json:"bytes_recv"`
+	BytesSentRate uint64           `json:"bytes_sent_rate"`
+	BytesRecvRate uint64           `json:"bytes_recv_rate"`
+	Interfaces    []InterfaceStats `json:"interfaces,omitempty"`
+}
+
+type InterfaceStats struct {
+	Name          string `json:"name"`
 	BytesSent     uint64 `json:"bytes_sent"`
 	BytesRecv     uint64 `json:"bytes_recv"`
 	BytesSentRate uint64 `json:"bytes_sent_rate"`
 	BytesRecvRate uint64 `json:"bytes_recv_rate"`
+	Counted       bool   `json:"counted"`
+}
+
+// ContainerStats describes one container. Runtime is the engine that owns it
+// (docker, podman, containerd, crio) or "cgroup" when only the cgroup was
+// found. CPU is a percentage of one core. MemoryLimit is 0 for an unlimited
+// container, in which case MemoryPercent is relative to host memory. Network
+// counters are 0 for containers sharing the host network namespace.
+type ContainerStats struct {
+	ID            string  `json:"id"`
+	Name          string  `json:"name"`
+	Image         string  `json:"image,omitempty"`
+	Runtime       string  `json:"runtime"`
+	State         string  `json:"state"`
+	RestartCount  int     `json:"restart_count"`
+	StartedAt     int64   `json:"started_at,omitempty"`
+	CPU           float64 `json:"cpu"`
+	MemoryUsage   uint64  `json:"memory_usage"`
+	MemoryLimit   uint64  `json:"memory_limit"`
+	MemoryPercent float64 `json:"memory_percent"`
+	NetRx         uint64  `json:"net_rx"`
+	NetTx         uint64  `json:"net_tx"`
+	NetRxRate     uint64  `json:"net_rx_rate"`
+	NetTxRate     uint64  `json:"net_tx_rate"`
+}
+
+// ProcessInfo describes one process. CPU is a percentage of one core, as in
+// top, so it can exceed 100 on multi-core hosts.
+type ProcessInfo struct {
+	PID     int32   `json:"pid"`
+	Name    string  `json:"name"`
+	User    string  `json:"user"`
+	CPU     float64 `json:"cpu"`
+	RSS     uint64  `json:"rss"`
+	Cmdline string  `json:"cmdline"`
+}
+
+type ProcessesPayload struct {
+	TopCPU    []ProcessInfo `json:"top_cpu"`
+	TopMemory []ProcessInfo `json:"top_memory"`
+}
+
+type ProcessesRequestPayload struct {
+	Limit int `json:"limit,omitempty"`
+}
+
+// WatchSpec is a process or port the agent checks on every metrics cycle.
+// Type is "process", with Target a glob matched against the process name and
+// then the command line, or "port", with Target a TCP port number.
+type WatchSpec struct {
+	ID     string `json:"id"`
+	Type   string `json:"type"`
+	Target string `json:"target"`
+}
+
+// WatchConfigPayload replaces the agent's whole watch list.
+type WatchConfigPayload struct {
+	Watches []WatchSpec `json:"watches"`
+}
+
+// WatchStatus reports whether a watch is up. Count is the number of matching
+// processes or listening sockets.
+type WatchStatus struct {
+	ID    string `json:"id"`
+	Up    bool   `json:"up"`
+	Count int    `json:"count"`
+}
+
+// CustomMetric is one value reported by a plugin. Labels distinguish series
+// of the same name, as in Prometheus.
+type CustomMetric struct {
+	Name   string            `json:"name"`
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// CustomMetricsPayload carries the output of one plugin run. Error is set
+// when the plugin failed; Metrics then holds whatever could be parsed.
+type CustomMetricsPayload struct {
+	Plugin    string         `json:"plugin"`
+	Metrics   []CustomMetric `json:"metrics"`
+	Timestamp int64          `json:"timestamp"`
+	Error     string         `json:"error,omitempty"`
+}
+
+// CommandRunPayload runs a shell command, or the script ScriptID after
+// checking it against Checksum, once. Params are passed as environment
+// variables. Timeout is in seconds.
+type CommandRunPayload struct {
+	RunID    string            `json:"run_id"`
+	Command  string            `json:"command,omitempty"`
+	ScriptID string            `json:"script_id,omitempty"`
+	Checksum string            `json:"checksum,omitempty"`
+	Params   map[string]string `json:"params,omitempty"`
+	Timeout  int               `json:"timeout"`
+}
+
+type CommandCancelPayload struct {
+	RunID string `json:"run_id"`
+}
+
+// CommandOutputPayload carries output lines in the order they were written.
+// The first one is sent, possibly without lines, when the command starts.
+type CommandOutputPayload struct {
+	RunID string        `json:"run_id"`
+	Lines []CommandLine `json:"lines,omitempty"`
+}
+
+// CommandLine is a line of output without its line ending. Stream is
+// "stdout" or "stderr".
+type CommandLine struct {
+	Stream string `json:"stream"`
+	Text   string `json:"text"`
+}
+
+// CommandExitPayload ends a run. ExitCode is -1 and Error says why when the
+// command could not run or was stopped. Duration is in milliseconds.
+type CommandExitPayload struct {
+	RunID    string `json:"run_id"`
+	ExitCode int    `json:"exit_code"`
+	Error    string `json:"error,omitempty"`
+	Duration int64  `json:"duration"`
+}
+
+// TerminalOpenPayload starts a shell on a new pseudo-terminal. The agent
+// closes it after IdleTimeout seconds without input; 0 disables that.
+type TerminalOpenPayload struct {
+	SessionID   string `json:"session_id"`
+	Cols        int    `json:"cols"`
+	Rows        int    `json:"rows"`
+	IdleTimeout int    `json:"idle_timeout"`
+}
+
+// TerminalDataPayload carries input for a terminal or its output. The first
+// output is sent, possibly empty, when the shell starts.
+type TerminalDataPayload struct {
+	SessionID string `json:"session_id"`
+	Data      []byte `json:"data,omitempty"`
+}
+
+type TerminalResizePayload struct {
+	SessionID string `json:"session_id"`
+	Cols      int    `json:"cols"`
+	Rows      int    `json:"rows"`
+}
+
+type TerminalClosePayload struct {
+	SessionID string `json:"session_id"`
+}
+
+// TerminalExitPayload ends a session. ExitCode is -1 and Error says why when
+// the shell could not start or was stopped.
+type TerminalExitPayload struct {
+	SessionID string `json:"session_id"`
+	ExitCode  int    `json:"exit_code"`
+	Error     string `json:"error,omitempty"`
 }
 
 type TaskAssignPayload struct {
@@ -89,8 +349,21 @@ type TaskAssignPayload struct {
 	Params   map[string]string `json:"params,omitempty"`
 	Interval int               `json:"interval,omitempty"`
 	Timeout  int               `json:"timeout,omitempty"`
+	// A cron expression in Timezone, used instead of Interval when set.
+	// Each run is delayed by a random 0 to Jitter seconds.
+	Schedule string `json:"schedule,omitempty"`
+	Timezone string `json:"timezone,omitempty"`
+	Jitter   int    `json:"jitter,omitempty"`
 }
 
+// TaskCancelPayload stops a task on the agent. Tasks the agent does not run
+// are ignored.
+type TaskCancelPayload struct {
+	TaskID string `json:"task_id"`
+}
+
+// TaskAckPayload answers every task assignment. Success is false when the
+// agent could not start the task, with the reason in Error.
 type TaskAckPayload struct {
 	TaskID  string `json:"task_id"`
 	Success bool   `json:"success"`
@@ -98,11 +371,111 @@ type TaskAckPayload struct {
 }
 
 type TaskResultPayload struct {
-	TaskID   string `json:"task_id"`
-	Success  bool   `json:"success"`
-	Output   string `json:"output"`
-	Error    string `json:"error,omitempty"`
-	Duration int64  `json:"duration"`
+	TaskID     string            `json:"task_id"`
+	Success    bool              `json:"success"`
+	Output     string            `json:"output"`
+	Error      string            `json:"error,omitempty"`
+	Duration   int64             `json:"duration"`
+	Ping       *PingResult       `json:"ping,omitempty"`
+	HTTP       *HTTPResult       `json:"http,omitempty"`
+	TLS        *TLSResult        `json:"tls,omitempty"`
+	DNS        *DNSResult        `json:"dns,omitempty"`
+	Traceroute *TracerouteResult `json:"traceroute,omitempty"`
+}
+
+// TracerouteResult describes the path to a target over several rounds of
+// probes. Hops are in TTL order; a hop no probe was answered at has no
+// address.
+type TracerouteResult struct {
+	Target   string          `json:"target"`
+	Address  string          `json:"address"`
+	Protocol string          `json:"protocol"`
+	Rounds   int             `json:"rounds"`
+	Reached  bool            `json:"reached"`
+	Hops     []TracerouteHop `json:"hops"`
+}
+
+// TracerouteHop summarizes the replies at one TTL. Address answered most
+// often; Addresses lists every address that answered when there was more
+// than one. Latencies are in milliseconds.
+type TracerouteHop struct {
+	TTL       int      `json:"ttl"`
+	Address   string   `json:"address,omitempty"`
+	Addresses []string `json:"addresses,omitempty"`
+	Sent      int      `json:"sent"`
+	Received  int      `json:"received"`
+	Loss      float64  `json:"loss"`
+	Last      float64  `json:"last"`
+	Min       float64  `json:"min"`
+	Avg       float64  `json:"avg"`
+	Max       float64  `json:"max"`
+	StdDev    float64  `json:"stddev"`
+}
+
+// DNSResult describes a dns check. Resolver is empty when the system
+// resolver was used. MX answers are "preference host" and names are given
+// without the trailing dot.
+type DNSResult struct {
+	Name       string   `json:"name"`
+	RecordType string   `json:"record_type"`
+	Resolver   string   `json:"resolver,omitempty"`
+	Answers    []string `json:"answers"`
+	ResolveMs  float64  `json:"resolve_ms"`
+}
+
+// TLSResult describes the certificate chain a server presented, leaf first.
+// NotAfter and DaysRemaining are those of the leaf.
+type TLSResult struct {
+	Target        string           `json:"target"`
+	ServerName    string           `json:"server_name,omitempty"`
+	Version       string           `json:"version"`
+	Verified      bool             `json:"verified"`
+	VerifyError   string           `json:"verify_error,omitempty"`
+	NotAfter      time.Time        `json:"not_after"`
+	DaysRemaining float64          `json:"days_remaining"`
+	Chain         []TLSCertificate `json:"chain"`
+}
+
+type TLSCertificate struct {
+	Subject   string    `json:"subject"`
+	Issuer    string    `json:"issuer"`
+	SANs      []string  `json:"sans,omitempty"`
+	NotBefore time.Time `json:"not_before"`
+	NotAfter  time.Time `json:"not_after"`
+}
+
+// HTTPResult describes an http check. The phase timings, in milliseconds,
+// are those of the final request; TotalMs also covers any redirects before
+// it. Phases that did not happen, such as TLS for plain HTTP, are 0.
+type HTTPResult struct {
+	URL        string  `json:"url"`
+	Method     string  `json:"method"`
+	FinalURL   string  `json:"final_url,omitempty"`
+	StatusCode int     `json:"status_code"`
+	Redirects  int     `json:"redirects"`
+	BodyBytes  int     `json:"body_bytes"`
+	DNSMs      float64 `json:"dns_ms"`
+	ConnectMs  float64 `json:"connect_ms"`
+	TLSMs      float64 `json:"tls_ms"`
+	TTFBMs     float64 `json:"ttfb_ms"`
+	TotalMs    float64 `json:"total_ms"`
+}
+
+// PingResult summarises one ping run. Latencies are in milliseconds and only
+// cover the probes that were answered. Jitter is the mean difference between
+// consecutive round trips.
+type PingResult struct {
+	Target     string  `json:"target"`
+	Address    string  `json:"address,omitempty"`
+	Mode       string  `json:"mode"`
+	Sent       int     `json:"sent"`
+	Received   int     `json:"received"`
+	PacketLoss float64 `json:"packet_loss"`
+	Min        float64 `json:"min"`
+	Max        float64 `json:"max"`
+	Avg        float64 `json:"avg"`
+	StdDev     float64 `json:"stddev"`
+	Jitter     float64 `json:"jitter"`
 }
 
 type ConfigPayload struct {
@@ -28,11 +28,36 @@ const router = createRouter({
           name: 'admin-agent-detail',
           component: () => import('../views/admin/AgentDetail.vue')
         },
+        {
+          path: 'agents/:id/terminal',
+          name: 'admin-agent-terminal',
+          component: () => import('../views/admin/Terminal.vue')
+        },
         {
           path: 'tasks',
           name: 'admin-tasks',
           component: () => import('../views/admin/Tasks.vue')
         },
+        {
+          path: 'tasks/:id/chart',
+          name: 'admin-task-chart',
+          component: () => import('../views/admin/TaskChart.vue')
+        },
+        {
+          path: 'availability',
+          name: 'admin-availability',
+          component: () => import('../views/admin/Availability.vue')
+        },
+        {
+          path: 'certificates',
+          name: 'admin-certificates',
+          component: () => import('../views/admin/Certificates.vue')
+        },
+        {
+          path: 'commands',
+          name: 'admin-commands',
+          component: () => import('../views/admin/Commands.vue')
+        },
         {
           path: 'alerts',
           name: 'admin-alerts',